@@ -0,0 +1,82 @@
+package bookmarks
+
+import (
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+// ErrPostNotAccessible is returned when a user bookmarks a post in a channel
+// they cannot read
+var ErrPostNotAccessible = errors.New("You do not have access to the channel of the post")
+
+// CanReadChannel returns true if a user is a member of a channel, or may read
+// it without being a member. Users may read the public channels of their
+// teams, and system admins may read any channel
+func CanReadChannel(api pluginapi.API, userID, channelID string) (bool, error) {
+	_, err := api.GetChannelMember(channelID, userID)
+	if err == nil {
+		return true, nil
+	}
+	if !pluginapi.IsNotFound(err) {
+		return false, errors.Wrapf(err, "Unable to get the membership of user %s in channel %s", userID, channelID)
+	}
+
+	channel, err := api.GetChannel(channelID)
+	if pluginapi.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "Unable to get channel %s", channelID)
+	}
+
+	if channel.Type == model.CHANNEL_OPEN {
+		return api.HasPermissionToChannel(userID, channelID, model.PERMISSION_READ_PUBLIC_CHANNEL), nil
+	}
+	return api.HasPermissionToChannel(userID, channelID, model.PERMISSION_READ_CHANNEL), nil
+}
+
+// canReadChannel returns true if the user of the bookmarks may read a
+// channel. Access is checked once per channel
+func (b *Bookmarks) canReadChannel(channelID string) (bool, error) {
+	if readable, ok := b.channelAccess[channelID]; ok {
+		return readable, nil
+	}
+
+	readable, err := CanReadChannel(b.api, b.userID, channelID)
+	if err != nil {
+		return false, err
+	}
+	if b.channelAccess == nil {
+		b.channelAccess = make(map[string]bool)
+	}
+	b.channelAccess[channelID] = readable
+	return readable, nil
+}
+
+// restrictPost returns a hidden post in place of a post in a channel the user
+// of the bookmarks cannot read. The saved content of a deleted post is hidden
+// the same way
+func (b *Bookmarks) restrictPost(postID string, post *model.Post) (*model.Post, error) {
+	channelID := post.ChannelId
+	if isPostDeleted(post) {
+		bmark, ok := b.exists(postID)
+		if !ok || !bmark.HasSnapshot() {
+			return post, nil
+		}
+		channelID = bmark.Snapshot.ChannelID
+		// the channel of the saved content is unknown
+		if channelID == "" {
+			return hiddenPost(postID), nil
+		}
+	}
+
+	readable, err := b.canReadChannel(channelID)
+	if err != nil {
+		return nil, err
+	}
+	if !readable {
+		return hiddenPost(postID), nil
+	}
+	return post, nil
+}
@@ -0,0 +1,143 @@
+package bookmarks
+
+import (
+	"net/http"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockChannelMember makes the user a member of every channel
+func mockChannelMember(api *mock_pluginapi.MockAPI) {
+	api.EXPECT().GetChannelMember(gomock.Any(), gomock.Any()).Return(&model.ChannelMember{}, nil).AnyTimes()
+}
+
+func TestCanReadChannel(t *testing.T) {
+	notFound := &model.AppError{StatusCode: http.StatusNotFound}
+
+	tests := map[string]struct {
+		setup    func(api *mock_pluginapi.MockAPI)
+		expected bool
+		wantErr  bool
+	}{
+		"member": {
+			setup: func(api *mock_pluginapi.MockAPI) {
+				api.EXPECT().GetChannelMember("channelID", UserID).Return(&model.ChannelMember{}, nil)
+			},
+			expected: true,
+		},
+		"public channel of the team": {
+			setup: func(api *mock_pluginapi.MockAPI) {
+				api.EXPECT().GetChannelMember("channelID", UserID).Return(nil, notFound)
+				api.EXPECT().GetChannel("channelID").Return(&model.Channel{Type: model.CHANNEL_OPEN}, nil)
+				api.EXPECT().HasPermissionToChannel(UserID, "channelID", model.PERMISSION_READ_PUBLIC_CHANNEL).Return(true)
+			},
+			expected: true,
+		},
+		"left private channel": {
+			setup: func(api *mock_pluginapi.MockAPI) {
+				api.EXPECT().GetChannelMember("channelID", UserID).Return(nil, notFound)
+				api.EXPECT().GetChannel("channelID").Return(&model.Channel{Type: model.CHANNEL_PRIVATE}, nil)
+				api.EXPECT().HasPermissionToChannel(UserID, "channelID", model.PERMISSION_READ_CHANNEL).Return(false)
+			},
+			expected: false,
+		},
+		"deleted channel": {
+			setup: func(api *mock_pluginapi.MockAPI) {
+				api.EXPECT().GetChannelMember("channelID", UserID).Return(nil, notFound)
+				api.EXPECT().GetChannel("channelID").Return(nil, notFound)
+			},
+			expected: false,
+		},
+		"membership cannot be checked": {
+			setup: func(api *mock_pluginapi.MockAPI) {
+				api.EXPECT().GetChannelMember("channelID", UserID).Return(nil, &model.AppError{StatusCode: http.StatusInternalServerError})
+			},
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+			tt.setup(mockPluginAPI)
+
+			readable, err := CanReadChannel(mockPluginAPI, UserID, "channelID")
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, readable)
+		})
+	}
+}
+
+func TestGetBmarksEphemeralText_hiddenPosts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+
+	bmarks := NewBookmarks(UserID)
+	bmarks.api = mockPluginAPI
+	bmarks.ByID["ID1"] = &Bookmark{PostID: "ID1"}
+	bmarks.ByID["ID2"] = &Bookmark{PostID: "ID2", Title: "Title2"}
+	bmarks.ByID["ID3"] = &Bookmark{PostID: "ID3"}
+	bmarks.ByID["ID4"] = &Bookmark{PostID: "ID4", Snapshot: &PostSnapshot{Message: "secret4", ChannelID: "private"}}
+
+	mockPluginAPI.EXPECT().GetPostsByIds(gomock.Any()).Return(map[string]*model.Post{
+		"ID1": {Id: "ID1", Message: "message1", ChannelId: "public", CreateAt: 1},
+		"ID2": {Id: "ID2", Message: "secret2", ChannelId: "private", CreateAt: 2},
+		"ID3": {Id: "ID3", Message: "secret3", ChannelId: "private", CreateAt: 3},
+	}, nil).AnyTimes()
+	mockPluginAPI.EXPECT().GetConfig().Return(&model.Config{
+		ServiceSettings: model.ServiceSettings{SiteURL: model.NewString("https://myhost.com")},
+	}).AnyTimes()
+	mockPluginAPI.EXPECT().KVGet(GetLabelsKey(UserID)).Return(nil, nil).AnyTimes()
+
+	// access to each channel is checked once
+	mockPluginAPI.EXPECT().GetChannelMember("public", UserID).Return(&model.ChannelMember{}, nil)
+	mockPluginAPI.EXPECT().GetChannelMember("private", UserID).Return(nil, &model.AppError{StatusCode: http.StatusNotFound})
+	mockPluginAPI.EXPECT().GetChannel("private").Return(&model.Channel{Type: model.CHANNEL_PRIVATE}, nil)
+	mockPluginAPI.EXPECT().HasPermissionToChannel(UserID, "private", model.PERMISSION_READ_CHANNEL).Return(false)
+
+	text, err := bmarks.GetBmarksEphemeralText(UserID, nil, nil)
+	assert.Nil(t, err)
+	assert.Contains(t, text, "message1")
+	assert.Contains(t, text, "[:link:](https://myhost.com/_redirect/pl/ID2) **`HIDDEN`** **_Title2_**\n")
+	assert.Contains(t, text, "[:link:](https://myhost.com/_redirect/pl/ID3) **`HIDDEN`** Post in a channel you cannot read\n")
+	assert.Contains(t, text, "[:link:](https://myhost.com/_redirect/pl/ID4) **`HIDDEN`** Post in a channel you cannot read\n")
+	for _, secret := range []string{"secret2", "secret3", "secret4"} {
+		assert.NotContains(t, text, secret)
+	}
+
+	// the bookmarks are kept
+	assert.Len(t, bmarks.ByID, 4)
+
+	text, err = bmarks.GetBmarkTextDetailed(bmarks.ByID["ID3"], nil, nil)
+	assert.Nil(t, err)
+	assert.Contains(t, text, "_You no longer have access to the channel of this post_")
+	assert.NotContains(t, text, "secret3")
+}
+
+func TestAddBookmark_noAccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+
+	mockPluginAPI.EXPECT().GetPost("ID1").Return(&model.Post{Id: "ID1", ChannelId: "private"}, nil)
+	mockPluginAPI.EXPECT().GetChannelMember("private", UserID).Return(nil, &model.AppError{StatusCode: http.StatusNotFound})
+	mockPluginAPI.EXPECT().GetChannel("private").Return(&model.Channel{Type: model.CHANNEL_PRIVATE}, nil)
+	mockPluginAPI.EXPECT().HasPermissionToChannel(UserID, "private", model.PERMISSION_READ_CHANNEL).Return(false)
+
+	bmarks := NewBookmarks(UserID)
+	bmarks.api = mockPluginAPI
+	err := bmarks.AddBookmark(&Bookmark{PostID: "ID1"})
+	assert.Equal(t, ErrPostNotAccessible, errors.Cause(err))
+	assert.Empty(t, bmarks.ByID)
+}
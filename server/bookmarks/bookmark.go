@@ -1,12 +1,25 @@
 package bookmarks
 
+// Statuses of a bookmark. Bookmarks saved before statuses were recorded have
+// no status and are read
+const (
+	StatusUnread   = "unread"
+	StatusRead     = "read"
+	StatusArchived = "archived"
+)
+
 // Bookmark contains information about an individual bookmark
 type Bookmark struct {
-	PostID     string   `json:"postid"`              // PostID is the ID for the bookmarked post and doubles as the Bookmark ID
-	Title      string   `json:"title,omitempty"`     // Title given to the bookmark
-	CreateAt   int64    `json:"create_at"`           // The original creation time of the bookmark
-	ModifiedAt int64    `json:"update_at"`           // The original creation time of the bookmark
-	LabelIDs   []string `json:"label_ids,omitempty"` // Array of labels added to the bookmark
+	PostID       string        `json:"postid"`                 // PostID is the ID for the bookmarked post and doubles as the Bookmark ID
+	Title        string        `json:"title,omitempty"`        // Title given to the bookmark
+	CreateAt     int64         `json:"create_at"`              // The original creation time of the bookmark
+	ModifiedAt   int64         `json:"update_at"`              // The original creation time of the bookmark
+	LabelIDs     []string      `json:"label_ids,omitempty"`    // Array of labels added to the bookmark
+	PostEditedAt int64         `json:"post_edit_at,omitempty"` // The last time the bookmarked post was edited after it was bookmarked
+	Snapshot     *PostSnapshot `json:"snapshot,omitempty"`     // Content of the post when it was bookmarked
+	Note         string        `json:"note,omitempty"`         // Markdown note written by the user
+	RemindAt     int64         `json:"remind_at,omitempty"`    // The time the user is reminded of the bookmark
+	Status       string        `json:"status,omitempty"`       // Whether the bookmark is unread, read or archived
 }
 
 func (bm *Bookmark) HasUserTitle() bool {
@@ -25,6 +38,37 @@ func (bm *Bookmark) SetTitle(title string) {
 	bm.Title = title
 }
 
+// HasNote returns true if the user wrote a note for the bookmark
+func (bm *Bookmark) HasNote() bool {
+	return bm.GetNote() != ""
+}
+
+func (bm *Bookmark) GetNote() string {
+	return bm.Note
+}
+
+func (bm *Bookmark) SetNote(note string) {
+	bm.Note = note
+}
+
+// HasReminder returns true if the user is reminded of the bookmark
+func (bm *Bookmark) HasReminder() bool {
+	return bm.RemindAt != 0
+}
+
+// GetStatus returns the status of the bookmark, read if it has none
+func (bm *Bookmark) GetStatus() string {
+	if bm.Status == "" {
+		return StatusRead
+	}
+	return bm.Status
+}
+
+// IsArchived returns true if the user archived the bookmark
+func (bm *Bookmark) IsArchived() bool {
+	return bm.Status == StatusArchived
+}
+
 func (bm *Bookmark) GetLabelIDs() []string {
 	return bm.LabelIDs
 }
@@ -32,3 +76,15 @@ func (bm *Bookmark) GetLabelIDs() []string {
 func (bm *Bookmark) AddLabelIDs(ids []string) {
 	bm.LabelIDs = ids
 }
+
+// IsPostEdited returns true if the bookmarked post was edited after it was
+// bookmarked
+func (bm *Bookmark) IsPostEdited() bool {
+	return bm.PostEditedAt != 0
+}
+
+// HasSnapshot returns true if the content of the post was saved when it was
+// bookmarked
+func (bm *Bookmark) HasSnapshot() bool {
+	return bm.Snapshot != nil
+}
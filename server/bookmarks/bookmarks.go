@@ -27,28 +27,49 @@ type Bookmarks struct {
 	ByID   map[string]*Bookmark
 	api    pluginapi.API
 	userID string
+
+	// storedIndex and storedByID hold the values last loaded from or stored
+	// to the KV store. They are compared with the current values to find the
+	// changes to store and detect writes by other requests
+	storedIndex []byte
+	storedByID  map[string][]byte
+
+	// posts caches the bookmarked posts fetched while sorting, filtering and
+	// rendering, so each post is fetched once per listing
+	posts map[string]*model.Post
+
+	// channelAccess caches whether the user may read a channel
+	channelAccess map[string]bool
+
+	// labels caches the labels of the user while rendering
+	labels *Labels
 }
 
 func NewBookmarks(userID string) *Bookmarks {
 	return &Bookmarks{
-		ByID:   make(map[string]*Bookmark),
-		userID: userID,
+		ByID:       make(map[string]*Bookmark),
+		userID:     userID,
+		storedByID: make(map[string][]byte),
 	}
 }
 
-// NewBookmarksWithUser returns an initialized Bookmarks for a User
+// NewBookmarksWithUser returns an initialized Bookmarks for a User. Users whose
+// bookmarks are still stored in a single value are migrated to per-bookmark
+// keys
 func NewBookmarksWithUser(api pluginapi.API, userID string) (*Bookmarks, error) {
-	bb, appErr := api.KVGet(GetBookmarksKey(userID))
-	if appErr != nil {
-		return nil, errors.Wrapf(appErr, "Unable to get bookmarks for user %s", userID)
-	}
+	userBmarks := NewBookmarks(userID)
+	userBmarks.api = api
 
-	userBmarks, err := FromJSON(bb)
+	hasIndex, err := userBmarks.loadBookmarks()
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrapf(err, "Unable to get bookmarks for user %s", userID)
+	}
+
+	if !hasIndex {
+		if err = userBmarks.migrateUserBookmarks(); err != nil {
+			return nil, errors.Wrapf(err, "Unable to migrate bookmarks for user %s", userID)
+		}
 	}
-	userBmarks.api = api
-	userBmarks.userID = userID
 
 	return userBmarks, nil
 }
@@ -82,50 +103,139 @@ func (b *Bookmarks) updateTimes(bmarkID string) *Bookmark {
 	return bmark
 }
 
-// addBookmark stores the bookmark in a map,
+// addBookmark stores the bookmark in a map, with a snapshot of the content of
+// the post when it is first bookmarked
 func (b *Bookmarks) AddBookmark(bmark *Bookmark) error {
+	// only posts the user can read are bookmarked. Existing bookmarks may
+	// still be updated after the user lost access
+	if _, ok := b.exists(bmark.PostID); !ok {
+		post, err := b.getPost(bmark.PostID)
+		if err != nil {
+			return errors.Wrap(err, "failed to add bookmark")
+		}
+		if isPostHidden(post) {
+			return errors.Wrap(ErrPostNotAccessible, "failed to add bookmark")
+		}
+	}
+
+	if err := b.snapshotPost(bmark); err != nil {
+		return errors.Wrap(err, "failed to add bookmark")
+	}
+
+	var added bool
+	err := b.StoreBookmarks(func(bmarks *Bookmarks) error {
+		_, exists := bmarks.exists(bmark.PostID)
+		added = !exists
+		bmarks.addBookmark(bmark)
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to add bookmark")
+	}
+
+	// a post unflagged since the bookmark was added stays unflagged when the
+	// bookmark is updated
+	if !added {
+		return nil
+	}
+	if err = b.syncFlags([]string{bmark.PostID}, true); err != nil {
+		return errors.Wrap(err, "failed to flag the post of the bookmark")
+	}
+	return nil
+}
+
+// addBookmark adds or updates a bookmark in the map without storing it
+func (b *Bookmarks) addBookmark(bmark *Bookmark) {
 	// bookmark already exists, update ModifiedAt and save
-	_, ok := b.exists(bmark.PostID)
+	bmarkOrig, ok := b.exists(bmark.PostID)
 	if ok {
 		b.updateTimes(bmark.PostID)
 		b.updateLabels(bmark)
+		bmark.CreateAt = bmarkOrig.CreateAt
+		bmark.ModifiedAt = bmarkOrig.ModifiedAt
+
+		// keep what is known about the post
+		bmark.PostEditedAt = bmarkOrig.PostEditedAt
+		if !bmark.HasSnapshot() {
+			bmark.Snapshot = bmarkOrig.Snapshot
+		}
+
+		// reminders are only set with SetReminder and statuses with SetStatus
+		bmark.RemindAt = bmarkOrig.RemindAt
+		bmark.Status = bmarkOrig.Status
+	}
+
+	// new bookmarks are unread until they are viewed
+	if !ok && bmark.Status == "" {
+		bmark.Status = StatusUnread
+	}
+
+	// new bookmark, record when it was created
+	if bmark.CreateAt == 0 {
+		bmark.CreateAt = model.GetMillis()
+		bmark.ModifiedAt = bmark.CreateAt
 	}
 
 	// Add or update the bookmark
 	b.ByID[bmark.PostID] = bmark
-	if err := b.StoreBookmarks(); err != nil {
-		return errors.Wrap(err, "failed to add bookmark")
-	}
-	return nil
 }
 
-// ByPostCreateAt returns an array of bookmarks sorted by post.CreateAt times
+// ByPostCreateAt returns an array of bookmarks sorted by post.CreateAt times.
+// Bookmarks of posts created at the same time are ordered by the time they
+// were bookmarked, then by PostID
 func (b *Bookmarks) ByPostCreateAt() ([]*Bookmark, error) {
-	// build temp map
-	tempMap := make(map[int64]string)
-	for _, bmark := range b.ByID {
-		post, appErr := b.api.GetPost(bmark.PostID)
-		if appErr != nil {
-			return nil, appErr
-		}
-		tempMap[post.CreateAt] = bmark.PostID
+	places, err := b.getPostPlaces()
+	if err != nil {
+		return nil, err
 	}
 
-	// sort post.CreateAt (keys)
-	keys := make([]int, 0, len(tempMap))
-	for k := range tempMap {
-		keys = append(keys, int(k))
-	}
-	sort.Ints(keys)
+	return b.sortBy(func(bi, bj *Bookmark) int {
+		if c := compareInt64(places[bi.PostID].createAt, places[bj.PostID].createAt); c != 0 {
+			return c
+		}
+		return compareInt64(bi.CreateAt, bj.CreateAt)
+	}), nil
+}
 
-	// reconstruct the bookmarks in a sorted array
-	var bookmarks []*Bookmark
-	for _, k := range keys {
-		bmark := b.ByID[tempMap[int64(k)]]
-		bookmarks = append(bookmarks, bmark)
+// SetNote sets the note of a bookmark. An empty note removes it
+func (b *Bookmarks) SetNote(bmarkID string, note string) error {
+	return b.StoreBookmarks(func(bmarks *Bookmarks) error {
+		bmark, err := bmarks.GetBookmark(bmarkID)
+		if err != nil {
+			return err
+		}
+
+		bmark.SetNote(note)
+		bmarks.updateTimes(bmarkID)
+		return nil
+	})
+}
+
+// SetStatus sets the status of a bookmark. It returns false without storing
+// the bookmark if it already has the status
+func (b *Bookmarks) SetStatus(bmarkID string, status string) (bool, error) {
+	switch status {
+	case StatusUnread, StatusRead, StatusArchived:
+	default:
+		return false, errors.New(fmt.Sprintf("`%s` is not a bookmark status", status))
 	}
 
-	return bookmarks, nil
+	changed := false
+	err := b.StoreBookmarks(func(bmarks *Bookmarks) error {
+		bmark, err := bmarks.GetBookmark(bmarkID)
+		if err != nil {
+			return err
+		}
+
+		changed = bmark.GetStatus() != status
+		if !changed {
+			return nil
+		}
+		bmark.Status = status
+		bmarks.updateTimes(bmarkID)
+		return nil
+	})
+	return changed, err
 }
 
 // func (b *Bookmarks) GetBookmarksWithLabelID(labelID string) (IBookmarks, error) {
@@ -138,9 +248,7 @@ func (b *Bookmarks) GetBookmarksWithLabelID(id string) (*Bookmarks, error) {
 		if bmark.hasLabels() {
 			for _, lid := range bmark.GetLabelIDs() {
 				if id == lid {
-					if err := bmarks.AddBookmark(bmark); err != nil {
-						return nil, err
-					}
+					bmarks.ByID[bmark.PostID] = bmark
 				}
 			}
 		}
@@ -151,26 +259,25 @@ func (b *Bookmarks) GetBookmarksWithLabelID(id string) (*Bookmarks, error) {
 
 // DeleteLabel deletes a label from a bookmark
 func (b *Bookmarks) DeleteLabel(bmarkID string, labelID string) error {
-	bmark, err := b.GetBookmark(bmarkID)
-	if err != nil {
-		return err
-	}
-
-	var newLabels []string
-	origLabels := bmark.GetLabelIDs()
-	for _, ID := range origLabels {
-		if labelID == ID {
-			continue
+	return b.StoreBookmarks(func(bmarks *Bookmarks) error {
+		bmark, err := bmarks.GetBookmark(bmarkID)
+		if err != nil {
+			return err
 		}
-		newLabels = append(newLabels, ID)
-	}
 
-	bmark.AddLabelIDs(newLabels)
-	if err := b.AddBookmark(bmark); err != nil {
-		return err
-	}
+		var newLabels []string
+		origLabels := bmark.GetLabelIDs()
+		for _, ID := range origLabels {
+			if labelID == ID {
+				continue
+			}
+			newLabels = append(newLabels, ID)
+		}
 
-	return nil
+		bmark.AddLabelIDs(newLabels)
+		bmarks.addBookmark(bmark)
+		return nil
+	})
 }
 
 func (b *Bookmarks) updateLabels(bmark *Bookmark) *Bookmark {
@@ -181,7 +288,7 @@ func (b *Bookmarks) updateLabels(bmark *Bookmark) *Bookmark {
 
 // GetBmarkLabelNames returns an array of labelNames for a given bookmark
 func (b *Bookmarks) GetBmarkLabelNames(bmark *Bookmark) ([]string, error) {
-	labels, err := NewLabelsWithUser(b.api, b.userID)
+	labels, err := b.getLabels()
 	if err != nil {
 		return nil, err
 	}
@@ -199,28 +306,140 @@ func (b *Bookmarks) GetBmarkLabelNames(bmark *Bookmark) ([]string, error) {
 
 // GetBmarkTextOneLine returns a single line bookmark text used for an ephemeral post
 func (b *Bookmarks) GetBmarkTextOneLine(bmark *Bookmark, labelNames []string) (string, error) {
+	return b.getBmarkTextOneLine(bmark, labelNames, false)
+}
+
+// getBmarkTextOneLine returns a single line bookmark text. Unread bookmarks
+// are only marked in listings, so confirmations of a change do not show them
+// unread
+func (b *Bookmarks) getBmarkTextOneLine(bmark *Bookmark, labelNames []string, markUnread bool) (string, error) {
 	postMessage, err := b.getTitleFromPost(bmark.PostID)
 	if err != nil {
 		return "", err
 	}
 
-	codeBlockedNames := GetCodeBlockedLabels(labelNames)
+	post, err := b.getPost(bmark.PostID)
+	if err != nil {
+		return "", err
+	}
+
+	codeBlockedNames, err := b.getLabelChips(labelNames)
+	if err != nil {
+		return "", err
+	}
 
 	// bold and italicize titles saved by the user
 	title := "**_" + bmark.GetTitle() + "_**"
 
-	if !bmark.HasUserTitle() {
+	// a deleted post without a snapshot has no message left to display, and
+	// the message of a hidden post is not displayed
+	hasMessage := (!isPostDeleted(post) || bmark.HasSnapshot()) && !isPostHidden(post)
+
+	if !bmark.HasUserTitle() && hasMessage {
 		// display the first portion of the post message in place of a title
 		title = postMessage
 		// prepend the title from post label before other labels
 		codeBlockedNames = " " + utils.TitleFromPostLabel + codeBlockedNames
 	}
 
+	switch {
+	case isPostHidden(post):
+		codeBlockedNames = " " + utils.PostHiddenLabel + codeBlockedNames
+		if !bmark.HasUserTitle() {
+			title = "Post in a channel you cannot read"
+		}
+	case isPostDeleted(post):
+		codeBlockedNames = " " + utils.PostDeletedLabel + codeBlockedNames
+		if !bmark.HasUserTitle() && !hasMessage {
+			title = "Deleted post"
+		}
+	case bmark.IsPostEdited():
+		codeBlockedNames = " " + utils.PostEditedLabel + codeBlockedNames
+	}
+
+	switch {
+	case bmark.IsArchived():
+		codeBlockedNames = " " + utils.ArchivedLabel + codeBlockedNames
+	case markUnread && bmark.GetStatus() == StatusUnread:
+		codeBlockedNames = " " + utils.UnreadLabel + codeBlockedNames
+	}
+
 	text := fmt.Sprintf("%s%s %s\n", getIconLink(b.api, bmark.PostID), codeBlockedNames, title)
 
 	return text, nil
 }
 
+// getPostIDs returns the post IDs of all bookmarks
+func (b *Bookmarks) getPostIDs() []string {
+	postIDs := make([]string, 0, len(b.ByID))
+	for id := range b.ByID {
+		postIDs = append(postIDs, id)
+	}
+	return postIDs
+}
+
+// fetchPosts fetches the posts that are not yet cached in a single request.
+// Posts that no longer exist are cached as deleted posts, and posts the user
+// cannot read as hidden posts
+func (b *Bookmarks) fetchPosts(postIDs []string) error {
+	var missing []string
+	for _, id := range postIDs {
+		if _, ok := b.posts[id]; ok {
+			continue
+		}
+		missing = append(missing, id)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	posts, err := b.api.GetPostsByIds(missing)
+	if err != nil {
+		return err
+	}
+	for _, id := range missing {
+		post, ok := posts[id]
+		if !ok {
+			post = deletedPost(id)
+		}
+		if _, err = b.cachePost(id, post); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cachePost caches a bookmarked post, or a hidden post in its place if the
+// user cannot read it, and returns the cached post
+func (b *Bookmarks) cachePost(postID string, post *model.Post) (*model.Post, error) {
+	post, err := b.restrictPost(postID, post)
+	if err != nil {
+		return nil, err
+	}
+	if b.posts == nil {
+		b.posts = make(map[string]*model.Post)
+	}
+	b.posts[postID] = post
+	return post, nil
+}
+
+// getPost returns a bookmarked post from the cache, or fetches it. A post that
+// no longer exists is returned as a deleted post, and a post the user cannot
+// read as a hidden post
+func (b *Bookmarks) getPost(postID string) (*model.Post, error) {
+	if post, ok := b.posts[postID]; ok {
+		return post, nil
+	}
+
+	post, err := b.api.GetPost(postID)
+	if pluginapi.IsNotFound(err) {
+		post = deletedPost(postID)
+	} else if err != nil {
+		return nil, err
+	}
+	return b.cachePost(postID, post)
+}
+
 // getTitleFromPost returns a title generated from a Post.Message
 func (b *Bookmarks) getTitleFromPost(postID string) (string, error) {
 	// MaxTitleCharacters is the maximum length of characters displayed in a
@@ -231,14 +450,48 @@ func (b *Bookmarks) getTitleFromPost(postID string) (string, error) {
 	// numChars := math.Min(float64(len(post.Message)), MaxTitleCharacters)
 	// bookmark.Title = post.Message[0:int(numChars)]
 
-	post, appErr := b.api.GetPost(postID)
-	if appErr != nil {
-		return "", appErr
+	post, err := b.getPost(postID)
+	if err != nil {
+		return "", err
 	}
+
+	// display the saved message of a deleted post
+	if bmark, ok := b.exists(postID); ok && isPostDeleted(post) && bmark.HasSnapshot() {
+		return bmark.Snapshot.Message, nil
+	}
+
 	title := post.Message
 	return title, nil
 }
 
+// getLabels returns the labels of the user. They are loaded once
+func (b *Bookmarks) getLabels() (*Labels, error) {
+	if b.labels != nil {
+		return b.labels, nil
+	}
+
+	labels, err := NewLabelsWithUser(b.api, b.userID)
+	if err != nil {
+		return nil, err
+	}
+	b.labels = labels
+	return labels, nil
+}
+
+// getLabelChips returns the label names code blocked and preceded by an
+// emoji of their color
+func (b *Bookmarks) getLabelChips(names []string) (string, error) {
+	if len(names) == 0 {
+		return "", nil
+	}
+
+	labels, err := b.getLabels()
+	if err != nil {
+		return "", err
+	}
+	return labels.GetChips(names), nil
+}
+
 // GetCodeBlockedLabels returns a list of individually codeblocked names
 func GetCodeBlockedLabels(names []string) string {
 	labels := ""
@@ -250,14 +503,23 @@ func GetCodeBlockedLabels(names []string) string {
 }
 
 // getBmarksEphemeralText returns a the text for posting all bookmarks in an
-// ephemeral message
-func (b *Bookmarks) GetBmarksEphemeralText(userID string, filters *Filters) (string, error) {
-	var err error
-	if filters != nil {
-		b, err = b.ApplyFilters(filters)
-		if err != nil {
-			return "", err
-		}
+// ephemeral message. Bookmarks are listed one page at a time in the order of
+// the list options, or the first page by post.CreateAt if options is nil.
+// Archived bookmarks are left out if filters is nil
+func (b *Bookmarks) GetBmarksEphemeralText(userID string, filters *Filters, options *ListOptions) (string, error) {
+	if options == nil {
+		options = NewListOptions()
+	}
+	if err := options.IsValid(); err != nil {
+		return "", err
+	}
+	if filters == nil {
+		filters = &Filters{}
+	}
+
+	b, err := b.ApplyFilters(filters)
+	if err != nil {
+		return "", err
 	}
 
 	// bookmarks is nil if user has never added a bookmark.
@@ -267,19 +529,44 @@ func (b *Bookmarks) GetBmarksEphemeralText(userID string, filters *Filters) (str
 		return "You do not have any saved bookmarks", nil
 	}
 
-	bmarksSorted, err := b.ByPostCreateAt()
+	bmarksSorted, err := b.Sort(options.SortBy, options.Reverse)
+	if err != nil {
+		return "", err
+	}
+
+	bmarksPage := paginate(bmarksSorted, options.Limit, options.Page)
+	if len(bmarksPage) == 0 {
+		numPages := (len(bmarksSorted) + options.Limit - 1) / options.Limit
+		return fmt.Sprintf("Page %d is empty. The last page of bookmarks is %d", options.Page, numPages), nil
+	}
+
+	text, err := b.getBmarksListText("#### Bookmarks\n", bmarksPage)
 	if err != nil {
 		return "", err
 	}
 
+	return text + getPageFooterText(len(bmarksSorted), options.Limit, options.Page), nil
+}
+
+// getBmarksListText returns the legend, a header and a single line for each
+// of the provided bookmarks
+func (b *Bookmarks) getBmarksListText(header string, bmarks []*Bookmark) (string, error) {
+	postIDs := make([]string, 0, len(bmarks))
+	for _, bmark := range bmarks {
+		postIDs = append(postIDs, bmark.PostID)
+	}
+	if err := b.fetchPosts(postIDs); err != nil {
+		return "", err
+	}
+
 	text := utils.GetLegendText()
-	text += "#### Bookmarks\n"
-	for _, bmark := range bmarksSorted {
+	text += header
+	for _, bmark := range bmarks {
 		labelNames, err := b.GetBmarkLabelNames(bmark)
 		if err != nil {
 			return "", err
 		}
-		nextText, err := b.GetBmarkTextOneLine(bmark, labelNames)
+		nextText, err := b.getBmarkTextOneLine(bmark, labelNames, true)
 		if err != nil {
 			return "", err
 		}
@@ -299,10 +586,43 @@ func (b *Bookmarks) GetBmarkTextDetailed(bmark *Bookmark, labelNames []string, a
 		title = bmark.Title
 	}
 
-	codeBlockedNames := GetCodeBlockedLabels(labelNames)
-	post, appErr := b.api.GetPost(bmark.PostID)
-	if appErr != nil {
-		return "", appErr
+	codeBlockedNames, err := b.getLabelChips(labelNames)
+	if err != nil {
+		return "", err
+	}
+	post, err := b.getPost(bmark.PostID)
+	if err != nil {
+		return "", err
+	}
+
+	message := post.Message
+	switch {
+	case isPostHidden(post):
+		codeBlockedNames = " " + utils.PostHiddenLabel + codeBlockedNames
+		message = "_You no longer have access to the channel of this post_"
+		if !bmark.HasUserTitle() {
+			title = "Post in a channel you cannot read"
+		}
+	case isPostDeleted(post) && bmark.HasSnapshot():
+		codeBlockedNames = " " + utils.PostDeletedLabel + codeBlockedNames
+		message = "_The bookmarked post was deleted. This is its content when it was bookmarked_\n"
+		message += getSnapshotText(bmark.Snapshot)
+	case isPostDeleted(post):
+		codeBlockedNames = " " + utils.PostDeletedLabel + codeBlockedNames
+		message = "_The bookmarked post was deleted_"
+		if !bmark.HasUserTitle() {
+			title = "Deleted post"
+		}
+	case bmark.HasSnapshot() && bmark.Snapshot.differsFrom(post):
+		codeBlockedNames = " " + utils.PostEditedLabel + codeBlockedNames
+		message += "\n##### :warning: The post changed since it was bookmarked\n"
+		message += getSnapshotText(bmark.Snapshot)
+	case bmark.IsPostEdited():
+		codeBlockedNames = " " + utils.PostEditedLabel + codeBlockedNames
+	}
+
+	if bmark.IsArchived() {
+		codeBlockedNames = " " + utils.ArchivedLabel + codeBlockedNames
 	}
 
 	iconLink := getIconLink(b.api, bmark.PostID)
@@ -310,7 +630,12 @@ func (b *Bookmarks) GetBmarkTextDetailed(bmark *Bookmark, labelNames []string, a
 	text := fmt.Sprintf("%s\n#### Bookmark Title %s\n", codeBlockedNames, iconLink)
 	text += fmt.Sprintf("**%s**\n", title)
 	text += "##### Post Message \n"
-	text += post.Message
+	text += message
+
+	if bmark.HasNote() {
+		text += "\n##### Note\n"
+		text += bmark.GetNote() + "\n"
+	}
 
 	return text, nil
 }
@@ -1,11 +1,14 @@
 package bookmarks
 
 import (
-	"encoding/json"
+	"net/http"
+	"strings"
 	"testing"
 
 	gomock "github.com/golang/mock/gomock"
 	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/utils"
+	"github.com/mattermost/mattermost-server/v5/model"
 	"github.com/mattermost/mattermost-server/v5/plugin"
 	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
 
@@ -36,13 +39,15 @@ func TestStoreBookmarks(t *testing.T) {
 	bmarks.ByID[b2.PostID] = b2
 	bmarks.api = mockPluginAPI
 
-	// Markshal the bmarks and mock api call
-	jsonBookmarks, err := json.Marshal(bmarks)
-	assert.Nil(t, err)
-	mockPluginAPI.EXPECT().KVSet("bookmarks_userID1", jsonBookmarks).Return(nil)
+	// a user without an index stores a new index and each bookmark
+	mockPluginAPI.EXPECT().KVCompareAndSet("bookmark_index_userID1", nil, []byte(`{"version":1,"data":["ID1","ID2"]}`)).Return(true, nil)
+	for _, bmark := range []*Bookmark{b1, b2} {
+		expectPostBookmarked(t, mockPluginAPI, bmark.PostID, u1)
+		mockPluginAPI.EXPECT().KVSet(GetBookmarkKey(u1, bmark.PostID), mustEncodeDocument(t, bmark)).Return(nil)
+	}
 
 	// store bmarks using API
-	err = bmarks.StoreBookmarks()
+	err := bmarks.StoreBookmarks(func(bmarks *Bookmarks) error { return nil })
 	assert.Nil(t, err)
 }
 
@@ -70,6 +75,13 @@ func TestAddBookmark(t *testing.T) {
 	bmarksU2.ByID[b1.PostID] = b1
 	bmarksU2.ByID[b2.PostID] = b2
 	bmarksU2.api = mockPluginAPI
+	markStored(t, bmarksU2)
+
+	mockPostContent(mockPluginAPI)
+
+	// the users do not sync bookmarks with flagged posts
+	mockPluginAPI.EXPECT().KVGet(GetSettingsKey(u1)).Return(nil, nil).AnyTimes()
+	mockPluginAPI.EXPECT().KVGet(GetSettingsKey(u2)).Return(nil, nil).AnyTimes()
 
 	tests := []struct {
 		name    string
@@ -95,14 +107,13 @@ func TestAddBookmark(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			jsonBmarks, err := json.Marshal(tt.bmarks)
-			assert.Nil(t, err)
-
-			mockPluginAPI.EXPECT().KVGet(GetBookmarksKey(UserID)).Return(jsonBmarks, nil).AnyTimes()
-			mockPluginAPI.EXPECT().KVSet(gomock.Any(), gomock.Any()).Return(nil)
+			// a new bookmark stores the index and the bookmark
+			mockPluginAPI.EXPECT().KVCompareAndSet(GetBookmarksIndexKey(tt.userID), tt.bmarks.storedIndex, gomock.Any()).Return(true, nil)
+			expectPostBookmarked(t, mockPluginAPI, b3.PostID, tt.userID)
+			mockPluginAPI.EXPECT().KVSet(GetBookmarkKey(tt.userID, b3.PostID), gomock.Any()).Return(nil)
 
 			// store bmarks using API
-			err = tt.bmarks.AddBookmark(b3)
+			err := tt.bmarks.AddBookmark(b3)
 			assert.Nil(t, err)
 			assert.Equal(t, tt.want, len(tt.bmarks.ByID))
 		})
@@ -134,6 +145,9 @@ func TestDeleteBookmark(t *testing.T) {
 	bmarksU2 := NewBookmarks(u2)
 	bmarksU2.ByID[b2.PostID] = b2
 	bmarksU2.api = mockPluginAPI
+	markStored(t, bmarksU2)
+
+	mockPluginAPI.EXPECT().KVGet(GetSettingsKey(u2)).Return(nil, nil).AnyTimes()
 
 	tests := []struct {
 		name       string
@@ -158,17 +172,13 @@ func TestDeleteBookmark(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			jsonBmarks, err := json.Marshal(tt.bmarks)
-			assert.Nil(t, err)
-
 			if !tt.wantErr {
-				mockPluginAPI.EXPECT().KVGet(GetBookmarksKey(tt.userID)).Return(jsonBmarks, nil).AnyTimes()
+				mockPluginAPI.EXPECT().KVCompareAndSet(GetBookmarksIndexKey(tt.userID), mustEncodeDocument(t, []string{"ID2"}), mustEncodeDocument(t, []string{})).Return(true, nil)
+				mockPluginAPI.EXPECT().KVSetWithOptions(GetBookmarkKey(tt.userID, b2.PostID), nil, model.PluginKVSetOptions{Atomic: true, OldValue: mustEncodeDocument(t, b2)}).Return(true, nil)
+				expectPostUnbookmarked(t, mockPluginAPI, b2.PostID, tt.userID)
 			}
 
-			// not testing store in this test.  mock to accept anything
-			mockPluginAPI.EXPECT().KVSet(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
-
-			err = tt.bmarks.DeleteBookmark(b2.PostID)
+			err := tt.bmarks.DeleteBookmark(b2.PostID)
 			if tt.wantErr {
 				assert.Equal(t, err.Error(), tt.wantErrMsg)
 				return
@@ -178,3 +188,370 @@ func TestDeleteBookmark(t *testing.T) {
 		})
 	}
 }
+
+func TestGetBmarksEphemeralText_fetchesPostsOnce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+
+	posts := map[string]*model.Post{
+		"ID1": {Id: "ID1", Message: "message1", UserId: "author1", CreateAt: 3},
+		"ID2": {Id: "ID2", Message: "message2", UserId: "author1", CreateAt: 1},
+		"ID3": {Id: "ID3", Message: "message3", UserId: "author2", CreateAt: 2},
+	}
+
+	bmarks := NewBookmarks(UserID)
+	bmarks.api = mockPluginAPI
+	bmarks.ByID["ID1"] = &Bookmark{PostID: "ID1", Title: "Title1"}
+	bmarks.ByID["ID2"] = &Bookmark{PostID: "ID2"}
+	bmarks.ByID["ID3"] = &Bookmark{PostID: "ID3", Title: "Title3"}
+
+	// filtering, sorting and rendering share a single fetch of the posts.
+	// GetPost is not expected
+	mockPluginAPI.EXPECT().GetPostsByIds(gomock.Any()).DoAndReturn(func(postIDs []string) (map[string]*model.Post, error) {
+		assert.ElementsMatch(t, []string{"ID1", "ID2", "ID3"}, postIDs)
+		return posts, nil
+	}).Times(1)
+	mockPluginAPI.EXPECT().GetConfig().Return(&model.Config{
+		ServiceSettings: model.ServiceSettings{SiteURL: model.NewString("https://myhost.com")},
+	}).AnyTimes()
+	mockPluginAPI.EXPECT().KVGet(GetLabelsKey(UserID)).Return(nil, nil).AnyTimes()
+	mockChannelMember(mockPluginAPI)
+
+	text, err := bmarks.GetBmarksEphemeralText(UserID, &Filters{AuthorID: "author1"}, nil)
+	assert.Nil(t, err)
+	assert.Contains(t, text, "message2")
+	assert.Contains(t, text, "Title1")
+	assert.NotContains(t, text, "Title3")
+	assert.Less(t, strings.Index(text, "message2"), strings.Index(text, "Title1"))
+}
+
+func TestByPostCreateAt(t *testing.T) {
+	type bmark struct {
+		postID       string
+		postCreateAt int64
+		createAt     int64
+	}
+
+	tests := map[string]struct {
+		bmarks  []bmark
+		wantIDs []string
+	}{
+		"distinct post times": {
+			bmarks: []bmark{
+				{postID: "ID1", postCreateAt: 3, createAt: 1},
+				{postID: "ID2", postCreateAt: 1, createAt: 2},
+				{postID: "ID3", postCreateAt: 2, createAt: 3},
+			},
+			wantIDs: []string{"ID2", "ID3", "ID1"},
+		},
+		"same post time ordered by bookmark time": {
+			bmarks: []bmark{
+				{postID: "ID1", postCreateAt: 5, createAt: 3},
+				{postID: "ID2", postCreateAt: 5, createAt: 1},
+				{postID: "ID3", postCreateAt: 5, createAt: 2},
+			},
+			wantIDs: []string{"ID2", "ID3", "ID1"},
+		},
+		"same post and bookmark time ordered by PostID": {
+			bmarks: []bmark{
+				{postID: "ID3", postCreateAt: 5, createAt: 1},
+				{postID: "ID1", postCreateAt: 5, createAt: 1},
+				{postID: "ID2", postCreateAt: 5, createAt: 1},
+			},
+			wantIDs: []string{"ID1", "ID2", "ID3"},
+		},
+		"mixed ties": {
+			bmarks: []bmark{
+				{postID: "ID4", postCreateAt: 1, createAt: 9},
+				{postID: "ID3", postCreateAt: 2, createAt: 1},
+				{postID: "ID2", postCreateAt: 2, createAt: 1},
+				{postID: "ID1", postCreateAt: 2, createAt: 0},
+			},
+			wantIDs: []string{"ID4", "ID1", "ID2", "ID3"},
+		},
+		"post times beyond int32": {
+			bmarks: []bmark{
+				{postID: "ID1", postCreateAt: 1600000000001},
+				{postID: "ID2", postCreateAt: 1600000000000},
+			},
+			wantIDs: []string{"ID2", "ID1"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+
+			bmarks := NewBookmarks(UserID)
+			bmarks.api = mockPluginAPI
+			posts := make(map[string]*model.Post)
+			for _, b := range tt.bmarks {
+				bmarks.ByID[b.postID] = &Bookmark{PostID: b.postID, CreateAt: b.createAt}
+				posts[b.postID] = &model.Post{Id: b.postID, CreateAt: b.postCreateAt}
+			}
+			mockPluginAPI.EXPECT().GetPostsByIds(gomock.Any()).Return(posts, nil)
+			mockChannelMember(mockPluginAPI)
+
+			sorted, err := bmarks.ByPostCreateAt()
+			assert.Nil(t, err)
+
+			var ids []string
+			for _, bmark := range sorted {
+				ids = append(ids, bmark.PostID)
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+		})
+	}
+}
+
+func TestByPostCreateAt_snapshots(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+
+	bmarks := NewBookmarks(UserID)
+	bmarks.api = mockPluginAPI
+	bmarks.ByID["ID1"] = &Bookmark{PostID: "ID1", Snapshot: &PostSnapshot{ChannelID: "channelID", CreateAt: 3}}
+	bmarks.ByID["ID2"] = &Bookmark{PostID: "ID2"}
+	bmarks.ByID["ID3"] = &Bookmark{PostID: "ID3", Snapshot: &PostSnapshot{ChannelID: "channelID", CreateAt: 1}}
+
+	// only the post of the bookmark without a snapshot is fetched
+	mockPluginAPI.EXPECT().GetPostsByIds([]string{"ID2"}).Return(map[string]*model.Post{
+		"ID2": {Id: "ID2", ChannelId: "channelID", CreateAt: 2},
+	}, nil)
+	mockChannelMember(mockPluginAPI)
+
+	sorted, err := bmarks.ByPostCreateAt()
+	assert.Nil(t, err)
+
+	var ids []string
+	for _, bmark := range sorted {
+		ids = append(ids, bmark.PostID)
+	}
+	assert.Equal(t, []string{"ID3", "ID2", "ID1"}, ids)
+}
+
+func TestGetBmarksEphemeralText_deletedPosts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+
+	bmarks := NewBookmarks(UserID)
+	bmarks.api = mockPluginAPI
+	bmarks.ByID["ID1"] = &Bookmark{PostID: "ID1", Title: "Title1"}
+	bmarks.ByID["ID2"] = &Bookmark{PostID: "ID2"}
+	bmarks.ByID["ID3"] = &Bookmark{PostID: "ID3", Title: "Title3"}
+	bmarks.ByID["ID4"] = &Bookmark{PostID: "ID4", PostEditedAt: 10}
+
+	// deleted posts are left out by the server
+	mockPluginAPI.EXPECT().GetPostsByIds(gomock.Any()).DoAndReturn(func(postIDs []string) (map[string]*model.Post, error) {
+		assert.ElementsMatch(t, []string{"ID1", "ID2", "ID3", "ID4"}, postIDs)
+		return map[string]*model.Post{
+			"ID1": {Id: "ID1", Message: "message1", CreateAt: 1},
+			"ID4": {Id: "ID4", Message: "message4", CreateAt: 4},
+		}, nil
+	})
+	mockPluginAPI.EXPECT().GetConfig().Return(&model.Config{
+		ServiceSettings: model.ServiceSettings{SiteURL: model.NewString("https://myhost.com")},
+	}).AnyTimes()
+	mockPluginAPI.EXPECT().KVGet(GetLabelsKey(UserID)).Return(nil, nil).AnyTimes()
+	mockChannelMember(mockPluginAPI)
+
+	text, err := bmarks.GetBmarksEphemeralText(UserID, nil, nil)
+	assert.Nil(t, err)
+	assert.Contains(t, text, "[:link:](https://myhost.com/_redirect/pl/ID1) **_Title1_**\n")
+	assert.Contains(t, text, "[:link:](https://myhost.com/_redirect/pl/ID2) "+utils.PostDeletedLabel+" Deleted post\n")
+	assert.Contains(t, text, "[:link:](https://myhost.com/_redirect/pl/ID3) "+utils.PostDeletedLabel+" **_Title3_**\n")
+	assert.Contains(t, text, "[:link:](https://myhost.com/_redirect/pl/ID4) "+utils.PostEditedLabel+" "+utils.TitleFromPostLabel+" message4\n")
+
+	// a single deleted post is not found either
+	bmarks.posts = nil
+	mockPluginAPI.EXPECT().GetPost("ID2").Return(nil, &model.AppError{StatusCode: http.StatusNotFound})
+	text, err = bmarks.GetBmarkTextDetailed(bmarks.ByID["ID2"], nil, nil)
+	assert.Nil(t, err)
+	assert.Contains(t, text, "**Deleted post**")
+	assert.Contains(t, text, "_The bookmarked post was deleted_")
+}
+
+func TestSetNote(t *testing.T) {
+	tests := map[string]struct {
+		bmarkID      string
+		note         string
+		expectedNote string
+		wantErrMsg   string
+	}{
+		"note written": {
+			bmarkID:      "ID1",
+			note:         "paged the on-call\n* root cause: disk full",
+			expectedNote: "paged the on-call\n* root cause: disk full",
+		},
+		"note removed": {
+			bmarkID: "ID1",
+			note:    "",
+		},
+		"bookmark does not exist": {
+			bmarkID:    "ID2",
+			note:       "a note",
+			wantErrMsg: "Bookmark `ID2` does not exist",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+			kv := mockKVStore(mockPluginAPI)
+			storeTestBookmarks(t, kv, UserID, &Bookmark{PostID: "ID1", CreateAt: 1, ModifiedAt: 1, Note: "old note"})
+
+			bmarks, err := NewBookmarksWithUser(mockPluginAPI, UserID)
+			assert.Nil(t, err)
+
+			err = bmarks.SetNote(tt.bmarkID, tt.note)
+			if tt.wantErrMsg != "" {
+				assert.EqualError(t, err, tt.wantErrMsg)
+				return
+			}
+			assert.Nil(t, err)
+
+			bmarks, err = NewBookmarksWithUser(mockPluginAPI, UserID)
+			assert.Nil(t, err)
+			bmark, err := bmarks.GetBookmark(tt.bmarkID)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expectedNote, bmark.Note)
+			assert.Greater(t, bmark.ModifiedAt, int64(1))
+		})
+	}
+}
+
+func TestAddBookmark_unread(t *testing.T) {
+	bmarks := NewBookmarks(UserID)
+	bmarks.addBookmark(&Bookmark{PostID: "ID1"})
+	assert.Equal(t, StatusUnread, bmarks.ByID["ID1"].Status)
+
+	// a bookmark added again keeps its status
+	bmarks.ByID["ID1"].Status = StatusRead
+	bmarks.addBookmark(&Bookmark{PostID: "ID1", Title: "read later"})
+	assert.Equal(t, StatusRead, bmarks.ByID["ID1"].Status)
+}
+
+func TestSetStatus(t *testing.T) {
+	tests := map[string]struct {
+		bmarkID        string
+		status         string
+		expectedStatus string
+		changed        bool
+		wantErrMsg     string
+	}{
+		"bookmark archived": {
+			bmarkID:        "ID1",
+			status:         StatusArchived,
+			expectedStatus: StatusArchived,
+			changed:        true,
+		},
+		"bookmark marked read": {
+			bmarkID:        "ID1",
+			status:         StatusRead,
+			expectedStatus: StatusRead,
+			changed:        true,
+		},
+		"bookmark already unread": {
+			bmarkID:        "ID1",
+			status:         StatusUnread,
+			expectedStatus: StatusUnread,
+		},
+		"bookmark without a status is read": {
+			bmarkID:        "ID3",
+			status:         StatusRead,
+			expectedStatus: StatusRead,
+		},
+		"unknown status": {
+			bmarkID:    "ID1",
+			status:     "deleted",
+			wantErrMsg: "`deleted` is not a bookmark status",
+		},
+		"bookmark does not exist": {
+			bmarkID:    "ID2",
+			status:     StatusRead,
+			wantErrMsg: "Bookmark `ID2` does not exist",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+			kv := mockKVStore(mockPluginAPI)
+			storeTestBookmarks(t, kv, UserID,
+				&Bookmark{PostID: "ID1", CreateAt: 1, ModifiedAt: 1, Status: StatusUnread},
+				&Bookmark{PostID: "ID3", CreateAt: 1, ModifiedAt: 1},
+			)
+
+			bmarks, err := NewBookmarksWithUser(mockPluginAPI, UserID)
+			assert.Nil(t, err)
+
+			changed, err := bmarks.SetStatus(tt.bmarkID, tt.status)
+			if tt.wantErrMsg != "" {
+				assert.EqualError(t, err, tt.wantErrMsg)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.changed, changed)
+
+			// adding the bookmark again keeps its status
+			assert.Nil(t, bmarks.StoreBookmarks(func(bmarks *Bookmarks) error {
+				bmarks.addBookmark(&Bookmark{PostID: tt.bmarkID, Title: "read later"})
+				return nil
+			}))
+
+			bmarks, err = NewBookmarksWithUser(mockPluginAPI, UserID)
+			assert.Nil(t, err)
+			bmark, err := bmarks.GetBookmark(tt.bmarkID)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expectedStatus, bmark.GetStatus())
+		})
+	}
+}
+
+func TestGetBmarkTextDetailed_note(t *testing.T) {
+	tests := map[string]struct {
+		bmark       *Bookmark
+		contains    []string
+		notContains []string
+	}{
+		"no note": {
+			bmark:       &Bookmark{PostID: "ID1"},
+			notContains: []string{"##### Note"},
+		},
+		"note": {
+			bmark:    &Bookmark{PostID: "ID1", Note: "why we saved it\n* second line"},
+			contains: []string{"##### Post Message \npost message\n##### Note\nwhy we saved it\n* second line\n"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+			mockPluginAPI.EXPECT().GetConfig().Return(&model.Config{
+				ServiceSettings: model.ServiceSettings{SiteURL: model.NewString("https://myhost.com")},
+			}).AnyTimes()
+			mockPluginAPI.EXPECT().GetPost("ID1").Return(&model.Post{Id: "ID1", Message: "post message"}, nil)
+			mockChannelMember(mockPluginAPI)
+
+			bmarks := NewBookmarks(UserID)
+			bmarks.api = mockPluginAPI
+			bmarks.ByID["ID1"] = tt.bmark
+
+			text, err := bmarks.GetBmarkTextDetailed(tt.bmark, nil, nil)
+			assert.Nil(t, err)
+			for _, s := range tt.contains {
+				assert.Contains(t, text, s)
+			}
+			for _, s := range tt.notContains {
+				assert.NotContains(t, text, s)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package bookmarks
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Bulk actions apply to every bookmark selected by filters
+const (
+	BulkLabelAdd       = "label-add"
+	BulkLabelRemove    = "label-remove"
+	BulkRemove         = "remove"
+	BulkSetTitlePrefix = "set-title-prefix"
+)
+
+// AddLabelsToBookmarks adds labels to each of the bookmarks that does not
+// have them yet. It returns the number of bookmarks changed
+func (b *Bookmarks) AddLabelsToBookmarks(bmarkIDs []string, labelIDs []string) (int, error) {
+	var changed int
+	err := b.StoreBookmarks(func(bmarks *Bookmarks) error {
+		changed = 0
+		for _, bmark := range bmarks.getBookmarks(bmarkIDs) {
+			ids := bmark.GetLabelIDs()
+			for _, labelID := range labelIDs {
+				if !bmark.hasLabelID(labelID) {
+					ids = append(ids, labelID)
+				}
+			}
+			if len(ids) == len(bmark.GetLabelIDs()) {
+				continue
+			}
+
+			bmark.AddLabelIDs(ids)
+			bmarks.updateTimes(bmark.PostID)
+			changed++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to add labels to bookmarks")
+	}
+	return changed, nil
+}
+
+// RemoveLabelsFromBookmarks removes labels from each of the bookmarks. It
+// returns the number of bookmarks changed
+func (b *Bookmarks) RemoveLabelsFromBookmarks(bmarkIDs []string, labelIDs []string) (int, error) {
+	remove := make(map[string]bool)
+	for _, id := range labelIDs {
+		remove[id] = true
+	}
+
+	var changed int
+	err := b.StoreBookmarks(func(bmarks *Bookmarks) error {
+		changed = 0
+		for _, bmark := range bmarks.getBookmarks(bmarkIDs) {
+			if !bmark.hasAnyLabelID(remove) {
+				continue
+			}
+
+			var ids []string
+			for _, id := range bmark.GetLabelIDs() {
+				if !remove[id] {
+					ids = append(ids, id)
+				}
+			}
+
+			bmark.AddLabelIDs(ids)
+			bmarks.updateTimes(bmark.PostID)
+			changed++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to remove labels from bookmarks")
+	}
+	return changed, nil
+}
+
+// DeleteBookmarks deletes the bookmarks from the store. It returns the
+// number of bookmarks deleted
+func (b *Bookmarks) DeleteBookmarks(bmarkIDs []string) (int, error) {
+	changed, err := b.deleteBookmarks(bmarkIDs)
+	if err != nil {
+		return 0, err
+	}
+	if err = b.syncFlags(bmarkIDs, false); err != nil {
+		return 0, errors.Wrap(err, "failed to unflag the posts of removed bookmarks")
+	}
+	return changed, nil
+}
+
+// deleteBookmarks deletes the bookmarks from the store without unflagging
+// their posts
+func (b *Bookmarks) deleteBookmarks(bmarkIDs []string) (int, error) {
+	var changed int
+	err := b.StoreBookmarks(func(bmarks *Bookmarks) error {
+		changed = 0
+		for _, bmark := range bmarks.getBookmarks(bmarkIDs) {
+			delete(bmarks.ByID, bmark.PostID)
+			changed++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to remove bookmarks")
+	}
+	return changed, nil
+}
+
+// SetTitlePrefix adds a prefix to the title of each of the bookmarks whose
+// title does not start with it. Bookmarks without a title are titled with
+// the prefix followed by the post message. It returns the number of bookmarks
+// changed
+func (b *Bookmarks) SetTitlePrefix(bmarkIDs []string, prefix string) (int, error) {
+	// posts are fetched before the bookmarks are changed, so a retried change
+	// does not fetch them again
+	if err := b.fetchPosts(bmarkIDs); err != nil {
+		return 0, err
+	}
+
+	var changed int
+	err := b.StoreBookmarks(func(bmarks *Bookmarks) error {
+		changed = 0
+		for _, bmark := range bmarks.getBookmarks(bmarkIDs) {
+			// the title is read from the bookmarks passed to mutate, which are
+			// reloaded when another request changed them
+			title := bmark.GetTitle()
+			if !bmark.HasUserTitle() {
+				var err error
+				title, err = bmarks.getTitleFromPost(bmark.PostID)
+				if err != nil {
+					return err
+				}
+			}
+			if strings.HasPrefix(title, prefix) {
+				continue
+			}
+
+			bmark.SetTitle(prefix + title)
+			bmarks.updateTimes(bmark.PostID)
+			changed++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to set the title prefix of bookmarks")
+	}
+	return changed, nil
+}
+
+// GetBmarksListText returns the legend, a header and a single line for each
+// bookmark, in the order of the post create times
+func (b *Bookmarks) GetBmarksListText(header string) (string, error) {
+	bmarks, err := b.ByPostCreateAt()
+	if err != nil {
+		return "", err
+	}
+	return b.getBmarksListText(header, bmarks)
+}
+
+// getBookmarks returns the bookmarks with the given IDs. Bookmarks removed
+// since the IDs were selected are skipped
+func (b *Bookmarks) getBookmarks(bmarkIDs []string) []*Bookmark {
+	var bmarks []*Bookmark
+	for _, id := range bmarkIDs {
+		if bmark, ok := b.exists(id); ok {
+			bmarks = append(bmarks, bmark)
+		}
+	}
+	return bmarks
+}
+
+// hasLabelID returns true if the bookmark has the label ID
+func (bm *Bookmark) hasLabelID(id string) bool {
+	for _, labelID := range bm.GetLabelIDs() {
+		if labelID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package bookmarks
+
+import (
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkActions(t *testing.T) {
+	tests := map[string]struct {
+		apply            func(bmarks *Bookmarks) (int, error)
+		expectedChanged  int
+		expectedLabelIDs map[string][]string
+		expectedTitles   map[string]string
+		expectedIDs      []string
+	}{
+		"add labels": {
+			apply: func(bmarks *Bookmarks) (int, error) {
+				return bmarks.AddLabelsToBookmarks([]string{"ID1", "ID2", "ID3"}, []string{"UUID1", "UUID3"})
+			},
+			expectedChanged: 2,
+			expectedLabelIDs: map[string][]string{
+				"ID1": {"UUID1", "UUID2", "UUID3"},
+				"ID2": {"UUID1", "UUID3"},
+				"ID3": {"UUID3", "UUID1"},
+				"ID4": {"UUID2"},
+			},
+		},
+		"remove labels": {
+			apply: func(bmarks *Bookmarks) (int, error) {
+				return bmarks.RemoveLabelsFromBookmarks([]string{"ID1", "ID2", "ID3", "ID4"}, []string{"UUID1", "UUID3"})
+			},
+			expectedChanged: 3,
+			expectedLabelIDs: map[string][]string{
+				"ID1": {"UUID2"},
+				"ID2": nil,
+				"ID3": nil,
+				"ID4": {"UUID2"},
+			},
+		},
+		"remove bookmarks": {
+			apply: func(bmarks *Bookmarks) (int, error) {
+				return bmarks.DeleteBookmarks([]string{"ID1", "ID3", "removedID"})
+			},
+			expectedChanged: 2,
+			expectedIDs:     []string{"ID2", "ID4"},
+		},
+		"set title prefix": {
+			apply: func(bmarks *Bookmarks) (int, error) {
+				return bmarks.SetTitlePrefix([]string{"ID1", "ID2", "ID3"}, "[done] ")
+			},
+			expectedChanged: 2,
+			expectedTitles: map[string]string{
+				"ID1": "[done] message ID1",
+				"ID2": "[done] deploy",
+				"ID3": "[done] retro",
+				"ID4": "",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+			kv := mockKVStore(mockPluginAPI)
+			mockPluginAPI.EXPECT().GetPostsByIds(gomock.Any()).DoAndReturn(func(postIDs []string) (map[string]*model.Post, error) {
+				posts := make(map[string]*model.Post)
+				for _, id := range postIDs {
+					posts[id] = &model.Post{Id: id, Message: "message " + id, ChannelId: "channelID"}
+				}
+				return posts, nil
+			}).AnyTimes()
+			mockChannelMember(mockPluginAPI)
+
+			storeTestBookmarks(t, kv, UserID, []*Bookmark{
+				{PostID: "ID1", LabelIDs: []string{"UUID1", "UUID2"}},
+				{PostID: "ID2", Title: "deploy", LabelIDs: []string{"UUID1", "UUID3"}},
+				{PostID: "ID3", Title: "[done] retro", LabelIDs: []string{"UUID3"}},
+				{PostID: "ID4", LabelIDs: []string{"UUID2"}},
+			}...)
+
+			bmarks, err := NewBookmarksWithUser(mockPluginAPI, UserID)
+			assert.Nil(t, err)
+
+			changed, err := tt.apply(bmarks)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expectedChanged, changed)
+
+			bmarks, err = NewBookmarksWithUser(mockPluginAPI, UserID)
+			assert.Nil(t, err)
+			for id, expected := range tt.expectedLabelIDs {
+				assert.Equal(t, expected, bmarks.ByID[id].LabelIDs, id)
+			}
+			for id, expected := range tt.expectedTitles {
+				assert.Equal(t, expected, bmarks.ByID[id].Title, id)
+			}
+			if tt.expectedIDs != nil {
+				assert.ElementsMatch(t, tt.expectedIDs, bmarks.getPostIDs())
+			}
+		})
+	}
+}
+
+func TestSetTitlePrefix_titleChanged(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	kv := mockKVStore(mockPluginAPI)
+	mockPluginAPI.EXPECT().GetPostsByIds(gomock.Any()).DoAndReturn(func(postIDs []string) (map[string]*model.Post, error) {
+		posts := make(map[string]*model.Post)
+		for _, id := range postIDs {
+			posts[id] = &model.Post{Id: id, Message: "message " + id, ChannelId: "channelID"}
+		}
+		return posts, nil
+	}).AnyTimes()
+	mockChannelMember(mockPluginAPI)
+
+	storeTestBookmarks(t, kv, UserID,
+		&Bookmark{PostID: "ID1", Title: "deploy"},
+		&Bookmark{PostID: "ID2"},
+	)
+	bmarks, err := NewBookmarksWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+
+	// another request removes the title of ID1 and titles ID2 after the
+	// bookmarks were loaded
+	storeTestBookmarks(t, kv, UserID,
+		&Bookmark{PostID: "ID1", ModifiedAt: 1},
+		&Bookmark{PostID: "ID2", Title: "retro", ModifiedAt: 1},
+	)
+
+	changed, err := bmarks.SetTitlePrefix([]string{"ID1", "ID2"}, "[done] ")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, changed)
+
+	bmarks, err = NewBookmarksWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Equal(t, "[done] message ID1", bmarks.ByID["ID1"].Title)
+	assert.Equal(t, "[done] retro", bmarks.ByID["ID2"].Title)
+}
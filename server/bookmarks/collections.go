@@ -0,0 +1,92 @@
+package bookmarks
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi"
+	"github.com/pkg/errors"
+)
+
+// Collections contains a map of saved collections with the collection name as
+// the key
+type Collections struct {
+	ByName map[string]*Collection
+	api    pluginapi.API
+	userID string
+
+	// stored holds the value last loaded from or stored to the KV store
+	stored []byte
+}
+
+// Collection is a named set of view filters. The filter flags are saved as
+// given by the user, so relative dates and names are resolved each time the
+// collection is viewed
+type Collection struct {
+	Name string   `json:"name"`
+	Args []string `json:"args"`
+}
+
+// NewCollections returns an initialized Collections struct
+func NewCollections(userID string) *Collections {
+	return &Collections{
+		ByName: make(map[string]*Collection),
+		userID: userID,
+	}
+}
+
+// NewCollectionsWithUser returns an initialized Collections for a User
+func NewCollectionsWithUser(api pluginapi.API, userID string) (*Collections, error) {
+	bb, appErr := api.KVGet(GetCollectionsKey(userID))
+	if appErr != nil {
+		return nil, errors.Wrapf(appErr, "Unable to get collections for user %s", userID)
+	}
+
+	userCollections, err := CollectionsFromJSON(bb)
+	if err != nil {
+		return nil, err
+	}
+	userCollections.api = api
+	userCollections.userID = userID
+	userCollections.stored = bb
+
+	return userCollections, nil
+}
+
+// CollectionsFromJSON returns unmarshalled collections or initialized
+// collections if bytes are empty
+func CollectionsFromJSON(bytes []byte) (*Collections, error) {
+	collections := &Collections{
+		ByName: make(map[string]*Collection),
+	}
+
+	if len(bytes) != 0 {
+		jsonErr := json.Unmarshal(bytes, &collections)
+		if jsonErr != nil {
+			return nil, jsonErr
+		}
+	}
+	return collections, nil
+}
+
+// GetCollection returns the collection with the provided name
+func (c *Collections) GetCollection(name string) (*Collection, error) {
+	collection, ok := c.ByName[name]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Collection `%s` does not exist", name))
+	}
+	return collection, nil
+}
+
+// SaveCollection adds a collection to the users collection store, replacing
+// any collection with the same name
+func (c *Collections) SaveCollection(collection *Collection) error {
+	err := c.StoreCollections(func(collections *Collections) error {
+		collections.ByName[collection.Name] = collection
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to save collection")
+	}
+	return nil
+}
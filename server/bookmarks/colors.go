@@ -0,0 +1,79 @@
+package bookmarks
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ParseColor returns a hex color in the form #rrggbb. The leading # is
+// optional and the short form #rgb is expanded
+func ParseColor(color string) (string, error) {
+	hex := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(color), "#"))
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return "", errors.New(fmt.Sprintf("Color `%s` is not a hex color like #1e90ff", color))
+	}
+	if _, err := strconv.ParseUint(hex, 16, 32); err != nil {
+		return "", errors.New(fmt.Sprintf("Color `%s` is not a hex color like #1e90ff", color))
+	}
+	return "#" + hex, nil
+}
+
+// colorEmoji returns the colored circle emoji closest to a color parsed by
+// ParseColor. Markdown cannot color text, so listings show the emoji next to
+// the label name
+func colorEmoji(color string) string {
+	rgb, err := strconv.ParseUint(strings.TrimPrefix(color, "#"), 16, 32)
+	if err != nil {
+		return ""
+	}
+	r := float64(rgb>>16&0xff) / 255
+	g := float64(rgb>>8&0xff) / 255
+	b := float64(rgb&0xff) / 255
+
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	lightness := (max + min) / 2
+
+	// grays have no hue
+	if max-min < 0.15 {
+		if lightness >= 0.5 {
+			return "⚪"
+		}
+		return "⚫"
+	}
+
+	var hue float64
+	switch max {
+	case r:
+		hue = math.Mod((g-b)/(max-min)+6, 6)
+	case g:
+		hue = (b-r)/(max-min) + 2
+	default:
+		hue = (r-g)/(max-min) + 4
+	}
+	hue *= 60
+
+	switch {
+	case hue < 15 || hue >= 330:
+		return "🔴"
+	case hue < 45 && lightness < 0.35:
+		return "🟤"
+	case hue < 45:
+		return "🟠"
+	case hue < 70:
+		return "🟡"
+	case hue < 170:
+		return "🟢"
+	case hue < 260:
+		return "🔵"
+	default:
+		return "🟣"
+	}
+}
@@ -0,0 +1,60 @@
+package bookmarks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := map[string]struct {
+		color      string
+		expected   string
+		wantErrMsg string
+	}{
+		"long form":            {color: "#1E90FF", expected: "#1e90ff"},
+		"no #":                 {color: "1e90ff", expected: "#1e90ff"},
+		"short form":           {color: "#f0a", expected: "#ff00aa"},
+		"not hex":              {color: "#ggg", wantErrMsg: "Color `#ggg` is not a hex color like #1e90ff"},
+		"wrong length":         {color: "#1e90f", wantErrMsg: "Color `#1e90f` is not a hex color like #1e90ff"},
+		"color name":           {color: "red", wantErrMsg: "Color `red` is not a hex color like #1e90ff"},
+		"signed hex":           {color: "+1e90f", wantErrMsg: "Color `+1e90f` is not a hex color like #1e90ff"},
+		"empty":                {color: "", wantErrMsg: "Color `` is not a hex color like #1e90ff"},
+		"surrounded by spaces": {color: " #000000 ", expected: "#000000"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			color, err := ParseColor(tt.color)
+			if tt.wantErrMsg != "" {
+				assert.EqualError(t, err, tt.wantErrMsg)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, color)
+		})
+	}
+}
+
+func TestColorEmoji(t *testing.T) {
+	tests := map[string]struct {
+		color    string
+		expected string
+	}{
+		"red":    {color: "#e53935", expected: "🔴"},
+		"pink":   {color: "#e91e63", expected: "🔴"},
+		"orange": {color: "#fb8c00", expected: "🟠"},
+		"brown":  {color: "#6d4c41", expected: "🟤"},
+		"yellow": {color: "#fdd835", expected: "🟡"},
+		"green":  {color: "#43a047", expected: "🟢"},
+		"blue":   {color: "#1e90ff", expected: "🔵"},
+		"purple": {color: "#8e24aa", expected: "🟣"},
+		"white":  {color: "#fafafa", expected: "⚪"},
+		"gray":   {color: "#9e9e9e", expected: "⚪"},
+		"black":  {color: "#212121", expected: "⚫"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, colorEmoji(tt.color))
+		})
+	}
+}
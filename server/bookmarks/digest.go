@@ -0,0 +1,423 @@
+package bookmarks
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/utils"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+// Frequencies of the digest
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+const (
+	// StoreDigestUsersKey is the key used to store the IDs of the users who
+	// get a digest, so the due digests are found without loading the settings
+	// of every user
+	StoreDigestUsersKey = "digest_users"
+
+	// digestsLockKey is held by the node sending the due digests, so a digest
+	// is sent once in a cluster
+	digestsLockKey = "digests_lock"
+
+	// staleBookmarkAge is the age after which a bookmark without labels is
+	// listed in the digest
+	staleBookmarkAge = 30 * 24 * time.Hour
+
+	// maxDigestBookmarks is the number of bookmarks listed in each section of
+	// the digest
+	maxDigestBookmarks = 10
+)
+
+// ParseDigestSchedule sets the schedule of the digest from the fields of a
+// command: a frequency, the day of a weekly digest and a time of day (9am,
+// 14:00). Weekly digests without a day are sent on Mondays, and digests
+// without a time at 9am. The settings are not stored
+func (s *Settings) ParseDigestSchedule(fields []string) error {
+	usage := errors.New("Please specify `daily` or `weekly`, an optional day of the week for a weekly digest and an optional time, e.g. `daily 9am` or `weekly friday 4pm`")
+	if len(fields) == 0 {
+		return usage
+	}
+
+	frequency := strings.ToLower(fields[0])
+	if frequency != DigestDaily && frequency != DigestWeekly {
+		return usage
+	}
+	fields = fields[1:]
+
+	weekday := time.Monday
+	if frequency == DigestWeekly && len(fields) > 0 {
+		if day, ok := parseWeekday(fields[0]); ok {
+			weekday = day
+			fields = fields[1:]
+		}
+	}
+
+	hour, minute := defaultRemindHour, 0
+	switch len(fields) {
+	case 0:
+	case 1:
+		var ok bool
+		if hour, minute, ok = parseTimeOfDay(fields[0]); !ok {
+			return errors.New(fmt.Sprintf("`%s` is not a time of day, e.g. `9am`, `2:30pm` or `14:00`", fields[0]))
+		}
+	default:
+		return usage
+	}
+
+	s.DigestFrequency = frequency
+	s.DigestWeekday = weekday
+	s.DigestHour = hour
+	s.DigestMinute = minute
+	return nil
+}
+
+// parseWeekday returns the day of the week of a name like monday or mon
+func parseWeekday(value string) (time.Weekday, bool) {
+	value = strings.ToLower(value)
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		name := strings.ToLower(day.String())
+		if value == name || value == name[:3] {
+			return day, true
+		}
+	}
+	return time.Sunday, false
+}
+
+// HasDigest returns true if the user gets a digest of their bookmarks
+func (s *Settings) HasDigest() bool {
+	return s.DigestFrequency != ""
+}
+
+// GetDigestScheduleText returns when the digest is sent, e.g. daily at 9:00 AM
+func (s *Settings) GetDigestScheduleText() string {
+	at := time.Date(2000, time.January, 1, s.DigestHour, s.DigestMinute, 0, 0, time.UTC).Format("3:04 PM")
+	if s.DigestFrequency == DigestWeekly {
+		return fmt.Sprintf("every %s at %s", s.DigestWeekday, at)
+	}
+	return fmt.Sprintf("daily at %s", at)
+}
+
+// EnableDigest stores the schedule of the digest set by ParseDigestSchedule.
+// The first digest is sent at the next scheduled time after now
+func (s *Settings) EnableDigest(now int64) error {
+	frequency, weekday, hour, minute := s.DigestFrequency, s.DigestWeekday, s.DigestHour, s.DigestMinute
+	err := s.StoreSettings(func(settings *Settings) error {
+		settings.DigestFrequency = frequency
+		settings.DigestWeekday = weekday
+		settings.DigestHour = hour
+		settings.DigestMinute = minute
+		settings.LastDigestAt = now
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return setDigestUser(s.api, s.userID, true)
+}
+
+// DisableDigest stops sending the digest to the user
+func (s *Settings) DisableDigest() error {
+	err := s.StoreSettings(func(settings *Settings) error {
+		settings.DigestFrequency = ""
+		settings.DigestWeekday = time.Sunday
+		settings.DigestHour = 0
+		settings.DigestMinute = 0
+		settings.LastDigestAt = 0
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return setDigestUser(s.api, s.userID, false)
+}
+
+// nextDigestAt returns the first scheduled time of the digest after a time,
+// in the location of that time
+func (s *Settings) nextDigestAt(after time.Time) time.Time {
+	year, month, day := after.Date()
+	next := time.Date(year, month, day, s.DigestHour, s.DigestMinute, 0, 0, after.Location())
+
+	days := 1
+	if s.DigestFrequency == DigestWeekly {
+		days = 7
+		next = next.AddDate(0, 0, (int(s.DigestWeekday)-int(next.Weekday())+7)%7)
+	}
+	for !next.After(after) {
+		next = next.AddDate(0, 0, days)
+	}
+	return next
+}
+
+// GetDigestText returns the digest of the bookmarks of a user: the bookmarks
+// added since the last digest, the bookmarks with reminders due before the
+// next digest and the bookmarks without labels older than staleBookmarkAge.
+// Archived bookmarks are not listed. The text is empty if there is nothing to list
+func (b *Bookmarks) GetDigestText(since, until, now int64) (string, error) {
+	staleBefore := now - int64(staleBookmarkAge/time.Millisecond)
+
+	var added, reminders, stale []*Bookmark
+	for _, bmark := range b.sortBy(func(bi, bj *Bookmark) int { return compareInt64(bi.CreateAt, bj.CreateAt) }) {
+		// the user is done with archived bookmarks
+		if bmark.IsArchived() {
+			continue
+		}
+		if bmark.CreateAt > since {
+			added = append(added, bmark)
+		}
+		if bmark.HasReminder() && bmark.RemindAt <= until {
+			reminders = append(reminders, bmark)
+		}
+		if !bmark.hasLabels() && bmark.CreateAt <= staleBefore {
+			stale = append(stale, bmark)
+		}
+	}
+	sort.SliceStable(reminders, func(i, j int) bool {
+		return reminders[i].RemindAt < reminders[j].RemindAt
+	})
+
+	if len(added) == 0 && len(reminders) == 0 && len(stale) == 0 {
+		return "", nil
+	}
+
+	var postIDs []string
+	for _, bmarks := range [][]*Bookmark{added, reminders, stale} {
+		for i, bmark := range bmarks {
+			if i < maxDigestBookmarks {
+				postIDs = append(postIDs, bmark.PostID)
+			}
+		}
+	}
+	if err := b.fetchPosts(postIDs); err != nil {
+		return "", err
+	}
+
+	text := utils.GetLegendText()
+	text += "#### Bookmarks digest\n"
+	sections := []struct {
+		header string
+		bmarks []*Bookmark
+	}{
+		{header: "New bookmarks", bmarks: added},
+		{header: "Reminders due before the next digest", bmarks: reminders},
+		{header: "Bookmarks without labels older than 30 days", bmarks: stale},
+	}
+	for _, section := range sections {
+		if len(section.bmarks) == 0 {
+			continue
+		}
+
+		sectionText, err := b.getDigestSectionText(section.header, section.bmarks)
+		if err != nil {
+			return "", err
+		}
+		text += sectionText
+	}
+	return text, nil
+}
+
+// getDigestSectionText returns a header and a single line for each of the
+// first maxDigestBookmarks bookmarks
+func (b *Bookmarks) getDigestSectionText(header string, bmarks []*Bookmark) (string, error) {
+	text := fmt.Sprintf("##### %s (%d)\n", header, len(bmarks))
+	for i, bmark := range bmarks {
+		if i == maxDigestBookmarks {
+			text += fmt.Sprintf("and %d more\n", len(bmarks)-maxDigestBookmarks)
+			break
+		}
+
+		labelNames, err := b.GetBmarkLabelNames(bmark)
+		if err != nil {
+			return "", err
+		}
+		bmarkText, err := b.GetBmarkTextOneLine(bmark, labelNames)
+		if err != nil {
+			return "", err
+		}
+		text += bmarkText
+	}
+	return text, nil
+}
+
+// getDigestUserIDs returns the IDs of the users who get a digest and the
+// stored value they were read from
+func getDigestUserIDs(api pluginapi.API) ([]string, []byte, error) {
+	bb, appErr := api.KVGet(StoreDigestUsersKey)
+	if appErr != nil {
+		return nil, nil, appErr
+	}
+	if bb == nil {
+		return nil, nil, nil
+	}
+
+	data, _, err := decodeDocument(documentDigestUsers, bb)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var userIDs []string
+	if jsonErr := json.Unmarshal(data, &userIDs); jsonErr != nil {
+		return nil, nil, jsonErr
+	}
+	return userIDs, bb, nil
+}
+
+// setDigestUser adds a user to or removes a user from the users who get a
+// digest
+func setDigestUser(api pluginapi.API, userID string, enabled bool) error {
+	for i := 0; i < maxStoreAttempts; i++ {
+		userIDs, stored, err := getDigestUserIDs(api)
+		if err != nil {
+			return err
+		}
+
+		found := false
+		var newUserIDs []string
+		for _, id := range userIDs {
+			if id == userID {
+				found = true
+				continue
+			}
+			newUserIDs = append(newUserIDs, id)
+		}
+		if found == enabled {
+			return nil
+		}
+
+		if enabled {
+			newUserIDs = append(newUserIDs, userID)
+			sort.Strings(newUserIDs)
+		}
+
+		// a nil value deletes the key
+		var value []byte
+		if len(newUserIDs) != 0 {
+			value, err = encodeDocument(newUserIDs)
+			if err != nil {
+				return err
+			}
+		}
+
+		ok, appErr := api.KVSetWithOptions(StoreDigestUsersKey, value, model.PluginKVSetOptions{
+			Atomic:   true,
+			OldValue: stored,
+		})
+		if appErr != nil {
+			return appErr
+		}
+		if ok {
+			return nil
+		}
+	}
+
+	return errors.New(fmt.Sprintf("Unable to store the users who get a digest. They were changed by another request %d times", maxStoreAttempts))
+}
+
+// SendDueDigests calls send with the digest of each user whose digest is due
+// at now. Users with nothing to list get no digest. Only one node of a cluster
+// sends digests at a time, the others return without sending any. It returns
+// the number of digests sent
+func SendDueDigests(api pluginapi.API, now int64, send func(userID, message string) error) (int, error) {
+	lock, err := lockJob(api, digestsLockKey)
+	if err != nil {
+		return 0, errors.Wrap(err, "Unable to lock digests")
+	}
+	if lock == nil {
+		return 0, nil
+	}
+	defer lock.unlock()
+
+	userIDs, _, err := getDigestUserIDs(api)
+	if err != nil {
+		return 0, errors.Wrap(err, "Unable to get the users who get a digest")
+	}
+
+	sent := 0
+	var sendErr error
+	for _, userID := range userIDs {
+		// the node that took an expired lock sends the remaining digests
+		held, err := lock.refresh()
+		if err != nil {
+			return sent, errors.Wrap(err, "Unable to refresh the lock of digests")
+		}
+		if !held {
+			break
+		}
+
+		ok, err := sendDigest(api, userID, now, send)
+		if err != nil {
+			// a digest that fails is sent again later, without holding up
+			// the digests of other users
+			if sendErr == nil {
+				sendErr = err
+			}
+			continue
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, sendErr
+}
+
+// sendDigest sends the digest of a user if it is due. It returns true if the
+// digest was sent
+func sendDigest(api pluginapi.API, userID string, now int64, send func(userID, message string) error) (bool, error) {
+	settings, err := NewSettingsWithUser(api, userID)
+	if err != nil {
+		return false, err
+	}
+	if !settings.HasDigest() {
+		return false, setDigestUser(api, userID, false)
+	}
+
+	location, err := GetUserLocation(api, userID)
+	if err != nil {
+		return false, err
+	}
+	last := time.Unix(0, settings.LastDigestAt*int64(time.Millisecond)).In(location)
+	due := settings.nextDigestAt(last)
+	if utils.GetMillis(due) > now {
+		return false, nil
+	}
+
+	bmarks, err := NewBookmarksWithUser(api, userID)
+	if err != nil {
+		return false, err
+	}
+
+	// reminders are listed until the digest after this one
+	until := settings.nextDigestAt(time.Unix(0, now*int64(time.Millisecond)).In(location))
+	text, err := bmarks.GetDigestText(settings.LastDigestAt, utils.GetMillis(until), now)
+	if err != nil {
+		return false, err
+	}
+
+	if text != "" {
+		if err = send(userID, text); err != nil {
+			return false, errors.Wrapf(err, "Unable to send the digest to user %s", userID)
+		}
+	}
+
+	// only the time of the digest is stored, so settings changed while the
+	// digest was sent are kept
+	err = settings.StoreSettings(func(settings *Settings) error {
+		if settings.HasDigest() && settings.LastDigestAt < now {
+			settings.LastDigestAt = now
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return text != "", nil
+}
@@ -0,0 +1,298 @@
+package bookmarks
+
+import (
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/utils"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDigestSchedule(t *testing.T) {
+	tests := map[string]struct {
+		fields       []string
+		expected     *Settings
+		expectedText string
+		wantErr      string
+	}{
+		"daily defaults to 9am": {
+			fields:       []string{"daily"},
+			expected:     &Settings{DigestFrequency: DigestDaily, DigestWeekday: time.Monday, DigestHour: 9},
+			expectedText: "daily at 9:00 AM",
+		},
+		"daily at a time": {
+			fields:       []string{"Daily", "17:30"},
+			expected:     &Settings{DigestFrequency: DigestDaily, DigestWeekday: time.Monday, DigestHour: 17, DigestMinute: 30},
+			expectedText: "daily at 5:30 PM",
+		},
+		"weekly defaults to Monday": {
+			fields:       []string{"weekly", "8am"},
+			expected:     &Settings{DigestFrequency: DigestWeekly, DigestWeekday: time.Monday, DigestHour: 8},
+			expectedText: "every Monday at 8:00 AM",
+		},
+		"weekly on a day": {
+			fields:       []string{"weekly", "fri", "4pm"},
+			expected:     &Settings{DigestFrequency: DigestWeekly, DigestWeekday: time.Friday, DigestHour: 16},
+			expectedText: "every Friday at 4:00 PM",
+		},
+		"unknown frequency": {
+			fields:  []string{"hourly"},
+			wantErr: "Please specify `daily` or `weekly`",
+		},
+		"invalid time": {
+			fields:  []string{"daily", "25:00"},
+			wantErr: "`25:00` is not a time of day",
+		},
+		"day of a daily digest": {
+			fields:  []string{"daily", "friday", "4pm"},
+			wantErr: "Please specify `daily` or `weekly`",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			settings := &Settings{}
+			err := settings.ParseDigestSchedule(tt.fields)
+			if tt.wantErr != "" {
+				assert.NotNil(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, settings)
+			assert.Equal(t, tt.expectedText, settings.GetDigestScheduleText())
+		})
+	}
+}
+
+func TestNextDigestAt(t *testing.T) {
+	location, err := time.LoadLocation("Europe/Berlin")
+	assert.Nil(t, err)
+
+	tests := map[string]struct {
+		settings *Settings
+		after    time.Time
+		expected time.Time
+	}{
+		"daily later today": {
+			settings: &Settings{DigestFrequency: DigestDaily, DigestHour: 9},
+			after:    time.Date(2026, time.October, 19, 8, 0, 0, 0, location),
+			expected: time.Date(2026, time.October, 19, 9, 0, 0, 0, location),
+		},
+		"daily tomorrow": {
+			settings: &Settings{DigestFrequency: DigestDaily, DigestHour: 9},
+			after:    time.Date(2026, time.October, 19, 9, 0, 0, 0, location),
+			expected: time.Date(2026, time.October, 20, 9, 0, 0, 0, location),
+		},
+		"daily keeps the time of day across a daylight saving change": {
+			settings: &Settings{DigestFrequency: DigestDaily, DigestHour: 9},
+			after:    time.Date(2026, time.October, 24, 10, 0, 0, 0, location),
+			expected: time.Date(2026, time.October, 25, 9, 0, 0, 0, location),
+		},
+		"weekly later this week": {
+			settings: &Settings{DigestFrequency: DigestWeekly, DigestWeekday: time.Friday, DigestHour: 16},
+			after:    time.Date(2026, time.October, 19, 8, 0, 0, 0, location),
+			expected: time.Date(2026, time.October, 23, 16, 0, 0, 0, location),
+		},
+		"weekly next week": {
+			settings: &Settings{DigestFrequency: DigestWeekly, DigestWeekday: time.Monday, DigestHour: 9},
+			after:    time.Date(2026, time.October, 19, 10, 0, 0, 0, location),
+			expected: time.Date(2026, time.October, 26, 9, 0, 0, 0, location),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			actual := tt.settings.nextDigestAt(tt.after)
+			assert.True(t, tt.expected.Equal(actual), "expected %v, actual %v", tt.expected, actual)
+		})
+	}
+}
+
+// mockDigestPosts returns a post for every bookmark listed in a digest
+func mockDigestPosts(api *mock_pluginapi.MockAPI) {
+	mockPostContent(api)
+	api.EXPECT().GetPostsByIds(gomock.Any()).DoAndReturn(func(postIDs []string) (map[string]*model.Post, error) {
+		posts := make(map[string]*model.Post)
+		for _, id := range postIDs {
+			posts[id] = &model.Post{Id: id, Message: "message " + id, UserId: "authorID", ChannelId: "channelID"}
+		}
+		return posts, nil
+	}).AnyTimes()
+	api.EXPECT().GetConfig().Return(&model.Config{
+		ServiceSettings: model.ServiceSettings{SiteURL: model.NewString("https://myhost.com")},
+	}).AnyTimes()
+}
+
+func TestGetDigestText(t *testing.T) {
+	now := utils.GetMillis(time.Date(2026, time.October, 19, 9, 0, 0, 0, time.UTC))
+	day := int64(24 * time.Hour / time.Millisecond)
+
+	tests := map[string]struct {
+		bmarks           []*Bookmark
+		expectedContains []string
+		expectedMissing  []string
+	}{
+		"nothing to list": {
+			bmarks: []*Bookmark{
+				{PostID: "ID1", CreateAt: now - 2*day, LabelIDs: []string{"UUID1"}},
+			},
+		},
+		"new bookmarks, reminders and stale bookmarks": {
+			bmarks: []*Bookmark{
+				{PostID: "IDNew", CreateAt: now - day/2, LabelIDs: []string{"UUID1"}},
+				{PostID: "IDRemind", CreateAt: now - 2*day, LabelIDs: []string{"UUID1"}, RemindAt: now + day/2},
+				{PostID: "IDLater", CreateAt: now - 2*day, LabelIDs: []string{"UUID1"}, RemindAt: now + 2*day},
+				{PostID: "IDStale", CreateAt: now - 40*day},
+				{PostID: "IDStaleLabeled", CreateAt: now - 40*day, LabelIDs: []string{"UUID1"}},
+				{PostID: "IDArchived", CreateAt: now - 40*day, Status: StatusArchived},
+			},
+			expectedContains: []string{
+				"#### Bookmarks digest",
+				"##### New bookmarks (1)\n[:link:](https://myhost.com/_redirect/pl/IDNew)",
+				"##### Reminders due before the next digest (1)\n[:link:](https://myhost.com/_redirect/pl/IDRemind)",
+				"##### Bookmarks without labels older than 30 days (1)\n[:link:](https://myhost.com/_redirect/pl/IDStale)",
+			},
+			expectedMissing: []string{"IDLater", "IDStaleLabeled", "IDArchived"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+			mockDigestPosts(mockPluginAPI)
+			labels := NewLabels(UserID)
+			labels.ByID["UUID1"] = &Label{Name: "label1", ID: "UUID1"}
+			mockPluginAPI.EXPECT().KVGet(GetLabelsKey(UserID)).Return(mustEncodeDocument(t, labels), nil).AnyTimes()
+
+			bmarks := NewBookmarks(UserID)
+			bmarks.api = mockPluginAPI
+			for _, bmark := range tt.bmarks {
+				bmarks.ByID[bmark.PostID] = bmark
+			}
+
+			text, err := bmarks.GetDigestText(now-day, now+day, now)
+			assert.Nil(t, err)
+			if tt.expectedContains == nil {
+				assert.Equal(t, "", text)
+			}
+			for _, s := range tt.expectedContains {
+				assert.Contains(t, text, s)
+			}
+			for _, s := range tt.expectedMissing {
+				assert.NotContains(t, text, s)
+			}
+		})
+	}
+}
+
+func TestSendDueDigests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	kv := mockKVStore(mockPluginAPI)
+	mockDigestPosts(mockPluginAPI)
+	mockPluginAPI.EXPECT().KVDelete(gomock.Any()).DoAndReturn(func(key string) error {
+		delete(kv, key)
+		return nil
+	}).AnyTimes()
+	mockPluginAPI.EXPECT().GetUser(UserID).Return(&model.User{
+		Id:       UserID,
+		Timezone: model.StringMap{"useAutomaticTimezone": "false", "manualTimezone": "Europe/Berlin"},
+	}, nil).AnyTimes()
+	location, err := time.LoadLocation("Europe/Berlin")
+	assert.Nil(t, err)
+
+	// the digest was turned on the day before at noon
+	enabledAt := utils.GetMillis(time.Date(2026, time.October, 18, 12, 0, 0, 0, location))
+	settings, err := NewSettingsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Nil(t, settings.ParseDigestSchedule([]string{"daily", "9am"}))
+	assert.Nil(t, settings.EnableDigest(enabledAt))
+
+	storeTestBookmarks(t, kv, UserID, &Bookmark{PostID: "ID1", CreateAt: enabledAt + 1000})
+
+	var messages []string
+	send := func(userID, message string) error {
+		assert.Equal(t, UserID, userID)
+		messages = append(messages, message)
+		return nil
+	}
+
+	// the digest is not due before 9am in the timezone of the user
+	sent, err := SendDueDigests(mockPluginAPI, utils.GetMillis(time.Date(2026, time.October, 19, 8, 59, 0, 0, location)), send)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, sent)
+
+	// another node holds the lock
+	dueAt := utils.GetMillis(time.Date(2026, time.October, 19, 9, 0, 0, 0, location))
+	kv[digestsLockKey] = []byte("locked")
+	sent, err = SendDueDigests(mockPluginAPI, dueAt, send)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, sent)
+	delete(kv, digestsLockKey)
+
+	sent, err = SendDueDigests(mockPluginAPI, dueAt, send)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Len(t, messages, 1)
+	assert.Contains(t, messages[0], "##### New bookmarks (1)\n[:link:](https://myhost.com/_redirect/pl/ID1)")
+	assert.Nil(t, kv[digestsLockKey])
+
+	settings, err = NewSettingsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Equal(t, dueAt, settings.LastDigestAt)
+
+	// the next digest has nothing new to list
+	sent, err = SendDueDigests(mockPluginAPI, dueAt+int64(24*time.Hour/time.Millisecond), send)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, sent)
+	assert.Len(t, messages, 1)
+
+	// turning the digest off removes the user from the digest users
+	assert.Nil(t, settings.DisableDigest())
+	assert.Nil(t, kv[StoreDigestUsersKey])
+}
+
+func TestSendDueDigests_settingsChanged(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	kv := mockKVStore(mockPluginAPI)
+	mockDigestPosts(mockPluginAPI)
+	mockPluginAPI.EXPECT().GetUser(UserID).Return(&model.User{Id: UserID}, nil).AnyTimes()
+
+	enabledAt := utils.GetMillis(time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC))
+	settings, err := NewSettingsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Nil(t, settings.ParseDigestSchedule([]string{"daily", "9am"}))
+	assert.Nil(t, settings.EnableDigest(enabledAt))
+
+	storeTestBookmarks(t, kv, UserID, &Bookmark{PostID: "ID1", CreateAt: enabledAt + 1000})
+
+	// the user changes the schedule and turns the sync on while the digest
+	// is sent
+	send := func(userID, message string) error {
+		settings, err := NewSettingsWithUser(mockPluginAPI, userID)
+		assert.Nil(t, err)
+		assert.Nil(t, settings.StoreSettings(func(settings *Settings) error {
+			settings.SyncFlagged = true
+			settings.DigestHour = 18
+			return nil
+		}))
+		return nil
+	}
+
+	dueAt := utils.GetMillis(time.Date(2026, time.October, 19, 9, 0, 0, 0, time.UTC))
+	sent, err := SendDueDigests(mockPluginAPI, dueAt, send)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, sent)
+
+	settings, err = NewSettingsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.True(t, settings.SyncFlagged)
+	assert.Equal(t, 18, settings.DigestHour)
+	assert.Equal(t, dueAt, settings.LastDigestAt)
+}
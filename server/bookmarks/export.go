@@ -0,0 +1,389 @@
+package bookmarks
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/utils"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+// Formats bookmarks can be exported to
+const (
+	ExportFormatJSON     = "json"
+	ExportFormatCSV      = "csv"
+	ExportFormatMarkdown = "md"
+	ExportFormatHTML     = "html"
+)
+
+// ExportVersion is the version of the JSON export format
+const ExportVersion = 1
+
+// Export contains all bookmarks and labels of a user
+type Export struct {
+	Version    int                 `json:"version"`
+	ExportedAt int64               `json:"exported_at"`
+	Bookmarks  []*ExportedBookmark `json:"bookmarks"`
+	Labels     []*Label            `json:"labels"`
+
+	// Invalid are the rows of an imported file that were skipped, with the
+	// reason. They are not exported
+	Invalid []string `json:"-"`
+}
+
+// ExportedBookmark is a bookmark with its labels resolved to names
+type ExportedBookmark struct {
+	PostID     string        `json:"post_id"`
+	Permalink  string        `json:"permalink"`
+	Title      string        `json:"title,omitempty"`
+	Labels     []string      `json:"labels,omitempty"`
+	Note       string        `json:"note,omitempty"`
+	CreateAt   int64         `json:"create_at"`
+	ModifiedAt int64         `json:"update_at"`
+	Post       *ExportedPost `json:"post,omitempty"`
+}
+
+// ExportedPost is the content of a bookmarked post. The content of posts in
+// channels the user cannot read is not exported
+type ExportedPost struct {
+	Message     string   `json:"message"`
+	AuthorName  string   `json:"author_name,omitempty"`
+	ChannelName string   `json:"channel_name,omitempty"`
+	CreateAt    int64    `json:"create_at"`
+	FileNames   []string `json:"file_names,omitempty"`
+	Deleted     bool     `json:"deleted,omitempty"`
+	Edited      bool     `json:"edited,omitempty"`
+}
+
+// GetExportFileName returns the name of the file of an export
+func GetExportFileName(format string, now time.Time) string {
+	return fmt.Sprintf("bookmarks-%s.%s", now.UTC().Format("2006-01-02"), format)
+}
+
+// Export returns all bookmarks, in the order of the post create times, and
+// all labels of the user in the requested format
+func (b *Bookmarks) Export(format string) ([]byte, error) {
+	export, err := b.getExport()
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case ExportFormatJSON:
+		return json.MarshalIndent(export, "", "  ")
+	case ExportFormatCSV:
+		return getExportCSV(export)
+	case ExportFormatMarkdown:
+		return getExportMarkdown(export), nil
+	case ExportFormatHTML:
+		return getExportHTML(export), nil
+	}
+	return nil, errors.New(fmt.Sprintf("Export format `%s` is not supported. Use one of json, csv, md or html", format))
+}
+
+// getExport returns all bookmarks and labels of the user
+func (b *Bookmarks) getExport() (*Export, error) {
+	labels, err := b.getLabels()
+	if err != nil {
+		return nil, err
+	}
+
+	// every exported post is needed, so they are fetched in one request
+	if err = b.fetchPosts(b.getPostIDs()); err != nil {
+		return nil, err
+	}
+	bmarks, err := b.ByPostCreateAt()
+	if err != nil {
+		return nil, err
+	}
+
+	export := &Export{
+		Version:    ExportVersion,
+		ExportedAt: model.GetMillis(),
+		Bookmarks:  make([]*ExportedBookmark, 0, len(bmarks)),
+		Labels:     make([]*Label, 0, len(labels.ByID)),
+	}
+
+	siteURL := utils.GetSiteURL(b.api)
+	names := &exportNames{users: make(map[string]string), channels: make(map[string]string)}
+	for _, bmark := range bmarks {
+		labelNames, err := b.GetBmarkLabelNames(bmark)
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(labelNames)
+
+		post, err := b.getExportedPost(bmark, names)
+		if err != nil {
+			return nil, err
+		}
+
+		export.Bookmarks = append(export.Bookmarks, &ExportedBookmark{
+			PostID:     bmark.PostID,
+			Permalink:  getPermaLink(siteURL, bmark.PostID),
+			Title:      bmark.GetTitle(),
+			Labels:     labelNames,
+			Note:       bmark.GetNote(),
+			CreateAt:   bmark.CreateAt,
+			ModifiedAt: bmark.ModifiedAt,
+			Post:       post,
+		})
+	}
+
+	for id, label := range labels.ByID {
+		// labels stored before labels had IDs only have them as keys
+		exported := *label
+		exported.ID = id
+		export.Labels = append(export.Labels, &exported)
+	}
+	sort.Slice(export.Labels, func(i, j int) bool {
+		return compareLabelNames(export.Labels[i].Name, export.Labels[j].Name) < 0
+	})
+
+	return export, nil
+}
+
+// exportNames caches the names of the authors and channels of exported posts
+type exportNames struct {
+	users    map[string]string
+	channels map[string]string
+}
+
+// getExportedPost returns the content of a bookmarked post, or nil if the
+// post was deleted without a snapshot or the user cannot read it
+func (b *Bookmarks) getExportedPost(bmark *Bookmark, names *exportNames) (*ExportedPost, error) {
+	post, err := b.getPost(bmark.PostID)
+	if err != nil {
+		return nil, err
+	}
+	if isPostHidden(post) {
+		return nil, nil
+	}
+
+	if isPostDeleted(post) {
+		if !bmark.HasSnapshot() {
+			return nil, nil
+		}
+		s := bmark.Snapshot
+		return &ExportedPost{
+			Message:     s.Message,
+			AuthorName:  s.AuthorName,
+			ChannelName: s.ChannelName,
+			CreateAt:    s.CreateAt,
+			FileNames:   s.FileNames,
+			Deleted:     true,
+		}, nil
+	}
+
+	exported := &ExportedPost{
+		Message:  post.Message,
+		CreateAt: post.CreateAt,
+		Edited:   bmark.IsPostEdited(),
+	}
+
+	// the snapshot has the names at the time the post was bookmarked
+	if s := bmark.Snapshot; s != nil && !s.differsFrom(post) {
+		exported.AuthorName = s.AuthorName
+		exported.ChannelName = s.ChannelName
+		exported.FileNames = s.FileNames
+		return exported, nil
+	}
+
+	authorName, ok := names.users[post.UserId]
+	if !ok {
+		user, err := b.api.GetUser(post.UserId)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to get the author of post %s", post.Id)
+		}
+		authorName = user.Username
+		names.users[post.UserId] = authorName
+	}
+	exported.AuthorName = authorName
+
+	channelName, ok := names.channels[post.ChannelId]
+	if !ok {
+		channel, err := b.api.GetChannel(post.ChannelId)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to get the channel of post %s", post.Id)
+		}
+		channelName = channel.DisplayName
+		if channelName == "" {
+			channelName = channel.Name
+		}
+		names.channels[post.ChannelId] = channelName
+	}
+	exported.ChannelName = channelName
+
+	for _, fileID := range post.FileIds {
+		info, err := b.api.GetFileInfo(fileID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to get file %s of post %s", fileID, post.Id)
+		}
+		exported.FileNames = append(exported.FileNames, info.Name)
+	}
+
+	return exported, nil
+}
+
+// getDisplayTitle returns the title of an exported bookmark, or the first
+// line of the post message for bookmarks without a title
+func (e *ExportedBookmark) getDisplayTitle() string {
+	if e.Title != "" {
+		return e.Title
+	}
+	if e.Post != nil && e.Post.Message != "" {
+		return strings.SplitN(e.Post.Message, "\n", 2)[0]
+	}
+	return e.Permalink
+}
+
+// formatExportTime returns a time in milliseconds as an RFC 3339 UTC time
+func formatExportTime(millis int64) string {
+	if millis == 0 {
+		return ""
+	}
+	return time.Unix(0, millis*int64(time.Millisecond)).UTC().Format(time.RFC3339)
+}
+
+// getExportCSV returns one row for each bookmark. Labels and file names are
+// separated by commas
+func getExportCSV(export *Export) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	header := []string{"post_id", "permalink", "title", "labels", "note", "bookmarked_at", "updated_at",
+		"post_message", "post_author", "post_channel", "post_created_at", "post_files", "post_deleted", "post_edited"}
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	for _, bmark := range export.Bookmarks {
+		record := []string{
+			bmark.PostID,
+			bmark.Permalink,
+			bmark.Title,
+			strings.Join(bmark.Labels, ","),
+			bmark.Note,
+			formatExportTime(bmark.CreateAt),
+			formatExportTime(bmark.ModifiedAt),
+		}
+		if post := bmark.Post; post != nil {
+			record = append(record,
+				post.Message,
+				post.AuthorName,
+				post.ChannelName,
+				formatExportTime(post.CreateAt),
+				strings.Join(post.FileNames, ","),
+				fmt.Sprint(post.Deleted),
+				fmt.Sprint(post.Edited),
+			)
+		} else {
+			record = append(record, "", "", "", "", "", "", "")
+		}
+		for i := range record {
+			record[i] = escapeCSVFormula(record[i])
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// csvFormulaPrefixes are the first characters of a cell that a spreadsheet
+// runs as a formula
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// escapeCSVFormula prefixes a cell that a spreadsheet would run as a formula
+// with a quote, so it is shown as text
+func escapeCSVFormula(cell string) string {
+	if cell != "" && strings.ContainsRune(csvFormulaPrefixes, rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// unescapeCSVFormula removes the quote added by escapeCSVFormula
+func unescapeCSVFormula(cell string) string {
+	if len(cell) > 1 && cell[0] == '\'' && strings.ContainsRune(csvFormulaPrefixes, rune(cell[1])) {
+		return cell[1:]
+	}
+	return cell
+}
+
+// markdownLinkTextReplacer escapes the characters that end the text or the
+// URL of a markdown link
+var markdownLinkTextReplacer = strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, ")", `\)`)
+
+// escapeMarkdownLinkText returns text that can be used as the text of a
+// markdown link
+func escapeMarkdownLinkText(text string) string {
+	return markdownLinkTextReplacer.Replace(text)
+}
+
+// getExportMarkdown returns a markdown list of the bookmarks with links to
+// the posts
+func getExportMarkdown(export *Export) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# Bookmarks\n\n")
+
+	for _, bmark := range export.Bookmarks {
+		fmt.Fprintf(&buf, "- [%s](%s)%s\n", escapeMarkdownLinkText(bmark.getDisplayTitle()), bmark.Permalink, GetCodeBlockedLabels(bmark.Labels))
+		if post := bmark.Post; post != nil {
+			fmt.Fprintf(&buf, "  - Posted by @%s in %s on %s\n", post.AuthorName, post.ChannelName, formatExportTime(post.CreateAt))
+			if bmark.Title != "" && post.Message != "" {
+				for _, line := range strings.Split(post.Message, "\n") {
+					buf.WriteString("    > " + line + "\n")
+				}
+			}
+		}
+		if bmark.Note != "" {
+			buf.WriteString("  - Note:\n")
+			for _, line := range strings.Split(bmark.Note, "\n") {
+				buf.WriteString("    " + line + "\n")
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+// getExportHTML returns the bookmarks in the Netscape bookmark file format,
+// which browsers import. Labels are exported as tags
+func getExportHTML(export *Export) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+`)
+	fmt.Fprintf(&buf, "    <DT><H3 ADD_DATE=\"%d\">Mattermost</H3>\n    <DL><p>\n", export.ExportedAt/1000)
+	for _, bmark := range export.Bookmarks {
+		fmt.Fprintf(&buf, "        <DT><A HREF=\"%s\" ADD_DATE=\"%d\" LAST_MODIFIED=\"%d\"",
+			html.EscapeString(bmark.Permalink), bmark.CreateAt/1000, bmark.ModifiedAt/1000)
+		if len(bmark.Labels) != 0 {
+			fmt.Fprintf(&buf, " TAGS=\"%s\"", html.EscapeString(strings.Join(bmark.Labels, ",")))
+		}
+		fmt.Fprintf(&buf, ">%s</A>\n", html.EscapeString(bmark.getDisplayTitle()))
+		if bmark.Note != "" {
+			fmt.Fprintf(&buf, "        <DD>%s\n", html.EscapeString(bmark.Note))
+		}
+	}
+	buf.WriteString("    </DL><p>\n</DL><p>\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,189 @@
+package bookmarks
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// getExportTestBookmarks stores bookmarks of a post, a deleted post with a
+// snapshot and a post in a channel the user cannot read
+func getExportTestBookmarks(t *testing.T, api *mock_pluginapi.MockAPI) *Bookmarks {
+	kv := mockKVStore(api)
+	notFound := &model.AppError{StatusCode: http.StatusNotFound}
+
+	api.EXPECT().GetConfig().Return(&model.Config{
+		ServiceSettings: model.ServiceSettings{SiteURL: model.NewString("https://myhost.com")},
+	}).AnyTimes()
+	api.EXPECT().GetPostsByIds(gomock.Any()).Return(map[string]*model.Post{
+		"ID1": {Id: "ID1", Message: "first line\nsecond line", UserId: "authorID", ChannelId: "channelID", CreateAt: 1000},
+		"ID3": {Id: "ID3", Message: "secret", UserId: "authorID", ChannelId: "privateID", CreateAt: 3000},
+	}, nil).AnyTimes()
+	api.EXPECT().GetChannelMember("privateID", UserID).Return(nil, notFound).AnyTimes()
+	api.EXPECT().GetChannel("privateID").Return(nil, notFound).AnyTimes()
+	api.EXPECT().GetUser("authorID").Return(&model.User{Id: "authorID", Username: "author"}, nil).AnyTimes()
+	api.EXPECT().GetChannel("channelID").Return(&model.Channel{Id: "channelID", DisplayName: "Town Square"}, nil).AnyTimes()
+	mockChannelMember(api)
+
+	kv[GetLabelsKey(UserID)] = mustEncodeDocument(t, &Labels{ByID: map[string]*Label{
+		"UUID1": {Name: "prod", ID: "UUID1", Color: "#e53935"},
+		"UUID2": {Name: "Docs"},
+	}})
+	storeTestBookmarks(t, kv, UserID, []*Bookmark{
+		{PostID: "ID1", Note: "check, \"later\"", LabelIDs: []string{"UUID1", "UUID2"}, CreateAt: 5000, ModifiedAt: 6000},
+		{PostID: "ID2", Title: "deploy <v2>", CreateAt: 2000, ModifiedAt: 2000, Snapshot: &PostSnapshot{
+			Message: "deploy steps", AuthorID: "authorID", AuthorName: "author", ChannelID: "channelID", ChannelName: "Town Square", CreateAt: 2000,
+		}},
+		{PostID: "ID3", Title: "secret plans", CreateAt: 4000, ModifiedAt: 4000},
+	}...)
+
+	bmarks, err := NewBookmarksWithUser(api, UserID)
+	assert.Nil(t, err)
+	return bmarks
+}
+
+func TestExportJSON(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	bmarks := getExportTestBookmarks(t, mockPluginAPI)
+
+	data, err := bmarks.Export(ExportFormatJSON)
+	assert.Nil(t, err)
+
+	var export Export
+	assert.Nil(t, json.Unmarshal(data, &export))
+	assert.Equal(t, ExportVersion, export.Version)
+
+	assert.Equal(t, []*Label{
+		{Name: "Docs", ID: "UUID2"},
+		{Name: "prod", ID: "UUID1", Color: "#e53935"},
+	}, export.Labels)
+
+	assert.Equal(t, []*ExportedBookmark{
+		{
+			PostID:     "ID3",
+			Permalink:  "https://myhost.com/_redirect/pl/ID3",
+			Title:      "secret plans",
+			CreateAt:   4000,
+			ModifiedAt: 4000,
+		},
+		{
+			PostID:     "ID1",
+			Permalink:  "https://myhost.com/_redirect/pl/ID1",
+			Labels:     []string{"Docs", "prod"},
+			Note:       "check, \"later\"",
+			CreateAt:   5000,
+			ModifiedAt: 6000,
+			Post:       &ExportedPost{Message: "first line\nsecond line", AuthorName: "author", ChannelName: "Town Square", CreateAt: 1000},
+		},
+		{
+			PostID:     "ID2",
+			Permalink:  "https://myhost.com/_redirect/pl/ID2",
+			Title:      "deploy <v2>",
+			CreateAt:   2000,
+			ModifiedAt: 2000,
+			Post:       &ExportedPost{Message: "deploy steps", AuthorName: "author", ChannelName: "Town Square", CreateAt: 2000, Deleted: true},
+		},
+	}, export.Bookmarks)
+}
+
+func TestExportFormats(t *testing.T) {
+	tests := map[string]struct {
+		format           string
+		expectedContains []string
+		wantErr          bool
+	}{
+		"csv": {
+			format: ExportFormatCSV,
+			expectedContains: []string{
+				"post_id,permalink,title,labels,note,bookmarked_at,updated_at,post_message,post_author,post_channel,post_created_at,post_files,post_deleted,post_edited\n",
+				"ID1,https://myhost.com/_redirect/pl/ID1,,\"Docs,prod\",\"check, \"\"later\"\"\",1970-01-01T00:00:05Z,1970-01-01T00:00:06Z,\"first line\nsecond line\",author,Town Square,1970-01-01T00:00:01Z,,false,false\n",
+				"ID3,https://myhost.com/_redirect/pl/ID3,secret plans,,,1970-01-01T00:00:04Z,1970-01-01T00:00:04Z,,,,,,,\n",
+			},
+		},
+		"markdown": {
+			format: ExportFormatMarkdown,
+			expectedContains: []string{
+				"- [first line](https://myhost.com/_redirect/pl/ID1) `Docs` `prod`\n  - Posted by @author in Town Square",
+				"- [deploy <v2>](https://myhost.com/_redirect/pl/ID2)\n",
+				"    > deploy steps\n",
+				"  - Note:\n    check, \"later\"\n",
+			},
+		},
+		"html": {
+			format: ExportFormatHTML,
+			expectedContains: []string{
+				"<!DOCTYPE NETSCAPE-Bookmark-file-1>",
+				`<DT><A HREF="https://myhost.com/_redirect/pl/ID1" ADD_DATE="5" LAST_MODIFIED="6" TAGS="Docs,prod">first line</A>`,
+				"<DD>check, &#34;later&#34;\n",
+				">deploy &lt;v2&gt;</A>",
+			},
+		},
+		"unknown format": {
+			format:  "xml",
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+			bmarks := getExportTestBookmarks(t, mockPluginAPI)
+
+			data, err := bmarks.Export(tt.format)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			for _, expected := range tt.expectedContains {
+				assert.Contains(t, string(data), expected)
+			}
+			assert.NotContains(t, string(data), "secret\n")
+		})
+	}
+}
+
+func TestGetExportFileName(t *testing.T) {
+	now := time.Date(2026, 10, 17, 23, 0, 0, 0, time.FixedZone("UTC-5", -5*60*60))
+	assert.Equal(t, "bookmarks-2026-10-18.csv", GetExportFileName(ExportFormatCSV, now))
+	assert.True(t, strings.HasSuffix(GetExportFileName(ExportFormatHTML, now), ".html"))
+}
+
+func TestEscapeCSVFormula(t *testing.T) {
+	tests := map[string]string{
+		"":                       "",
+		"plain title":            "plain title",
+		"=HYPERLINK(\"x\")":      "'=HYPERLINK(\"x\")",
+		"+1 for this":            "'+1 for this",
+		"-2 days left":           "'-2 days left",
+		"@channel please review": "'@channel please review",
+		"a = b":                  "a = b",
+	}
+	for cell, expected := range tests {
+		assert.Equal(t, expected, escapeCSVFormula(cell), cell)
+
+		// imported cells are read as they were exported
+		assert.Equal(t, cell, unescapeCSVFormula(expected), cell)
+	}
+}
+
+func TestEscapeMarkdownLinkText(t *testing.T) {
+	tests := map[string]string{
+		"plain title":             "plain title",
+		"see [docs](http://x)":    `see \[docs\](http://x\)`,
+		`ends with a backslash \`: `ends with a backslash \\`,
+	}
+	for text, expected := range tests {
+		assert.Equal(t, expected, escapeMarkdownLinkText(text), text)
+	}
+}
@@ -3,13 +3,27 @@ package bookmarks
 import (
 	"regexp"
 
-	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi"
+	"github.com/mattermost/mattermost-server/v5/model"
 )
 
 type Filters struct {
 	TitleText  string
 	LabelIDs   []string
 	LabelNames []string
+	Since      int64  // Bookmarks created at or after this time in milliseconds
+	Until      int64  // Bookmarks created at or before this time in milliseconds
+	ChannelID  string // Bookmarks of posts in this channel
+	TeamID     string // Bookmarks of posts in a channel of this team
+	AuthorID   string // Bookmarks of posts written by this user
+	Query      *Query // Bookmarks with labels satisfying the query
+
+	IncludeArchived bool // Archived bookmarks are left out unless this is set
+	Unread          bool // Only unread bookmarks are kept if this is set
+}
+
+// hasPostFilters returns true if a filter requires the bookmarked post
+func (f *Filters) hasPostFilters() bool {
+	return f.ChannelID != "" || f.TeamID != "" || f.AuthorID != ""
 }
 
 // ApplyFilters will apply the available filters to an object of bookmarks
@@ -18,11 +32,66 @@ func (b *Bookmarks) ApplyFilters(filters *Filters) (*Bookmarks, error) {
 	bmarks := NewBookmarks(b.userID)
 	bmarks.api = b.api
 
+	if filters.hasPostFilters() {
+		if err := b.fetchPosts(b.getPostIDs()); err != nil {
+			return nil, err
+		}
+	}
+	// share the fetched posts and checked channels with the filtered bookmarks
+	if b.posts == nil {
+		b.posts = make(map[string]*model.Post)
+	}
+	if b.channelAccess == nil {
+		b.channelAccess = make(map[string]bool)
+	}
+	bmarks.posts = b.posts
+	bmarks.channelAccess = b.channelAccess
+
+	// cache the team of each channel so it is only fetched once
+	teamIDs := make(map[string]string)
+
+	// the labels are loaded once for all label filters
+	var labels *Labels
+	if filters.Query != nil || filters.LabelIDs != nil || len(filters.LabelNames) != 0 {
+		var err error
+		labels, err = b.getLabels()
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// iter through bookmarks
 	for _, bmark := range b.ByID {
-		filteredBmark := bmark.withLabelIDs(filters.LabelIDs)
-		filteredBmark = filteredBmark.withLabelNames(filters.LabelNames, b.api, b.userID)
+		filteredBmark := bmark.withLabelIDs(filters.LabelIDs, labels)
+		filteredBmark = filteredBmark.withLabelNames(filters.LabelNames, labels)
 		filteredBmark = filteredBmark.withTitleText(filters.TitleText)
+		filteredBmark = filteredBmark.withTimeRange(filters.Since, filters.Until)
+		filteredBmark = filteredBmark.withQuery(filters.Query, labels)
+		filteredBmark = filteredBmark.withArchived(filters.IncludeArchived)
+		filteredBmark = filteredBmark.withUnread(filters.Unread)
+
+		if filteredBmark != nil && filters.hasPostFilters() {
+			post, err := b.getPost(bmark.PostID)
+			if err != nil {
+				return nil, err
+			}
+			filteredBmark = filteredBmark.withChannelID(filters.ChannelID, post)
+			filteredBmark = filteredBmark.withAuthorID(filters.AuthorID, post)
+
+			if filteredBmark != nil && filters.TeamID != "" {
+				// deleted posts have no channel and match no team
+				teamID, ok := teamIDs[post.ChannelId]
+				if !ok && post.ChannelId != "" {
+					channel, err := b.api.GetChannel(post.ChannelId)
+					if err != nil {
+						return nil, err
+					}
+					teamID = channel.TeamId
+					teamIDs[post.ChannelId] = teamID
+				}
+				filteredBmark = filteredBmark.withTeamID(filters.TeamID, teamID)
+			}
+		}
 
 		if filteredBmark != nil {
 			// Do not save the bookmarks to the store. only hold in data structure
@@ -33,8 +102,9 @@ func (b *Bookmarks) ApplyFilters(filters *Filters) (*Bookmarks, error) {
 	return bmarks, nil
 }
 
-// withLabels returns a bookmark with given label IDs or nil
-func (bm *Bookmark) withLabelIDs(ids []string) *Bookmark {
+// withLabels returns a bookmark with given label IDs or nil. IDs of merged
+// labels are resolved to the label they were merged into
+func (bm *Bookmark) withLabelIDs(ids []string, labels *Labels) *Bookmark {
 	// return bookmark if no ids requested or bmark is nil
 	if ids == nil || bm == nil {
 		return bm
@@ -45,7 +115,7 @@ func (bm *Bookmark) withLabelIDs(ids []string) *Bookmark {
 		// iter through requested labelIDs
 		for _, id := range ids {
 			// return bookmark if has requested labelID
-			if labelID == id {
+			if labels.resolveID(labelID) == labels.resolveID(id) {
 				return bm
 			}
 		}
@@ -53,25 +123,25 @@ func (bm *Bookmark) withLabelIDs(ids []string) *Bookmark {
 	return nil
 }
 
-// withLabelNames returns a bookmark with given label names or nil
-func (bm *Bookmark) withLabelNames(names []string, api pluginapi.API, userID string) *Bookmark {
+// withLabelNames returns a bookmark with given label names, or labels nested
+// under them, or nil
+func (bm *Bookmark) withLabelNames(names []string, labels *Labels) *Bookmark {
 	// return bookmark if no names requested or bmark is nil
 	if len(names) == 0 || bm == nil {
 		return bm
 	}
 
-	labels, _ := NewLabelsWithUser(api, userID)
-	// if err != nil {
-	// 	return p.responsef(args, err.Error())
-	// }
-
 	// iter through bmark label ids
 	for _, labelID := range bm.GetLabelIDs() {
 		// iter through requested label names
 		for _, name := range names {
+			// an alias is resolved to the name of its label
+			if label := labels.GetLabelByName(name); label != nil {
+				name = label.Name
+			}
 			// return bookmark if has requested label name
 			n, _ := labels.GetNameFromID(labelID)
-			if n == name {
+			if getLabelKey(n) == getLabelKey(name) || isLabelDescendant(n, name) {
 				return bm
 			}
 		}
@@ -95,3 +165,107 @@ func (bm *Bookmark) withTitleText(text string) *Bookmark {
 
 	return nil
 }
+
+// withTimeRange returns a bookmark created within the time range or nil. A
+// zero since or until leaves that end of the range open. The time of the
+// bookmarked post is not used, so no post is fetched
+func (bm *Bookmark) withTimeRange(since, until int64) *Bookmark {
+	if (since == 0 && until == 0) || bm == nil {
+		return bm
+	}
+
+	// bookmarks saved before creation times were recorded only have a
+	// modified time
+	createAt := bm.CreateAt
+	if createAt == 0 {
+		createAt = bm.ModifiedAt
+	}
+
+	if since != 0 && createAt < since {
+		return nil
+	}
+	if until != 0 && createAt > until {
+		return nil
+	}
+	return bm
+}
+
+// withChannelID returns a bookmark of a post in the given channel or nil
+func (bm *Bookmark) withChannelID(channelID string, post *model.Post) *Bookmark {
+	if channelID == "" || bm == nil {
+		return bm
+	}
+	if post.ChannelId == channelID {
+		return bm
+	}
+	return nil
+}
+
+// withAuthorID returns a bookmark of a post written by the given user or nil
+func (bm *Bookmark) withAuthorID(userID string, post *model.Post) *Bookmark {
+	if userID == "" || bm == nil {
+		return bm
+	}
+	if post.UserId == userID {
+		return bm
+	}
+	return nil
+}
+
+// withTeamID returns a bookmark of a post in a channel of the given team or nil
+func (bm *Bookmark) withTeamID(teamID, postTeamID string) *Bookmark {
+	if teamID == "" || bm == nil {
+		return bm
+	}
+	if postTeamID == teamID {
+		return bm
+	}
+	return nil
+}
+
+// withQuery returns a bookmark whose labels satisfy the query or nil
+func (bm *Bookmark) withQuery(query *Query, labels *Labels) *Bookmark {
+	if query == nil || bm == nil {
+		return bm
+	}
+
+	var names []string
+	for _, id := range bm.GetLabelIDs() {
+		label, ok := labels.ByID[labels.resolveID(id)]
+		if !ok {
+			continue
+		}
+		// a label term may be written with an alias of the label
+		names = append(names, label.Name)
+		names = append(names, label.Aliases...)
+	}
+
+	if query.Match(names) {
+		return bm
+	}
+	return nil
+}
+
+// withArchived returns a bookmark that is not archived, or any bookmark if
+// archived bookmarks are included, or nil
+func (bm *Bookmark) withArchived(includeArchived bool) *Bookmark {
+	if includeArchived || bm == nil {
+		return bm
+	}
+	if bm.IsArchived() {
+		return nil
+	}
+	return bm
+}
+
+// withUnread returns a bookmark that is unread, or any bookmark if unread is
+// not set, or nil
+func (bm *Bookmark) withUnread(unread bool) *Bookmark {
+	if !unread || bm == nil {
+		return bm
+	}
+	if bm.GetStatus() != StatusUnread {
+		return nil
+	}
+	return bm
+}
@@ -6,24 +6,28 @@ import (
 
 	gomock "github.com/golang/mock/gomock"
 	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+	"github.com/mattermost/mattermost-server/v5/model"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestApplyFilters(t *testing.T) {
 	// create some test bookmarks
 	b1 := &Bookmark{
-		PostID: "postID1",
-		Title:  "This is my first title",
+		PostID:   "postID1",
+		Title:    "This is my first title",
+		CreateAt: 1000,
 	}
 	b2 := &Bookmark{
 		PostID:   "postID2",
 		LabelIDs: []string{"LID1", "LID2"},
 		Title:    "This is my second title",
+		CreateAt: 2000,
 	}
 	b3 := &Bookmark{
-		PostID:   "postID3",
-		LabelIDs: []string{"LID1", "LID2", "LID3"},
-		Title:    "This is my third title",
+		PostID:     "postID3",
+		LabelIDs:   []string{"LID1", "LID2", "LID3"},
+		Title:      "This is my third title",
+		ModifiedAt: 3000,
 	}
 
 	// User1 has no bookmarks
@@ -42,6 +46,8 @@ func TestApplyFilters(t *testing.T) {
 		bmarks           *Bookmarks
 		titleText        string
 		labelIDs         []string
+		since            int64
+		until            int64
 		expectedBmarkIDs []string
 	}{
 		{
@@ -137,6 +143,32 @@ func TestApplyFilters(t *testing.T) {
 			bmarks:           bmarksU2,
 			expectedBmarkIDs: nil,
 		},
+		{
+			name:             "TIME has bmarks  since requested",
+			since:            2000,
+			bmarks:           bmarksU2,
+			expectedBmarkIDs: []string{"postID2", "postID3"},
+		},
+		{
+			name:             "TIME has bmarks  until requested",
+			until:            2000,
+			bmarks:           bmarksU2,
+			expectedBmarkIDs: []string{"postID1", "postID2"},
+		},
+		{
+			name:             "TIME has bmarks  since and until requested",
+			since:            1500,
+			until:            2500,
+			bmarks:           bmarksU2,
+			expectedBmarkIDs: []string{"postID2"},
+		},
+		{
+			name:             "TIME_LABELS has bmarks  since and label requested",
+			since:            1500,
+			labelIDs:         []string{"LID3"},
+			bmarks:           bmarksU2,
+			expectedBmarkIDs: []string{"postID3"},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -146,10 +178,13 @@ func TestApplyFilters(t *testing.T) {
 
 			bmarks := tt.bmarks
 			bmarks.api = mockPluginAPI
+			mockPluginAPI.EXPECT().KVGet(GetLabelsKey(bmarks.userID)).Return(nil, nil).AnyTimes()
 
 			filters := &Filters{
 				TitleText: tt.titleText,
 				LabelIDs:  tt.labelIDs,
+				Since:     tt.since,
+				Until:     tt.until,
 			}
 
 			bmarks, err := bmarks.ApplyFilters(filters)
@@ -167,3 +202,101 @@ func TestApplyFilters(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyFilters_mergedLabels(t *testing.T) {
+	labels := &Labels{
+		ByID: map[string]*Label{
+			"LID1": {Name: "label1", ID: "LID1"},
+		},
+		// LID2 was merged into LID1
+		Redirects: map[string]string{"LID2": "LID1"},
+	}
+
+	tests := map[string]struct {
+		filters          *Filters
+		expectedBmarkIDs []string
+	}{
+		"label ID of the merged label": {
+			filters:          &Filters{LabelIDs: []string{"LID1"}},
+			expectedBmarkIDs: []string{"ID1", "ID2"},
+		},
+		"label ID of a label merged into another": {
+			filters:          &Filters{LabelIDs: []string{"LID2"}},
+			expectedBmarkIDs: []string{"ID1", "ID2"},
+		},
+		"label name": {
+			filters:          &Filters{LabelNames: []string{"label1"}},
+			expectedBmarkIDs: []string{"ID1", "ID2"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+			mockPluginAPI.EXPECT().KVGet(GetLabelsKey(UserID)).Return(mustEncodeDocument(t, labels), nil)
+
+			bmarks := NewBookmarks(UserID)
+			bmarks.api = mockPluginAPI
+			bmarks.ByID["ID1"] = &Bookmark{PostID: "ID1", LabelIDs: []string{"LID1"}}
+			bmarks.ByID["ID2"] = &Bookmark{PostID: "ID2", LabelIDs: []string{"LID2"}}
+			bmarks.ByID["ID3"] = &Bookmark{PostID: "ID3"}
+
+			filtered, err := bmarks.ApplyFilters(tt.filters)
+			assert.Nil(t, err)
+
+			var ids []string
+			for id := range filtered.ByID {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			assert.Equal(t, tt.expectedBmarkIDs, ids)
+		})
+	}
+}
+
+func TestApplyFilters_labelsNotLoaded(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	mockPluginAPI.EXPECT().KVGet(GetLabelsKey(UserID)).Return(nil, &model.AppError{Message: "KV store unavailable"})
+
+	bmarks := NewBookmarks(UserID)
+	bmarks.api = mockPluginAPI
+	bmarks.ByID["ID1"] = &Bookmark{PostID: "ID1", LabelIDs: []string{"LID1"}}
+
+	_, err := bmarks.ApplyFilters(&Filters{LabelNames: []string{"label1"}})
+	assert.Contains(t, err.Error(), "KV store unavailable")
+}
+
+func TestApplyFilters_archived(t *testing.T) {
+	bmarks := NewBookmarks(UserID)
+	bmarks.ByID["ID1"] = &Bookmark{PostID: "ID1", Status: StatusUnread}
+	bmarks.ByID["ID2"] = &Bookmark{PostID: "ID2", Status: StatusRead}
+	bmarks.ByID["ID3"] = &Bookmark{PostID: "ID3", Status: StatusArchived}
+
+	// bookmarks saved before statuses were recorded are read
+	bmarks.ByID["ID4"] = &Bookmark{PostID: "ID4"}
+
+	tests := map[string]struct {
+		filters     *Filters
+		expectedIDs []string
+	}{
+		"archived bookmarks hidden":   {filters: &Filters{}, expectedIDs: []string{"ID1", "ID2", "ID4"}},
+		"archived bookmarks included": {filters: &Filters{IncludeArchived: true}, expectedIDs: []string{"ID1", "ID2", "ID3", "ID4"}},
+		"unread bookmarks":            {filters: &Filters{Unread: true, IncludeArchived: true}, expectedIDs: []string{"ID1"}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			filtered, err := bmarks.ApplyFilters(tt.filters)
+			assert.Nil(t, err)
+
+			var ids []string
+			for id := range filtered.ByID {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			assert.Equal(t, tt.expectedIDs, ids)
+		})
+	}
+}
@@ -0,0 +1,335 @@
+package bookmarks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+// StoreFlaggedPostsKey is the key used to store the flagged posts of a user
+// at the last sync of the bookmarks with the flagged posts
+const StoreFlaggedPostsKey = "flagged_posts"
+
+func GetFlaggedPostsKey(userID string) string {
+	return fmt.Sprintf("%s_%s", StoreFlaggedPostsKey, userID)
+}
+
+// FlagSyncResult reports what a sync with the flagged posts changed
+type FlagSyncResult struct {
+	Flagged int // posts flagged because they were bookmarked
+	Added   int // bookmarks added because their posts were flagged
+	Removed int // bookmarks removed because their posts were unflagged
+}
+
+// getFlaggedPostIDs returns the IDs of the posts a user flagged in
+// Mattermost
+func getFlaggedPostIDs(api pluginapi.API, userID string) ([]string, error) {
+	preferences, err := api.GetPreferencesForUser(userID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to get the flagged posts of user %s", userID)
+	}
+
+	var postIDs []string
+	for _, preference := range preferences {
+		if preference.Category == model.PREFERENCE_CATEGORY_FLAGGED_POST && preference.Value == "true" {
+			postIDs = append(postIDs, preference.Name)
+		}
+	}
+	return postIDs, nil
+}
+
+// flagPosts flags or unflags posts for a user in Mattermost
+func flagPosts(api pluginapi.API, userID string, postIDs []string, flagged bool) error {
+	if len(postIDs) == 0 {
+		return nil
+	}
+
+	preferences := make([]model.Preference, 0, len(postIDs))
+	for _, postID := range postIDs {
+		preferences = append(preferences, model.Preference{
+			UserId:   userID,
+			Category: model.PREFERENCE_CATEGORY_FLAGGED_POST,
+			Name:     postID,
+			Value:    "true",
+		})
+	}
+
+	if flagged {
+		if err := api.UpdatePreferencesForUser(userID, preferences); err != nil {
+			return errors.Wrapf(err, "Unable to flag posts for user %s", userID)
+		}
+		return nil
+	}
+	if err := api.DeletePreferencesForUser(userID, preferences); err != nil {
+		return errors.Wrapf(err, "Unable to unflag posts for user %s", userID)
+	}
+	return nil
+}
+
+// getSyncedFlaggedPostIDs returns the posts that were flagged at the last sync
+func (b *Bookmarks) getSyncedFlaggedPostIDs() (map[string]bool, error) {
+	synced, _, err := b.loadSyncedFlaggedPostIDs()
+	return synced, err
+}
+
+// loadSyncedFlaggedPostIDs returns the posts that were flagged at the last
+// sync and the stored value they were read from
+func (b *Bookmarks) loadSyncedFlaggedPostIDs() (map[string]bool, []byte, error) {
+	bb, appErr := b.api.KVGet(GetFlaggedPostsKey(b.userID))
+	if appErr != nil {
+		return nil, nil, errors.Wrapf(appErr, "Unable to get the synced flagged posts of user %s", b.userID)
+	}
+
+	var postIDs []string
+	if len(bb) != 0 {
+		if err := json.Unmarshal(bb, &postIDs); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	synced := make(map[string]bool, len(postIDs))
+	for _, postID := range postIDs {
+		synced[postID] = true
+	}
+	return synced, bb, nil
+}
+
+// storeSyncedFlaggedPostIDs applies mutate to the posts that were flagged at
+// the last sync and stores them. If another request changed them since they
+// were loaded, they are reloaded and mutate is applied again
+func (b *Bookmarks) storeSyncedFlaggedPostIDs(mutate func(synced map[string]bool)) error {
+	for i := 0; i < maxStoreAttempts; i++ {
+		synced, stored, err := b.loadSyncedFlaggedPostIDs()
+		if err != nil {
+			return err
+		}
+		mutate(synced)
+
+		postIDs := make([]string, 0, len(synced))
+		for postID := range synced {
+			postIDs = append(postIDs, postID)
+		}
+		sort.Strings(postIDs)
+
+		bb, err := json.Marshal(postIDs)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(bb, stored) {
+			return nil
+		}
+
+		ok, appErr := b.api.KVCompareAndSet(GetFlaggedPostsKey(b.userID), stored, bb)
+		if appErr != nil {
+			return appErr
+		}
+		if ok {
+			return nil
+		}
+	}
+
+	return errors.New(fmt.Sprintf("Unable to store the synced flagged posts for user %s. They were changed by another request %d times", b.userID, maxStoreAttempts))
+}
+
+// syncFlags flags or unflags the posts of bookmarks that were added or
+// removed, if the user syncs bookmarks with flagged posts
+func (b *Bookmarks) syncFlags(postIDs []string, flagged bool) error {
+	settings, err := NewSettingsWithUser(b.api, b.userID)
+	if err != nil {
+		return err
+	}
+	if !settings.SyncFlagged || len(postIDs) == 0 {
+		return nil
+	}
+
+	if err = flagPosts(b.api, b.userID, postIDs, flagged); err != nil {
+		return err
+	}
+
+	// the next sync must not take the change for one made in Mattermost
+	return b.storeSyncedFlaggedPostIDs(func(synced map[string]bool) {
+		for _, postID := range postIDs {
+			if flagged {
+				synced[postID] = true
+			} else {
+				delete(synced, postID)
+			}
+		}
+	})
+}
+
+// SyncFlaggedPosts applies the posts flagged and unflagged in Mattermost
+// since the last sync to the bookmarks. Flagged posts are bookmarked, and the
+// bookmarks of unflagged posts are removed
+func (b *Bookmarks) SyncFlaggedPosts() (*FlagSyncResult, error) {
+	flagged, err := getFlaggedPostIDs(b.api, b.userID)
+	if err != nil {
+		return nil, err
+	}
+	synced, err := b.getSyncedFlaggedPostIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	current := make(map[string]bool, len(flagged))
+	export := &Export{Version: ExportVersion}
+	var newlyFlagged []string
+	for _, postID := range flagged {
+		current[postID] = true
+		if !synced[postID] {
+			export.Bookmarks = append(export.Bookmarks, &ExportedBookmark{PostID: postID})
+			newlyFlagged = append(newlyFlagged, postID)
+		}
+	}
+	var unflagged []string
+	for postID := range synced {
+		if !current[postID] {
+			unflagged = append(unflagged, postID)
+		}
+	}
+
+	result := &FlagSyncResult{}
+	if result.Added, err = b.importFlaggedPosts(export); err != nil {
+		return nil, err
+	}
+	if result.Removed, err = b.deleteBookmarks(unflagged); err != nil {
+		return nil, err
+	}
+
+	// only the changes found are applied, so changes stored by another
+	// request since the synced posts were read are kept
+	err = b.storeSyncedFlaggedPostIDs(func(synced map[string]bool) {
+		for _, postID := range newlyFlagged {
+			synced[postID] = true
+		}
+		for _, postID := range unflagged {
+			delete(synced, postID)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SyncFlaggedPostsWithUser applies the posts flagged and unflagged in
+// Mattermost to the bookmarks of a user who syncs them. Nothing is changed
+// for a user who does not sync them
+func SyncFlaggedPostsWithUser(api pluginapi.API, userID string) (*FlagSyncResult, error) {
+	settings, err := NewSettingsWithUser(api, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !settings.SyncFlagged {
+		return &FlagSyncResult{}, nil
+	}
+
+	bmarks, err := NewBookmarksWithUser(api, userID)
+	if err != nil {
+		return nil, err
+	}
+	return bmarks.SyncFlaggedPosts()
+}
+
+// EnableFlaggedSync starts syncing the bookmarks of the user with the flagged
+// posts. Bookmarked posts are flagged and flagged posts are bookmarked first
+func (b *Bookmarks) EnableFlaggedSync() (*FlagSyncResult, error) {
+	flagged, err := getFlaggedPostIDs(b.api, b.userID)
+	if err != nil {
+		return nil, err
+	}
+
+	synced := make(map[string]bool)
+	export := &Export{Version: ExportVersion}
+	for _, postID := range flagged {
+		synced[postID] = true
+		if _, ok := b.exists(postID); !ok {
+			export.Bookmarks = append(export.Bookmarks, &ExportedBookmark{PostID: postID})
+		}
+	}
+	var unflagged []string
+	for _, postID := range b.getPostIDs() {
+		if !synced[postID] {
+			unflagged = append(unflagged, postID)
+			synced[postID] = true
+		}
+	}
+
+	result := &FlagSyncResult{Flagged: len(unflagged)}
+	if err = flagPosts(b.api, b.userID, unflagged, true); err != nil {
+		return nil, err
+	}
+	if result.Added, err = b.importFlaggedPosts(export); err != nil {
+		return nil, err
+	}
+
+	// turning the sync on replaces the posts of an earlier sync
+	err = b.storeSyncedFlaggedPostIDs(func(stored map[string]bool) {
+		for postID := range stored {
+			delete(stored, postID)
+		}
+		for postID := range synced {
+			stored[postID] = true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	settings, err := NewSettingsWithUser(b.api, b.userID)
+	if err != nil {
+		return nil, err
+	}
+	err = settings.StoreSettings(func(settings *Settings) error {
+		settings.SyncFlagged = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DisableFlaggedSync stops syncing the bookmarks of the user with the flagged
+// posts. Bookmarks and flagged posts are kept
+func DisableFlaggedSync(api pluginapi.API, userID string) error {
+	settings, err := NewSettingsWithUser(api, userID)
+	if err != nil {
+		return err
+	}
+	err = settings.StoreSettings(func(settings *Settings) error {
+		settings.SyncFlagged = false
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if appErr := api.KVDelete(GetFlaggedPostsKey(userID)); appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+// importFlaggedPosts bookmarks flagged posts that are not bookmarked. The
+// posts are already flagged, so they are not flagged again. It returns the
+// number of bookmarks added
+func (b *Bookmarks) importFlaggedPosts(export *Export) (int, error) {
+	if len(export.Bookmarks) == 0 {
+		return 0, nil
+	}
+
+	labels, err := NewLabelsWithUser(b.api, b.userID)
+	if err != nil {
+		return 0, err
+	}
+	result, _, err := b.importBookmarks(labels, export)
+	if err != nil {
+		return 0, err
+	}
+	return result.Added, nil
+}
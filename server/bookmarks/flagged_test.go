@@ -0,0 +1,251 @@
+package bookmarks
+
+import (
+	"encoding/json"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockFlaggedPosts backs the flagged posts of the user with an in-memory set
+func mockFlaggedPosts(api *mock_pluginapi.MockAPI, postIDs ...string) map[string]bool {
+	flagged := make(map[string]bool)
+	for _, postID := range postIDs {
+		flagged[postID] = true
+	}
+
+	api.EXPECT().GetPreferencesForUser(UserID).DoAndReturn(func(userID string) ([]model.Preference, error) {
+		var preferences []model.Preference
+		for postID := range flagged {
+			preferences = append(preferences, model.Preference{UserId: userID, Category: model.PREFERENCE_CATEGORY_FLAGGED_POST, Name: postID, Value: "true"})
+		}
+		return preferences, nil
+	}).AnyTimes()
+	api.EXPECT().UpdatePreferencesForUser(UserID, gomock.Any()).DoAndReturn(func(userID string, preferences []model.Preference) error {
+		for _, preference := range preferences {
+			flagged[preference.Name] = true
+		}
+		return nil
+	}).AnyTimes()
+	api.EXPECT().DeletePreferencesForUser(UserID, gomock.Any()).DoAndReturn(func(userID string, preferences []model.Preference) error {
+		for _, preference := range preferences {
+			delete(flagged, preference.Name)
+		}
+		return nil
+	}).AnyTimes()
+	api.EXPECT().KVDelete(gomock.Any()).Return(nil).AnyTimes()
+	return flagged
+}
+
+func TestFlaggedSync(t *testing.T) {
+	tests := map[string]struct {
+		syncFlagged      bool
+		unflagged        []string // posts unflagged in Mattermost since the last sync
+		apply            func(t *testing.T, bmarks *Bookmarks)
+		expectedFlagged  []string
+		expectedBookmark []string
+	}{
+		"adding a bookmark without sync does not flag the post": {
+			apply: func(t *testing.T, bmarks *Bookmarks) {
+				assert.Nil(t, bmarks.AddBookmark(&Bookmark{PostID: "ID3"}))
+			},
+			expectedFlagged:  []string{"ID2"},
+			expectedBookmark: []string{"ID1", "ID3"},
+		},
+		"adding a bookmark flags the post": {
+			syncFlagged: true,
+			apply: func(t *testing.T, bmarks *Bookmarks) {
+				assert.Nil(t, bmarks.AddBookmark(&Bookmark{PostID: "ID3"}))
+			},
+			expectedFlagged:  []string{"ID1", "ID2", "ID3"},
+			expectedBookmark: []string{"ID1", "ID3"},
+		},
+		"updating a bookmark does not flag the post again": {
+			syncFlagged: true,
+			unflagged:   []string{"ID1"},
+			apply: func(t *testing.T, bmarks *Bookmarks) {
+				assert.Nil(t, bmarks.AddBookmark(&Bookmark{PostID: "ID1", Title: "follow up"}))
+			},
+			expectedFlagged:  []string{"ID2"},
+			expectedBookmark: []string{"ID1"},
+		},
+		"importing bookmarks flags the posts of the added bookmarks": {
+			syncFlagged: true,
+			unflagged:   []string{"ID1"},
+			apply: func(t *testing.T, bmarks *Bookmarks) {
+				labels, err := NewLabelsWithUser(bmarks.api, UserID)
+				assert.Nil(t, err)
+				result, err := bmarks.Import(labels, &Export{Version: ExportVersion, Bookmarks: []*ExportedBookmark{
+					{PostID: "ID1", Title: "follow up"},
+					{PostID: "ID3"},
+				}})
+				assert.Nil(t, err)
+				assert.Equal(t, 1, result.Added)
+			},
+			expectedFlagged:  []string{"ID2", "ID3"},
+			expectedBookmark: []string{"ID1", "ID3"},
+		},
+		"removing a bookmark unflags the post": {
+			syncFlagged: true,
+			apply: func(t *testing.T, bmarks *Bookmarks) {
+				assert.Nil(t, bmarks.DeleteBookmark("ID1"))
+			},
+			expectedFlagged:  []string{"ID2"},
+			expectedBookmark: []string{},
+		},
+		"removing bookmarks in bulk unflags the posts": {
+			syncFlagged: true,
+			apply: func(t *testing.T, bmarks *Bookmarks) {
+				removed, err := bmarks.DeleteBookmarks([]string{"ID1"})
+				assert.Nil(t, err)
+				assert.Equal(t, 1, removed)
+			},
+			expectedFlagged:  []string{"ID2"},
+			expectedBookmark: []string{},
+		},
+		"enabling sync flags bookmarked posts and bookmarks flagged posts": {
+			apply: func(t *testing.T, bmarks *Bookmarks) {
+				result, err := bmarks.EnableFlaggedSync()
+				assert.Nil(t, err)
+				assert.Equal(t, &FlagSyncResult{Flagged: 1, Added: 1}, result)
+			},
+			expectedFlagged:  []string{"ID1", "ID2"},
+			expectedBookmark: []string{"ID1", "ID2"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+			kv := mockKVStore(mockPluginAPI)
+			mockPostContent(mockPluginAPI)
+			mockPluginAPI.EXPECT().GetPostsByIds(gomock.Any()).DoAndReturn(func(postIDs []string) (map[string]*model.Post, error) {
+				posts := make(map[string]*model.Post)
+				for _, id := range postIDs {
+					posts[id] = &model.Post{Id: id, Message: "message " + id, UserId: "authorID", ChannelId: "channelID"}
+				}
+				return posts, nil
+			}).AnyTimes()
+			flagged := mockFlaggedPosts(mockPluginAPI, "ID2")
+
+			// ID1 is bookmarked and ID2 is flagged
+			storeTestBookmarks(t, kv, UserID, &Bookmark{PostID: "ID1"})
+			if tt.syncFlagged {
+				flagged["ID1"] = true
+				kv[GetSettingsKey(UserID)] = []byte(`{"sync_flagged":true}`)
+				kv[GetFlaggedPostsKey(UserID)] = []byte(`["ID1","ID2"]`)
+			}
+			for _, postID := range tt.unflagged {
+				delete(flagged, postID)
+			}
+
+			bmarks, err := NewBookmarksWithUser(mockPluginAPI, UserID)
+			assert.Nil(t, err)
+			tt.apply(t, bmarks)
+
+			var actualFlagged []string
+			for postID := range flagged {
+				actualFlagged = append(actualFlagged, postID)
+			}
+			assert.ElementsMatch(t, tt.expectedFlagged, actualFlagged)
+
+			bmarks, err = NewBookmarksWithUser(mockPluginAPI, UserID)
+			assert.Nil(t, err)
+			assert.ElementsMatch(t, tt.expectedBookmark, bmarks.getPostIDs())
+
+			// the synced posts are the flagged posts after the change, and the
+			// posts unflagged in Mattermost until the next sync
+			settings, err := NewSettingsWithUser(mockPluginAPI, UserID)
+			assert.Nil(t, err)
+			if settings.SyncFlagged {
+				var synced []string
+				assert.Nil(t, json.Unmarshal(kv[GetFlaggedPostsKey(UserID)], &synced))
+				expectedSynced := append([]string{}, tt.expectedFlagged...)
+				assert.ElementsMatch(t, append(expectedSynced, tt.unflagged...), synced)
+			}
+		})
+	}
+}
+
+func TestSyncFlaggedPosts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	kv := mockKVStore(mockPluginAPI)
+	mockPostContent(mockPluginAPI)
+	mockPluginAPI.EXPECT().GetPostsByIds(gomock.Any()).DoAndReturn(func(postIDs []string) (map[string]*model.Post, error) {
+		posts := make(map[string]*model.Post)
+		for _, id := range postIDs {
+			posts[id] = &model.Post{Id: id, Message: "message " + id, UserId: "authorID", ChannelId: "channelID"}
+		}
+		return posts, nil
+	}).AnyTimes()
+
+	// ID1 was unflagged, ID3 was flagged and ID4 was bookmarked before the
+	// sync was turned on
+	mockFlaggedPosts(mockPluginAPI, "ID2", "ID3")
+	kv[GetSettingsKey(UserID)] = []byte(`{"sync_flagged":true}`)
+	kv[GetFlaggedPostsKey(UserID)] = []byte(`["ID1","ID2"]`)
+	storeTestBookmarks(t, kv, UserID, &Bookmark{PostID: "ID1"}, &Bookmark{PostID: "ID2"}, &Bookmark{PostID: "ID4"})
+
+	bmarks, err := NewBookmarksWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	result, err := bmarks.SyncFlaggedPosts()
+	assert.Nil(t, err)
+	assert.Equal(t, &FlagSyncResult{Added: 1, Removed: 1}, result)
+
+	bmarks, err = NewBookmarksWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"ID2", "ID3", "ID4"}, bmarks.getPostIDs())
+	assert.Equal(t, `["ID2","ID3"]`, string(kv[GetFlaggedPostsKey(UserID)]))
+
+	// a second sync has nothing left to change
+	result, err = bmarks.SyncFlaggedPosts()
+	assert.Nil(t, err)
+	assert.Equal(t, &FlagSyncResult{}, result)
+}
+
+func TestStoreSyncedFlaggedPostIDs(t *testing.T) {
+	addID3 := func(synced map[string]bool) {
+		synced["ID3"] = true
+	}
+
+	t.Run("another request changed the synced posts", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+		key := GetFlaggedPostsKey(UserID)
+
+		// ID2 is stored between the first read and the compare-and-set, so
+		// the change is applied again to the stored posts
+		gomock.InOrder(
+			mockPluginAPI.EXPECT().KVGet(key).Return([]byte(`["ID1"]`), nil),
+			mockPluginAPI.EXPECT().KVCompareAndSet(key, []byte(`["ID1"]`), []byte(`["ID1","ID3"]`)).Return(false, nil),
+			mockPluginAPI.EXPECT().KVGet(key).Return([]byte(`["ID1","ID2"]`), nil),
+			mockPluginAPI.EXPECT().KVCompareAndSet(key, []byte(`["ID1","ID2"]`), []byte(`["ID1","ID2","ID3"]`)).Return(true, nil),
+		)
+
+		b := NewBookmarks(UserID)
+		b.api = mockPluginAPI
+		assert.Nil(t, b.storeSyncedFlaggedPostIDs(addID3))
+	})
+
+	t.Run("the synced posts keep changing", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+		key := GetFlaggedPostsKey(UserID)
+
+		mockPluginAPI.EXPECT().KVGet(key).Return(nil, nil).Times(maxStoreAttempts)
+		mockPluginAPI.EXPECT().KVCompareAndSet(key, nil, []byte(`["ID3"]`)).Return(false, nil).Times(maxStoreAttempts)
+
+		b := NewBookmarks(UserID)
+		b.api = mockPluginAPI
+		err := b.storeSyncedFlaggedPostIDs(addID3)
+		assert.EqualError(t, err, "Unable to store the synced flagged posts for user UserID. They were changed by another request 5 times")
+	})
+}
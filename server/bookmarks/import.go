@@ -0,0 +1,411 @@
+package bookmarks
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi"
+	"github.com/pkg/errors"
+)
+
+// ImportedLabelName is the label of bookmarks imported from flagged posts
+const ImportedLabelName = "imported"
+
+// ImportResult reports what an import changed
+type ImportResult struct {
+	Added     int // bookmarks of posts that were not bookmarked
+	Merged    int // existing bookmarks that got labels, a title or a note
+	Unchanged int // existing bookmarks that already had everything imported
+
+	// Conflicts are the post IDs of existing bookmarks with a title or note
+	// that differs from the imported one. The existing title and note are kept
+	Conflicts []string
+
+	// UnknownPostIDs are the post IDs of posts that do not exist or that the
+	// user cannot read
+	UnknownPostIDs []string
+
+	// Invalid are the imported rows that were skipped, with the reason
+	Invalid []string
+}
+
+// ParseImport returns the bookmarks and labels of a file in the JSON export
+// format or in CSV. CSV files need a post_id or a permalink column, the other
+// columns of the CSV export are optional
+func ParseImport(format string, data []byte) (*Export, error) {
+	switch format {
+	case ExportFormatJSON:
+		var export Export
+		if err := json.Unmarshal(data, &export); err != nil {
+			return nil, errors.Wrap(err, "Unable to parse the JSON file")
+		}
+		if export.Version > ExportVersion {
+			return nil, errors.New(fmt.Sprintf("Export version %d is not supported. Update the plugin to import it", export.Version))
+		}
+		return &export, nil
+	case ExportFormatCSV:
+		return parseImportCSV(data)
+	}
+	return nil, errors.New(fmt.Sprintf("Import format `%s` is not supported. Use one of json or csv", format))
+}
+
+// parseImportCSV returns the bookmarks of a CSV file with a header row
+func parseImportCSV(data []byte) (*Export, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	header, err := r.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to read the header of the CSV file")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	_, hasPostID := columns["post_id"]
+	_, hasPermalink := columns["permalink"]
+	if !hasPostID && !hasPermalink {
+		return nil, errors.New("The CSV file needs a `post_id` or a `permalink` column")
+	}
+
+	export := &Export{Version: ExportVersion}
+	// the header is the first row
+	for row := 2; ; row++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "Unable to parse the CSV file")
+		}
+
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(unescapeCSVFormula(record[i]))
+		}
+
+		if isEmptyRecord(record) {
+			continue
+		}
+
+		postID := field("post_id")
+		if postID == "" {
+			postID = getPostIDFromPermalink(field("permalink"))
+		}
+		if postID == "" {
+			export.Invalid = append(export.Invalid, fmt.Sprintf("Row %d has no post ID or permalink", row))
+			continue
+		}
+
+		bmark := &ExportedBookmark{
+			PostID: postID,
+			Title:  field("title"),
+			Note:   field("note"),
+		}
+		for _, name := range strings.Split(field("labels"), ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				bmark.Labels = append(bmark.Labels, name)
+			}
+		}
+		if bmark.CreateAt, err = parseImportTime(field("bookmarked_at")); err != nil {
+			export.Invalid = append(export.Invalid, fmt.Sprintf("Row %d: %s", row, err.Error()))
+			continue
+		}
+		export.Bookmarks = append(export.Bookmarks, bmark)
+	}
+	return export, nil
+}
+
+// isEmptyRecord returns true if every field of a CSV record is blank
+func isEmptyRecord(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// getPostIDFromPermalink returns the post ID at the end of a permalink
+func getPostIDFromPermalink(permalink string) string {
+	permalink = strings.TrimRight(permalink, "/")
+	return permalink[strings.LastIndex(permalink, "/")+1:]
+}
+
+// parseImportTime returns an RFC 3339 time in milliseconds
+func parseImportTime(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("Time `%s` is not an RFC 3339 time", value))
+	}
+	return t.UnixNano() / int64(time.Millisecond), nil
+}
+
+// NewImportFromFlaggedPosts returns the posts the user flagged in Mattermost
+// as bookmarks with the imported label
+func NewImportFromFlaggedPosts(api pluginapi.API, userID string) (*Export, error) {
+	postIDs, err := getFlaggedPostIDs(api, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &Export{Version: ExportVersion}
+	for _, postID := range postIDs {
+		export.Bookmarks = append(export.Bookmarks, &ExportedBookmark{
+			PostID: postID,
+			Labels: []string{ImportedLabelName},
+		})
+	}
+	return export, nil
+}
+
+// Import merges imported bookmarks into the bookmarks of the user. Labels that
+// do not exist are created. Existing bookmarks get the imported labels, and
+// the imported title and note unless they already have one. The posts of the
+// added bookmarks are flagged if the user syncs bookmarks with flagged posts
+func (b *Bookmarks) Import(labels *Labels, export *Export) (*ImportResult, error) {
+	result, addedIDs, err := b.importBookmarks(labels, export)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = b.syncFlags(addedIDs, true); err != nil {
+		return nil, errors.Wrap(err, "failed to flag the posts of the imported bookmarks")
+	}
+	return result, nil
+}
+
+// importBookmarks merges imported bookmarks into the bookmarks of the user
+// without flagging their posts. It returns the IDs of the added bookmarks
+func (b *Bookmarks) importBookmarks(labels *Labels, export *Export) (*ImportResult, []string, error) {
+	result := &ImportResult{Invalid: append([]string{}, export.Invalid...)}
+	imported := make(map[string]*ExportedBookmark)
+	var postIDs []string
+	for _, bmark := range export.Bookmarks {
+		if err := bmark.validate(); err != nil {
+			result.Invalid = append(result.Invalid, err.Error())
+			continue
+		}
+		if _, ok := imported[bmark.PostID]; ok {
+			continue
+		}
+		imported[bmark.PostID] = bmark
+		postIDs = append(postIDs, bmark.PostID)
+	}
+	if err := b.fetchPosts(postIDs); err != nil {
+		return nil, nil, err
+	}
+
+	// new bookmarks are only added for posts the user can read
+	added := make(map[string]*Bookmark)
+	var importedIDs []string
+	for _, postID := range postIDs {
+		if _, ok := b.exists(postID); ok {
+			importedIDs = append(importedIDs, postID)
+			continue
+		}
+		post, err := b.getPost(postID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if isPostDeleted(post) || isPostHidden(post) {
+			result.UnknownPostIDs = append(result.UnknownPostIDs, postID)
+			continue
+		}
+
+		bmark := &Bookmark{PostID: postID, CreateAt: imported[postID].CreateAt}
+		if err = b.snapshotPost(bmark); err != nil {
+			return nil, nil, err
+		}
+		added[postID] = bmark
+		importedIDs = append(importedIDs, postID)
+	}
+	postIDs = importedIDs
+
+	// only the labels of the imported bookmarks are created
+	var names []string
+	for _, postID := range postIDs {
+		names = append(names, imported[postID].Labels...)
+	}
+	labelIDs, err := importLabels(labels, export.Labels, names)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var addedIDs []string
+	err = b.StoreBookmarks(func(bmarks *Bookmarks) error {
+		result.Added, result.Merged, result.Unchanged, result.Conflicts = 0, 0, 0, nil
+		addedIDs = nil
+		for _, postID := range postIDs {
+			ibmark := imported[postID]
+			ids := make([]string, 0, len(ibmark.Labels))
+			for _, name := range ibmark.Labels {
+				ids = append(ids, labelIDs[getLabelKey(name)])
+			}
+
+			bmark, ok := bmarks.exists(postID)
+			if !ok {
+				bmark, ok = added[postID]
+				if !ok {
+					continue
+				}
+				// a copy, so applying mutate again starts from the same bookmark
+				bmark = &Bookmark{PostID: postID, CreateAt: bmark.CreateAt, Snapshot: bmark.Snapshot}
+				bmark.SetTitle(ibmark.Title)
+				bmark.SetNote(ibmark.Note)
+				bmark.AddLabelIDs(ids)
+				bmarks.addBookmark(bmark)
+				bmarks.updateTimes(postID)
+				result.Added++
+				addedIDs = append(addedIDs, postID)
+				continue
+			}
+
+			changed, conflict := bmark.merge(ibmark, ids)
+			if conflict {
+				result.Conflicts = append(result.Conflicts, postID)
+			}
+			if !changed {
+				result.Unchanged++
+				continue
+			}
+			bmarks.updateTimes(postID)
+			result.Merged++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to import bookmarks")
+	}
+	return result, addedIDs, nil
+}
+
+// validate returns an error if an imported bookmark cannot be imported
+func (e *ExportedBookmark) validate() error {
+	if e.PostID == "" {
+		return errors.New("A bookmark has no post ID")
+	}
+	for _, name := range e.Labels {
+		if err := validateLabelName(normalizeLabelName(name)); err != nil {
+			return errors.New(fmt.Sprintf("Bookmark of post `%s`: %s", e.PostID, err.Error()))
+		}
+	}
+	return nil
+}
+
+// merge adds imported labels to a bookmark, and the imported title and note
+// if the bookmark has none. It returns whether the bookmark changed and
+// whether its title or note differs from the imported one
+func (bm *Bookmark) merge(imported *ExportedBookmark, labelIDs []string) (changed, conflict bool) {
+	ids := bm.GetLabelIDs()
+	for _, id := range labelIDs {
+		if !bm.hasLabelID(id) {
+			ids = append(ids, id)
+			changed = true
+		}
+	}
+	if changed {
+		bm.AddLabelIDs(ids)
+	}
+
+	switch {
+	case imported.Title == "" || imported.Title == bm.GetTitle():
+	case !bm.HasUserTitle():
+		bm.SetTitle(imported.Title)
+		changed = true
+	default:
+		conflict = true
+	}
+
+	switch {
+	case imported.Note == "" || imported.Note == bm.GetNote():
+	case !bm.HasNote():
+		bm.SetNote(imported.Note)
+		changed = true
+	default:
+		conflict = true
+	}
+	return changed, conflict
+}
+
+// importLabels creates the labels with the given names that do not exist,
+// with the color and description of the exported label. It returns the label
+// IDs by label key
+func importLabels(labels *Labels, exportedLabels []*Label, names []string) (map[string]string, error) {
+	exported := make(map[string]*Label)
+	for _, label := range exportedLabels {
+		exported[getLabelKey(label.Name)] = label
+	}
+
+	labelIDs := make(map[string]string)
+	for _, name := range names {
+		key := getLabelKey(name)
+		if _, ok := labelIDs[key]; ok {
+			continue
+		}
+
+		if id, ok := labels.getIDByName(name); ok {
+			labelIDs[key] = id
+			continue
+		}
+
+		label, err := labels.AddLabel(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to add label %s", name)
+		}
+		if e, ok := exported[key]; ok && (e.Color != "" || e.Description != "") {
+			err = labels.StoreLabels(func(labels *Labels) error {
+				if l, ok := labels.ByID[label.ID]; ok {
+					l.Color = e.Color
+					l.Description = e.Description
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, errors.Wrapf(err, "Unable to add label %s", name)
+			}
+		}
+		labelIDs[key] = label.ID
+	}
+	return labelIDs, nil
+}
+
+// GetText returns a summary of the import
+func (r *ImportResult) GetText() string {
+	text := fmt.Sprintf("Imported %d bookmarks. %d existing bookmarks were updated and %d were unchanged\n", r.Added, r.Merged, r.Unchanged)
+
+	if len(r.Conflicts) != 0 {
+		sort.Strings(r.Conflicts)
+		text += "\n#### Conflicts\nThese bookmarks already have a different title or note. The existing title and note were kept\n"
+		for _, postID := range r.Conflicts {
+			text += fmt.Sprintf("* `%s`\n", postID)
+		}
+	}
+
+	if len(r.UnknownPostIDs) != 0 {
+		sort.Strings(r.UnknownPostIDs)
+		text += "\n#### Unknown posts\nThese posts do not exist or you cannot read them. They were not imported\n"
+		for _, postID := range r.UnknownPostIDs {
+			text += fmt.Sprintf("* `%s`\n", postID)
+		}
+	}
+
+	if len(r.Invalid) != 0 {
+		text += "\n#### Skipped\nThese bookmarks are not valid. They were not imported\n"
+		for _, reason := range r.Invalid {
+			text += fmt.Sprintf("* %s\n", reason)
+		}
+	}
+	return text
+}
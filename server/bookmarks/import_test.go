@@ -0,0 +1,195 @@
+package bookmarks
+
+import (
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseImport(t *testing.T) {
+	tests := map[string]struct {
+		format   string
+		data     string
+		expected []*ExportedBookmark
+		labels   []*Label
+		invalid  []string
+		wantErr  bool
+	}{
+		"json export": {
+			format: ExportFormatJSON,
+			data: `{"version": 1, "bookmarks": [{"post_id": "ID1", "title": "deploy", "labels": ["prod"], "create_at": 5}],
+				"labels": [{"name": "prod", "color": "#e53935"}]}`,
+			expected: []*ExportedBookmark{{PostID: "ID1", Title: "deploy", Labels: []string{"prod"}, CreateAt: 5}},
+			labels:   []*Label{{Name: "prod", Color: "#e53935"}},
+		},
+		"json export of a newer version": {
+			format:  ExportFormatJSON,
+			data:    `{"version": 2}`,
+			wantErr: true,
+		},
+		"csv export": {
+			format: ExportFormatCSV,
+			data: "post_id,permalink,title,labels,note,bookmarked_at\n" +
+				"ID1,https://myhost.com/_redirect/pl/ID1,deploy,\"prod, docs\",\"a note\",1970-01-01T00:00:05Z\n",
+			expected: []*ExportedBookmark{{PostID: "ID1", Title: "deploy", Labels: []string{"prod", "docs"}, Note: "a note", CreateAt: 5000}},
+		},
+		"csv with permalinks only": {
+			format:   ExportFormatCSV,
+			data:     "Permalink\nhttps://myhost.com/team/pl/ID1\n\nhttps://myhost.com/_redirect/pl/ID2/\n",
+			expected: []*ExportedBookmark{{PostID: "ID1"}, {PostID: "ID2"}},
+		},
+		"csv without post IDs": {
+			format:  ExportFormatCSV,
+			data:    "title,labels\ndeploy,prod\n",
+			wantErr: true,
+		},
+		"csv with invalid rows": {
+			format:   ExportFormatCSV,
+			data:     "post_id,title,bookmarked_at\nID1,deploy,yesterday\n,retro,\nID3,standup,\n",
+			expected: []*ExportedBookmark{{PostID: "ID3", Title: "standup"}},
+			invalid: []string{
+				"Row 2: Time `yesterday` is not an RFC 3339 time",
+				"Row 3 has no post ID or permalink",
+			},
+		},
+		"unknown format": {
+			format:  ExportFormatHTML,
+			data:    "<html>",
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			export, err := ParseImport(tt.format, []byte(tt.data))
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, export.Bookmarks)
+			assert.Equal(t, tt.labels, export.Labels)
+			assert.Equal(t, tt.invalid, export.Invalid)
+		})
+	}
+}
+
+func TestNewImportFromFlaggedPosts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	mockPluginAPI.EXPECT().GetPreferencesForUser(UserID).Return([]model.Preference{
+		{UserId: UserID, Category: model.PREFERENCE_CATEGORY_FLAGGED_POST, Name: "ID1", Value: "true"},
+		{UserId: UserID, Category: model.PREFERENCE_CATEGORY_FLAGGED_POST, Name: "ID2", Value: "false"},
+		{UserId: UserID, Category: model.PREFERENCE_CATEGORY_DISPLAY_SETTINGS, Name: "use_military_time", Value: "true"},
+	}, nil)
+
+	export, err := NewImportFromFlaggedPosts(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Equal(t, []*ExportedBookmark{{PostID: "ID1", Labels: []string{ImportedLabelName}}}, export.Bookmarks)
+}
+
+func TestImport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	kv := mockKVStore(mockPluginAPI)
+	mockPluginAPI.EXPECT().GetPostsByIds(gomock.Any()).DoAndReturn(func(postIDs []string) (map[string]*model.Post, error) {
+		posts := make(map[string]*model.Post)
+		for _, id := range postIDs {
+			if id != "removedID" {
+				posts[id] = &model.Post{Id: id, Message: "message " + id, UserId: "authorID", ChannelId: "channelID"}
+			}
+		}
+		return posts, nil
+	}).AnyTimes()
+	mockPluginAPI.EXPECT().GetUser("authorID").Return(&model.User{Id: "authorID", Username: "author"}, nil).AnyTimes()
+	mockPluginAPI.EXPECT().GetChannel("channelID").Return(&model.Channel{Id: "channelID", DisplayName: "Town Square"}, nil).AnyTimes()
+	mockChannelMember(mockPluginAPI)
+
+	kv[GetLabelsKey(UserID)] = mustEncodeDocument(t, &Labels{ByID: map[string]*Label{
+		"UUID1": {Name: "prod", ID: "UUID1"},
+	}})
+	storeTestBookmarks(t, kv, UserID, []*Bookmark{
+		{PostID: "ID1", Title: "deploy", LabelIDs: []string{"UUID1"}, CreateAt: 1, ModifiedAt: 1},
+		{PostID: "ID2", Title: "retro", Note: "my note", CreateAt: 1, ModifiedAt: 1},
+		{PostID: "ID3", CreateAt: 1, ModifiedAt: 1},
+	}...)
+
+	bmarks, err := NewBookmarksWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	labels, err := NewLabelsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+
+	result, err := bmarks.Import(labels, &Export{
+		Bookmarks: []*ExportedBookmark{
+			{PostID: "ID1", Title: "deploy", Labels: []string{"PROD"}},
+			{PostID: "ID2", Title: "planning", Note: "their note", Labels: []string{"docs"}},
+			{PostID: "ID3", Title: "standup", Note: "daily"},
+			{PostID: "ID4", Title: "new", Labels: []string{"docs"}, CreateAt: 7},
+			{PostID: "ID4", Title: "duplicate"},
+			{PostID: "removedID", Labels: []string{"removed"}},
+			{PostID: "ID5", Labels: []string{"projects//apollo"}},
+		},
+		Labels: []*Label{
+			{Name: "docs", Color: "#1e90ff", Description: "Documentation"},
+			{Name: "unused"},
+		},
+		Invalid: []string{"Row 9 has no post ID or permalink"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &ImportResult{
+		Added:          1,
+		Merged:         2,
+		Unchanged:      1,
+		Conflicts:      []string{"ID2"},
+		UnknownPostIDs: []string{"removedID"},
+		Invalid: []string{
+			"Row 9 has no post ID or permalink",
+			"Bookmark of post `ID5`: Label `projects//apollo` is not a valid label name. Nested label names are separated by `/`, e.g. `projects/apollo`",
+		},
+	}, result)
+
+	// labels of bookmarks that were not imported are not created
+	labels, err = NewLabelsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Len(t, labels.ByID, 2)
+	assert.Nil(t, labels.GetLabelByName("removed"))
+	assert.Nil(t, labels.GetLabelByName("unused"))
+	docs := labels.GetLabelByName("docs")
+	assert.NotNil(t, docs)
+	assert.Equal(t, "#1e90ff", docs.Color)
+	assert.Equal(t, "Documentation", docs.Description)
+
+	bmarks, err = NewBookmarksWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"ID1", "ID2", "ID3", "ID4"}, bmarks.getPostIDs())
+
+	assert.Equal(t, "deploy", bmarks.ByID["ID1"].Title)
+	assert.Equal(t, []string{"UUID1"}, bmarks.ByID["ID1"].LabelIDs)
+	assert.Equal(t, int64(1), bmarks.ByID["ID1"].ModifiedAt)
+
+	assert.Equal(t, "retro", bmarks.ByID["ID2"].Title)
+	assert.Equal(t, "my note", bmarks.ByID["ID2"].Note)
+	assert.Equal(t, []string{docs.ID}, bmarks.ByID["ID2"].LabelIDs)
+
+	assert.Equal(t, "standup", bmarks.ByID["ID3"].Title)
+	assert.Equal(t, "daily", bmarks.ByID["ID3"].Note)
+
+	assert.Equal(t, "new", bmarks.ByID["ID4"].Title)
+	assert.Equal(t, []string{docs.ID}, bmarks.ByID["ID4"].LabelIDs)
+	assert.Equal(t, int64(7), bmarks.ByID["ID4"].CreateAt)
+	assert.NotNil(t, bmarks.ByID["ID4"].Snapshot)
+}
+
+func TestImportResultGetText(t *testing.T) {
+	result := &ImportResult{Added: 2, Merged: 1, Conflicts: []string{"ID2"}, UnknownPostIDs: []string{"ID9", "ID8"},
+		Invalid: []string{"Row 3 has no post ID or permalink"}}
+	assert.Equal(t, "Imported 2 bookmarks. 1 existing bookmarks were updated and 0 were unchanged\n"+
+		"\n#### Conflicts\nThese bookmarks already have a different title or note. The existing title and note were kept\n* `ID2`\n"+
+		"\n#### Unknown posts\nThese posts do not exist or you cannot read them. They were not imported\n* `ID8`\n* `ID9`\n"+
+		"\n#### Skipped\nThese bookmarks are not valid. They were not imported\n* Row 3 has no post ID or permalink\n",
+		result.GetText())
+}
@@ -1,40 +1,262 @@
 package bookmarks
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"sync"
 
+	"github.com/mattermost/mattermost-server/v5/model"
 	"github.com/pkg/errors"
 )
 
-// StoreBookmarksKey is the key used to store bookmarks in the plugin KV store
-const StoreBookmarksKey = "bookmarks"
+const (
+	// StoreBookmarksKey is the key used to store all bookmarks of a user in a
+	// single value. It is only read to migrate users to per-bookmark keys
+	StoreBookmarksKey = "bookmarks"
 
+	// StoreBookmarkKey is the key prefix used to store an individual bookmark
+	StoreBookmarkKey = "bookmark"
+
+	// StoreBookmarksIndexKey is the key prefix used to store the IDs of all
+	// bookmarks of a user
+	StoreBookmarksIndexKey = "bookmark_index"
+)
+
+// GetBookmarksKey returns the key of the single value layout used before
+// bookmarks were stored individually
 func GetBookmarksKey(userID string) string {
 	return fmt.Sprintf("%s_%s", StoreBookmarksKey, userID)
 }
 
-// storeBookmarks stores all the users bookmarks
-func (b *Bookmarks) StoreBookmarks() error {
-	bb, jsonErr := json.Marshal(b)
-	if jsonErr != nil {
-		return jsonErr
+// GetBookmarksIndexKey returns the key of the index of a users bookmarks
+func GetBookmarksIndexKey(userID string) string {
+	return fmt.Sprintf("%s_%s", StoreBookmarksIndexKey, userID)
+}
+
+// GetBookmarkKey returns the key of an individual bookmark. A user ID and a
+// post ID together are longer than the 50 characters allowed in a KV key, so
+// they are hashed
+func GetBookmarkKey(userID, postID string) string {
+	hash := sha256.Sum256([]byte(userID + "_" + postID))
+	return fmt.Sprintf("%s_%x", StoreBookmarkKey, hash[:16])
+}
+
+// loadBookmarkWorkers is the number of bookmarks of a user read at once. The
+// plugin API cannot read several keys in one call, so the bookmarks listed in
+// the index are read concurrently
+const loadBookmarkWorkers = 16
+
+// maxStoreAttempts is the number of times a mutation is applied before
+// giving up on storing it while other requests keep changing the same values
+const maxStoreAttempts = 5
+
+// StoreBookmarks applies mutate to the bookmarks and stores the changed
+// bookmarks and index. If another request changed them since they were loaded,
+// the bookmarks are reloaded and mutate is applied again
+func (b *Bookmarks) StoreBookmarks(mutate func(bmarks *Bookmarks) error) error {
+	for i := 0; i < maxStoreAttempts; i++ {
+		if err := mutate(b); err != nil {
+			return err
+		}
+
+		stored, err := b.storeChanges()
+		if err != nil {
+			return err
+		}
+		if stored {
+			return nil
+		}
+
+		if err = b.reload(); err != nil {
+			return err
+		}
+	}
+
+	return errors.New(fmt.Sprintf("Unable to store bookmarks for user %s. They were changed by another request %d times", b.userID, maxStoreAttempts))
+}
+
+// storeChanges stores the index and the bookmarks that differ from the
+// loaded values. It returns false if another request changed them first.
+//
+// The index is stored first. A bookmark is only added after its ID is in the
+// index, so a new bookmark does not need a compare-and-set of its own
+func (b *Bookmarks) storeChanges() (bool, error) {
+	ids := make([]string, 0, len(b.ByID))
+	for id := range b.ByID {
+		ids = append(ids, id)
 	}
+	sort.Strings(ids)
+
+	index, err := encodeDocument(ids)
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(index, b.storedIndex) {
+		ok, appErr := b.api.KVCompareAndSet(GetBookmarksIndexKey(b.userID), b.storedIndex, index)
+		if appErr != nil {
+			return false, appErr
+		}
+		if !ok {
+			return false, nil
+		}
+		b.storedIndex = index
+	}
+
+	for _, id := range ids {
+		value, err := encodeDocument(b.ByID[id])
+		if err != nil {
+			return false, err
+		}
+
+		oldValue, ok := b.storedByID[id]
+		switch {
+		case ok && bytes.Equal(value, oldValue):
+			continue
+		case ok:
+			swapped, appErr := b.api.KVCompareAndSet(GetBookmarkKey(b.userID, id), oldValue, value)
+			if appErr != nil {
+				return false, appErr
+			}
+			if !swapped {
+				return false, nil
+			}
+		default:
+			// record the user with the post first, so a hook for the post
+			// finds every stored bookmark
+			if err := setPostBookmarked(b.api, id, b.userID, true); err != nil {
+				return false, err
+			}
+			if appErr := b.api.KVSet(GetBookmarkKey(b.userID, id), value); appErr != nil {
+				return false, appErr
+			}
+		}
+		b.storedByID[id] = value
+	}
+
+	for id, oldValue := range b.storedByID {
+		if _, ok := b.ByID[id]; ok {
+			continue
+		}
+
+		// a nil value deletes the key
+		deleted, appErr := b.api.KVSetWithOptions(GetBookmarkKey(b.userID, id), nil, model.PluginKVSetOptions{
+			Atomic:   true,
+			OldValue: oldValue,
+		})
+		if appErr != nil {
+			return false, appErr
+		}
+		if !deleted {
+			return false, nil
+		}
+		delete(b.storedByID, id)
 
-	key := GetBookmarksKey(b.userID)
-	appErr := b.api.KVSet(key, bb)
+		if err := setPostBookmarked(b.api, id, b.userID, false); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// reload replaces the bookmarks with the stored bookmarks
+func (b *Bookmarks) reload() error {
+	b.ByID = make(map[string]*Bookmark)
+	b.storedIndex = nil
+	b.storedByID = make(map[string][]byte)
+	_, err := b.loadBookmarks()
+	return err
+}
+
+// loadBookmarks returns the bookmarks listed in the users index. The returned
+// bool is false if the user has no index
+func (b *Bookmarks) loadBookmarks() (bool, error) {
+	bb, appErr := b.api.KVGet(GetBookmarksIndexKey(b.userID))
 	if appErr != nil {
-		return appErr
+		return false, appErr
+	}
+	if bb == nil {
+		return false, nil
 	}
 
-	return nil
+	data, _, err := decodeDocument(documentBookmarkIndex, bb)
+	if err != nil {
+		return false, err
+	}
+
+	var ids []string
+	if jsonErr := json.Unmarshal(data, &ids); jsonErr != nil {
+		return false, jsonErr
+	}
+	b.storedIndex = bb
+
+	values, err := b.getBookmarkValues(ids)
+	if err != nil {
+		return false, err
+	}
+
+	for _, bb := range values {
+		// the bookmark was removed after the index was read
+		if bb == nil {
+			continue
+		}
+
+		data, _, err := decodeDocument(documentBookmark, bb)
+		if err != nil {
+			return false, err
+		}
+
+		var bmark *Bookmark
+		if jsonErr := json.Unmarshal(data, &bmark); jsonErr != nil {
+			return false, jsonErr
+		}
+		b.ByID[bmark.PostID] = bmark
+		b.storedByID[bmark.PostID] = bb
+	}
+
+	return true, nil
+}
+
+// getBookmarkValues returns the stored values of the bookmarks with the given
+// IDs, in the same order. The value of a bookmark that is not stored is nil
+func (b *Bookmarks) getBookmarkValues(ids []string) ([][]byte, error) {
+	values := make([][]byte, len(ids))
+	errs := make([]error, len(ids))
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < loadBookmarkWorkers && w < len(ids); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				values[i], errs[i] = b.api.KVGet(GetBookmarkKey(b.userID, ids[i]))
+			}
+		}()
+	}
+	for i := range ids {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
 }
 
 // FromJSON returns unmarshalled bookmark or initialized bookmarks if
 // bytes are empty
 func FromJSON(bytes []byte) (*Bookmarks, error) {
 	bmarks := &Bookmarks{
-		ByID: make(map[string]*Bookmark),
+		ByID:       make(map[string]*Bookmark),
+		storedByID: make(map[string][]byte),
 	}
 
 	if len(bytes) != 0 {
@@ -48,15 +270,15 @@ func FromJSON(bytes []byte) (*Bookmarks, error) {
 
 // DeleteBookmark deletes a bookmark from the store
 func (b *Bookmarks) DeleteBookmark(bmarkID string) error {
-	_, ok := b.exists(bmarkID)
-	if !ok {
-		return errors.New(fmt.Sprintf("Bookmark `%v` does not exist", bmarkID))
-	}
-
-	delete(b.ByID, bmarkID)
-	if err := b.StoreBookmarks(); err != nil {
+	err := b.StoreBookmarks(func(bmarks *Bookmarks) error {
+		if _, ok := bmarks.exists(bmarkID); !ok {
+			return errors.New(fmt.Sprintf("Bookmark `%v` does not exist", bmarkID))
+		}
+		delete(bmarks.ByID, bmarkID)
+		return nil
+	})
+	if err != nil {
 		return err
 	}
-
-	return nil
+	return b.syncFlags([]string{bmarkID}, false)
 }
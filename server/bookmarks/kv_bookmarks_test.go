@@ -1,6 +1,11 @@
 package bookmarks
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 
@@ -65,10 +70,14 @@ func TestBookmarks_delete(t *testing.T) {
 	defer ctrl.Finish()
 	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
 
-	mockPluginAPI.EXPECT().KVSet(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockPluginAPI.EXPECT().KVCompareAndSet(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).AnyTimes()
+	mockPluginAPI.EXPECT().KVSetWithOptions(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).AnyTimes()
+	mockPluginAPI.EXPECT().KVGet(GetPostBookmarksKey("ID2")).Return(nil, nil)
+	mockPluginAPI.EXPECT().KVGet(GetSettingsKey(UserID)).Return(nil, nil)
 
 	bmarks := getTestBookmarks()
 	bmarks.api = mockPluginAPI
+	markStored(t, bmarks)
 	assert.Equal(t, 3, len(bmarks.ByID))
 	_ = bmarks.DeleteBookmark("ID2")
 	assert.Equal(t, 2, len(bmarks.ByID))
@@ -99,3 +108,368 @@ func TestBookmarks_updateTimes(t *testing.T) {
 	b2, _ := bmarks.GetBookmark("ID2")
 	assert.Greater(t, b2.ModifiedAt, b2.CreateAt)
 }
+
+func TestNewBookmarksWithUser(t *testing.T) {
+	b1 := &Bookmark{PostID: "ID1", Title: "Title1"}
+	b2 := &Bookmark{PostID: "ID2", Title: "Title2"}
+
+	jsonB1 := mustEncodeDocument(t, b1)
+	jsonB2 := mustEncodeDocument(t, b2)
+	jsonIndex := mustEncodeDocument(t, []string{"ID1", "ID2"})
+
+	// documents stored before schema versioning
+	rawB1, err := json.Marshal(b1)
+	assert.Nil(t, err)
+	rawB2, err := json.Marshal(b2)
+	assert.Nil(t, err)
+
+	legacy := NewBookmarks(UserID)
+	legacy.ByID[b1.PostID] = b1
+	legacy.ByID[b2.PostID] = b2
+	jsonLegacy, err := json.Marshal(legacy)
+	assert.Nil(t, err)
+
+	tests := map[string]struct {
+		setup       func(api *mock_pluginapi.MockAPI)
+		expectedIDs []string
+	}{
+		"no bookmarks": {
+			setup: func(api *mock_pluginapi.MockAPI) {
+				api.EXPECT().KVGet(GetBookmarksIndexKey(UserID)).Return(nil, nil)
+				api.EXPECT().KVGet(GetBookmarksKey(UserID)).Return(nil, nil)
+			},
+		},
+		"bookmarks stored individually": {
+			setup: func(api *mock_pluginapi.MockAPI) {
+				api.EXPECT().KVGet(GetBookmarksIndexKey(UserID)).Return(jsonIndex, nil)
+				api.EXPECT().KVGet(GetBookmarkKey(UserID, "ID1")).Return(jsonB1, nil)
+				api.EXPECT().KVGet(GetBookmarkKey(UserID, "ID2")).Return(jsonB2, nil)
+			},
+			expectedIDs: []string{"ID1", "ID2"},
+		},
+		"bookmarks stored before schema versioning": {
+			setup: func(api *mock_pluginapi.MockAPI) {
+				api.EXPECT().KVGet(GetBookmarksIndexKey(UserID)).Return([]byte(`["ID1","ID2"]`), nil)
+				api.EXPECT().KVGet(GetBookmarkKey(UserID, "ID1")).Return(rawB1, nil)
+				api.EXPECT().KVGet(GetBookmarkKey(UserID, "ID2")).Return(rawB2, nil)
+			},
+			expectedIDs: []string{"ID1", "ID2"},
+		},
+		"bookmark removed after the index was read": {
+			setup: func(api *mock_pluginapi.MockAPI) {
+				api.EXPECT().KVGet(GetBookmarksIndexKey(UserID)).Return(jsonIndex, nil)
+				api.EXPECT().KVGet(GetBookmarkKey(UserID, "ID1")).Return(jsonB1, nil)
+				api.EXPECT().KVGet(GetBookmarkKey(UserID, "ID2")).Return(nil, nil)
+			},
+			expectedIDs: []string{"ID1"},
+		},
+		"bookmarks stored in a single value are migrated": {
+			setup: func(api *mock_pluginapi.MockAPI) {
+				api.EXPECT().KVGet(GetBookmarksIndexKey(UserID)).Return(nil, nil)
+				api.EXPECT().KVGet(GetBookmarksKey(UserID)).Return(jsonLegacy, nil)
+				gomock.InOrder(
+					api.EXPECT().KVCompareAndSet(GetBookmarksIndexKey(UserID), nil, jsonIndex).Return(true, nil),
+					api.EXPECT().KVSet(GetBookmarkKey(UserID, "ID1"), jsonB1).Return(nil),
+					api.EXPECT().KVDelete(GetBookmarksKey(UserID)).Return(nil),
+				)
+				api.EXPECT().KVSet(GetBookmarkKey(UserID, "ID2"), jsonB2).Return(nil)
+				expectPostBookmarked(t, api, "ID1", UserID)
+				expectPostBookmarked(t, api, "ID2", UserID)
+			},
+			expectedIDs: []string{"ID1", "ID2"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+			tt.setup(mockPluginAPI)
+
+			bmarks, err := NewBookmarksWithUser(mockPluginAPI, UserID)
+			assert.Nil(t, err)
+
+			var ids []string
+			for id := range bmarks.ByID {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			assert.Equal(t, tt.expectedIDs, ids)
+		})
+	}
+}
+
+func TestLoadBookmarks_readsBookmarksConcurrently(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	kv := mockKVStore(mockPluginAPI)
+
+	var bmarks []*Bookmark
+	for i := 0; i < 200; i++ {
+		bmarks = append(bmarks, &Bookmark{PostID: fmt.Sprintf("ID%d", i)})
+	}
+	storeTestBookmarks(t, kv, UserID, bmarks...)
+
+	var mu sync.Mutex
+	reads := make(map[string]int)
+	counting := mock_pluginapi.NewMockAPI(ctrl)
+	counting.EXPECT().KVGet(gomock.Any()).DoAndReturn(func(key string) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reads[key]++
+		return kv[key], nil
+	}).AnyTimes()
+
+	loaded, err := NewBookmarksWithUser(counting, UserID)
+	assert.Nil(t, err)
+	assert.Len(t, loaded.ByID, 200)
+	assert.Len(t, reads, 201)
+	for key, n := range reads {
+		assert.Equal(t, 1, n, key)
+	}
+}
+
+func TestMigrateBookmarks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+
+	b1 := &Bookmark{PostID: "ID1", Title: "Title1"}
+	b2 := &Bookmark{PostID: "ID2", Title: "Title2"}
+
+	legacy := NewBookmarks("userID1")
+	legacy.ByID[b1.PostID] = b1
+	legacy.ByID[b2.PostID] = b2
+	jsonLegacy, err := json.Marshal(legacy)
+	assert.Nil(t, err)
+
+	// userID1 was partly migrated before. The stored bookmark wins over the
+	// single value
+	b2Migrated := &Bookmark{PostID: "ID2", Title: "Title2 - renamed"}
+	jsonB2Migrated, err := json.Marshal(b2Migrated)
+	assert.Nil(t, err)
+
+	mockPluginAPI.EXPECT().KVList(0, migrateKVListPerPage).Return([]string{
+		"bookmarks_userID1",
+		"bookmark_index_userID1",
+		"bookmark_index_userID2",
+		"labels_userID1",
+	}, nil)
+
+	mockPluginAPI.EXPECT().KVGet(GetBookmarksIndexKey("userID1")).Return([]byte(`["ID2"]`), nil)
+	mockPluginAPI.EXPECT().KVGet(GetBookmarkKey("userID1", "ID2")).Return(jsonB2Migrated, nil)
+	mockPluginAPI.EXPECT().KVGet(GetBookmarksKey("userID1")).Return(jsonLegacy, nil)
+	mockPluginAPI.EXPECT().KVCompareAndSet(GetBookmarksIndexKey("userID1"), []byte(`["ID2"]`), mustEncodeDocument(t, []string{"ID1", "ID2"})).Return(true, nil)
+	mockPluginAPI.EXPECT().KVSet(GetBookmarkKey("userID1", "ID1"), gomock.Any()).Return(nil)
+	expectPostBookmarked(t, mockPluginAPI, "ID1", "userID1")
+	mockPluginAPI.EXPECT().KVCompareAndSet(GetBookmarkKey("userID1", "ID2"), jsonB2Migrated, mustEncodeDocument(t, b2Migrated)).Return(true, nil)
+	mockPluginAPI.EXPECT().KVDelete(GetBookmarksKey("userID1")).Return(nil)
+
+	migrated, err := MigrateBookmarks(mockPluginAPI)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"userID1"}, migrated)
+}
+
+func TestGetBookmarkKey(t *testing.T) {
+	key := GetBookmarkKey("4xp9fdt77pncbef59f4k1qe83o", "h79hegdtgtbqxn89co1b1iwu7h")
+	assert.LessOrEqual(t, len(key), model.KEY_VALUE_KEY_MAX_RUNES)
+	assert.NotEqual(t, key, GetBookmarkKey("4xp9fdt77pncbef59f4k1qe83o", "5p4xi5hqmjddzfgggtqafk4iga"))
+}
+
+// markStored records the bookmarks as the values loaded from the KV store
+func markStored(t *testing.T, bmarks *Bookmarks) {
+	var ids []string
+	for id, bmark := range bmarks.ByID {
+		bmarks.storedByID[id] = mustEncodeDocument(t, bmark)
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	bmarks.storedIndex = mustEncodeDocument(t, ids)
+}
+
+// storeTestBookmarks stores the index and the bookmarks of a user in the KV
+// map of mockKVStore
+func storeTestBookmarks(t *testing.T, kv map[string][]byte, userID string, bmarks ...*Bookmark) {
+	stored := NewBookmarks(userID)
+	for _, bmark := range bmarks {
+		stored.ByID[bmark.PostID] = bmark
+	}
+	markStored(t, stored)
+
+	kv[GetBookmarksIndexKey(userID)] = stored.storedIndex
+	for id, bb := range stored.storedByID {
+		kv[GetBookmarkKey(userID, id)] = bb
+	}
+}
+
+// getStoredBookmark returns a bookmark stored in the KV map of mockKVStore,
+// or nil if it is not stored
+func getStoredBookmark(t *testing.T, kv map[string][]byte, userID, postID string) *Bookmark {
+	bb, ok := kv[GetBookmarkKey(userID, postID)]
+	if !ok {
+		return nil
+	}
+
+	data, _, err := decodeDocument(documentBookmark, bb)
+	assert.Nil(t, err)
+	var bmark *Bookmark
+	assert.Nil(t, json.Unmarshal(data, &bmark))
+	return bmark
+}
+
+// mockKVStore backs the KV calls of the mock API with an in-memory map, so
+// writes of one request are seen by the others
+func mockKVStore(api *mock_pluginapi.MockAPI) map[string][]byte {
+	kv := make(map[string][]byte)
+
+	// compareAndSet follows the plugin API. A nil oldValue only sets a key
+	// that does not exist and a nil newValue deletes the key
+	compareAndSet := func(key string, oldValue, newValue []byte) (bool, error) {
+		current, ok := kv[key]
+		if (oldValue == nil && ok) || (oldValue != nil && !bytes.Equal(current, oldValue)) {
+			return false, nil
+		}
+		if newValue == nil {
+			delete(kv, key)
+			return true, nil
+		}
+		kv[key] = newValue
+		return true, nil
+	}
+
+	api.EXPECT().KVGet(gomock.Any()).DoAndReturn(func(key string) ([]byte, error) {
+		return kv[key], nil
+	}).AnyTimes()
+	api.EXPECT().KVSet(gomock.Any(), gomock.Any()).DoAndReturn(func(key string, value []byte) error {
+		kv[key] = value
+		return nil
+	}).AnyTimes()
+	api.EXPECT().KVCompareAndSet(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(compareAndSet).AnyTimes()
+	api.EXPECT().KVSetWithOptions(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(key string, value []byte, options model.PluginKVSetOptions) (bool, error) {
+			return compareAndSet(key, options.OldValue, value)
+		}).AnyTimes()
+
+	api.EXPECT().KVList(gomock.Any(), gomock.Any()).DoAndReturn(func(page, perPage int) ([]string, error) {
+		var keys []string
+		for key := range kv {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		start := page * perPage
+		if start >= len(keys) {
+			return []string{}, nil
+		}
+		end := start + perPage
+		if end > len(keys) {
+			end = len(keys)
+		}
+		return keys[start:end], nil
+	}).AnyTimes()
+
+	return kv
+}
+
+func TestStoreBookmarks_interleavedWriters(t *testing.T) {
+	b1 := &Bookmark{PostID: "ID1", Title: "Title1"}
+	b2 := &Bookmark{PostID: "ID2", Title: "Title2"}
+
+	tests := map[string]struct {
+		writeA      func(bmarks *Bookmarks) error
+		writeB      func(bmarks *Bookmarks) error
+		expectedIDs []string
+		checks      func(t *testing.T, bmarks *Bookmarks)
+	}{
+		"both writers add a bookmark": {
+			writeA:      func(bmarks *Bookmarks) error { return bmarks.AddBookmark(&Bookmark{PostID: "ID3"}) },
+			writeB:      func(bmarks *Bookmarks) error { return bmarks.AddBookmark(&Bookmark{PostID: "ID4"}) },
+			expectedIDs: []string{"ID1", "ID2", "ID3", "ID4"},
+		},
+		"one writer deletes while the other adds": {
+			writeA:      func(bmarks *Bookmarks) error { return bmarks.DeleteBookmark("ID1") },
+			writeB:      func(bmarks *Bookmarks) error { return bmarks.AddBookmark(&Bookmark{PostID: "ID3"}) },
+			expectedIDs: []string{"ID2", "ID3"},
+		},
+		"both writers update the same bookmark": {
+			writeA: func(bmarks *Bookmarks) error {
+				return bmarks.AddBookmark(&Bookmark{PostID: "ID2", Title: "Title2", LabelIDs: []string{"UUID1"}})
+			},
+			writeB: func(bmarks *Bookmarks) error {
+				return bmarks.DeleteLabel("ID1", "UUID1")
+			},
+			expectedIDs: []string{"ID1", "ID2"},
+			checks: func(t *testing.T, bmarks *Bookmarks) {
+				assert.Equal(t, []string{"UUID1"}, bmarks.ByID["ID2"].LabelIDs)
+			},
+		},
+		"second writer fails once the bookmark is deleted": {
+			writeA: func(bmarks *Bookmarks) error { return bmarks.DeleteBookmark("ID1") },
+			writeB: func(bmarks *Bookmarks) error {
+				err := bmarks.DeleteLabel("ID1", "UUID1")
+				assert.Equal(t, "Bookmark `ID1` does not exist", err.Error())
+				return nil
+			},
+			expectedIDs: []string{"ID2"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+			mockKVStore(mockPluginAPI)
+			mockPostContent(mockPluginAPI)
+
+			initial := NewBookmarks(UserID)
+			initial.api = mockPluginAPI
+			err := initial.StoreBookmarks(func(bmarks *Bookmarks) error {
+				bmarks.ByID[b1.PostID] = &Bookmark{PostID: b1.PostID, Title: b1.Title, LabelIDs: []string{"UUID1"}}
+				bmarks.ByID[b2.PostID] = &Bookmark{PostID: b2.PostID, Title: b2.Title}
+				return nil
+			})
+			assert.Nil(t, err)
+
+			// both writers load the bookmarks before either stores a change
+			bmarksA, err := NewBookmarksWithUser(mockPluginAPI, UserID)
+			assert.Nil(t, err)
+			bmarksB, err := NewBookmarksWithUser(mockPluginAPI, UserID)
+			assert.Nil(t, err)
+
+			assert.Nil(t, tt.writeA(bmarksA))
+			assert.Nil(t, tt.writeB(bmarksB))
+
+			bmarks, err := NewBookmarksWithUser(mockPluginAPI, UserID)
+			assert.Nil(t, err)
+
+			var ids []string
+			for id := range bmarks.ByID {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			assert.Equal(t, tt.expectedIDs, ids)
+
+			if tt.checks != nil {
+				tt.checks(t, bmarks)
+			}
+		})
+	}
+}
+
+func TestStoreBookmarks_tooManyConflicts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+
+	// every reload finds an empty index, and every write finds it changed
+	mockPluginAPI.EXPECT().KVGet(GetBookmarksIndexKey(UserID)).Return([]byte(`[]`), nil).Times(maxStoreAttempts)
+	mockPluginAPI.EXPECT().KVCompareAndSet(GetBookmarksIndexKey(UserID), gomock.Any(), gomock.Any()).Return(false, nil).Times(maxStoreAttempts)
+	mockPostContent(mockPluginAPI)
+
+	bmarks := NewBookmarks(UserID)
+	bmarks.api = mockPluginAPI
+	err := bmarks.AddBookmark(&Bookmark{PostID: "ID1"})
+	assert.Equal(t, "failed to add bookmark: Unable to store bookmarks for user UserID. They were changed by another request 5 times", err.Error())
+}
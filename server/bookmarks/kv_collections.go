@@ -0,0 +1,71 @@
+package bookmarks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// StoreCollectionsKey is the key used to store collections in the plugin KV
+// store
+const StoreCollectionsKey = "collections"
+
+func GetCollectionsKey(userID string) string {
+	return fmt.Sprintf("%s_%s", StoreCollectionsKey, userID)
+}
+
+// StoreCollections applies mutate to the collections and stores them. If
+// another request changed the collections since they were loaded, the
+// collections are reloaded and mutate is applied again
+func (c *Collections) StoreCollections(mutate func(collections *Collections) error) error {
+	key := GetCollectionsKey(c.userID)
+	for i := 0; i < maxStoreAttempts; i++ {
+		if err := mutate(c); err != nil {
+			return err
+		}
+
+		bb, jsonErr := json.Marshal(c)
+		if jsonErr != nil {
+			return jsonErr
+		}
+		if bytes.Equal(bb, c.stored) {
+			return nil
+		}
+
+		ok, appErr := c.api.KVCompareAndSet(key, c.stored, bb)
+		if appErr != nil {
+			return appErr
+		}
+		if ok {
+			c.stored = bb
+			return nil
+		}
+
+		// another request stored the collections first
+		stored, appErr := c.api.KVGet(key)
+		if appErr != nil {
+			return appErr
+		}
+		collections, err := CollectionsFromJSON(stored)
+		if err != nil {
+			return err
+		}
+		c.ByName = collections.ByName
+		c.stored = stored
+	}
+
+	return errors.New(fmt.Sprintf("Unable to store collections for user %s. They were changed by another request %d times", c.userID, maxStoreAttempts))
+}
+
+// DeleteCollection deletes a collection from the store
+func (c *Collections) DeleteCollection(name string) error {
+	return c.StoreCollections(func(collections *Collections) error {
+		if _, ok := collections.ByName[name]; !ok {
+			return errors.New(fmt.Sprintf("Collection `%s` does not exist", name))
+		}
+		delete(collections.ByName, name)
+		return nil
+	})
+}
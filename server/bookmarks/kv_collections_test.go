@@ -0,0 +1,38 @@
+package bookmarks
+
+import (
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreCollections_concurrentSave(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	mockKVStore(mockPluginAPI)
+
+	// both requests load the collections before either saves
+	c1, err := NewCollectionsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	c2, err := NewCollectionsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+
+	assert.Nil(t, c1.SaveCollection(&Collection{Name: "standup", Args: []string{"--since", "1d"}}))
+	assert.Nil(t, c2.SaveCollection(&Collection{Name: "oncall", Args: []string{"--filter-labels", "prod"}}))
+
+	collections, err := NewCollectionsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Len(t, collections.ByName, 2)
+	assert.Contains(t, collections.ByName, "standup")
+	assert.Contains(t, collections.ByName, "oncall")
+
+	assert.Nil(t, collections.DeleteCollection("standup"))
+	assert.NotNil(t, collections.DeleteCollection("standup"))
+
+	collections, err = NewCollectionsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Len(t, collections.ByName, 1)
+}
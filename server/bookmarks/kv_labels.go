@@ -1,8 +1,10 @@
 package bookmarks
 
 import (
-	"encoding/json"
+	"bytes"
 	"fmt"
+
+	"github.com/pkg/errors"
 )
 
 // StoreLabelsKey is the key used to store labels in the plugin KV store
@@ -12,28 +14,68 @@ func GetLabelsKey(userID string) string {
 	return fmt.Sprintf("%s_%s", StoreLabelsKey, userID)
 }
 
-// storeLabels stores all the users labels
-func (l *Labels) StoreLabels() error {
-	bb, jsonErr := json.Marshal(l)
-	if jsonErr != nil {
-		return jsonErr
+// StoreLabels applies mutate to the labels and stores them. If another
+// request changed the labels since they were loaded, the labels are reloaded
+// and mutate is applied again
+func (l *Labels) StoreLabels(mutate func(labels *Labels) error) error {
+	key := GetLabelsKey(l.userID)
+	for i := 0; i < maxStoreAttempts; i++ {
+		if err := mutate(l); err != nil {
+			return err
+		}
+
+		bb, err := encodeDocument(l)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(bb, l.stored) {
+			return nil
+		}
+
+		ok, appErr := l.api.KVCompareAndSet(key, l.stored, bb)
+		if appErr != nil {
+			return appErr
+		}
+		if ok {
+			l.stored = bb
+			return nil
+		}
+
+		// another request stored the labels first
+		stored, appErr := l.api.KVGet(key)
+		if appErr != nil {
+			return appErr
+		}
+		labels, err := labelsFromStored(stored)
+		if err != nil {
+			return err
+		}
+		l.ByID = labels.ByID
+		l.Redirects = labels.Redirects
+		l.stored = stored
 	}
 
-	key := GetLabelsKey(l.userID)
-	appErr := l.api.KVSet(key, bb)
-	if appErr != nil {
-		return appErr
+	return errors.New(fmt.Sprintf("Unable to store labels for user %s. They were changed by another request %d times", l.userID, maxStoreAttempts))
+}
+
+// labelsFromStored returns the labels of a stored document upgraded to the
+// current schema version
+func labelsFromStored(bb []byte) (*Labels, error) {
+	if bb == nil {
+		return LabelsFromJSON(nil)
 	}
 
-	return nil
+	data, _, err := decodeDocument(documentLabels, bb)
+	if err != nil {
+		return nil, err
+	}
+	return LabelsFromJSON(data)
 }
 
 // DeleteByID deletes a label from the store
 func (l *Labels) DeleteByID(id string) error {
-	delete(l.ByID, id)
-
-	if err := l.StoreLabels(); err != nil {
-		return err
-	}
-	return nil
+	return l.StoreLabels(func(labels *Labels) error {
+		delete(labels.ByID, id)
+		return nil
+	})
 }
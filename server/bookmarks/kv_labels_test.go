@@ -0,0 +1,82 @@
+package bookmarks
+
+import (
+	"sort"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreLabels_interleavedWriters(t *testing.T) {
+	tests := map[string]struct {
+		writeA        func(labels *Labels) error
+		writeB        func(labels *Labels) error
+		expectedNames []string
+	}{
+		"both writers add a label": {
+			writeA: func(labels *Labels) error {
+				_, err := labels.AddLabel("label2")
+				return err
+			},
+			writeB: func(labels *Labels) error {
+				_, err := labels.AddLabel("label3")
+				return err
+			},
+			expectedNames: []string{"label1", "label2", "label3"},
+		},
+		"one writer renames while the other adds": {
+			writeA: func(labels *Labels) error { return labels.RenameLabel("label1", "renamed") },
+			writeB: func(labels *Labels) error {
+				_, err := labels.AddLabel("label2")
+				return err
+			},
+			expectedNames: []string{"label2", "renamed"},
+		},
+		"both writers add the same label": {
+			writeA: func(labels *Labels) error {
+				_, err := labels.AddLabel("label2")
+				return err
+			},
+			writeB: func(labels *Labels) error {
+				_, err := labels.AddLabel("label2")
+				assert.Equal(t, "failed to add label: Label with name `label2` already exists", err.Error())
+				return nil
+			},
+			expectedNames: []string{"label1", "label2"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+			mockKVStore(mockPluginAPI)
+
+			initial, err := NewLabelsWithUser(mockPluginAPI, UserID)
+			assert.Nil(t, err)
+			_, err = initial.AddLabel("label1")
+			assert.Nil(t, err)
+
+			// both writers load the labels before either stores a change
+			labelsA, err := NewLabelsWithUser(mockPluginAPI, UserID)
+			assert.Nil(t, err)
+			labelsB, err := NewLabelsWithUser(mockPluginAPI, UserID)
+			assert.Nil(t, err)
+
+			assert.Nil(t, tt.writeA(labelsA))
+			assert.Nil(t, tt.writeB(labelsB))
+
+			labels, err := NewLabelsWithUser(mockPluginAPI, UserID)
+			assert.Nil(t, err)
+
+			var names []string
+			for _, label := range labels.ByID {
+				names = append(names, label.Name)
+			}
+			sort.Strings(names)
+			assert.Equal(t, tt.expectedNames, names)
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package bookmarks
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	// StorePostBookmarksKey is the key prefix used to store the IDs of the
+	// users who bookmarked a post
+	StorePostBookmarksKey = "post_bookmarks"
+
+	// postBookmarksBuiltKey records that the users of bookmarks stored before
+	// the post bookmarks were kept have been added to them
+	postBookmarksBuiltKey = "post_bookmarks_built"
+)
+
+// GetPostBookmarksKey returns the key of the IDs of the users who bookmarked
+// a post
+func GetPostBookmarksKey(postID string) string {
+	return fmt.Sprintf("%s_%s", StorePostBookmarksKey, postID)
+}
+
+// GetPostBookmarkUserIDs returns the IDs of the users who bookmarked a post
+func GetPostBookmarkUserIDs(api pluginapi.API, postID string) ([]string, error) {
+	userIDs, _, err := getPostBookmarkUserIDs(api, postID)
+	return userIDs, err
+}
+
+// getPostBookmarkUserIDs returns the IDs of the users who bookmarked a post
+// and the stored value they were read from
+func getPostBookmarkUserIDs(api pluginapi.API, postID string) ([]string, []byte, error) {
+	bb, appErr := api.KVGet(GetPostBookmarksKey(postID))
+	if appErr != nil {
+		return nil, nil, appErr
+	}
+	if bb == nil {
+		return nil, nil, nil
+	}
+
+	data, _, err := decodeDocument(documentPostBookmarks, bb)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var userIDs []string
+	if jsonErr := json.Unmarshal(data, &userIDs); jsonErr != nil {
+		return nil, nil, jsonErr
+	}
+	return userIDs, bb, nil
+}
+
+// setPostBookmarked adds a user to or removes a user from the users who
+// bookmarked a post. The value is deleted once no user bookmarks the post
+func setPostBookmarked(api pluginapi.API, postID, userID string, bookmarked bool) error {
+	key := GetPostBookmarksKey(postID)
+	for i := 0; i < maxStoreAttempts; i++ {
+		userIDs, stored, err := getPostBookmarkUserIDs(api, postID)
+		if err != nil {
+			return err
+		}
+
+		found := false
+		var newUserIDs []string
+		for _, id := range userIDs {
+			if id == userID {
+				found = true
+				continue
+			}
+			newUserIDs = append(newUserIDs, id)
+		}
+		if found == bookmarked {
+			return nil
+		}
+
+		if bookmarked {
+			newUserIDs = append(newUserIDs, userID)
+			sort.Strings(newUserIDs)
+		}
+
+		// a nil value deletes the key
+		var value []byte
+		if len(newUserIDs) != 0 {
+			value, err = encodeDocument(newUserIDs)
+			if err != nil {
+				return err
+			}
+		}
+
+		ok, appErr := api.KVSetWithOptions(key, value, model.PluginKVSetOptions{
+			Atomic:   true,
+			OldValue: stored,
+		})
+		if appErr != nil {
+			return appErr
+		}
+		if ok {
+			return nil
+		}
+	}
+
+	return errors.New(fmt.Sprintf("Unable to store the users who bookmarked post %s. They were changed by another request %d times", postID, maxStoreAttempts))
+}
+
+// BuildPostBookmarks adds every user to the users who bookmarked each of
+// their bookmarked posts. Bookmarks added since are recorded as they are
+// stored, so this only runs until it completes once. It returns the number of
+// users whose bookmarks were added
+func BuildPostBookmarks(api pluginapi.API) (int, error) {
+	built, appErr := api.KVGet(postBookmarksBuiltKey)
+	if appErr != nil {
+		return 0, appErr
+	}
+	if built != nil {
+		return 0, nil
+	}
+
+	userIDs, err := listUserIDs(api, StoreBookmarksIndexKey)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, userID := range userIDs {
+		bmarks := NewBookmarks(userID)
+		bmarks.api = api
+		if _, err = bmarks.loadBookmarks(); err != nil {
+			return i, errors.Wrapf(err, "Unable to get bookmarks for user %s", userID)
+		}
+
+		for _, postID := range bmarks.getPostIDs() {
+			if err = setPostBookmarked(api, postID, userID, true); err != nil {
+				return i, errors.Wrapf(err, "Unable to store the users who bookmarked post %s", postID)
+			}
+		}
+	}
+
+	if appErr := api.KVSet(postBookmarksBuiltKey, []byte("true")); appErr != nil {
+		return len(userIDs), appErr
+	}
+	return len(userIDs), nil
+}
@@ -0,0 +1,188 @@
+package bookmarks
+
+import (
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// expectPostBookmarked expects a user to be recorded as the only user who
+// bookmarked a post
+func expectPostBookmarked(t *testing.T, api *mock_pluginapi.MockAPI, postID, userID string) {
+	api.EXPECT().KVGet(GetPostBookmarksKey(postID)).Return(nil, nil)
+	api.EXPECT().KVSetWithOptions(GetPostBookmarksKey(postID), mustEncodeDocument(t, []string{userID}), model.PluginKVSetOptions{Atomic: true}).Return(true, nil)
+}
+
+// expectPostUnbookmarked expects the only user who bookmarked a post to be
+// removed
+func expectPostUnbookmarked(t *testing.T, api *mock_pluginapi.MockAPI, postID, userID string) {
+	stored := mustEncodeDocument(t, []string{userID})
+	api.EXPECT().KVGet(GetPostBookmarksKey(postID)).Return(stored, nil)
+	api.EXPECT().KVSetWithOptions(GetPostBookmarksKey(postID), nil, model.PluginKVSetOptions{Atomic: true, OldValue: stored}).Return(true, nil)
+}
+
+func TestSetPostBookmarked(t *testing.T) {
+	tests := map[string]struct {
+		stored          []string
+		userID          string
+		bookmarked      bool
+		expectedUserIDs []string
+	}{
+		"first user": {
+			userID:          "userID2",
+			bookmarked:      true,
+			expectedUserIDs: []string{"userID2"},
+		},
+		"another user": {
+			stored:          []string{"userID3"},
+			userID:          "userID2",
+			bookmarked:      true,
+			expectedUserIDs: []string{"userID2", "userID3"},
+		},
+		"user already recorded": {
+			stored:          []string{"userID2"},
+			userID:          "userID2",
+			bookmarked:      true,
+			expectedUserIDs: []string{"userID2"},
+		},
+		"one of two users removed": {
+			stored:          []string{"userID2", "userID3"},
+			userID:          "userID3",
+			bookmarked:      false,
+			expectedUserIDs: []string{"userID2"},
+		},
+		"last user removed": {
+			stored:     []string{"userID2"},
+			userID:     "userID2",
+			bookmarked: false,
+		},
+		"user not recorded": {
+			userID:     "userID2",
+			bookmarked: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+			kv := mockKVStore(mockPluginAPI)
+			if tt.stored != nil {
+				kv[GetPostBookmarksKey("ID1")] = mustEncodeDocument(t, tt.stored)
+			}
+
+			err := setPostBookmarked(mockPluginAPI, "ID1", tt.userID, tt.bookmarked)
+			assert.Nil(t, err)
+
+			userIDs, err := GetPostBookmarkUserIDs(mockPluginAPI, "ID1")
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expectedUserIDs, userIDs)
+			if tt.expectedUserIDs == nil {
+				assert.NotContains(t, kv, GetPostBookmarksKey("ID1"))
+			}
+		})
+	}
+}
+
+func TestStoreBookmarks_recordsPostBookmarks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	mockKVStore(mockPluginAPI)
+	mockPostContent(mockPluginAPI)
+
+	for _, userID := range []string{"userID1", "userID2"} {
+		bmarks, err := NewBookmarksWithUser(mockPluginAPI, userID)
+		assert.Nil(t, err)
+		assert.Nil(t, bmarks.AddBookmark(&Bookmark{PostID: "ID1"}))
+	}
+
+	userIDs, err := GetPostBookmarkUserIDs(mockPluginAPI, "ID1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"userID1", "userID2"}, userIDs)
+
+	bmarks, err := NewBookmarksWithUser(mockPluginAPI, "userID1")
+	assert.Nil(t, err)
+	assert.Nil(t, bmarks.DeleteBookmark("ID1"))
+
+	userIDs, err = GetPostBookmarkUserIDs(mockPluginAPI, "ID1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"userID2"}, userIDs)
+}
+
+func TestBuildPostBookmarks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	kv := mockKVStore(mockPluginAPI)
+
+	// bookmarks stored before the users of each post were recorded
+	for userID, postIDs := range map[string][]string{
+		"userID1": {"ID1", "ID2"},
+		"userID2": {"ID2"},
+	} {
+		var bmarks []*Bookmark
+		for _, postID := range postIDs {
+			bmarks = append(bmarks, &Bookmark{PostID: postID})
+		}
+		storeTestBookmarks(t, kv, userID, bmarks...)
+	}
+
+	users, err := BuildPostBookmarks(mockPluginAPI)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, users)
+
+	userIDs, err := GetPostBookmarkUserIDs(mockPluginAPI, "ID1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"userID1"}, userIDs)
+	userIDs, err = GetPostBookmarkUserIDs(mockPluginAPI, "ID2")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"userID1", "userID2"}, userIDs)
+
+	// the post bookmarks are only built once
+	users, err = BuildPostBookmarks(mockPluginAPI)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, users)
+}
+
+func TestMarkPost(t *testing.T) {
+	tests := map[string]struct {
+		mark     func(api *mock_pluginapi.MockAPI) error
+		expected *Bookmark
+	}{
+		"edited": {
+			mark: func(api *mock_pluginapi.MockAPI) error {
+				return MarkPostEdited(api, &model.Post{Id: "ID1", EditAt: 5})
+			},
+			expected: &Bookmark{PostID: "ID1", CreateAt: 1, PostEditedAt: 5},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+			kv := mockKVStore(mockPluginAPI)
+
+			for _, userID := range []string{"userID1", "userID2"} {
+				storeTestBookmarks(t, kv, userID, &Bookmark{PostID: "ID1", CreateAt: 1})
+			}
+			// userID3 removed the bookmark after the user was recorded
+			kv[GetPostBookmarksKey("ID1")] = mustEncodeDocument(t, []string{"userID1", "userID2", "userID3"})
+
+			assert.Nil(t, tt.mark(mockPluginAPI))
+
+			for _, userID := range []string{"userID1", "userID2"} {
+				assert.Equal(t, tt.expected, getStoredBookmark(t, kv, userID, "ID1"))
+			}
+			assert.NotContains(t, kv, GetBookmarksIndexKey("userID3"))
+
+			userIDs, err := GetPostBookmarkUserIDs(mockPluginAPI, "ID1")
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"userID1", "userID2"}, userIDs)
+		})
+	}
+}
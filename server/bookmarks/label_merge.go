@@ -0,0 +1,150 @@
+package bookmarks
+
+import (
+	"fmt"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/utils"
+	"github.com/pkg/errors"
+)
+
+// MergeLabels replaces the source labels with the destination label on every
+// bookmark and removes the source labels. The destination label is created
+// if it does not exist. It returns the number of bookmarks changed.
+//
+// The source labels are removed and redirected to the destination label in a
+// single store, so bookmarks still holding a source label are displayed with
+// the destination label. The redirects are removed once the bookmarks are
+// changed. A merge that fails part way is completed by the next merge
+func MergeLabels(bmarks *Bookmarks, labels *Labels, sources []string, dest string) (int, error) {
+	dest = normalizeLabelName(dest)
+	if err := validateLabelName(dest); err != nil {
+		return 0, err
+	}
+
+	err := labels.StoreLabels(func(labels *Labels) error {
+		return labels.redirectLabels(sources, dest)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	redirects := make(map[string]string, len(labels.Redirects))
+	for from, to := range labels.Redirects {
+		redirects[from] = to
+	}
+
+	var changed int
+	err = bmarks.StoreBookmarks(func(bmarks *Bookmarks) error {
+		changed = 0
+		for _, bmark := range bmarks.ByID {
+			if bmark.redirectLabelIDs(redirects) {
+				bmarks.updateTimes(bmark.PostID)
+				changed++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to merge labels")
+	}
+
+	err = labels.StoreLabels(func(labels *Labels) error {
+		for from := range redirects {
+			delete(labels.Redirects, from)
+		}
+		if len(labels.Redirects) == 0 {
+			labels.Redirects = nil
+		}
+		return nil
+	})
+	if err != nil {
+		return changed, errors.Wrap(err, "failed to remove merged labels")
+	}
+
+	return changed, nil
+}
+
+// redirectLabels removes the source labels and redirects their IDs to the
+// destination label, which is created if it does not exist
+func (l *Labels) redirectLabels(sources []string, dest string) error {
+	destID, _ := l.getIDByName(dest)
+
+	sourceIDs := make(map[string]bool)
+	for _, name := range sources {
+		id, err := l.GetIDFromName(name)
+		if err != nil {
+			return err
+		}
+		if id == destID {
+			return errors.New(fmt.Sprintf("Cannot merge label `%v` into itself", name))
+		}
+		source := l.ByID[id]
+		if len(l.GetDescendants(source.Name)) != 0 {
+			return errors.New(fmt.Sprintf("Label `%v` has nested labels. Merge or remove them first", name))
+		}
+		if isLabelDescendant(dest, source.Name) {
+			return errors.New(fmt.Sprintf("Cannot merge label `%v` into `%v`. A label cannot be merged into a label nested under it", name, dest))
+		}
+		sourceIDs[id] = true
+	}
+
+	if destID == "" {
+		destID = utils.NewID()
+		l.ByID[destID] = &Label{
+			Name: dest,
+			ID:   destID,
+		}
+		// the parents of a nested label are created with it
+		l.addAncestors(dest)
+	}
+
+	if l.Redirects == nil {
+		l.Redirects = make(map[string]string)
+	}
+	// labels merged into a source label earlier now lead to the destination
+	for from, to := range l.Redirects {
+		if sourceIDs[to] {
+			l.Redirects[from] = destID
+		}
+	}
+	for id := range sourceIDs {
+		delete(l.ByID, id)
+		l.Redirects[id] = destID
+	}
+	return nil
+}
+
+// redirectLabelIDs replaces the IDs of merged labels of the bookmark with the
+// IDs of the labels they were merged into. It returns true if the bookmark
+// changed
+func (bm *Bookmark) redirectLabelIDs(redirects map[string]string) bool {
+	changed := false
+	seen := make(map[string]bool)
+	var labelIDs []string
+	for _, id := range bm.GetLabelIDs() {
+		if to, ok := redirects[id]; ok {
+			id = to
+			changed = true
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		labelIDs = append(labelIDs, id)
+	}
+
+	if changed {
+		bm.AddLabelIDs(labelIDs)
+	}
+	return changed
+}
+
+// hasAnyLabelID returns true if the bookmark has any of the label IDs
+func (bm *Bookmark) hasAnyLabelID(ids map[string]bool) bool {
+	for _, id := range bm.GetLabelIDs() {
+		if ids[id] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package bookmarks
+
+import (
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeLabels(t *testing.T) {
+	tests := map[string]struct {
+		sources          []string
+		dest             string
+		expectedChanged  int
+		expectedLabelIDs map[string][]string
+		expectedNames    []string
+		wantErrMsg       string
+	}{
+		"merged into an existing label": {
+			sources:         []string{"db", "DB"},
+			dest:            "database",
+			expectedChanged: 3,
+			expectedLabelIDs: map[string][]string{
+				"ID1": {"UUID3", "UUID4"},
+				"ID2": {"UUID3"},
+				"ID3": {"UUID3"},
+				"ID4": {"UUID4"},
+			},
+			expectedNames: []string{"database", "prod", "projects", "projects/apollo"},
+		},
+		"merged into a new label": {
+			sources:         []string{"db", "DB", "database"},
+			dest:            "storage/db",
+			expectedChanged: 3,
+			expectedLabelIDs: map[string][]string{
+				"ID1": {"new", "UUID4"},
+				"ID2": {"new"},
+				"ID3": {"new"},
+				"ID4": {"UUID4"},
+			},
+			expectedNames: []string{"prod", "projects", "projects/apollo", "storage", "storage/db"},
+		},
+		"source does not exist": {
+			sources:    []string{"cache"},
+			dest:       "database",
+			wantErrMsg: "Label: `cache` does not exist",
+		},
+		"source has nested labels": {
+			sources:    []string{"projects"},
+			dest:       "database",
+			wantErrMsg: "Label `projects` has nested labels. Merge or remove them first",
+		},
+		"merged into a label nested under a source": {
+			sources:    []string{"db"},
+			dest:       "db/x",
+			wantErrMsg: "Cannot merge label `db` into `db/x`. A label cannot be merged into a label nested under it",
+		},
+		"merged into itself": {
+			sources:    []string{"db", "database"},
+			dest:       "database",
+			wantErrMsg: "Cannot merge label `database` into itself",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+			kv := mockKVStore(mockPluginAPI)
+			kv[GetLabelsKey(UserID)] = mustEncodeDocument(t, &Labels{ByID: map[string]*Label{
+				"UUID1": {Name: "db", ID: "UUID1"},
+				"UUID2": {Name: "DB", ID: "UUID2"},
+				"UUID3": {Name: "database", ID: "UUID3"},
+				"UUID4": {Name: "prod", ID: "UUID4"},
+				"UUID5": {Name: "projects", ID: "UUID5"},
+				"UUID6": {Name: "projects/apollo", ID: "UUID6"},
+			}})
+			storeTestBookmarks(t, kv, UserID,
+				&Bookmark{PostID: "ID1", LabelIDs: []string{"UUID1", "UUID4", "UUID2"}},
+				&Bookmark{PostID: "ID2", LabelIDs: []string{"UUID1", "UUID3"}},
+				&Bookmark{PostID: "ID3", LabelIDs: []string{"UUID2"}},
+				&Bookmark{PostID: "ID4", LabelIDs: []string{"UUID4"}},
+			)
+
+			bmarks, err := NewBookmarksWithUser(mockPluginAPI, UserID)
+			assert.Nil(t, err)
+			labels, err := NewLabelsWithUser(mockPluginAPI, UserID)
+			assert.Nil(t, err)
+
+			changed, err := MergeLabels(bmarks, labels, tt.sources, tt.dest)
+			if tt.wantErrMsg != "" {
+				assert.EqualError(t, err, tt.wantErrMsg)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expectedChanged, changed)
+
+			labels, err = NewLabelsWithUser(mockPluginAPI, UserID)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expectedNames, getLabelNames(labels))
+			assert.Nil(t, labels.Redirects)
+			destID, err := labels.GetIDFromName(tt.dest)
+			assert.Nil(t, err)
+
+			bmarks, err = NewBookmarksWithUser(mockPluginAPI, UserID)
+			assert.Nil(t, err)
+			for id, expected := range tt.expectedLabelIDs {
+				for i := range expected {
+					if expected[i] == "new" {
+						expected[i] = destID
+					}
+				}
+				assert.Equal(t, expected, bmarks.ByID[id].LabelIDs, id)
+			}
+		})
+	}
+}
+
+func TestMergeLabels_interrupted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	kv := mockKVStore(mockPluginAPI)
+	kv[GetLabelsKey(UserID)] = mustEncodeDocument(t, &Labels{ByID: map[string]*Label{
+		"UUID1": {Name: "db", ID: "UUID1"},
+		"UUID2": {Name: "database", ID: "UUID2"},
+		"UUID3": {Name: "cache", ID: "UUID3"},
+		"UUID4": {Name: "storage", ID: "UUID4"},
+	}})
+	storeTestBookmarks(t, kv, UserID,
+		&Bookmark{PostID: "ID1", LabelIDs: []string{"UUID1"}},
+		&Bookmark{PostID: "ID2", LabelIDs: []string{"UUID3"}},
+	)
+
+	// a merge of db into database stopped after the labels were stored
+	labels, err := NewLabelsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Nil(t, labels.StoreLabels(func(labels *Labels) error {
+		return labels.redirectLabels([]string{"db"}, "database")
+	}))
+
+	// the bookmarks still holding db are displayed with database
+	bmarks, err := NewBookmarksWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	names, err := bmarks.GetBmarkLabelNames(bmarks.ByID["ID1"])
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"database"}, names)
+	assert.Nil(t, labels.GetLabelByName("db"))
+
+	// merging database and cache completes the interrupted merge
+	changed, err := MergeLabels(bmarks, labels, []string{"database", "cache"}, "storage")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, changed)
+
+	labels, err = NewLabelsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"storage"}, getLabelNames(labels))
+	assert.Nil(t, labels.Redirects)
+
+	bmarks, err = NewBookmarksWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"UUID4"}, bmarks.ByID["ID1"].LabelIDs)
+	assert.Equal(t, []string{"UUID4"}, bmarks.ByID["ID2"].LabelIDs)
+}
@@ -0,0 +1,151 @@
+package bookmarks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// duplicateLabelsCheckedKey is set once the labels of every user were checked
+// for names that differ only in case
+const duplicateLabelsCheckedKey = "duplicate_labels_checked"
+
+// normalizeLabelName returns a label name without surrounding spaces and in
+// Unicode compatibility composed form, so names typed with different but
+// equivalent characters are stored the same way
+func normalizeLabelName(name string) string {
+	return norm.NFKC.String(strings.TrimSpace(name))
+}
+
+// getLabelKey returns the key label names are compared by. Names with the same
+// key differ only in case, surrounding spaces or Unicode form
+func getLabelKey(name string) string {
+	return cases.Fold().String(normalizeLabelName(name))
+}
+
+// hasName returns true if a name is the name or an alias of the label
+func (l *Label) hasName(name string) bool {
+	key := getLabelKey(name)
+	if getLabelKey(l.Name) == key {
+		return true
+	}
+	for _, alias := range l.Aliases {
+		if getLabelKey(alias) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// removeAlias removes an alias from the label. It returns false if the label
+// does not have the alias
+func (l *Label) removeAlias(alias string) bool {
+	key := getLabelKey(alias)
+	for i, a := range l.Aliases {
+		if getLabelKey(a) != key {
+			continue
+		}
+		l.Aliases = append(l.Aliases[:i:i], l.Aliases[i+1:]...)
+		if len(l.Aliases) == 0 {
+			l.Aliases = nil
+		}
+		return true
+	}
+	return false
+}
+
+// GetDuplicates returns the groups of labels whose names differ only in case,
+// surrounding spaces or Unicode form. Labels stored before names were
+// normalized may have such duplicates
+func (l *Labels) GetDuplicates() [][]*Label {
+	byKey := make(map[string][]*Label)
+	for _, label := range l.ByID {
+		key := getLabelKey(label.Name)
+		byKey[key] = append(byKey[key], label)
+	}
+
+	var duplicates [][]*Label
+	for _, group := range byKey {
+		if len(group) < 2 {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].Name < group[j].Name
+		})
+		duplicates = append(duplicates, group)
+	}
+	sort.Slice(duplicates, func(i, j int) bool {
+		return duplicates[i][0].Name < duplicates[j][0].Name
+	})
+	return duplicates
+}
+
+// GetDuplicatesText returns the groups of duplicate labels, each with the
+// command that merges them
+func GetDuplicatesText(duplicates [][]*Label) string {
+	text := ""
+	for _, group := range duplicates {
+		names := make([]string, 0, len(group))
+		for _, label := range group {
+			names = append(names, label.Name)
+		}
+		text += fmt.Sprintf("*%s - merge them with `/bookmarks label merge %s`\n",
+			GetCodeBlockedLabels(names), strings.Join(names, " "))
+	}
+	return text
+}
+
+// FindDuplicateLabels returns the groups of duplicate labels of every user
+// with duplicates. Labels are normalized as they are added, so this only runs
+// until it completes once. The check is claimed first, so only one server of
+// a cluster returns the duplicates
+func FindDuplicateLabels(api pluginapi.API) (map[string][][]*Label, error) {
+	claimed, appErr := api.KVSetWithOptions(duplicateLabelsCheckedKey, []byte("true"), model.PluginKVSetOptions{
+		Atomic:   true,
+		OldValue: nil,
+	})
+	if appErr != nil {
+		return nil, appErr
+	}
+	if !claimed {
+		return nil, nil
+	}
+
+	duplicates, err := findDuplicateLabels(api)
+	if err != nil {
+		// release the claim, so the labels are checked again on the next
+		// activation. The error of the check is returned either way
+		_, _ = api.KVSetWithOptions(duplicateLabelsCheckedKey, nil, model.PluginKVSetOptions{
+			Atomic:   true,
+			OldValue: []byte("true"),
+		})
+		return nil, err
+	}
+	return duplicates, nil
+}
+
+// findDuplicateLabels returns the groups of duplicate labels of every user
+// with duplicates
+func findDuplicateLabels(api pluginapi.API) (map[string][][]*Label, error) {
+	userIDs, err := listUserIDs(api, StoreLabelsKey)
+	if err != nil {
+		return nil, err
+	}
+
+	duplicates := make(map[string][][]*Label)
+	for _, userID := range userIDs {
+		labels, err := NewLabelsWithUser(api, userID)
+		if err != nil {
+			return nil, err
+		}
+		if groups := labels.GetDuplicates(); len(groups) != 0 {
+			duplicates[userID] = groups
+		}
+	}
+	return duplicates, nil
+}
@@ -0,0 +1,267 @@
+package bookmarks
+
+import (
+	"sort"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetLabelKey(t *testing.T) {
+	tests := map[string]struct {
+		a, b  string
+		equal bool
+	}{
+		"case":                {a: "Prod", b: "prod", equal: true},
+		"surrounding spaces":  {a: " prod ", b: "prod", equal: true},
+		"unicode form":        {a: "café", b: "café", equal: true},
+		"compatibility form":  {a: "ｐｒｏｄ", b: "prod", equal: true},
+		"special case fold":   {a: "Straße", b: "STRASSE", equal: true},
+		"nested label":        {a: "Projects/Apollo", b: "projects/apollo", equal: true},
+		"different names":     {a: "prod", b: "production", equal: false},
+		"inner spaces differ": {a: "my label", b: "mylabel", equal: false},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.equal, getLabelKey(tt.a) == getLabelKey(tt.b))
+		})
+	}
+}
+
+func TestLabelsGetLabelByName(t *testing.T) {
+	labels := &Labels{ByID: map[string]*Label{
+		"UUID1": {Name: "database", ID: "UUID1", Aliases: []string{"db"}},
+		// duplicates stored before names were normalized
+		"UUID2": {Name: "Prod", ID: "UUID2"},
+		"UUID3": {Name: "prod", ID: "UUID3"},
+	}}
+
+	tests := map[string]struct {
+		name       string
+		expectedID string
+	}{
+		"exact name":            {name: "database", expectedID: "UUID1"},
+		"name in another case":  {name: "DataBase", expectedID: "UUID1"},
+		"alias":                 {name: "db", expectedID: "UUID1"},
+		"alias in another case": {name: " DB", expectedID: "UUID1"},
+		"exact duplicate":       {name: "Prod", expectedID: "UUID2"},
+		"other exact duplicate": {name: "prod", expectedID: "UUID3"},
+		"unknown name":          {name: "dev"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			id, err := labels.GetIDFromName(tt.name)
+			if tt.expectedID == "" {
+				assert.Nil(t, labels.GetLabelByName(tt.name))
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expectedID, id)
+			assert.Equal(t, tt.expectedID, labels.GetLabelByName(tt.name).ID)
+		})
+	}
+}
+
+func TestAddLabel_normalized(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	kv := mockKVStore(mockPluginAPI)
+	kv[GetLabelsKey(UserID)] = mustEncodeDocument(t, &Labels{ByID: map[string]*Label{
+		"UUID1": {Name: "Projects", ID: "UUID1"},
+	}})
+
+	labels, err := NewLabelsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+
+	_, err = labels.AddLabel("PROJECTS")
+	assert.EqualError(t, err, "Label with name `Projects` already exists")
+
+	label, err := labels.AddLabel(" projects/ａｐｏｌｌｏ ")
+	assert.Nil(t, err)
+	assert.Equal(t, "projects/apollo", label.Name)
+
+	// the existing parent is found regardless of case
+	labels, err = NewLabelsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Projects", "projects/apollo"}, getLabelNames(labels))
+}
+
+func TestRenameLabel_case(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	kv := mockKVStore(mockPluginAPI)
+	kv[GetLabelsKey(UserID)] = mustEncodeDocument(t, &Labels{ByID: map[string]*Label{
+		"UUID1": {Name: "projects", ID: "UUID1", Aliases: []string{"proj"}},
+		"UUID2": {Name: "projects/apollo", ID: "UUID2"},
+		"UUID3": {Name: "prod", ID: "UUID3"},
+	}})
+
+	labels, err := NewLabelsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Nil(t, labels.RenameLabel("PROJECTS", "Projects"))
+	assert.EqualError(t, labels.RenameLabel("Projects", "PROD"),
+		"Cannot rename Label `Projects` to `PROD`. Label already exists. Please choose a different label name")
+
+	labels, err = NewLabelsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Projects", "Projects/apollo", "prod"}, getLabelNames(labels))
+
+	// a label renamed to its alias loses the alias
+	assert.Nil(t, labels.RenameLabel("projects", "proj"))
+	labels, err = NewLabelsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Equal(t, &Label{Name: "proj", ID: "UUID1"}, labels.ByID["UUID1"])
+}
+
+func TestLabelsAliases(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	kv := mockKVStore(mockPluginAPI)
+	kv[GetLabelsKey(UserID)] = mustEncodeDocument(t, &Labels{ByID: map[string]*Label{
+		"UUID1": {Name: "database", ID: "UUID1"},
+		"UUID2": {Name: "prod", ID: "UUID2"},
+	}})
+
+	labels, err := NewLabelsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Nil(t, labels.AddAlias("Database", "db"))
+	assert.Nil(t, labels.AddAlias("db", "pg"))
+	assert.EqualError(t, labels.AddAlias("prod", "DB"), "Alias `DB` is already the name or an alias of label `database`")
+	assert.EqualError(t, labels.AddAlias("prod", "Database"), "Alias `Database` is already the name or an alias of label `database`")
+	assert.EqualError(t, labels.AddAlias("prod", "a/b"), "Alias `a/b` is not a valid alias. Aliases cannot contain `/`")
+	assert.EqualError(t, labels.AddAlias("dev", "d"), "Label `dev` does not exist")
+
+	labels, err = NewLabelsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"db", "pg"}, labels.ByID["UUID1"].Aliases)
+
+	label, err := labels.RemoveAlias("DB")
+	assert.Nil(t, err)
+	assert.Equal(t, "database", label.Name)
+	_, err = labels.RemoveAlias("db")
+	assert.EqualError(t, err, "Alias `db` does not exist")
+
+	_, err = labels.RemoveAlias("pg")
+	assert.Nil(t, err)
+	labels, err = NewLabelsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Equal(t, &Label{Name: "database", ID: "UUID1"}, labels.ByID["UUID1"])
+}
+
+func TestApplyFilters_labelNamesAndAliases(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	kv := mockKVStore(mockPluginAPI)
+	kv[GetLabelsKey(UserID)] = mustEncodeDocument(t, &Labels{ByID: map[string]*Label{
+		"UUID1": {Name: "Database", ID: "UUID1", Aliases: []string{"db"}},
+		"UUID2": {Name: "Projects/Apollo", ID: "UUID2"},
+		"UUID3": {Name: "prod", ID: "UUID3"},
+	}})
+
+	bmarks := NewBookmarks(UserID)
+	bmarks.api = mockPluginAPI
+	for id, labelID := range map[string]string{"ID1": "UUID1", "ID2": "UUID2", "ID3": "UUID3"} {
+		bmarks.ByID[id] = &Bookmark{PostID: id, LabelIDs: []string{labelID}}
+	}
+
+	aliasQuery, err := ParseQuery("label:DB OR label:projects")
+	assert.Nil(t, err)
+
+	tests := map[string]struct {
+		filters     *Filters
+		expectedIDs []string
+	}{
+		"name in another case":      {filters: &Filters{LabelNames: []string{"database"}}, expectedIDs: []string{"ID1"}},
+		"alias":                     {filters: &Filters{LabelNames: []string{"DB"}}, expectedIDs: []string{"ID1"}},
+		"missing parent":            {filters: &Filters{LabelNames: []string{"projects"}}, expectedIDs: []string{"ID2"}},
+		"query of alias and parent": {filters: &Filters{Query: aliasQuery}, expectedIDs: []string{"ID1", "ID2"}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			filtered, err := bmarks.ApplyFilters(tt.filters)
+			assert.Nil(t, err)
+			ids := filtered.getPostIDs()
+			sort.Strings(ids)
+			assert.Equal(t, tt.expectedIDs, ids)
+		})
+	}
+}
+
+func TestGetDuplicatesText(t *testing.T) {
+	labels := &Labels{ByID: map[string]*Label{
+		"UUID1": {Name: "Prod", ID: "UUID1"},
+		"UUID2": {Name: "prod", ID: "UUID2"},
+		"UUID3": {Name: "PROD", ID: "UUID3"},
+		"UUID4": {Name: "db", ID: "UUID4"},
+		"UUID5": {Name: "DB", ID: "UUID5"},
+		"UUID6": {Name: "docs", ID: "UUID6"},
+	}}
+
+	expected := "* `DB` `db` - merge them with `/bookmarks label merge DB db`\n" +
+		"* `PROD` `Prod` `prod` - merge them with `/bookmarks label merge PROD Prod prod`\n"
+	assert.Equal(t, expected, GetDuplicatesText(labels.GetDuplicates()))
+	assert.Empty(t, (&Labels{ByID: map[string]*Label{"UUID6": {Name: "docs"}}}).GetDuplicates())
+}
+
+func TestFindDuplicateLabels(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	kv := mockKVStore(mockPluginAPI)
+	kv[GetLabelsKey("user1")] = mustEncodeDocument(t, &Labels{ByID: map[string]*Label{
+		"UUID1": {Name: "Prod", ID: "UUID1"},
+		"UUID2": {Name: "prod", ID: "UUID2"},
+	}})
+	kv[GetLabelsKey("user2")] = mustEncodeDocument(t, &Labels{ByID: map[string]*Label{
+		"UUID3": {Name: "prod", ID: "UUID3"},
+	}})
+
+	duplicates, err := FindDuplicateLabels(mockPluginAPI)
+	assert.Nil(t, err)
+	assert.Len(t, duplicates, 1)
+	assert.Len(t, duplicates["user1"], 1)
+	assert.Len(t, duplicates["user1"][0], 2)
+
+	// users are only told once
+	duplicates, err = FindDuplicateLabels(mockPluginAPI)
+	assert.Nil(t, err)
+	assert.Empty(t, duplicates)
+}
+
+func TestFindDuplicateLabels_claimed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+
+	// another server claimed the check first. The labels are not read
+	mockPluginAPI.EXPECT().KVSetWithOptions(duplicateLabelsCheckedKey, []byte("true"), model.PluginKVSetOptions{Atomic: true}).Return(false, nil)
+
+	duplicates, err := FindDuplicateLabels(mockPluginAPI)
+	assert.Nil(t, err)
+	assert.Empty(t, duplicates)
+}
+
+func TestFindDuplicateLabels_failed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+
+	// a failed check releases the claim
+	gomock.InOrder(
+		mockPluginAPI.EXPECT().KVSetWithOptions(duplicateLabelsCheckedKey, []byte("true"), model.PluginKVSetOptions{Atomic: true}).Return(true, nil),
+		mockPluginAPI.EXPECT().KVList(0, migrateKVListPerPage).Return(nil, errors.New("list failed")),
+		mockPluginAPI.EXPECT().KVSetWithOptions(duplicateLabelsCheckedKey, nil, model.PluginKVSetOptions{Atomic: true, OldValue: []byte("true")}).Return(true, nil),
+	)
+
+	_, err := FindDuplicateLabels(mockPluginAPI)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,162 @@
+package bookmarks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/utils"
+	"github.com/pkg/errors"
+)
+
+// LabelPathSeparator separates the names of nested labels in a label path,
+// e.g. projects/apollo/backend
+const LabelPathSeparator = "/"
+
+// validateLabelName returns an error if a label path has an empty part
+func validateLabelName(name string) error {
+	for _, part := range strings.Split(name, LabelPathSeparator) {
+		if part == "" {
+			return errors.New(fmt.Sprintf("Label `%s` is not a valid label name. Nested label names are separated by `%s`, e.g. `projects/apollo`", name, LabelPathSeparator))
+		}
+	}
+	return nil
+}
+
+// getLabelAncestors returns the paths of the ancestors of a label, starting
+// with the top-level label
+func getLabelAncestors(name string) []string {
+	var ancestors []string
+	for i, r := range name {
+		if string(r) == LabelPathSeparator {
+			ancestors = append(ancestors, name[:i])
+		}
+	}
+	return ancestors
+}
+
+// getParentName returns the path of the parent of a label, or an empty string
+// for a top-level label
+func getParentName(name string) string {
+	i := strings.LastIndex(name, LabelPathSeparator)
+	if i == -1 {
+		return ""
+	}
+	return name[:i]
+}
+
+// isLabelDescendant returns true if a label is nested under an ancestor label
+// at any depth
+func isLabelDescendant(name, ancestor string) bool {
+	return strings.HasPrefix(getLabelKey(name), getLabelKey(ancestor)+LabelPathSeparator)
+}
+
+// getLabelSubpath returns the path of a label below one of its ancestors
+func getLabelSubpath(name, ancestor string) string {
+	parts := strings.Split(name, LabelPathSeparator)
+	depth := len(strings.Split(ancestor, LabelPathSeparator))
+	return strings.Join(parts[depth:], LabelPathSeparator)
+}
+
+// compareLabelNames orders label paths part by part regardless of case, so
+// nested labels follow their parent
+func compareLabelNames(a, b string) int {
+	partsA := strings.Split(getLabelKey(a), LabelPathSeparator)
+	partsB := strings.Split(getLabelKey(b), LabelPathSeparator)
+	for i := 0; i < len(partsA) && i < len(partsB); i++ {
+		if c := strings.Compare(partsA[i], partsB[i]); c != 0 {
+			return c
+		}
+	}
+	if len(partsA) != len(partsB) {
+		return len(partsA) - len(partsB)
+	}
+	return strings.Compare(a, b)
+}
+
+// GetParent returns the parent of a nested label, or nil for a top-level
+// label
+func (l *Labels) GetParent(label *Label) *Label {
+	parentName := getParentName(label.Name)
+	if parentName == "" {
+		return nil
+	}
+	return l.GetLabelByName(parentName)
+}
+
+// GetDescendants returns the labels nested under a label at any depth,
+// ordered as a tree
+func (l *Labels) GetDescendants(name string) []*Label {
+	var descendants []*Label
+	for _, label := range l.ByID {
+		if isLabelDescendant(label.Name, name) {
+			descendants = append(descendants, label)
+		}
+	}
+	sort.Slice(descendants, func(i, j int) bool {
+		return compareLabelNames(descendants[i].Name, descendants[j].Name) < 0
+	})
+	return descendants
+}
+
+// addAncestors adds the missing ancestors of a nested label without storing
+// them
+func (l *Labels) addAncestors(name string) {
+	for _, ancestor := range getLabelAncestors(name) {
+		if l.GetLabelByName(ancestor) != nil {
+			continue
+		}
+		id := utils.NewID()
+		l.ByID[id] = &Label{
+			Name: ancestor,
+			ID:   id,
+		}
+	}
+}
+
+// GetTreeText returns the labels as a nested markdown list. Each label is
+// listed by the last part of its path under its parent, followed by its
+// aliases
+func (l *Labels) GetTreeText() string {
+	// ancestors of labels stored before labels were nested may be missing.
+	// They are listed by name only
+	keys := make(map[string]bool)
+	names := make([]string, 0, len(l.ByID))
+	for _, label := range l.ByID {
+		keys[getLabelKey(label.Name)] = true
+		names = append(names, label.Name)
+	}
+	for _, label := range l.ByID {
+		for _, ancestor := range getLabelAncestors(label.Name) {
+			if !keys[getLabelKey(ancestor)] {
+				keys[getLabelKey(ancestor)] = true
+				names = append(names, ancestor)
+			}
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return compareLabelNames(names[i], names[j]) < 0
+	})
+
+	text := ""
+	for _, name := range names {
+		depth := len(getLabelAncestors(name))
+		node := &Label{Name: name[strings.LastIndex(name, LabelPathSeparator)+1:]}
+
+		if label := l.GetLabelByName(name); label != nil {
+			node.Color = label.Color
+			node.Description = label.Description
+			node.Aliases = append([]string(nil), label.Aliases...)
+		}
+
+		line := strings.Repeat("  ", depth) + "- " + node.GetChip()
+		if len(node.Aliases) != 0 {
+			line += " (aliases:" + GetCodeBlockedLabels(node.Aliases) + ")"
+		}
+		if node.Description != "" {
+			line += " - " + node.Description
+		}
+		text += line + "\n"
+	}
+	return text
+}
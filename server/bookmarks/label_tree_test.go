@@ -0,0 +1,175 @@
+package bookmarks
+
+import (
+	"sort"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+	"github.com/stretchr/testify/assert"
+)
+
+// getLabelNames returns the sorted names of all labels
+func getLabelNames(labels *Labels) []string {
+	var names []string
+	for _, label := range labels.ByID {
+		names = append(names, label.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestValidateLabelName(t *testing.T) {
+	for _, name := range []string{"prod", "projects/apollo", "projects/apollo/backend"} {
+		assert.Nil(t, validateLabelName(name), name)
+	}
+	for _, name := range []string{"", "/projects", "projects/", "projects//apollo"} {
+		assert.NotNil(t, validateLabelName(name), name)
+	}
+}
+
+func TestAddLabel_nested(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	kv := mockKVStore(mockPluginAPI)
+	kv[GetLabelsKey(UserID)] = mustEncodeDocument(t, &Labels{ByID: map[string]*Label{
+		"UUID1": {Name: "projects", ID: "UUID1"},
+	}})
+
+	labels, err := NewLabelsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	label, err := labels.AddLabel("projects/apollo/backend")
+	assert.Nil(t, err)
+	assert.Equal(t, "projects/apollo/backend", label.Name)
+
+	labels, err = NewLabelsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"projects", "projects/apollo", "projects/apollo/backend"}, getLabelNames(labels))
+
+	parent := labels.GetParent(labels.GetLabelByName("projects/apollo/backend"))
+	assert.Equal(t, "projects/apollo", parent.Name)
+	assert.Equal(t, "UUID1", labels.GetParent(parent).ID)
+	assert.Nil(t, labels.GetParent(labels.GetLabelByName("projects")))
+}
+
+func TestRenameLabel_nested(t *testing.T) {
+	tests := map[string]struct {
+		from          string
+		to            string
+		expectedNames []string
+		wantErrMsg    string
+	}{
+		"subtree moved": {
+			from:          "projects",
+			to:            "work",
+			expectedNames: []string{"projects-old", "work", "work/apollo", "work/apollo/backend"},
+		},
+		"subtree moved under a new parent": {
+			from:          "projects/apollo",
+			to:            "archive/apollo",
+			expectedNames: []string{"archive", "archive/apollo", "archive/apollo/backend", "projects", "projects-old"},
+		},
+		"label with a similar name not moved": {
+			from:          "projects-old",
+			to:            "old",
+			expectedNames: []string{"old", "projects", "projects/apollo", "projects/apollo/backend"},
+		},
+		"label nested under itself": {
+			from:       "projects",
+			to:         "projects/apollo2",
+			wantErrMsg: "Cannot rename Label `projects` to `projects/apollo2`. A label cannot be nested under itself",
+		},
+		"label moved under an existing label": {
+			from:          "projects/apollo/backend",
+			to:            "projects-old/backend",
+			expectedNames: []string{"projects", "projects-old", "projects-old/backend", "projects/apollo"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+			kv := mockKVStore(mockPluginAPI)
+			kv[GetLabelsKey(UserID)] = mustEncodeDocument(t, &Labels{ByID: map[string]*Label{
+				"UUID1": {Name: "projects", ID: "UUID1"},
+				"UUID2": {Name: "projects/apollo", ID: "UUID2"},
+				"UUID3": {Name: "projects/apollo/backend", ID: "UUID3"},
+				"UUID4": {Name: "projects-old", ID: "UUID4"},
+			}})
+
+			labels, err := NewLabelsWithUser(mockPluginAPI, UserID)
+			assert.Nil(t, err)
+			err = labels.RenameLabel(tt.from, tt.to)
+			if tt.wantErrMsg != "" {
+				assert.EqualError(t, err, tt.wantErrMsg)
+				return
+			}
+			assert.Nil(t, err)
+
+			labels, err = NewLabelsWithUser(mockPluginAPI, UserID)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expectedNames, getLabelNames(labels))
+		})
+	}
+}
+
+func TestRenameLabel_nestedLabelExists(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	kv := mockKVStore(mockPluginAPI)
+	// a nested label stored before its parent existed
+	kv[GetLabelsKey(UserID)] = mustEncodeDocument(t, &Labels{ByID: map[string]*Label{
+		"UUID1": {Name: "projects", ID: "UUID1"},
+		"UUID2": {Name: "projects/apollo", ID: "UUID2"},
+		"UUID3": {Name: "work/apollo", ID: "UUID3"},
+	}})
+
+	labels, err := NewLabelsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	err = labels.RenameLabel("projects", "work")
+	assert.EqualError(t, err, "Cannot rename Label `projects` to `work`. Nested label `work/apollo` already exists")
+}
+
+func TestApplyFilters_nestedLabels(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	kv := mockKVStore(mockPluginAPI)
+	kv[GetLabelsKey(UserID)] = mustEncodeDocument(t, &Labels{ByID: map[string]*Label{
+		"UUID1": {Name: "projects", ID: "UUID1"},
+		"UUID2": {Name: "projects/apollo", ID: "UUID2"},
+		"UUID3": {Name: "projects/apollo/backend", ID: "UUID3"},
+		"UUID4": {Name: "projects-old", ID: "UUID4"},
+	}})
+
+	bmarks := NewBookmarks(UserID)
+	bmarks.api = mockPluginAPI
+	for id, labelID := range map[string]string{"ID1": "UUID1", "ID2": "UUID2", "ID3": "UUID3", "ID4": "UUID4"} {
+		bmarks.ByID[id] = &Bookmark{PostID: id, LabelIDs: []string{labelID}}
+	}
+
+	query, err := ParseQuery("label:projects/apollo")
+	assert.Nil(t, err)
+
+	tests := map[string]struct {
+		filters     *Filters
+		expectedIDs []string
+	}{
+		"parent label":     {filters: &Filters{LabelNames: []string{"projects"}}, expectedIDs: []string{"ID1", "ID2", "ID3"}},
+		"nested label":     {filters: &Filters{LabelNames: []string{"projects/apollo"}}, expectedIDs: []string{"ID2", "ID3"}},
+		"leaf label":       {filters: &Filters{LabelNames: []string{"projects/apollo/backend"}}, expectedIDs: []string{"ID3"}},
+		"query of a label": {filters: &Filters{Query: query}, expectedIDs: []string{"ID2", "ID3"}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			filtered, err := bmarks.ApplyFilters(tt.filters)
+			assert.Nil(t, err)
+			ids := filtered.getPostIDs()
+			sort.Strings(ids)
+			assert.Equal(t, tt.expectedIDs, ids)
+		})
+	}
+}
@@ -3,6 +3,8 @@ package bookmarks
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi"
 	"github.com/jfrerich/mattermost-plugin-bookmarks/server/utils"
@@ -11,16 +13,35 @@ import (
 
 // Labels contains a map of labels with the label name as the key
 type Labels struct {
-	ByID   map[string]*Label
+	ByID map[string]*Label
+
+	// Redirects maps the IDs of merged labels to the ID of the label they
+	// were merged into, until every bookmark holds the new ID
+	Redirects map[string]string `json:",omitempty"`
+
 	api    pluginapi.API
 	userID string
+
+	// stored holds the value last loaded from or stored to the KV store
+	stored []byte
 }
 
 // Label defines the parameters of a label
 type Label struct {
-	Name string `json:"name"`
-	ID   string `json:"id"`
-	// Color string `json:"color"`
+	Name        string   `json:"name"`
+	ID          string   `json:"id"`
+	Color       string   `json:"color,omitempty"`       // Hex color in the form #rrggbb
+	Description string   `json:"description,omitempty"` // What the label is used for
+	Aliases     []string `json:"aliases,omitempty"`     // Other names the label is found by
+}
+
+// GetChip returns the label name code blocked, preceded by an emoji of its
+// color if it has one
+func (l *Label) GetChip() string {
+	if l.Color == "" {
+		return fmt.Sprintf("`%s`", l.Name)
+	}
+	return fmt.Sprintf("%s `%s`", colorEmoji(l.Color), l.Name)
 }
 
 // NewLabels returns an initialized Labels struct
@@ -38,12 +59,13 @@ func NewLabelsWithUser(api pluginapi.API, userID string) (*Labels, error) {
 		return nil, errors.Wrapf(appErr, "Unable to get labels for user %s", userID)
 	}
 
-	userLabels, err := LabelsFromJSON(bb)
+	userLabels, err := labelsFromStored(bb)
 	if err != nil {
 		return nil, err
 	}
 	userLabels.api = api
 	userLabels.userID = userID
+	userLabels.stored = bb
 
 	return userLabels, nil
 }
@@ -64,26 +86,33 @@ func LabelsFromJSON(bytes []byte) (*Labels, error) {
 	return labels, nil
 }
 
-// GetNameFromID returns the Name of a Label
+// GetNameFromID returns the Name of a Label. The ID of a merged label returns
+// the name of the label it was merged into
 func (l *Labels) GetNameFromID(id string) (string, error) {
-	label, ok := l.ByID[id]
+	label, ok := l.ByID[l.resolveID(id)]
 	if !ok {
 		return "", nil
 	}
 	return label.Name, nil
 }
 
-// GetLabelByName returns a label with the provided label name
+// resolveID returns the ID of the label a merged label was merged into, or
+// the ID itself
+func (l *Labels) resolveID(id string) string {
+	if to, ok := l.Redirects[id]; ok {
+		return to
+	}
+	return id
+}
+
+// GetLabelByName returns a label with the provided label name or alias.
+// Names are compared regardless of case, surrounding spaces and Unicode form
 func (l *Labels) GetLabelByName(name string) *Label {
-	if l == nil {
+	id, ok := l.getIDByName(name)
+	if !ok {
 		return nil
 	}
-	for _, label := range l.ByID {
-		if label.Name == name {
-			return label
-		}
-	}
-	return nil
+	return l.ByID[id]
 }
 
 // GetIDFromName returns a label name with the corresponding label ID
@@ -93,34 +122,190 @@ func (l *Labels) GetIDFromName(name string) (string, error) {
 	}
 
 	// return the labelId if found
+	if id, ok := l.getIDByName(name); ok {
+		return id, nil
+	}
+	return "", errors.New(fmt.Sprintf("Label: `%s` does not exist", name))
+}
+
+// getIDByName returns the ID of the label with the provided label name or
+// alias. An exact match is preferred, so labels stored before names were
+// normalized can still be told apart
+func (l *Labels) getIDByName(name string) (string, bool) {
+	if l == nil {
+		return "", false
+	}
 	for id, label := range l.ByID {
 		if label.Name == name {
-			return id, nil
+			return id, true
 		}
 	}
-	return "", errors.New(fmt.Sprintf("Label: `%s` does not exist", name))
+	for id, label := range l.ByID {
+		if label.hasName(name) {
+			return id, true
+		}
+	}
+	return "", false
 }
 
 // addLabel stores a label into the users label store
 func (l *Labels) AddLabel(labelName string) (*Label, error) {
-	// check if name already exists
-	label := l.GetLabelByName(labelName)
+	labelName = normalizeLabelName(labelName)
+	if err := validateLabelName(labelName); err != nil {
+		return nil, err
+	}
 
 	// User already has label with this labelName
-	if label != nil {
-		return nil, errors.New(fmt.Sprintf("Label with name `%s` already exists", label.Name))
+	if existing := l.GetLabelByName(labelName); existing != nil {
+		return nil, errors.New(fmt.Sprintf("Label with name `%s` already exists", existing.Name))
 	}
 
-	labelID := utils.NewID()
-	label = &Label{
+	label := &Label{
 		Name: labelName,
-		ID:   labelID,
+		ID:   utils.NewID(),
 	}
 
-	l.ByID[labelID] = label
-	if err := l.StoreLabels(); err != nil {
+	err := l.StoreLabels(func(labels *Labels) error {
+		// another request added a label with this labelName
+		if existing := labels.GetLabelByName(labelName); existing != nil {
+			return errors.New(fmt.Sprintf("Label with name `%s` already exists", existing.Name))
+		}
+		labels.ByID[label.ID] = label
+		// the parents of a nested label are created with it
+		labels.addAncestors(labelName)
+		return nil
+	})
+	if err != nil {
 		return nil, errors.Wrap(err, "failed to add label")
 	}
 
 	return label, nil
 }
+
+// RenameLabel changes the name of a label. The labels nested under it are
+// moved with it
+func (l *Labels) RenameLabel(from, to string) error {
+	to = normalizeLabelName(to)
+	if err := validateLabelName(to); err != nil {
+		return err
+	}
+	if isLabelDescendant(to, from) {
+		return errors.New(fmt.Sprintf("Cannot rename Label `%v` to `%v`. A label cannot be nested under itself", from, to))
+	}
+
+	return l.StoreLabels(func(labels *Labels) error {
+		lfrom := labels.GetLabelByName(from)
+		if lfrom == nil {
+			return errors.New(fmt.Sprintf("Label `%v` does not exist", from))
+		}
+
+		// if the "to" label already exists, alert the user with options. A
+		// label may be renamed to change the case of its name
+		if lto := labels.GetLabelByName(to); lto != nil && (lto != lfrom || lto.Name == to) {
+			return errors.New(fmt.Sprintf("Cannot rename Label `%v` to `%v`. Label already exists. Please choose a different label name", from, to))
+		}
+
+		descendants := labels.GetDescendants(lfrom.Name)
+		for _, label := range descendants {
+			name := to + LabelPathSeparator + getLabelSubpath(label.Name, lfrom.Name)
+			if existing := labels.GetLabelByName(name); existing != nil && existing != label {
+				return errors.New(fmt.Sprintf("Cannot rename Label `%v` to `%v`. Nested label `%v` already exists", from, to, name))
+			}
+		}
+
+		for _, label := range descendants {
+			label.Name = to + LabelPathSeparator + getLabelSubpath(label.Name, lfrom.Name)
+		}
+		lfrom.Name = to
+		lfrom.removeAlias(to)
+		labels.addAncestors(to)
+		return nil
+	})
+}
+
+// SetColor sets the color of a label. An empty color removes it
+func (l *Labels) SetColor(name, color string) error {
+	if color != "" {
+		var err error
+		if color, err = ParseColor(color); err != nil {
+			return err
+		}
+	}
+
+	return l.StoreLabels(func(labels *Labels) error {
+		label := labels.GetLabelByName(name)
+		if label == nil {
+			return errors.New(fmt.Sprintf("Label `%v` does not exist", name))
+		}
+		label.Color = color
+		return nil
+	})
+}
+
+// SetDescription sets the description of a label. An empty description
+// removes it
+func (l *Labels) SetDescription(name, description string) error {
+	return l.StoreLabels(func(labels *Labels) error {
+		label := labels.GetLabelByName(name)
+		if label == nil {
+			return errors.New(fmt.Sprintf("Label `%v` does not exist", name))
+		}
+		label.Description = description
+		return nil
+	})
+}
+
+// GetChips returns a list of individually code blocked label names, each
+// preceded by an emoji of its color. Names of unknown labels are only code
+// blocked
+func (l *Labels) GetChips(names []string) string {
+	chips := ""
+	sort.Strings(names)
+	for _, name := range names {
+		label := l.GetLabelByName(name)
+		if label == nil {
+			label = &Label{Name: name}
+		}
+		chips += " " + label.GetChip()
+	}
+	return chips
+}
+
+// AddAlias adds another name a label is found by
+func (l *Labels) AddAlias(name, alias string) error {
+	alias = normalizeLabelName(alias)
+	if alias == "" || strings.Contains(alias, LabelPathSeparator) {
+		return errors.New(fmt.Sprintf("Alias `%s` is not a valid alias. Aliases cannot contain `%s`", alias, LabelPathSeparator))
+	}
+
+	return l.StoreLabels(func(labels *Labels) error {
+		label := labels.GetLabelByName(name)
+		if label == nil {
+			return errors.New(fmt.Sprintf("Label `%v` does not exist", name))
+		}
+		if existing := labels.GetLabelByName(alias); existing != nil {
+			return errors.New(fmt.Sprintf("Alias `%v` is already the name or an alias of label `%v`", alias, existing.Name))
+		}
+		label.Aliases = append(label.Aliases, alias)
+		return nil
+	})
+}
+
+// RemoveAlias removes an alias from the label it belongs to
+func (l *Labels) RemoveAlias(alias string) (*Label, error) {
+	var label *Label
+	err := l.StoreLabels(func(labels *Labels) error {
+		label = nil
+		for _, candidate := range labels.ByID {
+			if candidate.removeAlias(alias) {
+				label = candidate
+				return nil
+			}
+		}
+		return errors.New(fmt.Sprintf("Alias `%v` does not exist", alias))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return label, nil
+}
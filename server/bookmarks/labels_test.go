@@ -0,0 +1,66 @@
+package bookmarks
+
+import (
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabelsSetColorAndDescription(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	kv := mockKVStore(mockPluginAPI)
+	kv[GetLabelsKey(UserID)] = mustEncodeDocument(t, &Labels{ByID: map[string]*Label{
+		"UUID1": {Name: "prod", ID: "UUID1"},
+	}})
+
+	labels, err := NewLabelsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Nil(t, labels.SetColor("prod", "E53935"))
+	assert.Nil(t, labels.SetDescription("prod", "Production incidents"))
+	assert.EqualError(t, labels.SetColor("prod", "red"), "Color `red` is not a hex color like #1e90ff")
+	assert.EqualError(t, labels.SetColor("dev", "#000"), "Label `dev` does not exist")
+	assert.EqualError(t, labels.SetDescription("dev", "a description"), "Label `dev` does not exist")
+
+	labels, err = NewLabelsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Equal(t, &Label{Name: "prod", ID: "UUID1", Color: "#e53935", Description: "Production incidents"}, labels.ByID["UUID1"])
+
+	assert.Nil(t, labels.SetColor("prod", ""))
+	assert.Nil(t, labels.SetDescription("prod", ""))
+	labels, err = NewLabelsWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Equal(t, &Label{Name: "prod", ID: "UUID1"}, labels.ByID["UUID1"])
+}
+
+func TestGetBmarkTextOneLine_labelColors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	mockPluginAPI.EXPECT().GetConfig().Return(&model.Config{
+		ServiceSettings: model.ServiceSettings{SiteURL: model.NewString("https://myhost.com")},
+	}).AnyTimes()
+	mockPluginAPI.EXPECT().GetPost("ID1").Return(&model.Post{Id: "ID1", Message: "post message"}, nil)
+	mockChannelMember(mockPluginAPI)
+
+	// labels are loaded once for all bookmarks
+	mockPluginAPI.EXPECT().KVGet(GetLabelsKey(UserID)).Return(mustEncodeDocument(t, &Labels{ByID: map[string]*Label{
+		"UUID1": {Name: "prod", ID: "UUID1", Color: "#e53935"},
+		"UUID2": {Name: "docs", ID: "UUID2"},
+	}}), nil).Times(1)
+
+	bmark := &Bookmark{PostID: "ID1", Title: "title", LabelIDs: []string{"UUID1", "UUID2"}}
+	bmarks := NewBookmarks(UserID)
+	bmarks.api = mockPluginAPI
+	bmarks.ByID["ID1"] = bmark
+
+	names, err := bmarks.GetBmarkLabelNames(bmark)
+	assert.Nil(t, err)
+	text, err := bmarks.GetBmarkTextOneLine(bmark, names)
+	assert.Nil(t, err)
+	assert.Equal(t, "[:link:](https://myhost.com/_redirect/pl/ID1) `docs` 🔴 `prod` **_title_**\n", text)
+}
@@ -0,0 +1,80 @@
+package bookmarks
+
+import (
+	"time"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/utils"
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// jobLockSeconds is the time after which the lock of a node that stopped
+// while running a job expires
+const jobLockSeconds = 60
+
+// jobLock is the lock of a job held by this node. Its value is unique to the
+// node, so a node whose lock expired cannot release or extend the lock
+// another node took since
+type jobLock struct {
+	api         pluginapi.API
+	key         string
+	owner       []byte
+	refreshedAt time.Time
+}
+
+// lockJob locks a job that runs on every node of a cluster, so only one node
+// runs it at a time. It returns nil if another node holds the lock
+func lockJob(api pluginapi.API, key string) (*jobLock, error) {
+	owner := []byte(utils.NewID())
+
+	// a nil old value only sets a key that does not exist
+	locked, appErr := api.KVSetWithOptions(key, owner, model.PluginKVSetOptions{
+		Atomic:          true,
+		OldValue:        nil,
+		ExpireInSeconds: jobLockSeconds,
+	})
+	if appErr != nil {
+		return nil, appErr
+	}
+	if !locked {
+		return nil, nil
+	}
+
+	return &jobLock{
+		api:         api,
+		key:         key,
+		owner:       owner,
+		refreshedAt: time.Now(),
+	}, nil
+}
+
+// refresh extends the lock once half of its time has passed. A job calls it
+// between units of work, and stops if it returns false because the lock
+// expired and another node took it
+func (l *jobLock) refresh() (bool, error) {
+	if time.Since(l.refreshedAt) < jobLockSeconds*time.Second/2 {
+		return true, nil
+	}
+
+	held, appErr := l.api.KVSetWithOptions(l.key, l.owner, model.PluginKVSetOptions{
+		Atomic:          true,
+		OldValue:        l.owner,
+		ExpireInSeconds: jobLockSeconds,
+	})
+	if appErr != nil {
+		return false, appErr
+	}
+	if held {
+		l.refreshedAt = time.Now()
+	}
+	return held, nil
+}
+
+// unlock releases the lock if this node still holds it
+func (l *jobLock) unlock() {
+	// a nil value deletes the key
+	_, _ = l.api.KVSetWithOptions(l.key, nil, model.PluginKVSetOptions{
+		Atomic:   true,
+		OldValue: l.owner,
+	})
+}
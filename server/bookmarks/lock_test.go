@@ -0,0 +1,54 @@
+package bookmarks
+
+import (
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobLock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	kv := mockKVStore(mockPluginAPI)
+
+	lock, err := lockJob(mockPluginAPI, "job_lock")
+	assert.Nil(t, err)
+	assert.NotNil(t, lock)
+
+	// the lock is held by one node at a time
+	other, err := lockJob(mockPluginAPI, "job_lock")
+	assert.Nil(t, err)
+	assert.Nil(t, other)
+
+	// a recent lock is not refreshed
+	held, err := lock.refresh()
+	assert.Nil(t, err)
+	assert.True(t, held)
+
+	lock.refreshedAt = time.Now().Add(-jobLockSeconds * time.Second)
+	held, err = lock.refresh()
+	assert.Nil(t, err)
+	assert.True(t, held)
+	assert.WithinDuration(t, time.Now(), lock.refreshedAt, time.Second)
+
+	// the lock expired and another node took it
+	delete(kv, "job_lock")
+	other, err = lockJob(mockPluginAPI, "job_lock")
+	assert.Nil(t, err)
+	assert.NotNil(t, other)
+
+	lock.refreshedAt = time.Now().Add(-jobLockSeconds * time.Second)
+	held, err = lock.refresh()
+	assert.Nil(t, err)
+	assert.False(t, held)
+
+	// only the node holding the lock releases it
+	lock.unlock()
+	assert.Equal(t, other.owner, kv["job_lock"])
+	other.unlock()
+	assert.NotContains(t, kv, "job_lock")
+}
@@ -0,0 +1,98 @@
+package bookmarks
+
+import (
+	"strings"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi"
+	"github.com/pkg/errors"
+)
+
+// migrateKVListPerPage is the number of keys listed per page while looking
+// for users to migrate
+const migrateKVListPerPage = 200
+
+// MigrateBookmarks moves the bookmarks of every user still stored in a single
+// value to per-bookmark keys. It returns the IDs of the migrated users
+func MigrateBookmarks(api pluginapi.API) ([]string, error) {
+	// collect the users first. Migrating deletes keys, which would shift the
+	// pages being listed
+	userIDs, err := listUserIDs(api, StoreBookmarksKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrated []string
+	for _, userID := range userIDs {
+		// the index may already exist if an earlier migration stopped before
+		// deleting the single value
+		bmarks := NewBookmarks(userID)
+		bmarks.api = api
+		if _, err := bmarks.loadBookmarks(); err != nil {
+			return migrated, errors.Wrapf(err, "Unable to get bookmarks for user %s", userID)
+		}
+		if err := bmarks.migrateUserBookmarks(); err != nil {
+			return migrated, errors.Wrapf(err, "Unable to migrate bookmarks for user %s", userID)
+		}
+		migrated = append(migrated, userID)
+	}
+
+	return migrated, nil
+}
+
+// migrateUserBookmarks moves the bookmarks stored in a single value to
+// per-bookmark keys. Bookmarks already stored under their own key are kept.
+// The single value is deleted once all bookmarks and the index are stored
+func (b *Bookmarks) migrateUserBookmarks() error {
+	bb, appErr := b.api.KVGet(GetBookmarksKey(b.userID))
+	if appErr != nil {
+		return appErr
+	}
+	if bb == nil {
+		return nil
+	}
+
+	legacy, err := FromJSON(bb)
+	if err != nil {
+		return err
+	}
+
+	err = b.StoreBookmarks(func(bmarks *Bookmarks) error {
+		for id, bmark := range legacy.ByID {
+			if _, ok := bmarks.exists(id); ok {
+				continue
+			}
+			bmarks.ByID[id] = bmark
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if appErr := b.api.KVDelete(GetBookmarksKey(b.userID)); appErr != nil {
+		return appErr
+	}
+
+	return nil
+}
+
+// listUserIDs returns the user IDs of all keys named <prefix>_<userID>
+func listUserIDs(api pluginapi.API, prefix string) ([]string, error) {
+	var userIDs []string
+	for page := 0; ; page++ {
+		keys, err := api.KVList(page, migrateKVListPerPage)
+		if err != nil {
+			return nil, errors.Wrap(err, "Unable to list keys")
+		}
+
+		for _, key := range keys {
+			if strings.HasPrefix(key, prefix+"_") {
+				userIDs = append(userIDs, strings.TrimPrefix(key, prefix+"_"))
+			}
+		}
+
+		if len(keys) < migrateKVListPerPage {
+			return userIDs, nil
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package bookmarks
+
+import (
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi"
+	"github.com/pkg/errors"
+)
+
+// SchemaUpgradeResult counts the work of a schema upgrade
+type SchemaUpgradeResult struct {
+	Users     int // users with at least one outdated document
+	Documents int // outdated documents found
+}
+
+// UpgradeSchema upgrades the stored bookmarks, bookmark indexes and labels of
+// every user to the current schema version. With dryRun, the outdated
+// documents are only logged.
+//
+// Documents are also upgraded as they are read, so an interrupted upgrade is
+// safe to run again
+func UpgradeSchema(api pluginapi.API, dryRun bool, logf func(msg string, keyValuePairs ...interface{})) (*SchemaUpgradeResult, error) {
+	bmarkUserIDs, err := listUserIDs(api, StoreBookmarksIndexKey)
+	if err != nil {
+		return nil, err
+	}
+	labelUserIDs, err := listUserIDs(api, StoreLabelsKey)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SchemaUpgradeResult{}
+	outdatedUsers := make(map[string]bool)
+	defer func() {
+		result.Users = len(outdatedUsers)
+	}()
+
+	for _, userID := range bmarkUserIDs {
+		bmarks := NewBookmarks(userID)
+		bmarks.api = api
+		if _, err = bmarks.loadBookmarks(); err != nil {
+			return result, errors.Wrapf(err, "Unable to get bookmarks for user %s", userID)
+		}
+
+		outdated := bmarks.countOutdated()
+		if outdated == 0 {
+			continue
+		}
+		outdatedUsers[userID] = true
+		result.Documents += outdated
+
+		if dryRun {
+			logf("Dry run: bookmarks would be upgraded", "user_id", userID, "documents", outdated, "schema_version", SchemaVersion)
+			continue
+		}
+		if err = bmarks.StoreBookmarks(func(*Bookmarks) error { return nil }); err != nil {
+			return result, errors.Wrapf(err, "Unable to upgrade bookmarks for user %s", userID)
+		}
+		logf("Upgraded bookmarks", "user_id", userID, "documents", outdated, "schema_version", SchemaVersion)
+	}
+
+	for _, userID := range labelUserIDs {
+		labels, err := NewLabelsWithUser(api, userID)
+		if err != nil {
+			return result, err
+		}
+		if !isOutdated(labels.stored) {
+			continue
+		}
+		outdatedUsers[userID] = true
+		result.Documents++
+
+		if dryRun {
+			logf("Dry run: labels would be upgraded", "user_id", userID, "schema_version", SchemaVersion)
+			continue
+		}
+		if err = labels.StoreLabels(func(*Labels) error { return nil }); err != nil {
+			return result, errors.Wrapf(err, "Unable to upgrade labels for user %s", userID)
+		}
+		logf("Upgraded labels", "user_id", userID, "schema_version", SchemaVersion)
+	}
+
+	return result, nil
+}
+
+// countOutdated returns the number of loaded documents stored with an older
+// schema version
+func (b *Bookmarks) countOutdated() int {
+	count := 0
+	if isOutdated(b.storedIndex) {
+		count++
+	}
+	for _, bb := range b.storedByID {
+		if isOutdated(bb) {
+			count++
+		}
+	}
+	return count
+}
+
+// isOutdated returns true if a stored document has an older schema version
+func isOutdated(bb []byte) bool {
+	if bb == nil {
+		return false
+	}
+	version, _ := unwrapDocument(bb)
+	return version < SchemaVersion
+}
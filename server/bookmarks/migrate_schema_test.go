@@ -0,0 +1,72 @@
+package bookmarks
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpgradeSchema(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	kv := mockKVStore(mockPluginAPI)
+
+	b1 := &Bookmark{PostID: "ID1", Title: "Title1"}
+	b2 := &Bookmark{PostID: "ID2", Title: "Title2"}
+	rawB1, err := json.Marshal(b1)
+	assert.Nil(t, err)
+	rawB2, err := json.Marshal(b2)
+	assert.Nil(t, err)
+
+	// userID1 stored bookmarks and labels before schema versioning
+	kv[GetBookmarksIndexKey("userID1")] = []byte(`["ID1","ID2"]`)
+	kv[GetBookmarkKey("userID1", "ID1")] = rawB1
+	kv[GetBookmarkKey("userID1", "ID2")] = rawB2
+	kv[GetLabelsKey("userID1")] = []byte(`{"ByID":{"UUID1":{"name":"label1","id":"UUID1"}}}`)
+
+	// userID2 is up to date
+	storeTestBookmarks(t, kv, "userID2", b1)
+
+	var logs []string
+	logf := func(msg string, keyValuePairs ...interface{}) {
+		logs = append(logs, fmt.Sprint(msg, " ", keyValuePairs))
+	}
+
+	// a dry run logs the outdated documents without changing them
+	result, err := UpgradeSchema(mockPluginAPI, true, logf)
+	assert.Nil(t, err)
+	assert.Equal(t, &SchemaUpgradeResult{Users: 1, Documents: 4}, result)
+	assert.Equal(t, []string{
+		"Dry run: bookmarks would be upgraded [user_id userID1 documents 3 schema_version 1]",
+		"Dry run: labels would be upgraded [user_id userID1 schema_version 1]",
+	}, logs)
+	assert.Equal(t, []byte(`["ID1","ID2"]`), kv[GetBookmarksIndexKey("userID1")])
+	assert.Equal(t, rawB1, kv[GetBookmarkKey("userID1", "ID1")])
+
+	// the upgrade stores every document with the current version
+	logs = nil
+	result, err = UpgradeSchema(mockPluginAPI, false, logf)
+	assert.Nil(t, err)
+	assert.Equal(t, &SchemaUpgradeResult{Users: 1, Documents: 4}, result)
+	assert.Len(t, logs, 2)
+	assert.Equal(t, mustEncodeDocument(t, []string{"ID1", "ID2"}), kv[GetBookmarksIndexKey("userID1")])
+	assert.Equal(t, mustEncodeDocument(t, b1), kv[GetBookmarkKey("userID1", "ID1")])
+	assert.Equal(t, mustEncodeDocument(t, b2), kv[GetBookmarkKey("userID1", "ID2")])
+
+	labels, err := NewLabelsWithUser(mockPluginAPI, "userID1")
+	assert.Nil(t, err)
+	assert.False(t, isOutdated(labels.stored))
+	assert.Equal(t, "label1", labels.ByID["UUID1"].Name)
+
+	// nothing is left to upgrade
+	logs = nil
+	result, err = UpgradeSchema(mockPluginAPI, false, logf)
+	assert.Nil(t, err)
+	assert.Equal(t, &SchemaUpgradeResult{}, result)
+	assert.Empty(t, logs)
+}
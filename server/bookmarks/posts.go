@@ -0,0 +1,94 @@
+package bookmarks
+
+import (
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+// MarkPostEdited records the time a bookmarked post was edited for every user
+// who bookmarked it
+func MarkPostEdited(api pluginapi.API, post *model.Post) error {
+	editAt := post.EditAt
+	if editAt == 0 {
+		editAt = model.GetMillis()
+	}
+
+	return updatePostBookmarks(api, post.Id, func(bmark *Bookmark) {
+		if editAt > bmark.PostEditedAt {
+			bmark.PostEditedAt = editAt
+		}
+	})
+}
+
+// updatePostBookmarks applies update to the bookmark of a post of every user
+// who bookmarked it. All users are updated even if updating one of them
+// fails, and the first error is returned
+func updatePostBookmarks(api pluginapi.API, postID string, update func(bmark *Bookmark)) error {
+	userIDs, err := GetPostBookmarkUserIDs(api, postID)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to get the users who bookmarked post %s", postID)
+	}
+
+	var firstErr error
+	for _, userID := range userIDs {
+		if err := updateUserPostBookmark(api, userID, postID, update); err != nil && firstErr == nil {
+			firstErr = errors.Wrapf(err, "Unable to update the bookmark of post %s for user %s", postID, userID)
+		}
+	}
+	return firstErr
+}
+
+// updateUserPostBookmark applies update to the bookmark of a post of a user.
+// A user who no longer bookmarks the post is removed from its users
+func updateUserPostBookmark(api pluginapi.API, userID, postID string, update func(bmark *Bookmark)) error {
+	bmarks, err := NewBookmarksWithUser(api, userID)
+	if err != nil {
+		return err
+	}
+	if _, ok := bmarks.exists(postID); !ok {
+		return setPostBookmarked(api, postID, userID, false)
+	}
+
+	return bmarks.StoreBookmarks(func(bmarks *Bookmarks) error {
+		// the bookmark was removed after it was loaded
+		if bmark, ok := bmarks.exists(postID); ok {
+			update(bmark)
+		}
+		return nil
+	})
+}
+
+// deletedPost returns a placeholder for a bookmarked post that was deleted.
+// It has no message, channel or author. Deleted posts are only detected when
+// the server no longer finds them
+func deletedPost(postID string) *model.Post {
+	return &model.Post{
+		Id:       postID,
+		DeleteAt: model.GetMillis(),
+	}
+}
+
+// hiddenPostType is the type of the placeholder for a post the user cannot
+// read
+const hiddenPostType = "custom_bookmarks_hidden"
+
+// hiddenPost returns a placeholder for a bookmarked post in a channel the user
+// cannot read. It has no message, channel or author
+func hiddenPost(postID string) *model.Post {
+	return &model.Post{
+		Id:   postID,
+		Type: hiddenPostType,
+	}
+}
+
+// isPostHidden returns true if the post is a placeholder for a post the user
+// cannot read
+func isPostHidden(post *model.Post) bool {
+	return post.Type == hiddenPostType
+}
+
+// isPostDeleted returns true if the post is a placeholder for a deleted post
+func isPostDeleted(post *model.Post) bool {
+	return post.DeleteAt != 0
+}
@@ -0,0 +1,196 @@
+package bookmarks
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const queryLabelPrefix = "label:"
+
+// Query is a compiled boolean expression of label terms, for example
+// `label:prod AND (label:db OR label:cache) AND NOT label:done`.
+//
+// NOT binds tighter than AND, which binds tighter than OR. Terms written next
+// to each other without an operator are combined with AND
+type Query struct {
+	expr string
+	root queryNode
+}
+
+// queryNode is a node of a parsed query expression. hasLabel reports whether
+// the bookmark being evaluated carries the named label
+type queryNode interface {
+	eval(hasLabel func(name string) bool) bool
+}
+
+type queryAnd struct{ left, right queryNode }
+type queryOr struct{ left, right queryNode }
+type queryNot struct{ node queryNode }
+type queryLabel struct{ name string }
+
+func (q *queryAnd) eval(hasLabel func(string) bool) bool {
+	return q.left.eval(hasLabel) && q.right.eval(hasLabel)
+}
+
+func (q *queryOr) eval(hasLabel func(string) bool) bool {
+	return q.left.eval(hasLabel) || q.right.eval(hasLabel)
+}
+
+func (q *queryNot) eval(hasLabel func(string) bool) bool {
+	return !q.node.eval(hasLabel)
+}
+
+func (q *queryLabel) eval(hasLabel func(string) bool) bool {
+	return hasLabel(q.name)
+}
+
+// ParseQuery compiles a query expression
+func ParseQuery(expr string) (*Query, error) {
+	p := &queryParser{tokens: tokenizeQuery(expr)}
+	if len(p.tokens) == 0 {
+		return nil, errors.New("query is empty")
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok, ok := p.peek(); ok {
+		return nil, errors.New(fmt.Sprintf("unexpected `%s` in query", tok))
+	}
+
+	return &Query{expr: expr, root: root}, nil
+}
+
+// String returns the query expression
+func (q *Query) String() string {
+	return q.expr
+}
+
+// Match returns true if a bookmark with the given label names satisfies the
+// query. A label term also matches the labels nested under it, and label
+// names are compared regardless of case
+func (q *Query) Match(labelNames []string) bool {
+	keys := make(map[string]bool)
+	for _, name := range labelNames {
+		keys[getLabelKey(name)] = true
+		for _, ancestor := range getLabelAncestors(name) {
+			keys[getLabelKey(ancestor)] = true
+		}
+	}
+	return q.root.eval(func(name string) bool {
+		return keys[getLabelKey(name)]
+	})
+}
+
+// tokenizeQuery splits a query expression into parentheses and words
+func tokenizeQuery(expr string) []string {
+	expr = strings.ReplaceAll(expr, "(", " ( ")
+	expr = strings.ReplaceAll(expr, ")", " ) ")
+	return strings.Fields(expr)
+}
+
+// queryParser is a recursive descent parser of query tokens
+type queryParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *queryParser) peek() (string, bool) {
+	if p.pos >= len(p.tokens) {
+		return "", false
+	}
+	return p.tokens[p.pos], true
+}
+
+// accept consumes the next token if it is the operator op
+func (p *queryParser) accept(op string) bool {
+	tok, ok := p.peek()
+	if ok && strings.EqualFold(tok, op) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// parseOr parses: and ("OR" and)*
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &queryOr{left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseAnd parses: not (["AND"] not)*
+func (p *queryParser) parseAnd() (queryNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if !p.accept("AND") {
+			// terms without an operator between them are also combined with
+			// AND. stop at the end of the expression or group
+			tok, ok := p.peek()
+			if !ok || tok == ")" || strings.EqualFold(tok, "OR") {
+				return left, nil
+			}
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &queryAnd{left: left, right: right}
+	}
+}
+
+// parseNot parses: "NOT" not | primary
+func (p *queryParser) parseNot() (queryNode, error) {
+	if p.accept("NOT") {
+		node, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &queryNot{node: node}, nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary parses: "(" or ")" | "label:"name
+func (p *queryParser) parsePrimary() (queryNode, error) {
+	tok, ok := p.peek()
+	if !ok {
+		return nil, errors.New("query ends unexpectedly")
+	}
+	p.pos++
+
+	if tok == "(" {
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, errors.New("missing `)` in query")
+		}
+		return node, nil
+	}
+
+	if !strings.HasPrefix(strings.ToLower(tok), queryLabelPrefix) {
+		return nil, errors.New(fmt.Sprintf("unexpected `%s` in query. Terms are written as %s<name>", tok, queryLabelPrefix))
+	}
+	name := tok[len(queryLabelPrefix):]
+	if name == "" {
+		return nil, errors.New(fmt.Sprintf("`%s` is missing a label name", tok))
+	}
+	return &queryLabel{name: name}, nil
+}
@@ -0,0 +1,68 @@
+package bookmarks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := map[string]struct {
+		expr       string
+		wantErrMsg string
+	}{
+		"single term":          {expr: "label:prod"},
+		"operators any case":   {expr: "label:prod and not label:done Or label:db"},
+		"nested groups":        {expr: "((label:a OR label:b) AND (label:c OR NOT label:d))"},
+		"empty query":          {expr: "  ", wantErrMsg: "query is empty"},
+		"missing label prefix": {expr: "prod", wantErrMsg: "unexpected `prod` in query. Terms are written as label:<name>"},
+		"missing label name":   {expr: "label:", wantErrMsg: "`label:` is missing a label name"},
+		"dangling operator":    {expr: "label:prod AND", wantErrMsg: "query ends unexpectedly"},
+		"leading operator":     {expr: "OR label:prod", wantErrMsg: "unexpected `OR` in query. Terms are written as label:<name>"},
+		"unclosed group":       {expr: "(label:prod OR label:db", wantErrMsg: "missing `)` in query"},
+		"unopened group":       {expr: "label:prod)", wantErrMsg: "unexpected `)` in query"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			query, err := ParseQuery(tt.expr)
+			if tt.wantErrMsg != "" {
+				assert.NotNil(t, err)
+				assert.Equal(t, tt.wantErrMsg, err.Error())
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expr, query.String())
+		})
+	}
+}
+
+func TestQueryMatch(t *testing.T) {
+	tests := map[string]struct {
+		expr       string
+		labelNames []string
+		expected   bool
+	}{
+		"term match":                   {expr: "label:prod", labelNames: []string{"prod"}, expected: true},
+		"term no match":                {expr: "label:prod", labelNames: []string{"dev"}, expected: false},
+		"term no labels":               {expr: "label:prod", labelNames: nil, expected: false},
+		"and requires all":             {expr: "label:prod AND label:db", labelNames: []string{"prod"}, expected: false},
+		"and all present":              {expr: "label:prod AND label:db", labelNames: []string{"db", "prod"}, expected: true},
+		"implicit and":                 {expr: "label:prod label:db", labelNames: []string{"prod"}, expected: false},
+		"or requires any":              {expr: "label:prod OR label:db", labelNames: []string{"db"}, expected: true},
+		"not excludes":                 {expr: "NOT label:done", labelNames: []string{"done"}, expected: false},
+		"not without labels":           {expr: "NOT label:done", labelNames: nil, expected: true},
+		"and binds tighter than or":    {expr: "label:a OR label:b AND label:c", labelNames: []string{"a"}, expected: true},
+		"not binds tighter than and":   {expr: "NOT label:a AND label:b", labelNames: []string{"b"}, expected: true},
+		"groups override precedence":   {expr: "(label:a OR label:b) AND label:c", labelNames: []string{"a"}, expected: false},
+		"on-call query matches":        {expr: "label:prod AND (label:db OR label:cache) AND NOT label:done", labelNames: []string{"prod", "cache"}, expected: true},
+		"on-call query excludes done":  {expr: "label:prod AND (label:db OR label:cache) AND NOT label:done", labelNames: []string{"prod", "db", "done"}, expected: false},
+		"on-call query requires group": {expr: "label:prod AND (label:db OR label:cache) AND NOT label:done", labelNames: []string{"prod"}, expected: false},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			query, err := ParseQuery(tt.expr)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, query.Match(tt.labelNames))
+		})
+	}
+}
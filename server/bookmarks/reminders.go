@@ -0,0 +1,516 @@
+package bookmarks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	// StoreRemindersKey is the key prefix used to store the reminders of all
+	// users due in an hour, so the due reminders are found without loading
+	// every bookmark, and reminders of different hours are changed without
+	// conflicting
+	StoreRemindersKey = "reminders"
+
+	// remindersNextHourKey is the key used to store the first hour that may
+	// have reminders left to send. Earlier hours are not read again
+	remindersNextHourKey = "reminders_next_hour"
+
+	// remindersLockKey is held by the node sending the due reminders, so a
+	// reminder is sent once in a cluster
+	remindersLockKey = "reminders_lock"
+
+	// defaultRemindHour is the hour of a reminder set for a day without a time
+	defaultRemindHour = 9
+
+	// reminderRetryMinutes is the time after which a reminder that was not
+	// sent is sent again
+	reminderRetryMinutes = 5
+
+	// maxReminderAttempts is the number of times a reminder is sent before it
+	// is given up
+	maxReminderAttempts = 3
+
+	// maxRemindYears is how far from now a reminder can be set
+	maxRemindYears = 10
+	maxRemindIn    = maxRemindYears * 365 * 24 * time.Hour
+)
+
+// remindersHourMillis is the time covered by a key of the reminders index
+const remindersHourMillis = int64(time.Hour / time.Millisecond)
+
+// GetRemindersKey returns the key of the reminders due in the same hour as
+// remindAt. Hours are in UTC
+func GetRemindersKey(remindAt int64) string {
+	hour := time.Unix(0, remindAt*int64(time.Millisecond)).UTC()
+	return fmt.Sprintf("%s_%s", StoreRemindersKey, hour.Format("2006010215"))
+}
+
+// getRemindersHour returns the start of the hour of a time
+func getRemindersHour(remindAt int64) int64 {
+	return remindAt - remindAt%remindersHourMillis
+}
+
+// Reminder is an entry of the index of the reminders of all users
+type Reminder struct {
+	UserID   string `json:"user_id"`
+	PostID   string `json:"post_id"`
+	RemindAt int64  `json:"remind_at"`
+	Attempts int    `json:"attempts,omitempty"` // Times the reminder was sent and failed
+}
+
+var (
+	remindIn   = regexp.MustCompile(`^in (\d+)([mhdw])$`)
+	remindTime = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm)?$`)
+)
+
+// ParseRemindAt returns the time of a reminder set at now. The value is a
+// duration from now (in 30m, in 2h, in 3d, in 1w), a day (today, tomorrow,
+// YYYY-MM-DD) with an optional time (9am, 2:30pm, 14:00), or both. Days
+// without a time resolve to 9am. The time is in the location of now, which is
+// the timezone of the user
+func ParseRemindAt(value string, now time.Time) (time.Time, error) {
+	value = strings.ToLower(strings.Join(strings.Fields(value), " "))
+
+	remindAt, ok := parseRemindIn(value, now)
+	if !ok {
+		remindAt, ok = parseRemindDay(value, now)
+	}
+	if !ok {
+		return time.Time{}, errors.New(fmt.Sprintf("`%s` is not a reminder time. Use a duration (in 2h, in 3d), a day (today, tomorrow, YYYY-MM-DD) or a day and a time (tomorrow 9am)", value))
+	}
+
+	if !remindAt.After(now) {
+		return time.Time{}, errors.New(fmt.Sprintf("`%s` is in the past", value))
+	}
+	if remindAt.Sub(now) > maxRemindIn {
+		return time.Time{}, errors.New(fmt.Sprintf("`%s` is more than %d years from now", value, maxRemindYears))
+	}
+	return remindAt, nil
+}
+
+// parseRemindIn returns the time of a duration from now (in 30m, in 2h, in
+// 3d, in 1w)
+func parseRemindIn(value string, now time.Time) (time.Time, bool) {
+	match := remindIn.FindStringSubmatch(value)
+	if match == nil {
+		return time.Time{}, false
+	}
+
+	unit := time.Minute
+	switch match[2] {
+	case "h":
+		unit = time.Hour
+	case "d":
+		unit = 24 * time.Hour
+	case "w":
+		unit = 7 * 24 * time.Hour
+	}
+
+	// a larger n would overflow the duration, so it is only checked to be
+	// too far from now
+	n, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil || n > int64(maxRemindIn/unit) {
+		n = int64(maxRemindIn/unit) + 1
+	}
+	if n <= 0 {
+		return time.Time{}, false
+	}
+	return now.Add(time.Duration(n) * unit), true
+}
+
+// parseRemindDay returns the time of a day (today, tomorrow, YYYY-MM-DD) with
+// an optional time (9am, 2:30pm, 14:00). Days without a time resolve to 9am
+func parseRemindDay(value string, now time.Time) (time.Time, bool) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 || len(fields) > 2 {
+		return time.Time{}, false
+	}
+
+	var day time.Time
+	year, month, date := now.Date()
+	switch fields[0] {
+	case "today":
+		day = time.Date(year, month, date, 0, 0, 0, 0, now.Location())
+	case "tomorrow":
+		day = time.Date(year, month, date+1, 0, 0, 0, 0, now.Location())
+	default:
+		var err error
+		day, err = time.ParseInLocation("2006-01-02", fields[0], now.Location())
+		if err != nil {
+			return time.Time{}, false
+		}
+	}
+
+	hour, minute := defaultRemindHour, 0
+	if len(fields) == 2 {
+		var ok bool
+		if hour, minute, ok = parseTimeOfDay(fields[1]); !ok {
+			return time.Time{}, false
+		}
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, now.Location()), true
+}
+
+// parseTimeOfDay returns the hour and minute of a time of day in 12 hour
+// (9am, 2:30pm) or 24 hour (14:00) format
+func parseTimeOfDay(value string) (int, int, bool) {
+	match := remindTime.FindStringSubmatch(strings.ToLower(value))
+	if match == nil {
+		return 0, 0, false
+	}
+
+	hour, _ := strconv.Atoi(match[1])
+	minute := 0
+	if match[2] != "" {
+		minute, _ = strconv.Atoi(match[2])
+	}
+
+	switch {
+	case match[3] != "" && (hour < 1 || hour > 12):
+		return 0, 0, false
+	case match[3] == "am" && hour == 12:
+		hour = 0
+	case match[3] == "pm" && hour != 12:
+		hour += 12
+	}
+	if hour > 23 || minute > 59 {
+		return 0, 0, false
+	}
+	return hour, minute, true
+}
+
+// GetUserLocation returns the timezone of a user. Users without a valid
+// timezone get UTC
+func GetUserLocation(api pluginapi.API, userID string) (*time.Location, error) {
+	user, err := api.GetUser(userID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to get user %s", userID)
+	}
+
+	location, err := time.LoadLocation(user.GetPreferredTimezone())
+	if err != nil {
+		return time.UTC, nil
+	}
+	return location, nil
+}
+
+// FormatRemindAt returns the time of a reminder in the timezone of the user
+func FormatRemindAt(remindAt int64, location *time.Location) string {
+	return time.Unix(0, remindAt*int64(time.Millisecond)).In(location).Format("Mon Jan 2 2006 at 3:04 PM MST")
+}
+
+// SetReminder sets the time the user is reminded of a bookmark. A time of 0
+// removes the reminder
+func (b *Bookmarks) SetReminder(bmarkID string, remindAt int64) error {
+	var from int64
+	err := b.StoreBookmarks(func(bmarks *Bookmarks) error {
+		bmark, err := bmarks.GetBookmark(bmarkID)
+		if err != nil {
+			return err
+		}
+
+		from = bmark.RemindAt
+		bmark.RemindAt = remindAt
+		bmarks.updateTimes(bmarkID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return setReminderIndexed(b.api, from, Reminder{UserID: b.userID, PostID: bmarkID, RemindAt: remindAt})
+}
+
+// clearReminder removes the reminder of a bookmark once it was sent. A
+// reminder changed since it was due is kept
+func (b *Bookmarks) clearReminder(bmarkID string, remindAt int64) error {
+	cleared, err := b.moveReminder(bmarkID, remindAt, 0)
+	if err != nil || !cleared {
+		return err
+	}
+
+	return setReminderIndexed(b.api, remindAt, Reminder{UserID: b.userID, PostID: bmarkID})
+}
+
+// moveReminder changes the time of the reminder of a bookmark from one time
+// to another. It returns false if the reminder changed since it was read
+func (b *Bookmarks) moveReminder(bmarkID string, from, to int64) (bool, error) {
+	moved := false
+	err := b.StoreBookmarks(func(bmarks *Bookmarks) error {
+		moved = false
+		bmark, ok := bmarks.exists(bmarkID)
+		if !ok || bmark.RemindAt != from {
+			return nil
+		}
+
+		bmark.RemindAt = to
+		moved = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return moved, nil
+}
+
+// getReminders returns the reminders of all users stored under a key of the
+// index and the stored value they were read from
+func getReminders(api pluginapi.API, key string) ([]Reminder, []byte, error) {
+	bb, appErr := api.KVGet(key)
+	if appErr != nil {
+		return nil, nil, appErr
+	}
+	if bb == nil {
+		return nil, nil, nil
+	}
+
+	data, _, err := decodeDocument(documentReminders, bb)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var reminders []Reminder
+	if jsonErr := json.Unmarshal(data, &reminders); jsonErr != nil {
+		return nil, nil, jsonErr
+	}
+	return reminders, bb, nil
+}
+
+// setReminderIndexed moves the reminder of a bookmark in the index from the
+// hour of the time it was indexed with to the hour of its time. A from of 0
+// only adds the reminder and a reminder without a time is only removed
+func setReminderIndexed(api pluginapi.API, from int64, reminder Reminder) error {
+	// the reminder is added first, so a reminder that fails to be removed is
+	// found at its old time and only removed then
+	toKey := ""
+	if reminder.RemindAt != 0 {
+		toKey = GetRemindersKey(reminder.RemindAt)
+		if err := updateReminders(api, toKey, reminder); err != nil {
+			return err
+		}
+	}
+
+	if from != 0 && GetRemindersKey(from) != toKey {
+		removed := Reminder{UserID: reminder.UserID, PostID: reminder.PostID}
+		if err := updateReminders(api, GetRemindersKey(from), removed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateReminders replaces the reminder of a bookmark in a key of the index.
+// A reminder without a time is removed
+func updateReminders(api pluginapi.API, key string, reminder Reminder) error {
+	for i := 0; i < maxStoreAttempts; i++ {
+		reminders, stored, err := getReminders(api, key)
+		if err != nil {
+			return err
+		}
+
+		var newReminders []Reminder
+		for _, r := range reminders {
+			if r.UserID == reminder.UserID && r.PostID == reminder.PostID {
+				continue
+			}
+			newReminders = append(newReminders, r)
+		}
+		if reminder.RemindAt != 0 {
+			newReminders = append(newReminders, reminder)
+		}
+		sort.Slice(newReminders, func(i, j int) bool {
+			return newReminders[i].RemindAt < newReminders[j].RemindAt
+		})
+
+		// a nil value deletes the key
+		var value []byte
+		if len(newReminders) != 0 {
+			value, err = encodeDocument(newReminders)
+			if err != nil {
+				return err
+			}
+		}
+		if bytes.Equal(value, stored) {
+			return nil
+		}
+
+		ok, appErr := api.KVSetWithOptions(key, value, model.PluginKVSetOptions{
+			Atomic:   true,
+			OldValue: stored,
+		})
+		if appErr != nil {
+			return appErr
+		}
+		if ok {
+			return nil
+		}
+	}
+
+	return errors.New(fmt.Sprintf("Unable to store reminders. They were changed by another request %d times", maxStoreAttempts))
+}
+
+// getRemindersNextHour returns the first hour that may have reminders left to
+// send. It is the hour of now before any reminders were sent
+func getRemindersNextHour(api pluginapi.API, now int64) (int64, error) {
+	bb, appErr := api.KVGet(remindersNextHourKey)
+	if appErr != nil {
+		return 0, appErr
+	}
+	if bb == nil {
+		return getRemindersHour(now), nil
+	}
+
+	hour, err := strconv.ParseInt(string(bb), 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "Unable to read the next hour of reminders")
+	}
+	return hour, nil
+}
+
+// SendDueReminders calls send for each bookmark whose reminder is due at now
+// and removes the reminder once it was sent. Only one node of a cluster sends
+// reminders at a time, the others return without sending any. It returns the
+// number of reminders sent
+func SendDueReminders(api pluginapi.API, now int64, send func(userID string, bmarks *Bookmarks, bmark *Bookmark) error) (int, error) {
+	lock, err := lockJob(api, remindersLockKey)
+	if err != nil {
+		return 0, errors.Wrap(err, "Unable to lock reminders")
+	}
+	if lock == nil {
+		return 0, nil
+	}
+	defer lock.unlock()
+
+	nextHour, err := getRemindersNextHour(api, now)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	var sendErr error
+	for hour := nextHour; hour <= getRemindersHour(now); hour += remindersHourMillis {
+		hourSent, done, err := sendDueHourReminders(api, lock, GetRemindersKey(hour), now, send)
+		sent += hourSent
+		if err != nil && sendErr == nil {
+			sendErr = err
+		}
+		if !done {
+			break
+		}
+
+		// the hour of now gets reminders until it has passed, and an hour
+		// with reminders that failed is read again
+		if hour == getRemindersHour(now) || hour != nextHour || err != nil {
+			continue
+		}
+		nextHour = hour + remindersHourMillis
+		if appErr := api.KVSet(remindersNextHourKey, []byte(strconv.FormatInt(nextHour, 10))); appErr != nil {
+			return sent, errors.Wrap(appErr, "Unable to store the next hour of reminders")
+		}
+	}
+	return sent, sendErr
+}
+
+// sendDueHourReminders sends the reminders of a key of the index that are due
+// at now. It returns false if the lock of reminders was lost before all were
+// sent. A reminder that fails is sent again later, without holding up the
+// reminders of other users, and the first error is returned
+func sendDueHourReminders(api pluginapi.API, lock *jobLock, key string, now int64, send func(userID string, bmarks *Bookmarks, bmark *Bookmark) error) (int, bool, error) {
+	reminders, _, err := getReminders(api, key)
+	if err != nil {
+		return 0, false, errors.Wrap(err, "Unable to get reminders")
+	}
+
+	sent := 0
+	var sendErr error
+	for _, reminder := range reminders {
+		// reminders are sorted by time
+		if reminder.RemindAt > now {
+			break
+		}
+
+		// the node that took an expired lock sends the remaining reminders
+		held, err := lock.refresh()
+		if err != nil {
+			return sent, false, errors.Wrap(err, "Unable to refresh the lock of reminders")
+		}
+		if !held {
+			return sent, false, sendErr
+		}
+
+		ok, err := sendReminder(api, reminder, now, send)
+		if err != nil {
+			if sendErr == nil {
+				sendErr = err
+			}
+			continue
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, true, sendErr
+}
+
+// sendReminder sends a due reminder and removes it. The reminder is moved to
+// a later time before it is sent, so a reminder that fails to send, or whose
+// node stops while sending it, is sent again later. The last of
+// maxReminderAttempts removes the reminder before it is sent. A reminder of a
+// bookmark that was removed or whose reminder changed is only removed from
+// the index. It returns true if the reminder was sent
+func sendReminder(api pluginapi.API, reminder Reminder, now int64, send func(userID string, bmarks *Bookmarks, bmark *Bookmark) error) (bool, error) {
+	bmarks, err := NewBookmarksWithUser(api, reminder.UserID)
+	if err != nil {
+		return false, err
+	}
+
+	bmark, ok := bmarks.exists(reminder.PostID)
+	if !ok {
+		return false, setReminderIndexed(api, reminder.RemindAt, Reminder{UserID: reminder.UserID, PostID: reminder.PostID})
+	}
+	// a reminder changed to a time that is due is sent now, as the hour of
+	// that time may not be read again
+	if bmark.RemindAt != reminder.RemindAt && (bmark.RemindAt == 0 || bmark.RemindAt > now) {
+		return false, setReminderIndexed(api, reminder.RemindAt, Reminder{UserID: reminder.UserID, PostID: reminder.PostID, RemindAt: bmark.RemindAt})
+	}
+
+	retry := Reminder{UserID: reminder.UserID, PostID: reminder.PostID, Attempts: reminder.Attempts + 1}
+	if retry.Attempts < maxReminderAttempts {
+		retry.RemindAt = now + int64(reminderRetryMinutes*time.Minute/time.Millisecond)
+	}
+
+	// another node sent the reminder or the user changed it
+	moved, err := bmarks.moveReminder(reminder.PostID, bmark.RemindAt, retry.RemindAt)
+	if err != nil || !moved {
+		return false, err
+	}
+	if err = setReminderIndexed(api, reminder.RemindAt, retry); err != nil {
+		return false, err
+	}
+
+	bmark, ok = bmarks.exists(reminder.PostID)
+	if !ok {
+		return false, nil
+	}
+	if err = send(reminder.UserID, bmarks, bmark); err != nil {
+		if retry.RemindAt == 0 {
+			return false, errors.Wrapf(err, "Unable to send the reminder of bookmark %s to user %s. It was given up after %d attempts", reminder.PostID, reminder.UserID, maxReminderAttempts)
+		}
+		return false, errors.Wrapf(err, "Unable to send the reminder of bookmark %s to user %s", reminder.PostID, reminder.UserID)
+	}
+	if retry.RemindAt == 0 {
+		return true, nil
+	}
+	return true, bmarks.clearReminder(reminder.PostID, retry.RemindAt)
+}
@@ -0,0 +1,321 @@
+package bookmarks
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRemindAt(t *testing.T) {
+	location, err := time.LoadLocation("America/New_York")
+	assert.Nil(t, err)
+	now := time.Date(2026, time.October, 18, 15, 30, 0, 0, location)
+
+	tests := map[string]struct {
+		value    string
+		expected time.Time
+		wantErr  string
+	}{
+		"minutes from now": {
+			value:    "in 30m",
+			expected: now.Add(30 * time.Minute),
+		},
+		"hours from now": {
+			value:    "in 2h",
+			expected: now.Add(2 * time.Hour),
+		},
+		"weeks from now": {
+			value:    "In 1W",
+			expected: now.Add(7 * 24 * time.Hour),
+		},
+		"tomorrow defaults to 9am": {
+			value:    "tomorrow",
+			expected: time.Date(2026, time.October, 19, 9, 0, 0, 0, location),
+		},
+		"tomorrow at a time": {
+			value:    "tomorrow 9am",
+			expected: time.Date(2026, time.October, 19, 9, 0, 0, 0, location),
+		},
+		"today in the afternoon": {
+			value:    "today 4:45pm",
+			expected: time.Date(2026, time.October, 18, 16, 45, 0, 0, location),
+		},
+		"today at noon has passed": {
+			value:   "today 12pm",
+			wantErr: "`today 12pm` is in the past",
+		},
+		"date": {
+			value:    "2026-11-01",
+			expected: time.Date(2026, time.November, 1, 9, 0, 0, 0, location),
+		},
+		"date with a 24 hour time": {
+			value:    "2026-11-01 14:00",
+			expected: time.Date(2026, time.November, 1, 14, 0, 0, 0, location),
+		},
+		"date in the past": {
+			value:   "2026-10-01",
+			wantErr: "`2026-10-01` is in the past",
+		},
+		"zero duration": {
+			value:   "in 0m",
+			wantErr: "`in 0m` is not a reminder time",
+		},
+		"duration too far from now": {
+			value:   "in 600w",
+			wantErr: "`in 600w` is more than 10 years from now",
+		},
+		"duration that overflows": {
+			value:   "in 99999999999999999999w",
+			wantErr: "`in 99999999999999999999w` is more than 10 years from now",
+		},
+		"date too far from now": {
+			value:   "2040-01-01",
+			wantErr: "`2040-01-01` is more than 10 years from now",
+		},
+		"invalid time": {
+			value:   "tomorrow 13pm",
+			wantErr: "`tomorrow 13pm` is not a reminder time",
+		},
+		"unknown value": {
+			value:   "someday",
+			wantErr: "`someday` is not a reminder time",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := ParseRemindAt(tt.value, now)
+			if tt.wantErr != "" {
+				assert.NotNil(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.True(t, tt.expected.Equal(actual), "expected %v, actual %v", tt.expected, actual)
+		})
+	}
+}
+
+// getStoredReminders returns the index of the reminders of all users, in
+// the order of their hours
+func getStoredReminders(t *testing.T, kv map[string][]byte) []Reminder {
+	var keys []string
+	for key := range kv {
+		if isRemindersKey.MatchString(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var reminders []Reminder
+	for _, key := range keys {
+		data, _, err := decodeDocument(documentReminders, kv[key])
+		assert.Nil(t, err)
+		var hourReminders []Reminder
+		assert.Nil(t, json.Unmarshal(data, &hourReminders))
+		reminders = append(reminders, hourReminders...)
+	}
+	return reminders
+}
+
+var isRemindersKey = regexp.MustCompile(`^` + StoreRemindersKey + `_\d{10}$`)
+
+func TestSetReminder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	kv := mockKVStore(mockPluginAPI)
+
+	storeTestBookmarks(t, kv, UserID, &Bookmark{PostID: "ID1"}, &Bookmark{PostID: "ID2"})
+
+	bmarks, err := NewBookmarksWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Nil(t, bmarks.SetReminder("ID1", 2000))
+	assert.Nil(t, bmarks.SetReminder("ID2", 1000))
+	assert.NotNil(t, bmarks.SetReminder("ID3", 1000))
+
+	// reminders are sorted by time
+	assert.Equal(t, []Reminder{
+		{UserID: UserID, PostID: "ID2", RemindAt: 1000},
+		{UserID: UserID, PostID: "ID1", RemindAt: 2000},
+	}, getStoredReminders(t, kv))
+
+	// adding a bookmark again keeps its reminder
+	assert.Nil(t, bmarks.StoreBookmarks(func(bmarks *Bookmarks) error {
+		bmarks.addBookmark(&Bookmark{PostID: "ID1", Title: "follow up"})
+		return nil
+	}))
+	bmarks, err = NewBookmarksWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2000), bmarks.ByID["ID1"].RemindAt)
+
+	// a reminder moved to another hour is only indexed under that hour
+	later := 3*remindersHourMillis + 2000
+	assert.Nil(t, bmarks.SetReminder("ID1", later))
+	assert.NotNil(t, kv[GetRemindersKey(later)])
+	assert.Equal(t, []Reminder{
+		{UserID: UserID, PostID: "ID2", RemindAt: 1000},
+		{UserID: UserID, PostID: "ID1", RemindAt: later},
+	}, getStoredReminders(t, kv))
+
+	// removing the reminders removes the index
+	assert.Nil(t, bmarks.SetReminder("ID1", 0))
+	assert.Nil(t, bmarks.SetReminder("ID2", 0))
+	assert.Nil(t, getStoredReminders(t, kv))
+	assert.False(t, bmarks.ByID["ID1"].HasReminder())
+}
+
+func TestSendDueReminders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	kv := mockKVStore(mockPluginAPI)
+	mockPluginAPI.EXPECT().KVDelete(gomock.Any()).DoAndReturn(func(key string) error {
+		delete(kv, key)
+		return nil
+	}).AnyTimes()
+
+	// ID1 is due, ID2 is not, ID3 was snoozed after the index was read and
+	// ID4 was removed
+	storeTestBookmarks(t, kv, UserID,
+		&Bookmark{PostID: "ID1", RemindAt: 1000},
+		&Bookmark{PostID: "ID2", RemindAt: 5000},
+		&Bookmark{PostID: "ID3", RemindAt: 4000},
+	)
+	kv[GetRemindersKey(0)] = mustEncodeDocument(t, []Reminder{
+		{UserID: UserID, PostID: "ID1", RemindAt: 1000},
+		{UserID: UserID, PostID: "ID3", RemindAt: 1500},
+		{UserID: UserID, PostID: "ID4", RemindAt: 2000},
+		{UserID: UserID, PostID: "ID2", RemindAt: 5000},
+	})
+
+	var sentPostIDs []string
+	send := func(userID string, bmarks *Bookmarks, bmark *Bookmark) error {
+		assert.Equal(t, UserID, userID)
+		sentPostIDs = append(sentPostIDs, bmark.PostID)
+		return nil
+	}
+
+	// another node holds the lock
+	kv[remindersLockKey] = []byte("locked")
+	sent, err := SendDueReminders(mockPluginAPI, 3000, send)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, sent)
+	delete(kv, remindersLockKey)
+
+	sent, err = SendDueReminders(mockPluginAPI, 3000, send)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, []string{"ID1"}, sentPostIDs)
+	assert.Nil(t, kv[remindersLockKey])
+
+	assert.Equal(t, []Reminder{
+		{UserID: UserID, PostID: "ID3", RemindAt: 4000},
+		{UserID: UserID, PostID: "ID2", RemindAt: 5000},
+	}, getStoredReminders(t, kv))
+
+	bmarks, err := NewBookmarksWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.False(t, bmarks.ByID["ID1"].HasReminder())
+
+	// a sent reminder is not sent again
+	sent, err = SendDueReminders(mockPluginAPI, 3000, send)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, sent)
+}
+
+func TestSendDueReminders_failed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	kv := mockKVStore(mockPluginAPI)
+
+	storeTestBookmarks(t, kv, UserID, &Bookmark{PostID: "ID1", RemindAt: 1000})
+	kv[GetRemindersKey(0)] = mustEncodeDocument(t, []Reminder{
+		{UserID: UserID, PostID: "ID1", RemindAt: 1000},
+	})
+
+	attempts := 0
+	send := func(userID string, bmarks *Bookmarks, bmark *Bookmark) error {
+		attempts++
+		return errors.New("post failed")
+	}
+
+	// a reminder that fails is sent again after a while
+	retryAt := int64(1000)
+	for attempt := 1; attempt < maxReminderAttempts; attempt++ {
+		now := retryAt
+		sent, err := SendDueReminders(mockPluginAPI, now, send)
+		assert.NotNil(t, err)
+		assert.Equal(t, 0, sent)
+
+		retryAt = now + reminderRetryMinutes*60*1000
+		assert.Equal(t, []Reminder{
+			{UserID: UserID, PostID: "ID1", RemindAt: retryAt, Attempts: attempt},
+		}, getStoredReminders(t, kv))
+		bmarks, err := NewBookmarksWithUser(mockPluginAPI, UserID)
+		assert.Nil(t, err)
+		assert.Equal(t, retryAt, bmarks.ByID["ID1"].RemindAt)
+
+		// the reminder is not sent again before the retry
+		sent, err = SendDueReminders(mockPluginAPI, retryAt-1, send)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, sent)
+	}
+
+	// the last attempt gives the reminder up
+	_, err := SendDueReminders(mockPluginAPI, retryAt, send)
+	assert.EqualError(t, err, "Unable to send the reminder of bookmark ID1 to user UserID. It was given up after 3 attempts: post failed")
+	assert.Equal(t, maxReminderAttempts, attempts)
+	assert.Nil(t, getStoredReminders(t, kv))
+	bmarks, err := NewBookmarksWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.False(t, bmarks.ByID["ID1"].HasReminder())
+}
+
+func TestSendDueReminders_hours(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	kv := mockKVStore(mockPluginAPI)
+
+	// ID1 and ID2 are due in earlier hours, ID3 is due later in the hour of
+	// now
+	hour := remindersHourMillis
+	storeTestBookmarks(t, kv, UserID,
+		&Bookmark{PostID: "ID1", RemindAt: 1000},
+		&Bookmark{PostID: "ID2", RemindAt: 2*hour + 1000},
+		&Bookmark{PostID: "ID3", RemindAt: 3*hour + 5000},
+	)
+	kv[GetRemindersKey(1000)] = mustEncodeDocument(t, []Reminder{{UserID: UserID, PostID: "ID1", RemindAt: 1000}})
+	kv[GetRemindersKey(2*hour+1000)] = mustEncodeDocument(t, []Reminder{{UserID: UserID, PostID: "ID2", RemindAt: 2*hour + 1000}})
+	kv[GetRemindersKey(3*hour+5000)] = mustEncodeDocument(t, []Reminder{{UserID: UserID, PostID: "ID3", RemindAt: 3*hour + 5000}})
+	kv[remindersNextHourKey] = []byte("0")
+
+	var sentPostIDs []string
+	send := func(userID string, bmarks *Bookmarks, bmark *Bookmark) error {
+		sentPostIDs = append(sentPostIDs, bmark.PostID)
+		return nil
+	}
+
+	sent, err := SendDueReminders(mockPluginAPI, 3*hour+1000, send)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, sent)
+	assert.Equal(t, []string{"ID1", "ID2"}, sentPostIDs)
+	assert.Equal(t, []Reminder{{UserID: UserID, PostID: "ID3", RemindAt: 3*hour + 5000}}, getStoredReminders(t, kv))
+
+	// the passed hours are not read again, the hour of now is
+	assert.Equal(t, strconv.FormatInt(3*hour, 10), string(kv[remindersNextHourKey]))
+	sent, err = SendDueReminders(mockPluginAPI, 3*hour+5000, send)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, []string{"ID1", "ID2", "ID3"}, sentPostIDs)
+	assert.Nil(t, getStoredReminders(t, kv))
+}
@@ -0,0 +1,110 @@
+package bookmarks
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// SchemaVersion is the version of the stored bookmarks, bookmark index,
+// labels, post bookmarks, reminders and digest users documents written by this version of the plugin. Documents stored
+// without an envelope are version 0
+const SchemaVersion = 1
+
+// Kinds of stored documents, each upgraded by its own migration functions
+const (
+	documentBookmark      = "bookmark"
+	documentBookmarkIndex = "bookmark_index"
+	documentLabels        = "labels"
+	documentPostBookmarks = "post_bookmarks"
+	documentReminders     = "reminders"
+	documentDigestUsers   = "digest_users"
+)
+
+// schemaMigration upgrades stored documents from Version-1 to Version. A nil
+// function leaves that kind of document unchanged
+type schemaMigration struct {
+	Version       int
+	Description   string
+	Bookmark      func(data json.RawMessage) (json.RawMessage, error)
+	BookmarkIndex func(data json.RawMessage) (json.RawMessage, error)
+	Labels        func(data json.RawMessage) (json.RawMessage, error)
+}
+
+// schemaMigrations is the registry of migrations in version order. Add a
+// migration and increase SchemaVersion whenever the stored model changes
+var schemaMigrations = []schemaMigration{
+	{
+		Version:     1,
+		Description: "store documents in a versioned envelope",
+	},
+}
+
+// schemaEnvelope wraps a stored document with the schema version it was
+// written with
+type schemaEnvelope struct {
+	Version *int            `json:"version"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// encodeDocument returns the JSON of a document wrapped in an envelope of the
+// current schema version
+func encodeDocument(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	version := SchemaVersion
+	return json.Marshal(&schemaEnvelope{
+		Version: &version,
+		Data:    data,
+	})
+}
+
+// decodeDocument returns the data of a stored document upgraded to the
+// current schema version, and the version it was stored with
+func decodeDocument(kind string, bb []byte) (json.RawMessage, int, error) {
+	version, data := unwrapDocument(bb)
+	if version > SchemaVersion {
+		return nil, version, errors.New(fmt.Sprintf("%s was stored with schema version %d, which is newer than version %d of this plugin", kind, version, SchemaVersion))
+	}
+
+	for _, m := range schemaMigrations {
+		if m.Version <= version {
+			continue
+		}
+
+		var migrate func(json.RawMessage) (json.RawMessage, error)
+		switch kind {
+		case documentBookmark:
+			migrate = m.Bookmark
+		case documentBookmarkIndex:
+			migrate = m.BookmarkIndex
+		case documentLabels:
+			migrate = m.Labels
+		}
+		if migrate == nil {
+			continue
+		}
+
+		var err error
+		data, err = migrate(data)
+		if err != nil {
+			return nil, version, errors.Wrapf(err, "Unable to upgrade %s to schema version %d", kind, m.Version)
+		}
+	}
+
+	return data, version, nil
+}
+
+// unwrapDocument returns the version and data of a stored document. Documents
+// stored before the envelope was added are returned as is with version 0
+func unwrapDocument(bb []byte) (int, json.RawMessage) {
+	var envelope schemaEnvelope
+	if err := json.Unmarshal(bb, &envelope); err != nil || envelope.Version == nil || envelope.Data == nil {
+		return 0, bb
+	}
+	return *envelope.Version, envelope.Data
+}
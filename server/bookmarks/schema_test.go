@@ -0,0 +1,106 @@
+package bookmarks
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mustEncodeDocument returns a document as it is stored by this version of
+// the plugin
+func mustEncodeDocument(t *testing.T, v interface{}) []byte {
+	bb, err := encodeDocument(v)
+	assert.Nil(t, err)
+	return bb
+}
+
+func TestSchemaMigrations(t *testing.T) {
+	// migrations are numbered without gaps up to the current version
+	for i, m := range schemaMigrations {
+		assert.Equal(t, i+1, m.Version)
+		assert.NotEmpty(t, m.Description)
+	}
+	assert.Equal(t, SchemaVersion, len(schemaMigrations))
+}
+
+func TestDecodeDocument(t *testing.T) {
+	tests := map[string]struct {
+		kind            string
+		stored          string
+		expectedData    string
+		expectedVersion int
+		wantErrMsg      string
+	}{
+		"bookmark without envelope": {
+			kind:            documentBookmark,
+			stored:          `{"postid":"ID1","create_at":0,"update_at":0}`,
+			expectedData:    `{"postid":"ID1","create_at":0,"update_at":0}`,
+			expectedVersion: 0,
+		},
+		"index without envelope": {
+			kind:            documentBookmarkIndex,
+			stored:          `["ID1","ID2"]`,
+			expectedData:    `["ID1","ID2"]`,
+			expectedVersion: 0,
+		},
+		"labels without envelope": {
+			kind:            documentLabels,
+			stored:          `{"ByID":{"UUID1":{"name":"label1","id":"UUID1"}}}`,
+			expectedData:    `{"ByID":{"UUID1":{"name":"label1","id":"UUID1"}}}`,
+			expectedVersion: 0,
+		},
+		"current version": {
+			kind:            documentBookmarkIndex,
+			stored:          `{"version":1,"data":["ID1"]}`,
+			expectedData:    `["ID1"]`,
+			expectedVersion: 1,
+		},
+		"newer version": {
+			kind:       documentBookmark,
+			stored:     `{"version":99,"data":{}}`,
+			wantErrMsg: "bookmark was stored with schema version 99, which is newer than version 1 of this plugin",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, version, err := decodeDocument(tt.kind, []byte(tt.stored))
+			if tt.wantErrMsg != "" {
+				assert.Equal(t, tt.wantErrMsg, err.Error())
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expectedVersion, version)
+			assert.Equal(t, tt.expectedData, string(data))
+		})
+	}
+}
+
+func TestDecodeDocument_migrations(t *testing.T) {
+	defer func(migrations []schemaMigration) {
+		schemaMigrations = migrations
+	}(schemaMigrations)
+
+	// a later version renames a field of bookmarks only
+	schemaMigrations = append(schemaMigrations, schemaMigration{
+		Version:     2,
+		Description: "rename title",
+		Bookmark: func(data json.RawMessage) (json.RawMessage, error) {
+			var doc map[string]interface{}
+			if err := json.Unmarshal(data, &doc); err != nil {
+				return nil, err
+			}
+			doc["name"] = doc["title"]
+			delete(doc, "title")
+			return json.Marshal(doc)
+		},
+	})
+
+	data, _, err := decodeDocument(documentBookmark, []byte(`{"postid":"ID1","title":"Title1"}`))
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"postid":"ID1","name":"Title1"}`, string(data))
+
+	data, _, err = decodeDocument(documentBookmarkIndex, []byte(`["ID1"]`))
+	assert.Nil(t, err)
+	assert.Equal(t, `["ID1"]`, string(data))
+}
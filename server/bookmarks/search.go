@@ -0,0 +1,131 @@
+package bookmarks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// titleMatchWeight is the score given to a search term found in the
+	// bookmark title
+	titleMatchWeight = 3
+	// messageMatchWeight is the score given to a search term found in the
+	// bookmarked post message
+	messageMatchWeight = 1
+)
+
+// Search returns the bookmarks matching every term in the query, ranked by
+// relevance. Terms are matched case-insensitively against the bookmark title
+// and the message of the bookmarked post. Bookmarks with equal scores keep
+// the post.CreateAt ordering
+func (b *Bookmarks) Search(query string) ([]*Bookmark, error) {
+	terms := getSearchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	// every post message is searched, so the posts are fetched in one request
+	if err := b.fetchPosts(b.getPostIDs()); err != nil {
+		return nil, err
+	}
+	bmarksSorted, err := b.ByPostCreateAt()
+	if err != nil {
+		return nil, err
+	}
+
+	scores := make(map[string]int)
+	var results []*Bookmark
+	for _, bmark := range bmarksSorted {
+		message, err := b.getTitleFromPost(bmark.PostID)
+		if err != nil {
+			return nil, err
+		}
+
+		score := getSearchScore(terms, bmark.GetTitle(), message)
+		if score == 0 {
+			continue
+		}
+		scores[bmark.PostID] = score
+		results = append(results, bmark)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return scores[results[i].PostID] > scores[results[j].PostID]
+	})
+
+	return results, nil
+}
+
+// getSearchScore returns the relevance of a title and post message for the
+// search terms. A score of zero is returned unless every term is found
+func getSearchScore(terms []string, title, message string) int {
+	title = strings.ToLower(title)
+	message = strings.ToLower(message)
+
+	score := 0
+	for _, term := range terms {
+		termScore := strings.Count(title, term)*titleMatchWeight +
+			strings.Count(message, term)*messageMatchWeight
+		if termScore == 0 {
+			return 0
+		}
+		score += termScore
+	}
+	return score
+}
+
+// getSearchTerms splits a query into lowercase terms. Text wrapped in double
+// quotes is kept together as a single phrase
+func getSearchTerms(query string) []string {
+	var terms []string
+	for i, part := range strings.Split(strings.ToLower(query), `"`) {
+		// odd parts were enclosed in quotes
+		if i%2 == 1 {
+			if phrase := strings.TrimSpace(part); phrase != "" {
+				terms = append(terms, phrase)
+			}
+			continue
+		}
+		terms = append(terms, strings.Fields(part)...)
+	}
+	return terms
+}
+
+// GetBmarksSearchEphemeralText returns the text for posting the bookmarks
+// matching a search query in an ephemeral message. Results are listed one
+// page at a time by relevance, or the first page if options is nil. The sort
+// of the options is not used
+func (b *Bookmarks) GetBmarksSearchEphemeralText(query string, options *ListOptions) (string, error) {
+	if options == nil {
+		options = NewListOptions()
+	}
+	if err := options.IsValid(); err != nil {
+		return "", err
+	}
+
+	if b == nil || len(b.ByID) == 0 {
+		return "You do not have any saved bookmarks", nil
+	}
+
+	results, err := b.Search(query)
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return "No bookmarks found matching: `" + query + "`", nil
+	}
+
+	resultsPage := paginate(results, options.Limit, options.Page)
+	if len(resultsPage) == 0 {
+		numPages := (len(results) + options.Limit - 1) / options.Limit
+		return fmt.Sprintf("Page %d is empty. The last page of search results is %d", options.Page, numPages), nil
+	}
+
+	text, err := b.getBmarksListText("#### Search Results\n", resultsPage)
+	if err != nil {
+		return "", err
+	}
+
+	return text + getPageFooterText(len(results), options.Limit, options.Page), nil
+}
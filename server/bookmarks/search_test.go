@@ -0,0 +1,49 @@
+package bookmarks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSearchTerms(t *testing.T) {
+	tests := map[string]struct {
+		query    string
+		expected []string
+	}{
+		"empty query":              {query: "", expected: nil},
+		"single term":              {query: "Deploy", expected: []string{"deploy"}},
+		"multiple terms":           {query: " deploy  runbook ", expected: []string{"deploy", "runbook"}},
+		"quoted phrase":            {query: `"deploy runbook"`, expected: []string{"deploy runbook"}},
+		"phrase and terms":         {query: `prod "deploy runbook" db`, expected: []string{"prod", "deploy runbook", "db"}},
+		"unterminated quote":       {query: `prod "deploy runbook`, expected: []string{"prod", "deploy runbook"}},
+		"empty quotes are skipped": {query: `prod ""`, expected: []string{"prod"}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, getSearchTerms(tt.query))
+		})
+	}
+}
+
+func TestGetSearchScore(t *testing.T) {
+	tests := map[string]struct {
+		terms    []string
+		title    string
+		message  string
+		expected int
+	}{
+		"no match":                  {terms: []string{"deploy"}, title: "lunch", message: "friday", expected: 0},
+		"title match":               {terms: []string{"deploy"}, title: "Deploy notes", expected: titleMatchWeight},
+		"message match":             {terms: []string{"deploy"}, message: "how to deploy", expected: messageMatchWeight},
+		"title and message match":   {terms: []string{"deploy"}, title: "deploy", message: "deploy", expected: titleMatchWeight + messageMatchWeight},
+		"repeated matches":          {terms: []string{"deploy"}, message: "deploy, then deploy", expected: 2 * messageMatchWeight},
+		"every term must match":     {terms: []string{"deploy", "rollback"}, message: "deploy", expected: 0},
+		"terms matched in any text": {terms: []string{"deploy", "rollback"}, title: "rollback", message: "deploy", expected: titleMatchWeight + messageMatchWeight},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, getSearchScore(tt.terms, tt.title, tt.message))
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package bookmarks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi"
+	"github.com/pkg/errors"
+)
+
+// StoreSettingsKey is the key used to store the settings of a user in the
+// plugin KV store
+const StoreSettingsKey = "settings"
+
+func GetSettingsKey(userID string) string {
+	return fmt.Sprintf("%s_%s", StoreSettingsKey, userID)
+}
+
+// Settings are the preferences of a user for the plugin
+type Settings struct {
+	SyncFlagged     bool         `json:"sync_flagged,omitempty"`     // Bookmarks are kept in sync with the flagged posts of the user
+	DigestFrequency string       `json:"digest_frequency,omitempty"` // How often the user gets a digest of their bookmarks. Empty when they get none
+	DigestWeekday   time.Weekday `json:"digest_weekday,omitempty"`   // The day of a weekly digest
+	DigestHour      int          `json:"digest_hour,omitempty"`      // The hour of the digest in the timezone of the user
+	DigestMinute    int          `json:"digest_minute,omitempty"`    // The minute of the hour of the digest
+	LastDigestAt    int64        `json:"last_digest_at,omitempty"`   // The last time a digest was sent or the digest was turned on
+
+	api    pluginapi.API
+	userID string
+
+	// stored holds the value last loaded from or stored to the KV store
+	stored []byte
+}
+
+// NewSettingsWithUser returns the settings of a user. Users without stored
+// settings get the defaults
+func NewSettingsWithUser(api pluginapi.API, userID string) (*Settings, error) {
+	bb, appErr := api.KVGet(GetSettingsKey(userID))
+	if appErr != nil {
+		return nil, errors.Wrapf(appErr, "Unable to get settings for user %s", userID)
+	}
+
+	settings, err := settingsFromStored(api, userID, bb)
+	if err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// settingsFromStored returns the settings of a stored value
+func settingsFromStored(api pluginapi.API, userID string, bb []byte) (*Settings, error) {
+	settings := &Settings{}
+	if len(bb) != 0 {
+		if err := json.Unmarshal(bb, settings); err != nil {
+			return nil, err
+		}
+	}
+	settings.api = api
+	settings.userID = userID
+	settings.stored = bb
+
+	return settings, nil
+}
+
+// StoreSettings applies mutate to the settings and stores them. If another
+// request changed the settings since they were loaded, the settings are
+// reloaded and mutate is applied again
+func (s *Settings) StoreSettings(mutate func(settings *Settings) error) error {
+	key := GetSettingsKey(s.userID)
+	for i := 0; i < maxStoreAttempts; i++ {
+		if err := mutate(s); err != nil {
+			return err
+		}
+
+		bb, jsonErr := json.Marshal(s)
+		if jsonErr != nil {
+			return jsonErr
+		}
+		if bytes.Equal(bb, s.stored) {
+			return nil
+		}
+
+		ok, appErr := s.api.KVCompareAndSet(key, s.stored, bb)
+		if appErr != nil {
+			return appErr
+		}
+		if ok {
+			s.stored = bb
+			return nil
+		}
+
+		// another request stored the settings first
+		stored, appErr := s.api.KVGet(key)
+		if appErr != nil {
+			return appErr
+		}
+		settings, err := settingsFromStored(s.api, s.userID, stored)
+		if err != nil {
+			return err
+		}
+		*s = *settings
+	}
+
+	return errors.New(fmt.Sprintf("Unable to store settings for user %s. They were changed by another request %d times", s.userID, maxStoreAttempts))
+}
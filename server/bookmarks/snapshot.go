@@ -0,0 +1,107 @@
+package bookmarks
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi"
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// PostSnapshot is the content of a bookmarked post at the time it was
+// bookmarked. It is displayed once the post is deleted
+type PostSnapshot struct {
+	Message     string   `json:"message"`                // Message of the post
+	AuthorID    string   `json:"author_id"`              // ID of the user who wrote the post
+	AuthorName  string   `json:"author_name,omitempty"`  // Username of the user who wrote the post
+	ChannelID   string   `json:"channel_id,omitempty"`   // ID of the channel of the post
+	ChannelName string   `json:"channel_name,omitempty"` // Display name of the channel of the post
+	CreateAt    int64    `json:"create_at"`              // The creation time of the post
+	FileNames   []string `json:"file_names,omitempty"`   // Names of the files attached to the post
+}
+
+// newPostSnapshot returns a snapshot of the content of a post. It is best
+// effort: an author or channel that cannot be read is logged and left out,
+// and a file that cannot be read is kept by its ID so the number of files
+// still matches the post
+func newPostSnapshot(api pluginapi.API, post *model.Post) *PostSnapshot {
+	snapshot := &PostSnapshot{
+		Message:   post.Message,
+		AuthorID:  post.UserId,
+		ChannelID: post.ChannelId,
+		CreateAt:  post.CreateAt,
+	}
+
+	user, err := api.GetUser(post.UserId)
+	if err != nil {
+		api.LogWarn("Unable to get the author of a bookmarked post for its snapshot", "post_id", post.Id, "err", err.Error())
+	} else {
+		snapshot.AuthorName = user.Username
+	}
+
+	channel, err := api.GetChannel(post.ChannelId)
+	if err != nil {
+		api.LogWarn("Unable to get the channel of a bookmarked post for its snapshot", "post_id", post.Id, "err", err.Error())
+	} else {
+		snapshot.ChannelName = channel.DisplayName
+		if snapshot.ChannelName == "" {
+			snapshot.ChannelName = channel.Name
+		}
+	}
+
+	for _, fileID := range post.FileIds {
+		info, err := api.GetFileInfo(fileID)
+		if err != nil {
+			api.LogWarn("Unable to get a file of a bookmarked post for its snapshot", "post_id", post.Id, "file_id", fileID, "err", err.Error())
+			snapshot.FileNames = append(snapshot.FileNames, fileID)
+			continue
+		}
+		snapshot.FileNames = append(snapshot.FileNames, info.Name)
+	}
+
+	return snapshot
+}
+
+// differsFrom returns true if the message or the number of attached files of
+// a post changed since the snapshot was taken
+func (s *PostSnapshot) differsFrom(post *model.Post) bool {
+	return s.Message != post.Message || len(s.FileNames) != len(post.FileIds)
+}
+
+// snapshotPost takes a snapshot of a bookmarked post unless the bookmark
+// already has one
+func (b *Bookmarks) snapshotPost(bmark *Bookmark) error {
+	if bmark.HasSnapshot() {
+		return nil
+	}
+	if orig, ok := b.exists(bmark.PostID); ok && orig.HasSnapshot() {
+		return nil
+	}
+
+	post, err := b.getPost(bmark.PostID)
+	if err != nil {
+		return err
+	}
+	// a deleted post has no content left to keep, and the content of a hidden
+	// post must not be kept
+	if isPostDeleted(post) || isPostHidden(post) {
+		return nil
+	}
+
+	bmark.Snapshot = newPostSnapshot(b.api, post)
+	return nil
+}
+
+// getSnapshotText returns the saved content of a post as a quote
+func getSnapshotText(s *PostSnapshot) string {
+	createAt := time.Unix(0, s.CreateAt*int64(time.Millisecond)).UTC()
+	text := fmt.Sprintf("Posted by @%s in %s on %s\n", s.AuthorName, s.ChannelName, createAt.Format("2006-01-02 15:04 MST"))
+	for _, line := range strings.Split(s.Message, "\n") {
+		text += "> " + line + "\n"
+	}
+	if len(s.FileNames) != 0 {
+		text += "Files: " + strings.Join(s.FileNames, ", ") + "\n"
+	}
+	return text
+}
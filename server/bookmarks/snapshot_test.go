@@ -0,0 +1,200 @@
+package bookmarks
+
+import (
+	"net/http"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockPostContent answers the requests for the content of any post. Each post
+// is written by @author in Town Square
+func mockPostContent(api *mock_pluginapi.MockAPI) {
+	api.EXPECT().GetPost(gomock.Any()).DoAndReturn(func(postID string) (*model.Post, error) {
+		return &model.Post{Id: postID, Message: "message " + postID, UserId: "authorID", ChannelId: "channelID"}, nil
+	}).AnyTimes()
+	api.EXPECT().GetUser("authorID").Return(&model.User{Id: "authorID", Username: "author"}, nil).AnyTimes()
+	api.EXPECT().GetChannel("channelID").Return(&model.Channel{Id: "channelID", DisplayName: "Town Square"}, nil).AnyTimes()
+	mockChannelMember(api)
+}
+
+func TestNewPostSnapshot(t *testing.T) {
+	tests := map[string]struct {
+		post     *model.Post
+		channel  *model.Channel
+		expected *PostSnapshot
+	}{
+		"post with files": {
+			post:    &model.Post{Id: "ID1", Message: "message", UserId: "authorID", ChannelId: "channelID", CreateAt: 1, FileIds: []string{"fileID1", "fileID2"}},
+			channel: &model.Channel{Name: "town-square", DisplayName: "Town Square"},
+			expected: &PostSnapshot{
+				Message:     "message",
+				AuthorID:    "authorID",
+				AuthorName:  "author",
+				ChannelID:   "channelID",
+				ChannelName: "Town Square",
+				CreateAt:    1,
+				FileNames:   []string{"fileID1.txt", "fileID2.txt"},
+			},
+		},
+		"channel without a display name": {
+			post:    &model.Post{Id: "ID1", Message: "message", UserId: "authorID", ChannelId: "channelID", CreateAt: 1},
+			channel: &model.Channel{Name: "authorID__userID"},
+			expected: &PostSnapshot{
+				Message:     "message",
+				AuthorID:    "authorID",
+				AuthorName:  "author",
+				ChannelID:   "channelID",
+				ChannelName: "authorID__userID",
+				CreateAt:    1,
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+
+			mockPluginAPI.EXPECT().GetUser("authorID").Return(&model.User{Username: "author"}, nil)
+			mockPluginAPI.EXPECT().GetChannel("channelID").Return(tt.channel, nil)
+			mockPluginAPI.EXPECT().GetFileInfo(gomock.Any()).DoAndReturn(func(fileID string) (*model.FileInfo, error) {
+				return &model.FileInfo{Id: fileID, Name: fileID + ".txt"}, nil
+			}).AnyTimes()
+
+			assert.Equal(t, tt.expected, newPostSnapshot(mockPluginAPI, tt.post))
+		})
+	}
+}
+
+func TestAddBookmark_partialSnapshot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	mockKVStore(mockPluginAPI)
+	mockChannelMember(mockPluginAPI)
+
+	notFound := &model.AppError{StatusCode: http.StatusNotFound}
+	mockPluginAPI.EXPECT().GetPost("ID1").Return(&model.Post{Id: "ID1", Message: "message", UserId: "authorID", ChannelId: "channelID", CreateAt: 1, FileIds: []string{"fileID1", "fileID2"}}, nil)
+	mockPluginAPI.EXPECT().GetUser("authorID").Return(nil, notFound)
+	mockPluginAPI.EXPECT().GetChannel("channelID").Return(nil, notFound)
+	mockPluginAPI.EXPECT().GetFileInfo("fileID1").Return(&model.FileInfo{Id: "fileID1", Name: "report.pdf"}, nil)
+	mockPluginAPI.EXPECT().GetFileInfo("fileID2").Return(nil, notFound)
+	mockPluginAPI.EXPECT().LogWarn(gomock.Any(), gomock.Any()).Times(3)
+
+	bmarks, err := NewBookmarksWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Nil(t, bmarks.AddBookmark(&Bookmark{PostID: "ID1"}))
+
+	// the bookmark is added with what could be read of the post
+	assert.Equal(t, &PostSnapshot{
+		Message:   "message",
+		AuthorID:  "authorID",
+		ChannelID: "channelID",
+		CreateAt:  1,
+		FileNames: []string{"report.pdf", "fileID2"},
+	}, bmarks.ByID["ID1"].Snapshot)
+}
+
+func TestAddBookmark_snapshot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+	mockKVStore(mockPluginAPI)
+	mockPostContent(mockPluginAPI)
+
+	bmarks, err := NewBookmarksWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Nil(t, bmarks.AddBookmark(&Bookmark{PostID: "ID1"}))
+
+	expected := &PostSnapshot{
+		Message:     "message ID1",
+		AuthorID:    "authorID",
+		AuthorName:  "author",
+		ChannelID:   "channelID",
+		ChannelName: "Town Square",
+	}
+	assert.Equal(t, expected, bmarks.ByID["ID1"].Snapshot)
+
+	// updating the bookmark keeps the snapshot taken when it was added
+	assert.Nil(t, bmarks.AddBookmark(&Bookmark{PostID: "ID1", Title: "Title1"}))
+	bmarks, err = NewBookmarksWithUser(mockPluginAPI, UserID)
+	assert.Nil(t, err)
+	assert.Equal(t, "Title1", bmarks.ByID["ID1"].Title)
+	assert.Equal(t, expected, bmarks.ByID["ID1"].Snapshot)
+}
+
+func TestGetBmarkTextDetailed_snapshot(t *testing.T) {
+	snapshot := &PostSnapshot{
+		Message:     "original message",
+		AuthorID:    "authorID",
+		AuthorName:  "author",
+		ChannelID:   "channelID",
+		ChannelName: "Town Square",
+		CreateAt:    1600000000000,
+		FileNames:   []string{"report.pdf"},
+	}
+	snapshotText := "Posted by @author in Town Square on 2020-09-13 12:26 UTC\n> original message\nFiles: report.pdf\n"
+
+	tests := map[string]struct {
+		bmark       *Bookmark
+		post        *model.Post
+		contains    []string
+		notContains []string
+	}{
+		"post unchanged": {
+			bmark:       &Bookmark{PostID: "ID1", Snapshot: snapshot},
+			post:        &model.Post{Id: "ID1", Message: "original message", FileIds: []string{"fileID1"}},
+			contains:    []string{"##### Post Message \noriginal message"},
+			notContains: []string{"changed since it was bookmarked", snapshotText},
+		},
+		"post edited": {
+			bmark: &Bookmark{PostID: "ID1", Snapshot: snapshot},
+			post:  &model.Post{Id: "ID1", Message: "edited message", FileIds: []string{"fileID1"}},
+			contains: []string{
+				"**`EDITED`**",
+				"edited message\n##### :warning: The post changed since it was bookmarked\n" + snapshotText,
+			},
+		},
+		"post deleted": {
+			bmark: &Bookmark{PostID: "ID1", Snapshot: snapshot},
+			contains: []string{
+				"**`DELETED`**",
+				"**original message**",
+				"_The bookmarked post was deleted. This is its content when it was bookmarked_\n" + snapshotText,
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+			mockPluginAPI.EXPECT().GetConfig().Return(&model.Config{
+				ServiceSettings: model.ServiceSettings{SiteURL: model.NewString("https://myhost.com")},
+			}).AnyTimes()
+			if tt.post != nil {
+				mockPluginAPI.EXPECT().GetPost("ID1").Return(tt.post, nil)
+			} else {
+				mockPluginAPI.EXPECT().GetPost("ID1").Return(nil, &model.AppError{StatusCode: http.StatusNotFound})
+			}
+			mockChannelMember(mockPluginAPI)
+
+			bmarks := NewBookmarks(UserID)
+			bmarks.api = mockPluginAPI
+			bmarks.ByID["ID1"] = tt.bmark
+
+			text, err := bmarks.GetBmarkTextDetailed(tt.bmark, nil, nil)
+			assert.Nil(t, err)
+			for _, s := range tt.contains {
+				assert.Contains(t, text, s)
+			}
+			for _, s := range tt.notContains {
+				assert.NotContains(t, text, s)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package bookmarks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	SortByCreated    = "created"    // post.CreateAt of the bookmarked post
+	SortByModified   = "modified"   // last time the bookmark was modified
+	SortByBookmarked = "bookmarked" // time the bookmark was created
+	SortByTitle      = "title"      // bookmark title or post message
+	SortByChannel    = "channel"    // display name of the post channel
+
+	// DefaultPageSize is the number of bookmarks listed when no limit is
+	// requested. It keeps listings below the maximum post size
+	DefaultPageSize = 25
+
+	// MaxPageSize is the largest number of bookmarks listed on one page.
+	// Larger pages may exceed the maximum post size
+	MaxPageSize = 50
+)
+
+// ListOptions defines the order and the page of a bookmarks listing
+type ListOptions struct {
+	SortBy  string
+	Reverse bool
+	Limit   int
+	Page    int
+}
+
+// NewListOptions returns ListOptions for the first page in the default order
+func NewListOptions() *ListOptions {
+	return &ListOptions{
+		SortBy: SortByCreated,
+		Limit:  DefaultPageSize,
+		Page:   1,
+	}
+}
+
+// IsValid returns an error if the options cannot be used for a listing
+func (o *ListOptions) IsValid() error {
+	switch o.SortBy {
+	case SortByCreated, SortByModified, SortByBookmarked, SortByTitle, SortByChannel:
+	default:
+		return errors.New(fmt.Sprintf("Unknown sort `%s`. Sort by one of: %s", o.SortBy,
+			strings.Join([]string{SortByCreated, SortByModified, SortByBookmarked, SortByTitle, SortByChannel}, ", ")))
+	}
+	if o.Limit < 1 {
+		return errors.New("limit must be at least 1")
+	}
+	if o.Limit > MaxPageSize {
+		return errors.New(fmt.Sprintf("limit must be at most %d", MaxPageSize))
+	}
+	if o.Page < 1 {
+		return errors.New("page must be at least 1")
+	}
+	return nil
+}
+
+// Sort returns an array of bookmarks sorted by the requested key. Bookmarks
+// with equal keys are ordered by PostID so listings are stable between pages
+func (b *Bookmarks) Sort(sortBy string, reverse bool) ([]*Bookmark, error) {
+	var bmarks []*Bookmark
+	var err error
+
+	switch sortBy {
+	case SortByCreated:
+		bmarks, err = b.ByPostCreateAt()
+	case SortByModified:
+		bmarks = b.sortBy(func(bi, bj *Bookmark) int { return compareInt64(bi.ModifiedAt, bj.ModifiedAt) })
+	case SortByBookmarked:
+		bmarks = b.sortBy(func(bi, bj *Bookmark) int { return compareInt64(bi.CreateAt, bj.CreateAt) })
+	case SortByTitle:
+		bmarks, err = b.byTitle()
+	case SortByChannel:
+		bmarks, err = b.byChannel()
+	default:
+		err = errors.New(fmt.Sprintf("Unknown sort `%s`", sortBy))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if reverse {
+		for i, j := 0, len(bmarks)-1; i < j; i, j = i+1, j-1 {
+			bmarks[i], bmarks[j] = bmarks[j], bmarks[i]
+		}
+	}
+	return bmarks, nil
+}
+
+// sortBy returns an array of bookmarks sorted by compare, then PostID.
+// compare returns a negative number when bi sorts before bj, a positive
+// number when it sorts after, and zero when they are equal
+func (b *Bookmarks) sortBy(compare func(bi, bj *Bookmark) int) []*Bookmark {
+	bmarks := make([]*Bookmark, 0, len(b.ByID))
+	for _, bmark := range b.ByID {
+		bmarks = append(bmarks, bmark)
+	}
+
+	sort.Slice(bmarks, func(i, j int) bool {
+		if c := compare(bmarks[i], bmarks[j]); c != 0 {
+			return c < 0
+		}
+		return bmarks[i].PostID < bmarks[j].PostID
+	})
+	return bmarks
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// byTitle returns an array of bookmarks sorted by the displayed title
+func (b *Bookmarks) byTitle() ([]*Bookmark, error) {
+	// only bookmarks without a title are sorted by post message
+	var untitledIDs []string
+	for _, bmark := range b.ByID {
+		if !bmark.HasUserTitle() {
+			untitledIDs = append(untitledIDs, bmark.PostID)
+		}
+	}
+	if err := b.fetchPosts(untitledIDs); err != nil {
+		return nil, err
+	}
+
+	titles := make(map[string]string)
+	for _, bmark := range b.ByID {
+		title := bmark.GetTitle()
+		if !bmark.HasUserTitle() {
+			var err error
+			title, err = b.getTitleFromPost(bmark.PostID)
+			if err != nil {
+				return nil, err
+			}
+		}
+		titles[bmark.PostID] = strings.ToLower(title)
+	}
+
+	return b.sortBy(func(bi, bj *Bookmark) int {
+		return strings.Compare(titles[bi.PostID], titles[bj.PostID])
+	}), nil
+}
+
+// byChannel returns an array of bookmarks sorted by the display name of the
+// channel of the bookmarked post
+func (b *Bookmarks) byChannel() ([]*Bookmark, error) {
+	places, err := b.getPostPlaces()
+	if err != nil {
+		return nil, err
+	}
+
+	channelNames := make(map[string]string)
+	names := make(map[string]string)
+	for _, bmark := range b.ByID {
+		channelID := places[bmark.PostID].channelID
+
+		// deleted posts have no channel and are sorted first
+		name, ok := channelNames[channelID]
+		if !ok && channelID != "" {
+			channel, err := b.api.GetChannel(channelID)
+			if err != nil {
+				return nil, err
+			}
+			name = strings.ToLower(channel.DisplayName)
+			channelNames[channelID] = name
+		}
+		names[bmark.PostID] = name
+	}
+
+	return b.sortBy(func(bi, bj *Bookmark) int {
+		return strings.Compare(names[bi.PostID], names[bj.PostID])
+	}), nil
+}
+
+// postPlace is the channel and creation time of a bookmarked post
+type postPlace struct {
+	channelID string
+	createAt  int64
+}
+
+// getPostPlaces returns the channel and creation time of each bookmarked post
+// by post ID. They are read from the snapshots of the bookmarks, so only the
+// posts of bookmarks without a snapshot are fetched. Posts in channels the
+// user cannot read have no channel or creation time
+func (b *Bookmarks) getPostPlaces() (map[string]postPlace, error) {
+	var unsavedIDs []string
+	for _, bmark := range b.ByID {
+		if !bmark.HasSnapshot() || bmark.Snapshot.ChannelID == "" {
+			unsavedIDs = append(unsavedIDs, bmark.PostID)
+		}
+	}
+	if err := b.fetchPosts(unsavedIDs); err != nil {
+		return nil, err
+	}
+
+	places := make(map[string]postPlace, len(b.ByID))
+	for _, bmark := range b.ByID {
+		if bmark.HasSnapshot() && bmark.Snapshot.ChannelID != "" {
+			readable, err := b.canReadChannel(bmark.Snapshot.ChannelID)
+			if err != nil {
+				return nil, err
+			}
+			if readable {
+				places[bmark.PostID] = postPlace{channelID: bmark.Snapshot.ChannelID, createAt: bmark.Snapshot.CreateAt}
+			}
+			continue
+		}
+
+		post, err := b.getPost(bmark.PostID)
+		if err != nil {
+			return nil, err
+		}
+		places[bmark.PostID] = postPlace{channelID: post.ChannelId, createAt: post.CreateAt}
+	}
+	return places, nil
+}
+
+// paginate returns the bookmarks on the requested page
+func paginate(bmarks []*Bookmark, limit, page int) []*Bookmark {
+	start := (page - 1) * limit
+	if start >= len(bmarks) {
+		return nil
+	}
+	end := start + limit
+	if end > len(bmarks) {
+		end = len(bmarks)
+	}
+	return bmarks[start:end]
+}
+
+// getPageFooterText returns text describing the listed page and how to fetch
+// the next one. It is empty when all bookmarks fit on one page
+func getPageFooterText(total, limit, page int) string {
+	if total <= limit && page == 1 {
+		return ""
+	}
+
+	start := (page-1)*limit + 1
+	end := start + limit - 1
+	if end > total {
+		end = total
+	}
+
+	text := fmt.Sprintf("\n_Showing bookmarks %d-%d of %d._", start, end, total)
+	if end < total {
+		text += fmt.Sprintf(" _View the next page with_ `--page %d`", page+1)
+	}
+	return text + "\n"
+}
@@ -0,0 +1,75 @@
+package bookmarks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaginate(t *testing.T) {
+	bmarks := []*Bookmark{{PostID: "ID1"}, {PostID: "ID2"}, {PostID: "ID3"}, {PostID: "ID4"}, {PostID: "ID5"}}
+
+	tests := map[string]struct {
+		limit       int
+		page        int
+		expectedIDs []string
+	}{
+		"first page":          {limit: 2, page: 1, expectedIDs: []string{"ID1", "ID2"}},
+		"middle page":         {limit: 2, page: 2, expectedIDs: []string{"ID3", "ID4"}},
+		"partial last page":   {limit: 2, page: 3, expectedIDs: []string{"ID5"}},
+		"page after the last": {limit: 2, page: 4, expectedIDs: nil},
+		"limit above total":   {limit: 10, page: 1, expectedIDs: []string{"ID1", "ID2", "ID3", "ID4", "ID5"}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var ids []string
+			for _, bmark := range paginate(bmarks, tt.limit, tt.page) {
+				ids = append(ids, bmark.PostID)
+			}
+			assert.Equal(t, tt.expectedIDs, ids)
+		})
+	}
+}
+
+func TestGetPageFooterText(t *testing.T) {
+	tests := map[string]struct {
+		total    int
+		limit    int
+		page     int
+		expected string
+	}{
+		"single page":       {total: 3, limit: 25, page: 1, expected: ""},
+		"first of two":      {total: 30, limit: 25, page: 1, expected: "\n_Showing bookmarks 1-25 of 30._ _View the next page with_ `--page 2`\n"},
+		"last of two":       {total: 30, limit: 25, page: 2, expected: "\n_Showing bookmarks 26-30 of 30._\n"},
+		"exactly full page": {total: 50, limit: 25, page: 2, expected: "\n_Showing bookmarks 26-50 of 50._\n"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, getPageFooterText(tt.total, tt.limit, tt.page))
+		})
+	}
+}
+
+func TestListOptionsIsValid(t *testing.T) {
+	tests := map[string]struct {
+		options    *ListOptions
+		wantErrMsg string
+	}{
+		"defaults":        {options: NewListOptions()},
+		"sort by title":   {options: &ListOptions{SortBy: SortByTitle, Limit: 10, Page: 3}},
+		"unknown sort":    {options: &ListOptions{SortBy: "color", Limit: 10, Page: 1}, wantErrMsg: "Unknown sort `color`. Sort by one of: created, modified, bookmarked, title, channel"},
+		"zero limit":      {options: &ListOptions{SortBy: SortByCreated, Limit: 0, Page: 1}, wantErrMsg: "limit must be at least 1"},
+		"limit too large": {options: &ListOptions{SortBy: SortByCreated, Limit: MaxPageSize + 1, Page: 1}, wantErrMsg: "limit must be at most 50"},
+		"zero page":       {options: &ListOptions{SortBy: SortByCreated, Limit: 10, Page: 0}, wantErrMsg: "page must be at least 1"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tt.options.IsValid()
+			if tt.wantErrMsg == "" {
+				assert.Nil(t, err)
+				return
+			}
+			assert.Equal(t, tt.wantErrMsg, err.Error())
+		})
+	}
+}
@@ -3,6 +3,7 @@ package command
 import (
 	"fmt"
 	"strings"
+	"unicode"
 
 	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi"
 	"github.com/mattermost/mattermost-server/v5/model"
@@ -10,15 +11,27 @@ import (
 )
 
 const (
-	routeAPIPrefix             = "/api/v1"
-	routeAutocompleteLabels    = "/autocomplete/labels"
-	routeAutocompleteBookmarks = "/autocomplete/bookmarks"
-
-	add    = "add"
-	help   = "help"
-	label  = "label"
-	remove = "remove"
-	view   = "view"
+	routeAPIPrefix               = "/api/v1"
+	routeAutocompleteLabels      = "/autocomplete/labels"
+	routeAutocompleteBookmarks   = "/autocomplete/bookmarks"
+	routeAutocompleteCollections = "/autocomplete/collections"
+
+	add        = "add"
+	archive    = "archive"
+	bulk       = "bulk"
+	collection = "collection"
+	digest     = "digest"
+	done       = "done"
+	export     = "export"
+	help       = "help"
+	importCmd  = "import"
+	label      = "label"
+	note       = "note"
+	remind     = "remind"
+	remove     = "remove"
+	search     = "search"
+	syncCmd    = "sync"
+	view       = "view"
 )
 
 const (
@@ -32,16 +45,92 @@ const (
 	labelCommandText = `
 **/bookmarks label**
 * |/bookmarks label <post_id> --labels <labels>| - add labels (comma-separated) to a bookmark
-* |/bookmarks label add <labels> | - create a new label
-* |/bookmarks label rename <old> <new>| - rename a label
+* |/bookmarks label add <labels> | - create a new label. Nest labels with |/|, e.g. |projects/apollo|
+* |/bookmarks label rename <old> <new>| - rename a label and move the labels nested under it
+* |/bookmarks label merge <labels> <label>| - replace labels with another label on every bookmark and remove them
+* |/bookmarks label alias <label> <alias>| - find a label by another name as well
+* |/bookmarks label unalias <alias>| - remove an alias of a label
+* |/bookmarks label color <label> <hex>| - set the color of a label, e.g. |#1e90ff|. Omit the color to remove it
+* |/bookmarks label describe <label> <description>| - describe what a label is used for. Omit the description to remove it
 * |/bookmarks label remove <labels> | - remove a label
 * |/bookmarks label remove <labels> --force | - forces removal of labels from bookmarks currently using the label as well as the label list
-* |/bookmarks label view | - list all labels
+* |/bookmarks label view | - list all labels as a tree
 `
 	viewCommandText = `
 **/bookmarks view**
 * |/bookmarks view| - view all saved bookmarks
-* |/bookmarks view <post_id> OR <permalink>| - view detailed bookmark view
+* |/bookmarks view <post_id> OR <permalink>| - view detailed bookmark view and mark the bookmark read
+* |/bookmarks view --filter-labels <label1,label2>| - view bookmarks with any of the labels, or labels nested under them
+* |/bookmarks view --since <date> --until <date>| - view bookmarks saved in a date range. Dates are YYYY-MM-DD in your timezone or a duration ago (12h, 7d, 2w). The time the bookmark was saved is used, not the time of the post
+* |/bookmarks view --channel <~channel> --team <team>| - view bookmarks of posts in a channel or team
+* |/bookmarks view --from <@user>| - view bookmarks of posts written by a user
+* |/bookmarks view --query "label:a AND (label:b OR label:c) AND NOT label:d"| - view bookmarks with labels matching a query
+* |/bookmarks view --collection <name>| - view bookmarks with the filters of a saved collection
+* |/bookmarks view --include-archived| - view archived bookmarks as well. Archived bookmarks are hidden by default
+* |/bookmarks view --unread| - view bookmarks you have not read yet
+* |/bookmarks view --sort <created|modified|bookmarked|title|channel> --reverse| - change the order of the bookmarks
+* |/bookmarks view --limit <number> --page <number>| - view a page of bookmarks. 25 bookmarks are listed per page by default, and at most 50
+`
+	noteCommandText = `
+**/bookmarks note**
+* |/bookmarks note <post_id> <text>| - write a note for a bookmark. Notes are markdown and may span multiple lines
+* |/bookmarks note <post_id>| - remove the note of a bookmark
+`
+	archiveCommandText = `
+**/bookmarks archive**
+* |/bookmarks archive <post_id>| - archive a bookmark you are done with. Archived bookmarks are hidden from |/bookmarks view| unless |--include-archived| is given
+`
+	doneCommandText = `
+**/bookmarks done**
+* |/bookmarks done <post_id>| - mark a bookmark read. Viewing a bookmark with |/bookmarks view <post_id>| marks it read as well. An archived bookmark is restored
+`
+	digestCommandText = `
+**/bookmarks digest**
+* |/bookmarks digest daily <time>| - the Bookmarks bot sends you a direct message every day with new bookmarks, reminders that are due and bookmarks without labels older than 30 days. The time is in your timezone, e.g. |9am| or |17:30|, and defaults to 9am
+* |/bookmarks digest weekly <day> <time>| - get the digest once a week, e.g. |weekly friday 4pm|. The day defaults to Monday
+* |/bookmarks digest off| - stop sending the digest
+* |/bookmarks digest| - show when the digest is sent
+`
+	remindCommandText = `
+**/bookmarks remind**
+* |/bookmarks remind <post_id> <time>| - the Bookmarks bot sends you a direct message about a bookmark at a time in your timezone, e.g. |in 2h|, |tomorrow 9am| or |2026-11-01|. Days without a time remind you at 9am
+* |/bookmarks remind <post_id> off| - remove the reminder of a bookmark
+* |/bookmarks remind <post_id>| - show when you will be reminded of a bookmark
+`
+	collectionCommandText = `
+**/bookmarks collection**
+* |/bookmarks collection save <name> <filters>| - save the filters of a view command as a collection, e.g. |--filter-labels prod --since 1d|
+* |/bookmarks collection remove <name>| - remove a collection
+* |/bookmarks collection view| - list all collections
+`
+	exportCommandText = `
+**/bookmarks export**
+* |/bookmarks export --format <json|csv|md|html>| - export all bookmarks and labels. The Bookmarks bot sends the file in a direct message. |html| is a bookmark file browsers import
+`
+	importCommandText = `
+**/bookmarks import**
+* |/bookmarks import <post_id>| - import the JSON export or CSV files attached to a post. Upload the file in any channel, e.g. your direct message channel with the Bookmarks bot
+* |/bookmarks import --from-flagged| - import your saved (flagged) posts as bookmarks with the |imported| label
+`
+	searchCommandText = `
+**/bookmarks search**
+* |/bookmarks search <query>| - search bookmark titles and post messages. Wrap text in double quotes to match a phrase
+* |/bookmarks search <query> --limit <number> --page <number>| - view a page of search results. 25 results are listed per page by default, and at most 50
+`
+	syncCommandText = `
+**/bookmarks sync**
+* |/bookmarks sync on| - keep bookmarks in sync with your saved (flagged) posts. Saving a post bookmarks it, and removing either removes both
+* |/bookmarks sync off| - stop syncing. Bookmarks and saved posts are kept
+* |/bookmarks sync| - show whether bookmarks are synced
+`
+	bulkCommandText = `
+**/bookmarks bulk**
+* |/bookmarks bulk label-add <labels> <filters>| - add labels (comma-separated) to every bookmark matching the filters of a view command
+* |/bookmarks bulk label-remove <labels> <filters>| - remove labels from every bookmark matching the filters
+* |/bookmarks bulk set-title-prefix <prefix> <filters>| - add a prefix to the title of every bookmark matching the filters
+* |/bookmarks bulk remove <filters>| - remove every bookmark matching the filters, e.g. |--filter-labels sprint-41|
+* |/bookmarks bulk <action> <filters> --dry-run| - list the bookmarks an action applies to without changing them
+* |/bookmarks bulk <action> <filters> --include-archived| - apply the action to archived bookmarks as well. Archived bookmarks are left out by default
 `
 	removeCommandText = `
 **/bookmarks remove**
@@ -51,7 +140,18 @@ const (
 	helpCommandText = `###### Bookmarks Slash Command Help` +
 		addCommandText +
 		labelCommandText +
+		noteCommandText +
+		remindCommandText +
+		doneCommandText +
+		archiveCommandText +
+		digestCommandText +
 		viewCommandText +
+		collectionCommandText +
+		searchCommandText +
+		exportCommandText +
+		importCommandText +
+		syncCommandText +
+		bulkCommandText +
 		removeCommandText
 )
 
@@ -61,6 +161,9 @@ type Command struct {
 	Args      *model.CommandArgs
 	ChannelID string
 	API       pluginapi.API
+
+	// BotUserID is the ID of the Bookmarks bot, which sends files to users
+	BotUserID string
 }
 
 // RegisterFunc is a function that allows the runner to register commands with the mattermost server.
@@ -75,14 +178,69 @@ func getHelp(text string) string {
 	return strings.ReplaceAll(text, "|", "`")
 }
 
+// splitArguments splits a command into fields like strings.Fields, but keeps
+// text wrapped in double quotes together as a single field
+func splitArguments(command string) []string {
+	var args []string
+	var field strings.Builder
+	inField, quoted := false, false
+
+	for _, r := range command {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inField = true
+		case unicode.IsSpace(r) && !quoted:
+			if inField {
+				args = append(args, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(r)
+			inField = true
+		}
+	}
+	if inField {
+		args = append(args, field.String())
+	}
+
+	return args
+}
+
+// getTextAfterFields returns the text of a command after its first n fields,
+// keeping the spaces and line breaks within the text
+func getTextAfterFields(command string, n int) string {
+	for i := 0; i < n; i++ {
+		command = strings.TrimLeftFunc(command, unicode.IsSpace)
+		end := strings.IndexFunc(command, unicode.IsSpace)
+		if end == -1 {
+			return ""
+		}
+		command = command[end:]
+	}
+	return strings.TrimSpace(command)
+}
+
 func createBookmarksCommand() *model.Command {
 	bookmarks := model.NewAutocompleteData(
-		commandTriggerBookmarks, "[command]", "Available commands: add, label, remove, view, help")
+		commandTriggerBookmarks, "[command]", "Available commands: add, archive, bulk, collection, digest, done, export, import, label, note, remind, remove, search, sync, view, help")
 
 	// top-level commands
 	bookmarks.AddCommand(createAddCommand())
+	bookmarks.AddCommand(createArchiveCommand())
+	bookmarks.AddCommand(createBulkCommand())
+	bookmarks.AddCommand(createCollectionCommand())
+	bookmarks.AddCommand(createDigestCommand())
+	bookmarks.AddCommand(createDoneCommand())
+	bookmarks.AddCommand(createExportCommand())
+	bookmarks.AddCommand(createImportCommand())
 	bookmarks.AddCommand(createLabelCommand())
+	bookmarks.AddCommand(createNoteCommand())
+	bookmarks.AddCommand(createRemindCommand())
 	bookmarks.AddCommand(createRemoveCommand())
+	bookmarks.AddCommand(createSearchCommand())
+	bookmarks.AddCommand(createSyncCommand())
 	bookmarks.AddCommand(createViewCommand())
 	bookmarks.AddCommand(createHelpCommand())
 
@@ -93,7 +251,7 @@ func createBookmarksCommand() *model.Command {
 		AutoComplete:     true,
 		AutocompleteData: bookmarks,
 		AutoCompleteHint: "[command]",
-		AutoCompleteDesc: "Available commands: add, label, remove, view, help",
+		AutoCompleteDesc: "Available commands: add, archive, bulk, collection, digest, done, export, import, label, note, remind, remove, search, sync, view, help",
 	}
 }
 
@@ -115,13 +273,108 @@ func createAddCommand() *model.AutocompleteData {
 	return add
 }
 
+// createArchiveCommand adds the archive autocomplete option
+func createArchiveCommand() *model.AutocompleteData {
+	archive := model.NewAutocompleteData(
+		"archive", "[post-id OR permalink]", "Archive a bookmark and hide it from view")
+	archive.AddDynamicListArgument("[post_id] OR [permalink]", prefixWithAPI(routeAutocompleteBookmarks), false)
+	return archive
+}
+
+// createBulkCommand adds the bulk autocomplete option
+func createBulkCommand() *model.AutocompleteData {
+	bulk := model.NewAutocompleteData(
+		"bulk", "[label-add|label-remove|remove|set-title-prefix] [filters] --dry-run", "Change every bookmark matching filters")
+	bulk.AddStaticListArgument("Action", true, []model.AutocompleteListItem{
+		{Item: "label-add", HelpText: "Add labels to the bookmarks"},
+		{Item: "label-remove", HelpText: "Remove labels from the bookmarks"},
+		{Item: "remove", HelpText: "Remove the bookmarks"},
+		{Item: "set-title-prefix", HelpText: "Add a prefix to the titles of the bookmarks"},
+	})
+	return bulk
+}
+
+// createDigestCommand adds the digest autocomplete option
+func createDigestCommand() *model.AutocompleteData {
+	digest := model.NewAutocompleteData(
+		"digest", "[daily|weekly|off] [day] [time]", "Get a direct message with a digest of your bookmarks")
+	digest.AddStaticListArgument("Frequency", false, []model.AutocompleteListItem{
+		{Item: "daily", HelpText: "Every day at a time, e.g. daily 9am"},
+		{Item: "weekly", HelpText: "Once a week on a day at a time, e.g. weekly friday 4pm"},
+		{Item: "off", HelpText: "Stop sending the digest"},
+	})
+	return digest
+}
+
+// createDoneCommand adds the done autocomplete option
+func createDoneCommand() *model.AutocompleteData {
+	done := model.NewAutocompleteData(
+		"done", "[post-id OR permalink]", "Mark a bookmark read")
+	done.AddDynamicListArgument("[post_id] OR [permalink]", prefixWithAPI(routeAutocompleteBookmarks), false)
+	return done
+}
+
+// createExportCommand adds the export autocomplete option
+func createExportCommand() *model.AutocompleteData {
+	export := model.NewAutocompleteData(
+		"export", "--format [json|csv|md|html]", "Export all bookmarks and labels to a file")
+	export.AddNamedStaticListArgument("format", "Format of the file", false, []model.AutocompleteListItem{
+		{Item: "json", HelpText: "Bookmarks and labels, which can be imported again"},
+		{Item: "csv", HelpText: "One row for each bookmark"},
+		{Item: "md", HelpText: "Markdown list of links"},
+		{Item: "html", HelpText: "Bookmark file browsers import"},
+	})
+	return export
+}
+
+// createImportCommand adds the import autocomplete option
+func createImportCommand() *model.AutocompleteData {
+	importCmd := model.NewAutocompleteData(
+		"import", "[post-id OR permalink] OR --from-flagged", "Import bookmarks from a file attached to a post or from saved posts")
+	return importCmd
+}
+
+// createSyncCommand adds the sync autocomplete option
+func createSyncCommand() *model.AutocompleteData {
+	syncCmd := model.NewAutocompleteData(
+		"sync", "[on|off]", "Keep bookmarks in sync with saved posts")
+	syncCmd.AddStaticListArgument("Sync", false, []model.AutocompleteListItem{
+		{Item: "on", HelpText: "Bookmark saved posts and save bookmarked posts"},
+		{Item: "off", HelpText: "Stop syncing bookmarks with saved posts"},
+	})
+	return syncCmd
+}
+
+// createNoteCommand adds the note autocomplete option
+func createNoteCommand() *model.AutocompleteData {
+	note := model.NewAutocompleteData(
+		"note", "[post-id OR permalink] [text]", "Write or remove the note of a bookmark")
+	note.AddDynamicListArgument("[post_id] OR [permalink]", prefixWithAPI(routeAutocompleteBookmarks), false)
+	note.AddTextArgument("Note for the bookmark", "[text]", "")
+	return note
+}
+
+// createRemindCommand adds the remind autocomplete option
+func createRemindCommand() *model.AutocompleteData {
+	remind := model.NewAutocompleteData(
+		"remind", "[post-id OR permalink] [time OR off]", "Get a direct message about a bookmark at a time")
+	remind.AddDynamicListArgument("[post_id] OR [permalink]", prefixWithAPI(routeAutocompleteBookmarks), false)
+	remind.AddTextArgument("Time to remind you, e.g. in 2h, tomorrow 9am, 2026-11-01 or off", "[time]", "")
+	return remind
+}
+
 // createLabelCommand adds the label autocomplete with suboptions
 func createLabelCommand() *model.AutocompleteData {
 	label := model.NewAutocompleteData(
-		"label", "[add|remove|rename|view]", "Create, remove, modify, or view labels")
+		"label", "[add|alias|color|describe|merge|remove|rename|unalias|view]", "Create, remove, modify, or view labels")
 	label.AddCommand(createLabelAddCommand())
+	label.AddCommand(createLabelAliasCommand())
+	label.AddCommand(createLabelColorCommand())
+	label.AddCommand(createLabelDescribeCommand())
+	label.AddCommand(createLabelMergeCommand())
 	label.AddCommand(createLabelRemoveCommand())
 	label.AddCommand(createLabelRenameCommand())
+	label.AddCommand(createLabelUnaliasCommand())
 	label.AddCommand(createLabelViewCommand())
 	return label
 }
@@ -146,6 +399,44 @@ func createLabelAddCommand() *model.AutocompleteData {
 	return remove
 }
 
+func createLabelColorCommand() *model.AutocompleteData {
+	color := model.NewAutocompleteData(
+		"color", "[label-name] [hex-color]", "Set the color of a label")
+	color.AddDynamicListArgument("Label Name", prefixWithAPI(routeAutocompleteLabels), false)
+	color.AddTextArgument("Hex color, e.g. #1e90ff", "[hex-color]", "")
+	return color
+}
+
+func createLabelDescribeCommand() *model.AutocompleteData {
+	describe := model.NewAutocompleteData(
+		"describe", "[label-name] [description]", "Describe what a label is used for")
+	describe.AddDynamicListArgument("Label Name", prefixWithAPI(routeAutocompleteLabels), false)
+	describe.AddTextArgument("Description of the label", "[description]", "")
+	return describe
+}
+
+func createLabelMergeCommand() *model.AutocompleteData {
+	merge := model.NewAutocompleteData(
+		"merge", "[label-names] [into-label-name]", "Merge labels into another label")
+	merge.AddDynamicListArgument("Label Name", prefixWithAPI(routeAutocompleteLabels), false)
+	return merge
+}
+
+func createLabelAliasCommand() *model.AutocompleteData {
+	alias := model.NewAutocompleteData(
+		"alias", "[label-name] [alias]", "Find a label by another name")
+	alias.AddDynamicListArgument("Label Name", prefixWithAPI(routeAutocompleteLabels), true)
+	alias.AddTextArgument("Alias", "[alias]", "")
+	return alias
+}
+
+func createLabelUnaliasCommand() *model.AutocompleteData {
+	unalias := model.NewAutocompleteData(
+		"unalias", "[alias]", "Remove an alias of a label")
+	unalias.AddTextArgument("Alias", "[alias]", "")
+	return unalias
+}
+
 func createLabelRenameCommand() *model.AutocompleteData {
 	remove := model.NewAutocompleteData(
 		"rename", "[label-name] [new-label-name]", "Rename a label")
@@ -153,6 +444,36 @@ func createLabelRenameCommand() *model.AutocompleteData {
 	return remove
 }
 
+// createCollectionCommand adds the collection autocomplete with suboptions
+func createCollectionCommand() *model.AutocompleteData {
+	collection := model.NewAutocompleteData(
+		"collection", "[save|remove|view]", "Save, remove, or view collections of view filters")
+	collection.AddCommand(createCollectionSaveCommand())
+	collection.AddCommand(createCollectionRemoveCommand())
+	collection.AddCommand(createCollectionViewCommand())
+	return collection
+}
+
+func createCollectionSaveCommand() *model.AutocompleteData {
+	save := model.NewAutocompleteData(
+		"save", "[collection-name] [filters]", "Save view filters as a collection")
+	save.AddDynamicListArgument("Collection Name", prefixWithAPI(routeAutocompleteCollections), false)
+	return save
+}
+
+func createCollectionRemoveCommand() *model.AutocompleteData {
+	remove := model.NewAutocompleteData(
+		"remove", "[collection-name]", "Remove a collection")
+	remove.AddDynamicListArgument("Collection Name", prefixWithAPI(routeAutocompleteCollections), false)
+	return remove
+}
+
+func createCollectionViewCommand() *model.AutocompleteData {
+	view := model.NewAutocompleteData(
+		"view", "", "View all collections")
+	return view
+}
+
 // createRemoveCommand adds the remove autocomplete option
 func createRemoveCommand() *model.AutocompleteData {
 	remove := model.NewAutocompleteData(
@@ -161,11 +482,20 @@ func createRemoveCommand() *model.AutocompleteData {
 	return remove
 }
 
+// createSearchCommand adds the search autocomplete option
+func createSearchCommand() *model.AutocompleteData {
+	search := model.NewAutocompleteData(
+		"search", "[query]", "Search bookmark titles and post messages")
+	search.AddTextArgument("Text to search for", "[query]", "")
+	return search
+}
+
 // createViewCommand adds the View autocomplete option with suboptions
 func createViewCommand() *model.AutocompleteData {
 	view := model.NewAutocompleteData(
 		"view", "[post_id] OR [permalink]", "View a bookmark or all bookmarks")
 	view.AddDynamicListArgument("[post_id] OR [permalink]", prefixWithAPI(routeAutocompleteBookmarks), false)
+	view.AddNamedDynamicListArgument(flagCollection, "View bookmarks with the filters of a saved collection", prefixWithAPI(routeAutocompleteCollections), false)
 	return view
 }
 
@@ -194,10 +524,32 @@ func (c *Command) Handle() string {
 	switch action {
 	case add:
 		handler = c.executeCommandAdd
+	case archive:
+		handler = c.executeCommandArchive
+	case bulk:
+		handler = c.executeCommandBulk
+	case collection:
+		handler = c.executeCommandCollection
+	case digest:
+		handler = c.executeCommandDigest
+	case done:
+		handler = c.executeCommandDone
+	case export:
+		handler = c.executeCommandExport
+	case importCmd:
+		handler = c.executeCommandImport
 	case label:
 		handler = c.executeCommandLabel
+	case note:
+		handler = c.executeCommandNote
+	case remind:
+		handler = c.executeCommandRemind
 	case remove:
 		handler = c.executeCommandRemove
+	case search:
+		handler = c.executeCommandSearch
+	case syncCmd:
+		handler = c.executeCommandSync
 	case view:
 		handler = c.executeCommandView
 	case help:
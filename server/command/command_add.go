@@ -51,14 +51,40 @@ func (c *Command) executeCommandAdd() string {
 	}
 	postID := utils.GetPostIDFromLink(subCommand[0])
 
-	_, appErr := c.API.GetPost(postID)
+	post, appErr := c.API.GetPost(postID)
 	if appErr != nil {
 		return c.responsef(c.Args, "PostID `%s` is not a valid postID", postID)
 	}
 
+	// get all bookmarks for user
+	bmarks, err := bookmarks.NewBookmarksWithUser(c.API, c.Args.UserId)
+	if err != nil {
+		return c.responsef(c.Args, "Unable to get bookmarks")
+	}
+
+	// only posts the user can read are bookmarked. A post in another channel
+	// is reported like a post that does not exist
+	existing, err := bmarks.GetBookmark(postID)
+	if err != nil {
+		var readable bool
+		readable, err = bookmarks.CanReadChannel(c.API, c.Args.UserId, post.ChannelId)
+		if err != nil {
+			return c.responsef(c.Args, "Unable to check access to post `%s`", postID)
+		}
+		if !readable {
+			return c.responsef(c.Args, "PostID `%s` is not a valid postID", postID)
+		}
+	}
+
 	var bookmark bookmarks.Bookmark
 	bookmark.PostID = postID
 
+	// the note is edited with the note command and kept when a bookmark is
+	// added again
+	if existing != nil {
+		bookmark.SetNote(existing.GetNote())
+	}
+
 	// user provides a title
 	if len(subCommand) >= 2 {
 		title := c.getTitleFromArguments(subCommand[1:])
@@ -99,12 +125,6 @@ func (c *Command) executeCommandAdd() string {
 		bookmark.AddLabelIDs(labelIDsForBookmark)
 	}
 
-	// get all bookmarks for user
-	bmarks, err := bookmarks.NewBookmarksWithUser(c.API, c.Args.UserId)
-	if err != nil {
-		return c.responsef(c.Args, "Unable to get bookmarks")
-	}
-
 	err = bmarks.AddBookmark(&bookmark)
 	if err != nil {
 		return c.responsef(c.Args, "Unable to add bookmark")
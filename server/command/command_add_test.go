@@ -3,6 +3,7 @@ package command
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strings"
 	"testing"
 
@@ -54,6 +55,12 @@ func TestExecuteCommandAdd(t *testing.T) {
 			expectedMsgPrefix: strings.TrimSpace(fmt.Sprintf("PostID `%v` is not a valid postID", PostIDDoesNotExist)),
 			expectedContains:  nil,
 		},
+		"PostID in a channel the user cannot read": {
+			command:           "/bookmarks add privatePostID",
+			bookmarks:         getExecuteCommandTestBookmarks(),
+			labels:            getExecuteCommandTestLabels(),
+			expectedMsgPrefix: "PostID `privatePostID` is not a valid postID",
+		},
 		"Bookmark added  no title provided": {
 			command:           fmt.Sprintf("/bookmarks add %v", p1ID),
 			bookmarks:         getExecuteCommandTestBookmarks(),
@@ -151,6 +158,14 @@ func TestExecuteCommandAdd(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 		mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+		mockGetPostsByIds(mockPluginAPI)
+
+		// the user left a private channel
+		mockPluginAPI.EXPECT().GetPost("privatePostID").Return(&model.Post{Id: "privatePostID", ChannelId: "privateChannelID"}, nil).AnyTimes()
+		mockPluginAPI.EXPECT().GetChannelMember("privateChannelID", UserID).Return(nil, &model.AppError{StatusCode: http.StatusNotFound}).AnyTimes()
+		mockPluginAPI.EXPECT().GetChannel("privateChannelID").Return(&model.Channel{Type: model.CHANNEL_PRIVATE}, nil).AnyTimes()
+		mockPluginAPI.EXPECT().HasPermissionToChannel(UserID, "privateChannelID", model.PERMISSION_READ_CHANNEL).Return(false).AnyTimes()
+		mockChannelMember(mockPluginAPI)
 
 		config := &model.Config{
 			ServiceSettings: model.ServiceSettings{
@@ -166,14 +181,17 @@ func TestExecuteCommandAdd(t *testing.T) {
 		mockPluginAPI.EXPECT().GetPost(p3ID).Return(p3IDmodel, nil).AnyTimes()
 		mockPluginAPI.EXPECT().GetPost(p4ID).Return(p4IDmodel, nil).AnyTimes()
 
-		jsonBmarks, err := json.Marshal(tt.bookmarks)
+		// the content of an added post is saved with the bookmark
+		mockPluginAPI.EXPECT().GetUser(gomock.Any()).Return(&model.User{Username: "author"}, nil).AnyTimes()
+		mockPluginAPI.EXPECT().GetChannel(gomock.Any()).Return(&model.Channel{DisplayName: "Town Square"}, nil).AnyTimes()
+
 		jsonLabels, err := json.Marshal(tt.labels)
 
 		mockPluginAPI.EXPECT().KVGet(bookmarks.GetLabelsKey(UserID)).Return(jsonLabels, nil).AnyTimes()
-		mockPluginAPI.EXPECT().KVGet(bookmarks.GetBookmarksKey(UserID)).Return(jsonBmarks, nil).AnyTimes()
+		mockBookmarksKV(t, mockPluginAPI, tt.bookmarks)
 
-		mockPluginAPI.EXPECT().KVSet(bookmarks.GetBookmarksKey(UserID), gomock.Any()).Return(nil).AnyTimes()
-		mockPluginAPI.EXPECT().KVSet(bookmarks.GetLabelsKey(UserID), gomock.Any()).Return(nil).AnyTimes()
+		mockPluginAPI.EXPECT().KVSet(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+		mockPluginAPI.EXPECT().KVCompareAndSet(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).AnyTimes()
 
 		t.Run(name, func(t *testing.T) {
 			assert.Nil(t, err)
@@ -0,0 +1,207 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
+	"github.com/spf13/pflag"
+)
+
+const (
+	flagDryRun = "dry-run"
+)
+
+type bulkOptions struct {
+	viewBookmarkOptions
+	dryRun bool
+
+	// args are the arguments of the command that are not flags
+	args []string
+}
+
+func getBulkFlagSet() *pflag.FlagSet {
+	flagSet := getViewBookmarkFlagSet()
+	flagSet.Bool(flagDryRun, false, "list the bookmarks the action applies to without changing them")
+
+	return flagSet
+}
+
+func parseBulkArgs(args []string) (bulkOptions, error) {
+	var options bulkOptions
+
+	bulkFlagSet := getBulkFlagSet()
+	viewOptions, err := parseViewBookmarkFlags(bulkFlagSet, args)
+	if err != nil {
+		return options, err
+	}
+	options.viewBookmarkOptions = viewOptions
+
+	options.dryRun, err = bulkFlagSet.GetBool(flagDryRun)
+	if err != nil {
+		return options, err
+	}
+
+	options.args = bulkFlagSet.Args()
+
+	return options, nil
+}
+
+// parseBulkFilterArgs parses the filters of a bulk command, so the saved
+// filters of a collection may be combined with them
+func parseBulkFilterArgs(args []string) (viewBookmarkOptions, error) {
+	options, err := parseBulkArgs(args)
+	return options.viewBookmarkOptions, err
+}
+
+// executeCommandBulk applies an action to every bookmark selected by filters
+func (c *Command) executeCommandBulk() string {
+	subCommand := splitArguments(c.Args.Command)
+	if len(subCommand) < 3 {
+		return c.responsef(c.Args, "Missing bulk action. You can try %v", getHelp(bulkCommandText))
+	}
+
+	action := subCommand[2]
+	switch action {
+	case bookmarks.BulkLabelAdd, bookmarks.BulkLabelRemove, bookmarks.BulkRemove, bookmarks.BulkSetTitlePrefix:
+	default:
+		return c.responsef(c.Args, "Unknown bulk action: `%s`. You can try %v", action, getHelp(bulkCommandText))
+	}
+
+	options, err := parseBulkArgs(subCommand)
+	if err != nil {
+		return c.responsef(c.Args, "Unable to parse options, %s", err)
+	}
+	// the command, bulk and the action come before the action argument
+	actionArgs := options.args[3:]
+
+	// an action without filters would change every bookmark
+	if !options.hasFilters() {
+		return c.responsef(c.Args, "Please specify the filters of the bookmarks to change %v", getHelp(bulkCommandText))
+	}
+
+	var actionArg string
+	switch action {
+	case bookmarks.BulkRemove:
+		if len(actionArgs) != 0 {
+			return c.responsef(c.Args, "The remove action takes no arguments %v", getHelp(bulkCommandText))
+		}
+	default:
+		if len(actionArgs) != 1 {
+			return c.responsef(c.Args, "Please specify the labels or the title prefix of the %s action %v", action, getHelp(bulkCommandText))
+		}
+		actionArg = actionArgs[0]
+	}
+
+	options.viewBookmarkOptions, err = c.addCollectionArgs(options.viewBookmarkOptions, subCommand, parseBulkFilterArgs)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	bmarkFilters, err := c.getFilters(options.viewBookmarkOptions)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	bmarks, err := bookmarks.NewBookmarksWithUser(c.API, c.Args.UserId)
+	if err != nil {
+		return c.responsef(c.Args, "Unable to retrieve bookmarks for user %s", c.Args.UserId)
+	}
+
+	filtered, err := bmarks.ApplyFilters(bmarkFilters)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+	if len(filtered.ByID) == 0 {
+		return c.responsef(c.Args, "No bookmarks match the filters")
+	}
+
+	if options.dryRun {
+		header := fmt.Sprintf("#### Bookmarks the %s action applies to (%d)\n", action, len(filtered.ByID))
+		if !bmarkFilters.IncludeArchived {
+			header += "Archived bookmarks are left out. Add `--include-archived` to include them\n"
+		}
+		text, err := filtered.GetBmarksListText(header)
+		if err != nil {
+			return c.responsef(c.Args, err.Error())
+		}
+		return c.responsef(c.Args, text+"\nRun the command without `--dry-run` to apply the action")
+	}
+
+	var bmarkIDs []string
+	for id := range filtered.ByID {
+		bmarkIDs = append(bmarkIDs, id)
+	}
+
+	switch action {
+	case bookmarks.BulkLabelAdd:
+		return c.executeCommandBulkLabelAdd(bmarks, bmarkIDs, strings.Split(actionArg, ","))
+	case bookmarks.BulkLabelRemove:
+		return c.executeCommandBulkLabelRemove(bmarks, bmarkIDs, strings.Split(actionArg, ","))
+	case bookmarks.BulkSetTitlePrefix:
+		changed, err := bmarks.SetTitlePrefix(bmarkIDs, actionArg)
+		if err != nil {
+			return c.responsef(c.Args, err.Error())
+		}
+		return c.responsef(c.Args, "Added the title prefix `%s` to %d bookmarks", actionArg, changed)
+	default:
+		changed, err := bmarks.DeleteBookmarks(bmarkIDs)
+		if err != nil {
+			return c.responsef(c.Args, err.Error())
+		}
+		return c.responsef(c.Args, "Removed %d bookmarks", changed)
+	}
+}
+
+// executeCommandBulkLabelAdd adds labels to bookmarks. Labels that do not
+// exist are created
+func (c *Command) executeCommandBulkLabelAdd(bmarks *bookmarks.Bookmarks, bmarkIDs []string, names []string) string {
+	labels, err := bookmarks.NewLabelsWithUser(c.API, c.Args.UserId)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	var labelIDs []string
+	for _, name := range names {
+		if labels.GetLabelByName(name) == nil {
+			if _, err = labels.AddLabel(name); err != nil {
+				return c.responsef(c.Args, "Unable to add new label for: %s, err=%s", name, err.Error())
+			}
+		}
+		labelID, err := labels.GetIDFromName(name)
+		if err != nil {
+			return c.responsef(c.Args, err.Error())
+		}
+		labelIDs = append(labelIDs, labelID)
+	}
+
+	changed, err := bmarks.AddLabelsToBookmarks(bmarkIDs, labelIDs)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+	return c.responsef(c.Args, "Added labels%s to %d bookmarks", bookmarks.GetCodeBlockedLabels(names), changed)
+}
+
+// executeCommandBulkLabelRemove removes labels from bookmarks. The labels
+// are kept
+func (c *Command) executeCommandBulkLabelRemove(bmarks *bookmarks.Bookmarks, bmarkIDs []string, names []string) string {
+	labels, err := bookmarks.NewLabelsWithUser(c.API, c.Args.UserId)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	var labelIDs []string
+	for _, name := range names {
+		labelID, err := labels.GetIDFromName(name)
+		if err != nil {
+			return c.responsef(c.Args, err.Error())
+		}
+		labelIDs = append(labelIDs, labelID)
+	}
+
+	changed, err := bmarks.RemoveLabelsFromBookmarks(bmarkIDs, labelIDs)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+	return c.responsef(c.Args, "Removed labels%s from %d bookmarks", bookmarks.GetCodeBlockedLabels(names), changed)
+}
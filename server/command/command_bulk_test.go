@@ -0,0 +1,137 @@
+package command
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/utils"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteCommandBulk(t *testing.T) {
+	tests := map[string]struct {
+		command             string
+		expectedMsgPrefix   string
+		expectedContains    []string
+		expectedNotContains []string
+	}{
+		"User does not provide an action": {
+			command:           "/bookmarks bulk",
+			expectedMsgPrefix: "Missing bulk action",
+			expectedContains:  []string{"bookmarks bulk label-add"},
+		},
+		"User provides an unknown action": {
+			command:           "/bookmarks bulk archive --filter-labels label1",
+			expectedMsgPrefix: "Unknown bulk action: `archive`",
+		},
+		"User does not provide filters": {
+			command:           "/bookmarks bulk remove",
+			expectedMsgPrefix: "Please specify the filters of the bookmarks to change",
+		},
+		"User provides an unknown flag": {
+			command:           "/bookmarks bulk remove --bogus",
+			expectedMsgPrefix: "Unable to parse options, unknown flag: --bogus",
+		},
+		"User does not provide labels to add": {
+			command:           "/bookmarks bulk label-add --filter-labels label3",
+			expectedMsgPrefix: "Please specify the labels or the title prefix of the label-add action",
+		},
+		"User provides arguments to remove": {
+			command:           "/bookmarks bulk remove label1 --filter-labels label3",
+			expectedMsgPrefix: "The remove action takes no arguments",
+		},
+		"No bookmarks match the filters": {
+			command:           "/bookmarks bulk remove --query label:unknown",
+			expectedMsgPrefix: "No bookmarks match the filters",
+		},
+		"Dry run lists the bookmarks": {
+			command:             "/bookmarks bulk remove --filter-labels label3 --dry-run",
+			expectedMsgPrefix:   strings.TrimSpace(utils.GetLegendText()),
+			expectedContains:    []string{"#### Bookmarks the remove action applies to (2)\nArchived bookmarks are left out. Add `--include-archived` to include them\n", "ID2", "ID3", "without `--dry-run`"},
+			expectedNotContains: []string{"ID1", "ID4"},
+		},
+		"Add labels": {
+			command:           "/bookmarks bulk label-add label1,label8 --filter-labels label3",
+			expectedMsgPrefix: "Added labels `label1` `label8` to 2 bookmarks",
+		},
+		"Add labels the bookmarks have": {
+			command:           "/bookmarks bulk label-add label1 --filter-labels label2",
+			expectedMsgPrefix: "Added labels `label1` to 0 bookmarks",
+		},
+		"Remove labels": {
+			command:           "/bookmarks bulk label-remove label1 --filter-labels label3",
+			expectedMsgPrefix: "Removed labels `label1` from 1 bookmarks",
+		},
+		"Remove labels that do not exist": {
+			command:           "/bookmarks bulk label-remove label8 --filter-labels label3",
+			expectedMsgPrefix: "Label: `label8` does not exist",
+		},
+		"Remove bookmarks": {
+			command:           "/bookmarks bulk remove --filter-labels label1",
+			expectedMsgPrefix: "Removed 2 bookmarks",
+		},
+		"Remove bookmarks of a collection": {
+			command:           "/bookmarks bulk remove --collection untitled",
+			expectedMsgPrefix: "Removed 2 bookmarks",
+		},
+		"Set title prefix": {
+			command:           `/bookmarks bulk set-title-prefix "[sprint 41] " --query "label:label1 OR label:label3"`,
+			expectedMsgPrefix: "Added the title prefix `[sprint 41] ` to 3 bookmarks",
+		},
+	}
+	for name, tt := range tests {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+		mockGetPostsByIds(mockPluginAPI)
+		mockChannelMember(mockPluginAPI)
+
+		config := &model.Config{
+			ServiceSettings: model.ServiceSettings{
+				SiteURL: model.NewString("https://myhost.com"),
+			},
+		}
+		mockPluginAPI.EXPECT().GetConfig().Return(config).AnyTimes()
+		for i, postID := range []string{p1ID, p2ID, p3ID, p4ID} {
+			post := &model.Post{Message: "this is the post.Message", CreateAt: int64(i)}
+			mockPluginAPI.EXPECT().GetPost(postID).Return(post, nil).AnyTimes()
+		}
+
+		jsonCollections, err := json.Marshal(getExecuteCommandTestCollections())
+		assert.Nil(t, err)
+		jsonLabels, err := json.Marshal(getExecuteCommandViewLabels())
+		assert.Nil(t, err)
+
+		mockPluginAPI.EXPECT().KVGet(bookmarks.GetCollectionsKey(UserID)).Return(jsonCollections, nil).AnyTimes()
+		mockPluginAPI.EXPECT().KVGet(bookmarks.GetLabelsKey(UserID)).Return(jsonLabels, nil).AnyTimes()
+		mockBookmarksKV(t, mockPluginAPI, getExecuteCommandViewBookmarks())
+		mockPluginAPI.EXPECT().KVCompareAndSet(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).AnyTimes()
+		mockPluginAPI.EXPECT().KVSetWithOptions(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).AnyTimes()
+
+		t.Run(name, func(t *testing.T) {
+			testCommand := Command{
+				Args: &model.CommandArgs{
+					UserId:  UserID,
+					Command: tt.command},
+				API: mockPluginAPI,
+			}
+
+			message := testCommand.Handle()
+			actual := strings.TrimSpace(message)
+			assert.True(t, strings.HasPrefix(actual, tt.expectedMsgPrefix), "Expected returned message to start with: \n%s\nActual:\n%s", tt.expectedMsgPrefix, actual)
+
+			for i := range tt.expectedNotContains {
+				assert.NotContains(t, actual, tt.expectedNotContains[i])
+			}
+			for i := range tt.expectedContains {
+				assert.Contains(t, actual, tt.expectedContains[i])
+			}
+		})
+	}
+}
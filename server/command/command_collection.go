@@ -0,0 +1,138 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
+)
+
+// executeCommandCollection executes a collection sub-command
+func (c *Command) executeCommandCollection() string {
+	split := strings.Fields(c.Args.Command)
+	if len(split) < 3 {
+		return c.responsef(c.Args, "Missing collection sub-command. You can try %v", getHelp(collectionCommandText))
+	}
+
+	action := split[2]
+
+	handler := c.responsef(c.Args, fmt.Sprintf("Unknown command: "+c.Args.Command))
+	switch action {
+	case "save":
+		handler = c.executeCommandCollectionSave()
+	case "remove":
+		handler = c.executeCommandCollectionRemove()
+	case "view":
+		handler = c.executeCommandCollectionView()
+	case "help":
+		handler = c.responsef(c.Args, getHelp(collectionCommandText))
+	}
+	return handler
+}
+
+// executeCommandCollectionSave saves the filter flags of a view command
+// under a collection name
+func (c *Command) executeCommandCollectionSave() string {
+	subCommand := splitArguments(c.Args.Command)
+	if len(subCommand) < 5 || strings.HasPrefix(subCommand[3], "--") {
+		return c.responsef(c.Args, "Please specify a collection name and filters %v", getHelp(collectionCommandText))
+	}
+
+	name := subCommand[3]
+	args := subCommand[4:]
+
+	// make sure the filters are valid before saving them
+	viewBookmarkFlagSet := getViewBookmarkFlagSet()
+	options, err := parseViewBookmarkFlags(viewBookmarkFlagSet, args)
+	if err != nil {
+		return c.responsef(c.Args, "Unable to parse options, %s", err)
+	}
+	if extra := viewBookmarkFlagSet.Args(); len(extra) != 0 {
+		return c.responsef(c.Args, "Unexpected argument `%s`. A collection only saves filter flags %v", extra[0], getHelp(collectionCommandText))
+	}
+	if options.collection != "" {
+		return c.responsef(c.Args, "A collection cannot include another collection")
+	}
+	if _, err = c.getFilters(options); err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	collections, err := bookmarks.NewCollectionsWithUser(c.API, c.Args.UserId)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	collection := &bookmarks.Collection{
+		Name: name,
+		Args: args,
+	}
+	if err = collections.SaveCollection(collection); err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	return c.responsef(c.Args, "Saved collection `%s`: %s", name, getCollectionArgsText(collection))
+}
+
+// executeCommandCollectionRemove removes a collection from the store
+func (c *Command) executeCommandCollectionRemove() string {
+	subCommand := strings.Fields(c.Args.Command)
+	if len(subCommand) < 4 {
+		return c.responsef(c.Args, "Please specify a collection name %v", getHelp(collectionCommandText))
+	}
+
+	name := subCommand[3]
+
+	collections, err := bookmarks.NewCollectionsWithUser(c.API, c.Args.UserId)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	if err = collections.DeleteCollection(name); err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	return c.responsef(c.Args, "Removed collection: `%s`", name)
+}
+
+// executeCommandCollectionView lists all saved collections
+func (c *Command) executeCommandCollectionView() string {
+	subCommand := strings.Fields(c.Args.Command)
+	if len(subCommand) != 3 {
+		return c.responsef(c.Args, "view subcommand takes no arguments%v", getHelp(collectionCommandText))
+	}
+
+	collections, err := bookmarks.NewCollectionsWithUser(c.API, c.Args.UserId)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+	if len(collections.ByName) == 0 {
+		return c.responsef(c.Args, "You do not have any saved collections")
+	}
+
+	var names []string
+	for name := range collections.ByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	text := "#### Collections List\n"
+	for _, name := range names {
+		text += fmt.Sprintf("`%s` %s\n", name, getCollectionArgsText(collections.ByName[name]))
+	}
+
+	return c.responsef(c.Args, text)
+}
+
+// getCollectionArgsText returns the filter flags of a collection as they
+// would be typed in a view command
+func getCollectionArgsText(collection *bookmarks.Collection) string {
+	var args []string
+	for _, arg := range collection.Args {
+		if strings.ContainsAny(arg, " \t") {
+			arg = `"` + arg + `"`
+		}
+		args = append(args, arg)
+	}
+	return "`" + strings.Join(args, " ") + "`"
+}
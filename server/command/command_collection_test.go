@@ -0,0 +1,170 @@
+package command
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/utils"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func getExecuteCommandTestCollections() *bookmarks.Collections {
+	c1 := &bookmarks.Collection{
+		Name: "standup",
+		Args: []string{"--query", "label:label1 AND NOT label:label3"},
+	}
+	c2 := &bookmarks.Collection{
+		Name: "untitled",
+		Args: []string{"--filter-labels", "label3"},
+	}
+
+	collections := bookmarks.NewCollections(UserID)
+	collections.ByName[c1.Name] = c1
+	collections.ByName[c2.Name] = c2
+	return collections
+}
+
+func TestExecuteCommandCollection(t *testing.T) {
+	tests := map[string]struct {
+		command             string
+		collections         *bookmarks.Collections
+		expectedMsgPrefix   string
+		expectedContains    []string
+		expectedNotContains []string
+	}{
+		"User does not provide a sub-command": {
+			command:           "/bookmarks collection",
+			expectedMsgPrefix: "Missing collection sub-command",
+			expectedContains:  []string{"bookmarks collection save"},
+		},
+
+		// save
+		"Save without name or filters": {
+			command:           "/bookmarks collection save",
+			expectedMsgPrefix: "Please specify a collection name and filters",
+		},
+		"Save without filters": {
+			command:           "/bookmarks collection save standup",
+			expectedMsgPrefix: "Please specify a collection name and filters",
+		},
+		"Save with unknown flag": {
+			command:           "/bookmarks collection save standup --bogus",
+			expectedMsgPrefix: "Unable to parse options, unknown flag: --bogus",
+		},
+		"Save with invalid query": {
+			command:           `/bookmarks collection save standup --query "label:label1 OR"`,
+			expectedMsgPrefix: "Unable to parse query: query ends unexpectedly",
+		},
+		"Save collection including a collection": {
+			command:           "/bookmarks collection save standup --collection untitled",
+			expectedMsgPrefix: "A collection cannot include another collection",
+		},
+		"Save collection with an argument that is not a flag": {
+			command:           "/bookmarks collection save standup --filter-labels label1 junk",
+			expectedMsgPrefix: "Unexpected argument `junk`. A collection only saves filter flags",
+		},
+		"Save collection": {
+			command:           `/bookmarks collection save oncall --filter-labels label1 --query "label:label1 AND NOT label:label3" --since 1d`,
+			expectedMsgPrefix: "Saved collection `oncall`: `--filter-labels label1 --query \"label:label1 AND NOT label:label3\" --since 1d`",
+		},
+
+		// remove
+		"Remove collection that does not exist": {
+			command:           "/bookmarks collection remove unknown",
+			expectedMsgPrefix: "Collection `unknown` does not exist",
+		},
+		"Remove collection": {
+			command:           "/bookmarks collection remove standup",
+			expectedMsgPrefix: "Removed collection: `standup`",
+		},
+
+		// view
+		"View collections  none saved": {
+			command:           "/bookmarks collection view",
+			collections:       bookmarks.NewCollections(UserID),
+			expectedMsgPrefix: "You do not have any saved collections",
+		},
+		"View collections": {
+			command:           "/bookmarks collection view",
+			expectedMsgPrefix: "#### Collections List\n`standup` `--query \"label:label1 AND NOT label:label3\"`\n`untitled` `--filter-labels label3`",
+		},
+
+		// view bookmarks of a collection
+		"View bookmarks of collection": {
+			command:             "/bookmarks view --collection standup",
+			expectedMsgPrefix:   strings.TrimSpace(utils.GetLegendText()),
+			expectedContains:    []string{"ID1"},
+			expectedNotContains: []string{"ID2", "ID3", "ID4"},
+		},
+		"View bookmarks of collection with additional filters": {
+			command:             "/bookmarks view --collection untitled --since 2020-01-01",
+			expectedMsgPrefix:   strings.TrimSpace(utils.GetLegendText()),
+			expectedContains:    []string{"ID2", "ID3"},
+			expectedNotContains: []string{"ID1", "ID4"},
+		},
+		"View bookmarks of collection that does not exist": {
+			command:           "/bookmarks view --collection unknown",
+			expectedMsgPrefix: "Collection `unknown` does not exist",
+		},
+	}
+	for name, tt := range tests {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+		mockGetPostsByIds(mockPluginAPI)
+		mockChannelMember(mockPluginAPI)
+
+		config := &model.Config{
+			ServiceSettings: model.ServiceSettings{
+				SiteURL: model.NewString("https://myhost.com"),
+			},
+		}
+		mockPluginAPI.EXPECT().GetConfig().Return(config).AnyTimes()
+		mockPluginAPI.EXPECT().GetUser(UserID).Return(&model.User{Id: UserID}, nil).AnyTimes()
+		for i, postID := range []string{p1ID, p2ID, p3ID, p4ID} {
+			post := &model.Post{Message: "this is the post.Message", CreateAt: int64(i)}
+			mockPluginAPI.EXPECT().GetPost(postID).Return(post, nil).AnyTimes()
+		}
+
+		collections := tt.collections
+		if collections == nil {
+			collections = getExecuteCommandTestCollections()
+		}
+		jsonCollections, err := json.Marshal(collections)
+		assert.Nil(t, err)
+
+		jsonLabels, err := json.Marshal(getExecuteCommandViewLabels())
+		assert.Nil(t, err)
+
+		mockPluginAPI.EXPECT().KVGet(bookmarks.GetCollectionsKey(UserID)).Return(jsonCollections, nil).AnyTimes()
+		mockPluginAPI.EXPECT().KVGet(bookmarks.GetLabelsKey(UserID)).Return(jsonLabels, nil).AnyTimes()
+		mockBookmarksKV(t, mockPluginAPI, getExecuteCommandViewBookmarks())
+		mockPluginAPI.EXPECT().KVCompareAndSet(bookmarks.GetCollectionsKey(UserID), jsonCollections, gomock.Any()).Return(true, nil).AnyTimes()
+
+		t.Run(name, func(t *testing.T) {
+			testCommand := Command{
+				Args: &model.CommandArgs{
+					UserId:  UserID,
+					Command: tt.command},
+				API: mockPluginAPI,
+			}
+
+			message := testCommand.Handle()
+			actual := strings.TrimSpace(message)
+			assert.True(t, strings.HasPrefix(actual, tt.expectedMsgPrefix), "Expected returned message to start with: \n%s\nActual:\n%s", tt.expectedMsgPrefix, actual)
+
+			for i := range tt.expectedNotContains {
+				assert.NotContains(t, actual, tt.expectedNotContains[i])
+			}
+			for i := range tt.expectedContains {
+				assert.Contains(t, actual, tt.expectedContains[i])
+			}
+		})
+	}
+}
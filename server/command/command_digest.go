@@ -0,0 +1,47 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// executeCommandDigest turns the digest of the bookmarks of the user on or
+// off, or shows when it is sent
+func (c *Command) executeCommandDigest() string {
+	subCommand := strings.Fields(c.Args.Command)
+	subCommand = subCommand[2:]
+
+	settings, err := bookmarks.NewSettingsWithUser(c.API, c.Args.UserId)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	if len(subCommand) == 1 && subCommand[0] == "off" {
+		if err = settings.DisableDigest(); err != nil {
+			return c.responsef(c.Args, err.Error())
+		}
+		return c.responsef(c.Args, "The Bookmarks bot will no longer send you a digest of your bookmarks")
+	}
+
+	location, err := bookmarks.GetUserLocation(c.API, c.Args.UserId)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	if len(subCommand) == 0 {
+		if !settings.HasDigest() {
+			return c.responsef(c.Args, "You do not get a digest of your bookmarks. You can try %v", getHelp(digestCommandText))
+		}
+		return c.responsef(c.Args, "The Bookmarks bot sends you a digest of your bookmarks %s (%s)", settings.GetDigestScheduleText(), location)
+	}
+
+	if err = settings.ParseDigestSchedule(subCommand); err != nil {
+		return c.responsef(c.Args, "%s %v", err.Error(), getHelp(digestCommandText))
+	}
+	if err = settings.EnableDigest(model.GetMillis()); err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+	return c.responsef(c.Args, "The Bookmarks bot will send you a digest of your bookmarks %s (%s). It lists new bookmarks, reminders that are due and bookmarks without labels older than 30 days", settings.GetDigestScheduleText(), location)
+}
@@ -0,0 +1,110 @@
+package command
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteCommandDigest(t *testing.T) {
+	tests := map[string]struct {
+		command           string
+		settings          *bookmarks.Settings
+		expectedMsgPrefix string
+		expectedSettings  *bookmarks.Settings
+		expectedEnabled   *bool
+	}{
+		"User does not get a digest": {
+			command:           "/bookmarks digest",
+			settings:          &bookmarks.Settings{},
+			expectedMsgPrefix: "You do not get a digest of your bookmarks",
+		},
+		"User gets a digest": {
+			command:           "/bookmarks digest",
+			settings:          &bookmarks.Settings{DigestFrequency: bookmarks.DigestWeekly, DigestWeekday: time.Friday, DigestHour: 16},
+			expectedMsgPrefix: "The Bookmarks bot sends you a digest of your bookmarks every Friday at 4:00 PM (Europe/Berlin)",
+		},
+		"User provides an unknown frequency": {
+			command:           "/bookmarks digest hourly",
+			settings:          &bookmarks.Settings{},
+			expectedMsgPrefix: "Please specify `daily` or `weekly`",
+		},
+		"User turns the daily digest on": {
+			command:           "/bookmarks digest daily 17:30",
+			settings:          &bookmarks.Settings{SyncFlagged: true},
+			expectedMsgPrefix: "The Bookmarks bot will send you a digest of your bookmarks daily at 5:30 PM (Europe/Berlin)",
+			expectedSettings:  &bookmarks.Settings{SyncFlagged: true, DigestFrequency: bookmarks.DigestDaily, DigestWeekday: time.Monday, DigestHour: 17, DigestMinute: 30},
+			expectedEnabled:   model.NewBool(true),
+		},
+		"User turns the digest off": {
+			command:           "/bookmarks digest off",
+			settings:          &bookmarks.Settings{DigestFrequency: bookmarks.DigestDaily, DigestHour: 9, LastDigestAt: 1000},
+			expectedMsgPrefix: "The Bookmarks bot will no longer send you a digest of your bookmarks",
+			expectedSettings:  &bookmarks.Settings{},
+			expectedEnabled:   model.NewBool(false),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+
+			jsonSettings, err := json.Marshal(tt.settings)
+			assert.Nil(t, err)
+			mockPluginAPI.EXPECT().KVGet(bookmarks.GetSettingsKey(UserID)).Return(jsonSettings, nil).AnyTimes()
+			mockPluginAPI.EXPECT().GetUser(UserID).Return(&model.User{
+				Id:       UserID,
+				Timezone: model.StringMap{"useAutomaticTimezone": "false", "manualTimezone": "Europe/Berlin"},
+			}, nil).AnyTimes()
+
+			if tt.expectedSettings != nil {
+				mockPluginAPI.EXPECT().KVCompareAndSet(bookmarks.GetSettingsKey(UserID), jsonSettings, gomock.Any()).DoAndReturn(func(key string, oldValue, value []byte) (bool, error) {
+					var actual bookmarks.Settings
+					assert.Nil(t, json.Unmarshal(value, &actual))
+					if actual.LastDigestAt != 0 {
+						assert.InDelta(t, model.GetMillis(), actual.LastDigestAt, float64(time.Minute/time.Millisecond))
+						actual.LastDigestAt = 0
+					}
+					assert.Equal(t, tt.expectedSettings, &actual)
+					return true, nil
+				})
+			}
+			if tt.expectedEnabled != nil {
+				var stored []byte
+				if !*tt.expectedEnabled {
+					stored = []byte(`{"version":1,"data":["UserID"]}`)
+				}
+				mockPluginAPI.EXPECT().KVGet(bookmarks.StoreDigestUsersKey).Return(stored, nil)
+				mockPluginAPI.EXPECT().KVSetWithOptions(bookmarks.StoreDigestUsersKey, gomock.Any(), gomock.Any()).DoAndReturn(
+					func(key string, value []byte, options model.PluginKVSetOptions) (bool, error) {
+						if *tt.expectedEnabled {
+							assert.Equal(t, `{"version":1,"data":["UserID"]}`, string(value))
+						} else {
+							assert.Nil(t, value)
+						}
+						return true, nil
+					})
+			}
+
+			testCommand := Command{
+				Args: &model.CommandArgs{
+					UserId:  UserID,
+					Command: tt.command},
+				API: mockPluginAPI,
+			}
+
+			message := testCommand.Handle()
+			actual := strings.TrimSpace(message)
+			assert.True(t, strings.HasPrefix(actual, tt.expectedMsgPrefix), "Expected returned message to start with: \n%s\nActual:\n%s", tt.expectedMsgPrefix, actual)
+		})
+	}
+}
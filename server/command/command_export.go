@@ -0,0 +1,99 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+const (
+	flagFormat = "format"
+)
+
+type exportOptions struct {
+	format string
+}
+
+func getExportFlagSet() *pflag.FlagSet {
+	flagSet := pflag.NewFlagSet("export bookmarks", pflag.ContinueOnError)
+	flagSet.String(flagFormat, bookmarks.ExportFormatJSON, "format of the file: json, csv, md or html")
+
+	return flagSet
+}
+
+func parseExportArgs(args []string) (exportOptions, error) {
+	var options exportOptions
+	exportFlagSet := getExportFlagSet()
+	err := exportFlagSet.Parse(args)
+	if err != nil {
+		return options, err
+	}
+
+	options.format, err = exportFlagSet.GetString(flagFormat)
+	if err != nil {
+		return options, err
+	}
+
+	return options, nil
+}
+
+// executeCommandExport exports all bookmarks and labels to a file, which the
+// Bookmarks bot sends to the user in a direct message
+func (c *Command) executeCommandExport() string {
+	subCommand := strings.Fields(c.Args.Command)
+
+	options, err := parseExportArgs(subCommand)
+	if err != nil {
+		return c.responsef(c.Args, "Unable to parse options, %s", err)
+	}
+
+	bmarks, err := bookmarks.NewBookmarksWithUser(c.API, c.Args.UserId)
+	if err != nil {
+		return c.responsef(c.Args, "Unable to retrieve bookmarks for user %s", c.Args.UserId)
+	}
+	if bmarks == nil || len(bmarks.ByID) == 0 {
+		return c.responsef(c.Args, "You do not have any saved bookmarks")
+	}
+
+	data, err := bmarks.Export(options.format)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	fileName := bookmarks.GetExportFileName(options.format, time.Now())
+	if err = c.sendBotFile(data, fileName, "Your exported bookmarks are attached"); err != nil {
+		return c.responsef(c.Args, "Unable to send the exported bookmarks, %s", err)
+	}
+
+	return c.responsef(c.Args, "Exported %d bookmarks to `%s`. The Bookmarks bot sent you the file in a direct message", len(bmarks.ByID), fileName)
+}
+
+// sendBotFile posts a message with a file attachment from the Bookmarks bot
+// in its direct message channel with the user
+func (c *Command) sendBotFile(data []byte, fileName, message string) error {
+	channel, err := c.API.GetDirectChannel(c.Args.UserId, c.BotUserID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get the direct channel of the bot")
+	}
+
+	info, err := c.API.UploadFile(data, channel.Id, fileName)
+	if err != nil {
+		return errors.Wrap(err, "failed to upload the file")
+	}
+
+	_, err = c.API.CreatePost(&model.Post{
+		UserId:    c.BotUserID,
+		ChannelId: channel.Id,
+		Message:   message,
+		FileIds:   model.StringArray{info.Id},
+	})
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to post the file %s", fileName))
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package command
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteCommandExport(t *testing.T) {
+	botID := "botID"
+	tests := map[string]struct {
+		command           string
+		bookmarks         *bookmarks.Bookmarks
+		expectedMsgPrefix string
+		expectedFileName  string
+		expectedContains  []string
+	}{
+		"User has no bookmarks": {
+			command:           "/bookmarks export",
+			expectedMsgPrefix: "You do not have any saved bookmarks",
+		},
+		"User provides an unknown flag": {
+			command:           "/bookmarks export --bogus",
+			bookmarks:         getExecuteCommandViewBookmarks(),
+			expectedMsgPrefix: "Unable to parse options, unknown flag: --bogus",
+		},
+		"User provides an unknown format": {
+			command:           "/bookmarks export --format xml",
+			bookmarks:         getExecuteCommandViewBookmarks(),
+			expectedMsgPrefix: "Export format `xml` is not supported",
+		},
+		"Export to JSON by default": {
+			command:           "/bookmarks export",
+			bookmarks:         getExecuteCommandViewBookmarks(),
+			expectedMsgPrefix: "Exported 4 bookmarks to `bookmarks-",
+			expectedFileName:  ".json",
+			expectedContains:  []string{`"post_id": "ID1"`, `"name": "label1"`},
+		},
+		"Export to CSV": {
+			command:           "/bookmarks export --format csv",
+			bookmarks:         getExecuteCommandViewBookmarks(),
+			expectedMsgPrefix: "Exported 4 bookmarks to `bookmarks-",
+			expectedFileName:  ".csv",
+			expectedContains:  []string{"post_id,permalink,title", "ID2,https://myhost.com/_redirect/pl/ID2,Title2"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+			mockGetPostsByIds(mockPluginAPI)
+			mockChannelMember(mockPluginAPI)
+
+			config := &model.Config{
+				ServiceSettings: model.ServiceSettings{
+					SiteURL: model.NewString("https://myhost.com"),
+				},
+			}
+			mockPluginAPI.EXPECT().GetConfig().Return(config).AnyTimes()
+			for i, postID := range []string{p1ID, p2ID, p3ID, p4ID} {
+				post := &model.Post{Id: postID, Message: "this is the post.Message", UserId: "authorID", ChannelId: "channelID", CreateAt: int64(i)}
+				mockPluginAPI.EXPECT().GetPost(postID).Return(post, nil).AnyTimes()
+			}
+			mockPluginAPI.EXPECT().GetUser("authorID").Return(&model.User{Username: "author"}, nil).AnyTimes()
+			mockPluginAPI.EXPECT().GetChannel("channelID").Return(&model.Channel{DisplayName: "Town Square"}, nil).AnyTimes()
+
+			jsonLabels, err := json.Marshal(getExecuteCommandViewLabels())
+			assert.Nil(t, err)
+			mockPluginAPI.EXPECT().KVGet(bookmarks.GetLabelsKey(UserID)).Return(jsonLabels, nil).AnyTimes()
+			mockBookmarksKV(t, mockPluginAPI, tt.bookmarks)
+
+			if tt.expectedFileName != "" {
+				mockPluginAPI.EXPECT().GetDirectChannel(UserID, botID).Return(&model.Channel{Id: "dmID"}, nil)
+				mockPluginAPI.EXPECT().UploadFile(gomock.Any(), "dmID", gomock.Any()).DoAndReturn(
+					func(data []byte, channelID, fileName string) (*model.FileInfo, error) {
+						assert.True(t, strings.HasSuffix(fileName, tt.expectedFileName))
+						for _, expected := range tt.expectedContains {
+							assert.Contains(t, string(data), expected)
+						}
+						return &model.FileInfo{Id: "fileID", Name: fileName}, nil
+					})
+				mockPluginAPI.EXPECT().CreatePost(gomock.Any()).DoAndReturn(func(post *model.Post) (*model.Post, error) {
+					assert.Equal(t, botID, post.UserId)
+					assert.Equal(t, "dmID", post.ChannelId)
+					assert.Equal(t, model.StringArray{"fileID"}, post.FileIds)
+					return post, nil
+				})
+			}
+
+			testCommand := Command{
+				Args: &model.CommandArgs{
+					UserId:  UserID,
+					Command: tt.command},
+				API:       mockPluginAPI,
+				BotUserID: botID,
+			}
+
+			message := testCommand.Handle()
+			actual := strings.TrimSpace(message)
+			assert.True(t, strings.HasPrefix(actual, tt.expectedMsgPrefix), "Expected returned message to start with: \n%s\nActual:\n%s", tt.expectedMsgPrefix, actual)
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+const (
+	flagFromFlagged = "from-flagged"
+)
+
+type importOptions struct {
+	fromFlagged bool
+
+	// args are the arguments of the command that are not flags
+	args []string
+}
+
+func getImportFlagSet() *pflag.FlagSet {
+	flagSet := pflag.NewFlagSet("import bookmarks", pflag.ContinueOnError)
+	flagSet.Bool(flagFromFlagged, false, "import the saved (flagged) posts of the user")
+
+	return flagSet
+}
+
+func parseImportArgs(args []string) (importOptions, error) {
+	var options importOptions
+	importFlagSet := getImportFlagSet()
+	err := importFlagSet.Parse(args)
+	if err != nil {
+		return options, err
+	}
+
+	options.fromFlagged, err = importFlagSet.GetBool(flagFromFlagged)
+	if err != nil {
+		return options, err
+	}
+
+	options.args = importFlagSet.Args()
+
+	return options, nil
+}
+
+// executeCommandImport merges bookmarks from the files attached to a post, or
+// from the saved posts of the user, into the bookmarks of the user
+func (c *Command) executeCommandImport() string {
+	subCommand := strings.Fields(c.Args.Command)
+
+	options, err := parseImportArgs(subCommand)
+	if err != nil {
+		return c.responsef(c.Args, "Unable to parse options, %s", err)
+	}
+	// the command and import come before the post ID
+	args := options.args[2:]
+
+	var export *bookmarks.Export
+	switch {
+	case options.fromFlagged && len(args) == 0:
+		export, err = bookmarks.NewImportFromFlaggedPosts(c.API, c.Args.UserId)
+		if err != nil {
+			return c.responsef(c.Args, err.Error())
+		}
+		if len(export.Bookmarks) == 0 {
+			return c.responsef(c.Args, "You do not have any saved posts")
+		}
+	case !options.fromFlagged && len(args) == 1:
+		export, err = c.getImportFromPost(utils.GetPostIDFromLink(args[0]))
+		if err != nil {
+			return c.responsef(c.Args, err.Error())
+		}
+	default:
+		return c.responsef(c.Args, "Please specify a post with an attached file or `--from-flagged` %v", getHelp(importCommandText))
+	}
+
+	bmarks, err := bookmarks.NewBookmarksWithUser(c.API, c.Args.UserId)
+	if err != nil {
+		return c.responsef(c.Args, "Unable to retrieve bookmarks for user %s", c.Args.UserId)
+	}
+	labels, err := bookmarks.NewLabelsWithUser(c.API, c.Args.UserId)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	result, err := bmarks.Import(labels, export)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+	return c.responsef(c.Args, result.GetText())
+}
+
+// getImportFromPost returns the bookmarks of the JSON and CSV files attached
+// to a post the user can read
+func (c *Command) getImportFromPost(postID string) (*bookmarks.Export, error) {
+	post, err := c.API.GetPost(postID)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("PostID `%s` is not a valid postID", postID))
+	}
+	readable, err := bookmarks.CanReadChannel(c.API, c.Args.UserId, post.ChannelId)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Unable to check access to post `%s`", postID))
+	}
+	if !readable {
+		return nil, errors.New(fmt.Sprintf("PostID `%s` is not a valid postID", postID))
+	}
+
+	export := &bookmarks.Export{Version: bookmarks.ExportVersion}
+	var imported int
+	for _, fileID := range post.FileIds {
+		info, err := c.API.GetFileInfo(fileID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to get file %s", fileID)
+		}
+		format := strings.ToLower(info.Extension)
+		if format != bookmarks.ExportFormatJSON && format != bookmarks.ExportFormatCSV {
+			continue
+		}
+
+		data, err := c.API.GetFile(fileID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to read file %s", info.Name)
+		}
+		fileExport, err := bookmarks.ParseImport(format, data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to import file %s", info.Name)
+		}
+		export.Bookmarks = append(export.Bookmarks, fileExport.Bookmarks...)
+		export.Labels = append(export.Labels, fileExport.Labels...)
+		for _, reason := range fileExport.Invalid {
+			export.Invalid = append(export.Invalid, fmt.Sprintf("%s: %s", info.Name, reason))
+		}
+		imported++
+	}
+
+	if imported == 0 {
+		return nil, errors.New(fmt.Sprintf("Post `%s` has no attached JSON or CSV file", postID))
+	}
+	return export, nil
+}
@@ -0,0 +1,101 @@
+package command
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteCommandImport(t *testing.T) {
+	tests := map[string]struct {
+		command           string
+		expectedMsgPrefix string
+		expectedContains  []string
+	}{
+		"User does not provide a post": {
+			command:           "/bookmarks import",
+			expectedMsgPrefix: "Please specify a post with an attached file or `--from-flagged`",
+		},
+		"User provides a post and --from-flagged": {
+			command:           "/bookmarks import fileID --from-flagged",
+			expectedMsgPrefix: "Please specify a post with an attached file or `--from-flagged`",
+		},
+		"User provides an unknown flag": {
+			command:           "/bookmarks import --bogus",
+			expectedMsgPrefix: "Unable to parse options, unknown flag: --bogus",
+		},
+		"User provides a post that does not exist": {
+			command:           "/bookmarks import unknownID",
+			expectedMsgPrefix: "PostID `unknownID` is not a valid postID",
+		},
+		"Post has no JSON or CSV file": {
+			command:           "/bookmarks import imageID",
+			expectedMsgPrefix: "Post `imageID` has no attached JSON or CSV file",
+		},
+		"Import a CSV file": {
+			command:           "/bookmarks import csvID",
+			expectedMsgPrefix: "Imported 1 bookmarks. 0 existing bookmarks were updated and 1 were unchanged",
+			expectedContains:  []string{"#### Conflicts", "`ID2`", "#### Unknown posts", "`deletedID`"},
+		},
+		"Import flagged posts": {
+			command:           "/bookmarks import --from-flagged",
+			expectedMsgPrefix: "Imported 1 bookmarks. 1 existing bookmarks were updated and 0 were unchanged",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+			mockGetPostsByIds(mockPluginAPI)
+			mockChannelMember(mockPluginAPI)
+
+			for _, postID := range []string{p1ID, p2ID, p3ID, p4ID, "newID"} {
+				post := &model.Post{Id: postID, Message: "this is the post.Message", UserId: "authorID", ChannelId: "channelID"}
+				mockPluginAPI.EXPECT().GetPost(postID).Return(post, nil).AnyTimes()
+			}
+			mockPluginAPI.EXPECT().GetPost("unknownID").Return(nil, &model.AppError{StatusCode: 404}).AnyTimes()
+			mockPluginAPI.EXPECT().GetPost("deletedID").Return(&model.Post{Id: "deletedID", ChannelId: "channelID", DeleteAt: 1}, nil).AnyTimes()
+			mockPluginAPI.EXPECT().GetPost("imageID").Return(&model.Post{Id: "imageID", ChannelId: "channelID", FileIds: []string{"imageFileID"}}, nil).AnyTimes()
+			mockPluginAPI.EXPECT().GetPost("csvID").Return(&model.Post{Id: "csvID", ChannelId: "channelID", FileIds: []string{"imageFileID", "csvFileID"}}, nil).AnyTimes()
+			mockPluginAPI.EXPECT().GetFileInfo("imageFileID").Return(&model.FileInfo{Name: "image.png", Extension: "png"}, nil).AnyTimes()
+			mockPluginAPI.EXPECT().GetFileInfo("csvFileID").Return(&model.FileInfo{Name: "bookmarks.csv", Extension: "csv"}, nil).AnyTimes()
+			mockPluginAPI.EXPECT().GetFile("csvFileID").Return([]byte("post_id,title,labels\nID2,other title,label1\nnewID,,label8\ndeletedID,,\n"), nil).AnyTimes()
+			mockPluginAPI.EXPECT().GetUser("authorID").Return(&model.User{Username: "author"}, nil).AnyTimes()
+			mockPluginAPI.EXPECT().GetChannel("channelID").Return(&model.Channel{DisplayName: "Town Square"}, nil).AnyTimes()
+			mockPluginAPI.EXPECT().GetPreferencesForUser(UserID).Return([]model.Preference{
+				{UserId: UserID, Category: model.PREFERENCE_CATEGORY_FLAGGED_POST, Name: p1ID, Value: "true"},
+				{UserId: UserID, Category: model.PREFERENCE_CATEGORY_FLAGGED_POST, Name: "newID", Value: "true"},
+			}, nil).AnyTimes()
+
+			jsonLabels, err := json.Marshal(getExecuteCommandViewLabels())
+			assert.Nil(t, err)
+			mockPluginAPI.EXPECT().KVGet(bookmarks.GetLabelsKey(UserID)).Return(jsonLabels, nil).AnyTimes()
+			mockBookmarksKV(t, mockPluginAPI, getExecuteCommandViewBookmarks())
+			mockPluginAPI.EXPECT().KVGet(bookmarks.GetBookmarkKey(UserID, "newID")).Return(nil, nil).AnyTimes()
+			mockPluginAPI.EXPECT().KVSet(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			mockPluginAPI.EXPECT().KVCompareAndSet(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).AnyTimes()
+
+			testCommand := Command{
+				Args: &model.CommandArgs{
+					UserId:  UserID,
+					Command: tt.command},
+				API: mockPluginAPI,
+			}
+
+			message := testCommand.Handle()
+			actual := strings.TrimSpace(message)
+			assert.True(t, strings.HasPrefix(actual, tt.expectedMsgPrefix), "Expected returned message to start with: \n%s\nActual:\n%s", tt.expectedMsgPrefix, actual)
+			for i := range tt.expectedContains {
+				assert.Contains(t, actual, tt.expectedContains[i])
+			}
+		})
+	}
+}
@@ -53,10 +53,20 @@ func (c *Command) executeCommandLabel() string {
 	switch action {
 	case "add":
 		handler = c.executeCommandLabelAdd()
+	case "alias":
+		handler = c.executeCommandLabelAlias()
+	case "color":
+		handler = c.executeCommandLabelColor()
+	case "describe":
+		handler = c.executeCommandLabelDescribe()
+	case "merge":
+		handler = c.executeCommandLabelMerge()
 	case "remove":
 		handler = c.executeCommandLabelRemove()
 	case "rename":
 		handler = c.executeCommandLabelRename()
+	case "unalias":
+		handler = c.executeCommandLabelUnalias()
 	case "view":
 		handler = c.executeCommandLabelView()
 	case "help":
@@ -89,6 +99,104 @@ func (c *Command) executeCommandLabelAdd() string {
 	return c.responsef(c.Args, fmt.Sprint(text))
 }
 
+func (c *Command) executeCommandLabelColor() string {
+	subCommand := strings.Fields(c.Args.Command)
+	if len(subCommand) < 4 {
+		return c.responsef(c.Args, "Please specify a label name %v", getHelp(labelCommandText))
+	}
+
+	labelName := subCommand[3]
+	color := ""
+	if len(subCommand) > 4 {
+		color = subCommand[4]
+	}
+
+	labels, err := bookmarks.NewLabelsWithUser(c.API, c.Args.UserId)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	if err = labels.SetColor(labelName, color); err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	label := labels.GetLabelByName(labelName)
+	if label.Color == "" {
+		return c.responsef(c.Args, "Removed the color of label %s", label.GetChip())
+	}
+	return c.responsef(c.Args, "Set the color of label %s to `%s`", label.GetChip(), label.Color)
+}
+
+func (c *Command) executeCommandLabelDescribe() string {
+	subCommand := strings.Fields(c.Args.Command)
+	if len(subCommand) < 4 {
+		return c.responsef(c.Args, "Please specify a label name %v", getHelp(labelCommandText))
+	}
+
+	labelName := subCommand[3]
+	description := getTextAfterFields(c.Args.Command, 4)
+
+	labels, err := bookmarks.NewLabelsWithUser(c.API, c.Args.UserId)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	if err = labels.SetDescription(labelName, description); err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	label := labels.GetLabelByName(labelName)
+	if description == "" {
+		return c.responsef(c.Args, "Removed the description of label %s", label.GetChip())
+	}
+	return c.responsef(c.Args, "Set the description of label %s: %s", label.GetChip(), description)
+}
+
+// executeCommandLabelAlias adds another name a label is found by
+func (c *Command) executeCommandLabelAlias() string {
+	subCommand := strings.Fields(c.Args.Command)
+	if len(subCommand) < 5 {
+		return c.responsef(c.Args, "Please specify a label name and an alias%v", getHelp(labelCommandText))
+	}
+
+	labelName := subCommand[3]
+	alias := subCommand[4]
+
+	labels, err := bookmarks.NewLabelsWithUser(c.API, c.Args.UserId)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	if err = labels.AddAlias(labelName, alias); err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	label := labels.GetLabelByName(labelName)
+	return c.responsef(c.Args, "Added alias `%s` to label %s", alias, label.GetChip())
+}
+
+// executeCommandLabelUnalias removes an alias of a label
+func (c *Command) executeCommandLabelUnalias() string {
+	subCommand := strings.Fields(c.Args.Command)
+	if len(subCommand) < 4 {
+		return c.responsef(c.Args, "Please specify an alias%v", getHelp(labelCommandText))
+	}
+
+	alias := subCommand[3]
+
+	labels, err := bookmarks.NewLabelsWithUser(c.API, c.Args.UserId)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	label, err := labels.RemoveAlias(alias)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	return c.responsef(c.Args, "Removed alias `%s` from label %s", alias, label.GetChip())
+}
+
 func (c *Command) executeCommandLabelRename() string {
 	subCommand := strings.Fields(c.Args.Command)
 	if len(subCommand) < 5 {
@@ -103,26 +211,42 @@ func (c *Command) executeCommandLabelRename() string {
 		return c.responsef(c.Args, err.Error())
 	}
 
-	lfrom := labels.GetLabelByName(from)
-	if lfrom == nil {
-		return c.responsef(c.Args, fmt.Sprintf("Label `%v` does not exist", from))
+	if err = labels.RenameLabel(from, to); err != nil {
+		return c.responsef(c.Args, err.Error())
 	}
 
-	// if the "to" label already exists, alert the user with options
-	lto := labels.GetLabelByName(to)
-	if lto != nil {
-		return c.responsef(c.Args, fmt.Sprintf("Cannot rename Label `%v` to `%v`. Label already exists. Please choose a different label name", from, to))
+	text := fmt.Sprintf("Renamed label from `%v` to `%v`", from, to)
+
+	return c.responsef(c.Args, fmt.Sprint(text))
+}
+
+// executeCommandLabelMerge replaces labels with another label on every
+// bookmark and removes them
+func (c *Command) executeCommandLabelMerge() string {
+	subCommand := strings.Fields(c.Args.Command)
+	if len(subCommand) < 5 {
+		return c.responsef(c.Args, "Please specify the labels to merge and the label to merge them into%v", getHelp(labelCommandText))
 	}
 
-	lfrom.Name = to
-	labels.ByID[lfrom.ID] = lfrom
-	if err := labels.StoreLabels(); err != nil {
-		return c.responsef(c.Args, "failed to add label")
+	sources := subCommand[3 : len(subCommand)-1]
+	dest := subCommand[len(subCommand)-1]
+
+	labels, err := bookmarks.NewLabelsWithUser(c.API, c.Args.UserId)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+	bmarks, err := bookmarks.NewBookmarksWithUser(c.API, c.Args.UserId)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
 	}
 
-	text := fmt.Sprintf("Renamed label from `%v` to `%v`", from, to)
+	changed, err := bookmarks.MergeLabels(bmarks, labels, sources, dest)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
 
-	return c.responsef(c.Args, fmt.Sprint(text))
+	return c.responsef(c.Args, "Merged labels%s into `%v`. Updated %d bookmarks",
+		bookmarks.GetCodeBlockedLabels(sources), dest, changed)
 }
 
 // executeCommandLabelRemove removes a given bookmark from the store
@@ -146,6 +270,9 @@ func (c *Command) executeCommandLabelRemove() string {
 	if err != nil {
 		return c.responsef(c.Args, err.Error())
 	}
+	if len(labels.GetDescendants(labelName)) != 0 {
+		return c.responsef(c.Args, "Label `%s` has nested labels. Remove them first, or rename the label to move them", labelName)
+	}
 	bmarks, err := bookmarks.NewBookmarksWithUser(c.API, c.Args.UserId)
 	if err != nil {
 		return c.responsef(c.Args, err.Error())
@@ -158,12 +285,12 @@ func (c *Command) executeCommandLabelRemove() string {
 
 	if bmarks != nil {
 		// check to see if any bookmarks currently have the label
-		bmarks, err = bmarks.GetBookmarksWithLabelID(labelID)
+		bmarksWithLabel, err := bmarks.GetBookmarksWithLabelID(labelID)
 		if err != nil {
 			return c.responsef(c.Args, err.Error())
 		}
 
-		numBmarksWithLabel := len(bmarks.ByID)
+		numBmarksWithLabel := len(bmarksWithLabel.ByID)
 		if numBmarksWithLabel != 0 && !options.force {
 			return c.responsef(
 				c.Args,
@@ -173,7 +300,7 @@ func (c *Command) executeCommandLabelRemove() string {
 		}
 
 		// delete label from bookmarks
-		for _, bmark := range bmarks.ByID {
+		for _, bmark := range bmarksWithLabel.ByID {
 			err = bmarks.DeleteLabel(bmark.PostID, labelID)
 			if err != nil {
 				return c.responsef(c.Args, err.Error())
@@ -208,9 +335,12 @@ func (c *Command) executeCommandLabelView() string {
 	}
 
 	text := "#### Labels List\n"
-	for _, label := range labels.ByID {
-		v := fmt.Sprintf("`%s`\n", label.Name)
-		text += v
+	text += labels.GetTreeText()
+
+	if duplicates := labels.GetDuplicates(); len(duplicates) != 0 {
+		text += "\n#### Duplicate Labels\n"
+		text += "These labels differ only in case\n"
+		text += bookmarks.GetDuplicatesText(duplicates)
 	}
 
 	return c.responsef(c.Args, fmt.Sprint(text))
@@ -86,6 +86,84 @@ func TestExecuteCommandLabel(t *testing.T) {
 			expectedContains:  nil,
 		},
 
+		// COLOR
+		"COLOR User does not provide label name": {
+			command:          "/bookmarks label color",
+			labels:           getExecuteCommandTestLabels(),
+			expectedContains: []string{"Please specify a label name"},
+		},
+		"COLOR User sets the color of a label that doesn't exist": {
+			command:           "/bookmarks label color labeldoesnotexist #1e90ff",
+			labels:            getExecuteCommandTestLabels(),
+			expectedMsgPrefix: "Label `labeldoesnotexist` does not exist",
+		},
+		"COLOR User provides an invalid color": {
+			command:           "/bookmarks label color label1 blue",
+			labels:            getExecuteCommandTestLabels(),
+			expectedMsgPrefix: "Color `blue` is not a hex color like #1e90ff",
+		},
+		"COLOR User successfully sets the color of a label": {
+			command:           "/bookmarks label color label1 #1E90FF",
+			labels:            getExecuteCommandTestLabels(),
+			expectedMsgPrefix: "Set the color of label 🔵 `label1` to `#1e90ff`",
+		},
+		"COLOR User successfully removes the color of a label": {
+			command:           "/bookmarks label color label1",
+			labels:            getExecuteCommandTestLabels(),
+			expectedMsgPrefix: "Removed the color of label `label1`",
+		},
+
+		// DESCRIBE
+		"DESCRIBE User does not provide label name": {
+			command:          "/bookmarks label describe",
+			labels:           getExecuteCommandTestLabels(),
+			expectedContains: []string{"Please specify a label name"},
+		},
+		"DESCRIBE User describes a label that doesn't exist": {
+			command:           "/bookmarks label describe labeldoesnotexist a description",
+			labels:            getExecuteCommandTestLabels(),
+			expectedMsgPrefix: "Label `labeldoesnotexist` does not exist",
+		},
+		"DESCRIBE User successfully describes a label": {
+			command:           "/bookmarks label describe label1 Incidents of the  payments team",
+			labels:            getExecuteCommandTestLabels(),
+			expectedMsgPrefix: "Set the description of label `label1`: Incidents of the  payments team",
+		},
+		"DESCRIBE User successfully removes the description of a label": {
+			command:           "/bookmarks label describe label1",
+			labels:            getExecuteCommandTestLabels(),
+			expectedMsgPrefix: "Removed the description of label `label1`",
+		},
+
+		// MERGE
+		"MERGE User provides only one label": {
+			command:           "/bookmarks label merge label1",
+			labels:            getExecuteCommandTestLabels(),
+			expectedMsgPrefix: "Please specify the labels to merge and the label to merge them into",
+		},
+		"MERGE User merges a label that doesn't exist": {
+			command:           "/bookmarks label merge labeldoesnotexist label1",
+			labels:            getExecuteCommandTestLabels(),
+			expectedMsgPrefix: "Label: `labeldoesnotexist` does not exist",
+		},
+		"MERGE User merges a label into itself": {
+			command:           "/bookmarks label merge label1 label2 label1",
+			labels:            getExecuteCommandTestLabels(),
+			expectedMsgPrefix: "Cannot merge label `label1` into itself",
+		},
+		"MERGE User successfully merges labels": {
+			command:           "/bookmarks label merge label2 label1 label8",
+			labels:            getExecuteCommandTestLabels(),
+			bookmarks:         getExecuteCommandTestBookmarks(),
+			expectedMsgPrefix: "Merged labels `label1` `label2` into `label8`. Updated 2 bookmarks",
+		},
+		"MERGE User successfully merges labels into a new label": {
+			command:           "/bookmarks label merge label1 label2 database",
+			labels:            getExecuteCommandTestLabels(),
+			bookmarks:         getExecuteCommandTestBookmarks(),
+			expectedMsgPrefix: "Merged labels `label1` `label2` into `database`. Updated 2 bookmarks",
+		},
+
 		// REMOVE - user does not have any saved labels
 		"REMOVE User does not provide label name": {
 			command:           "/bookmarks label remove",
@@ -143,19 +221,94 @@ func TestExecuteCommandLabel(t *testing.T) {
 			expectedMsgPrefix: "",
 			expectedContains:  []string{"#### Labels List", "label1", "label2"},
 		},
+		"VIEW Labels are sorted with their colors and descriptions": {
+			command: "/bookmarks label view",
+			labels: &bookmarks.Labels{ByID: map[string]*bookmarks.Label{
+				"UUID1": {Name: "prod", Color: "#e53935", Description: "Production incidents"},
+				"UUID2": {Name: "docs"},
+			}},
+			expectedMsgPrefix: "#### Labels List\n- `docs`\n- 🔴 `prod` - Production incidents",
+		},
+		"VIEW Nested labels are listed as a tree": {
+			command: "/bookmarks label view",
+			labels: &bookmarks.Labels{ByID: map[string]*bookmarks.Label{
+				"UUID1": {Name: "projects"},
+				"UUID2": {Name: "projects/apollo", Color: "#1e90ff"},
+				"UUID3": {Name: "projects/apollo/backend", Description: "API and workers"},
+				"UUID4": {Name: "projects-old"},
+				"UUID5": {Name: "legacy/nested"},
+			}},
+			expectedMsgPrefix: "#### Labels List\n- `legacy`\n  - `nested`\n- `projects`\n  - 🔵 `apollo`\n    - `backend` - API and workers\n- `projects-old`",
+		},
+		"REMOVE User tries to remove a label with nested labels": {
+			command: "/bookmarks label remove projects",
+			labels: &bookmarks.Labels{ByID: map[string]*bookmarks.Label{
+				"UUID1": {Name: "projects"},
+				"UUID2": {Name: "projects/apollo"},
+			}},
+			expectedMsgPrefix: "Label `projects` has nested labels. Remove them first, or rename the label to move them",
+		},
+		"ADD User tries creating a label that differs only in case": {
+			command:           "/bookmarks label add LABEL1",
+			labels:            getExecuteCommandTestLabels(),
+			expectedMsgPrefix: "Label with name `label1` already exists",
+		},
+		"ALIAS User provides no alias": {
+			command:           "/bookmarks label alias label1",
+			labels:            getExecuteCommandTestLabels(),
+			expectedMsgPrefix: "Please specify a label name and an alias",
+		},
+		"ALIAS User successfully adds an alias": {
+			command:           "/bookmarks label alias Label1 l1",
+			labels:            getExecuteCommandTestLabels(),
+			expectedMsgPrefix: "Added alias `l1` to label `label1`",
+		},
+		"ALIAS User adds an alias that is a label name": {
+			command:           "/bookmarks label alias label1 LABEL2",
+			labels:            getExecuteCommandTestLabels(),
+			expectedMsgPrefix: "Alias `LABEL2` is already the name or an alias of label `label2`",
+		},
+		"UNALIAS User successfully removes an alias": {
+			command: "/bookmarks label unalias DB",
+			labels: &bookmarks.Labels{ByID: map[string]*bookmarks.Label{
+				"UUID1": {Name: "database", Aliases: []string{"db"}},
+			}},
+			expectedMsgPrefix: "Removed alias `DB` from label `database`",
+		},
+		"UNALIAS User removes an alias that doesn't exist": {
+			command:           "/bookmarks label unalias db",
+			labels:            getExecuteCommandTestLabels(),
+			expectedMsgPrefix: "Alias `db` does not exist",
+		},
+		"VIEW Aliases and duplicate labels are listed": {
+			command: "/bookmarks label view",
+			labels: &bookmarks.Labels{ByID: map[string]*bookmarks.Label{
+				"UUID1": {Name: "database", Aliases: []string{"pg", "db"}},
+				"UUID2": {Name: "Prod"},
+				"UUID3": {Name: "prod"},
+			}},
+			expectedMsgPrefix: "#### Labels List\n- `database` (aliases: `db` `pg`)\n- `Prod`\n- `prod`\n\n" +
+				"#### Duplicate Labels\nThese labels differ only in case\n" +
+				"* `Prod` `prod` - merge them with `/bookmarks label merge Prod prod`",
+		},
+		"ADD User tries creating a label with an empty nested name": {
+			command:           "/bookmarks label add projects//apollo",
+			labels:            getExecuteCommandTestLabels(),
+			expectedMsgPrefix: "Label `projects//apollo` is not a valid label name",
+		},
 	}
 	for name, tt := range tests {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 		mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
 
-		jsonBmarks, err := json.Marshal(tt.bookmarks)
-		mockPluginAPI.EXPECT().KVGet(bookmarks.GetBookmarksKey(UserID)).Return(jsonBmarks, nil).AnyTimes()
+		mockBookmarksKV(t, mockPluginAPI, tt.bookmarks)
 
 		jsonLabels, err := json.Marshal(tt.labels)
 		mockPluginAPI.EXPECT().KVGet(bookmarks.GetLabelsKey(UserID)).Return(jsonLabels, nil).AnyTimes()
 		// api.On("KVSet", mock.Anything, mock.Anything).Return(nil)
 		mockPluginAPI.EXPECT().KVSet(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+		mockPluginAPI.EXPECT().KVCompareAndSet(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).AnyTimes()
 
 		t.Run(name, func(t *testing.T) {
 			assert.Nil(t, err)
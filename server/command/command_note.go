@@ -0,0 +1,49 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/utils"
+)
+
+// executeCommandNote writes or removes the note of a bookmark
+func (c *Command) executeCommandNote() string {
+	subCommand := strings.Fields(c.Args.Command)
+
+	if len(subCommand) < 3 {
+		return c.responsef(c.Args, "Missing sub-command. You can try %v", getHelp(noteCommandText))
+	}
+	bmarkID := utils.GetPostIDFromLink(subCommand[2])
+
+	// the note is the rest of the command, including its line breaks
+	text := getTextAfterFields(c.Args.Command, 3)
+
+	bmarks, err := bookmarks.NewBookmarksWithUser(c.API, c.Args.UserId)
+	if err != nil {
+		return c.responsef(c.Args, "Unable to get bookmarks")
+	}
+
+	err = bmarks.SetNote(bmarkID, text)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	bmark, err := bmarks.GetBookmark(bmarkID)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+	labelNames, err := bmarks.GetBmarkLabelNames(bmark)
+	if err != nil {
+		return c.responsef(c.Args, "Unable to get labels for bookmark, %s", err)
+	}
+	bmarkText, err := bmarks.GetBmarkTextOneLine(bmark, labelNames)
+	if err != nil {
+		return c.responsef(c.Args, "Unable to get bookmarks list bookmark")
+	}
+
+	if text == "" {
+		return c.responsef(c.Args, "Removed the note of bookmark: %s", bmarkText)
+	}
+	return c.responsef(c.Args, "Saved the note of bookmark: %s", bmarkText)
+}
@@ -0,0 +1,122 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteCommandNote(t *testing.T) {
+	tests := map[string]struct {
+		command           string
+		expectedMsgPrefix string
+		expectedContains  []string
+		expectedNote      string
+		stored            bool
+	}{
+		"User doesn't provide an ID": {
+			command:           "/bookmarks note",
+			expectedMsgPrefix: "Missing sub-command",
+			expectedContains:  []string{"bookmarks note"},
+		},
+		"Bookmark doesn't exist": {
+			command:           fmt.Sprintf("/bookmarks note %v a note", PostIDDoesNotExist),
+			expectedMsgPrefix: fmt.Sprintf("Bookmark `%v` does not exist", PostIDDoesNotExist),
+		},
+		"Note saved": {
+			command:           fmt.Sprintf("/bookmarks note %v paged  the on-call", p2ID),
+			expectedMsgPrefix: "Saved the note of bookmark: [:link:](https://myhost.com/_redirect/pl/ID2) `label1` `label2` **_Title2",
+			expectedNote:      "paged  the on-call",
+			stored:            true,
+		},
+		"Multi-line note saved": {
+			command:           fmt.Sprintf("/bookmarks note %v root cause:\n* disk full\n* no alert", p2ID),
+			expectedMsgPrefix: "Saved the note of bookmark:",
+			expectedNote:      "root cause:\n* disk full\n* no alert",
+			stored:            true,
+		},
+		"Note removed": {
+			command:           fmt.Sprintf("/bookmarks note %v", p2ID),
+			expectedMsgPrefix: "Removed the note of bookmark: [:link:](https://myhost.com/_redirect/pl/ID2)",
+			stored:            true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+			mockGetPostsByIds(mockPluginAPI)
+			mockChannelMember(mockPluginAPI)
+			mockPluginAPI.EXPECT().GetPost(p2ID).Return(&model.Post{Message: "this is the post.Message"}, nil).AnyTimes()
+
+			config := &model.Config{
+				ServiceSettings: model.ServiceSettings{
+					SiteURL: model.NewString("https://myhost.com"),
+				},
+			}
+			mockPluginAPI.EXPECT().GetConfig().Return(config).AnyTimes()
+
+			bmarks := getExecuteCommandTestBookmarks()
+			bmarks.ByID[p2ID].Note = "old note"
+			jsonLabels, err := json.Marshal(getExecuteCommandTestLabels())
+			assert.Nil(t, err)
+			mockPluginAPI.EXPECT().KVGet(bookmarks.GetLabelsKey(UserID)).Return(jsonLabels, nil).AnyTimes()
+			mockBookmarksKV(t, mockPluginAPI, bmarks)
+
+			// bookmarks loaded from an older schema are stored again as well
+			var stored []byte
+			if tt.stored {
+				mockPluginAPI.EXPECT().KVCompareAndSet(bookmarks.GetBookmarkKey(UserID, p2ID), gomock.Any(), gomock.Any()).DoAndReturn(
+					func(key string, oldValue, newValue []byte) (bool, error) {
+						stored = newValue
+						return true, nil
+					})
+			}
+			mockPluginAPI.EXPECT().KVCompareAndSet(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).AnyTimes()
+
+			testCommand := Command{
+				Args: &model.CommandArgs{
+					UserId:  UserID,
+					Command: tt.command},
+				API: mockPluginAPI,
+			}
+
+			actual := strings.TrimSpace(testCommand.Handle())
+			assert.True(t, strings.HasPrefix(actual, tt.expectedMsgPrefix), "Expected returned message to start with: \n%s\nActual:\n%s", tt.expectedMsgPrefix, actual)
+			for _, s := range tt.expectedContains {
+				assert.Contains(t, actual, s)
+			}
+
+			if tt.stored {
+				assert.Equal(t, tt.expectedNote, getStoredBookmark(t, stored).Note)
+			}
+		})
+	}
+}
+
+func TestGetTextAfterFields(t *testing.T) {
+	tests := map[string]struct {
+		command  string
+		n        int
+		expected string
+	}{
+		"no text":                {command: "/bookmarks note ID1", n: 3, expected: ""},
+		"trailing spaces":        {command: "/bookmarks note ID1   ", n: 3, expected: ""},
+		"spaces kept":            {command: "/bookmarks note  ID1  a  b ", n: 3, expected: "a  b"},
+		"line breaks kept":       {command: "/bookmarks note ID1 a\n\nb", n: 3, expected: "a\n\nb"},
+		"line break after field": {command: "/bookmarks note ID1\na", n: 3, expected: "a"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, getTextAfterFields(tt.command, tt.n))
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package command
+
+import (
+	"strings"
+	"time"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/utils"
+)
+
+// executeCommandRemind sets, removes or shows the reminder of a bookmark
+func (c *Command) executeCommandRemind() string {
+	subCommand := strings.Fields(c.Args.Command)
+
+	if len(subCommand) < 3 {
+		return c.responsef(c.Args, "Missing sub-command. You can try %v", getHelp(remindCommandText))
+	}
+	bmarkID := utils.GetPostIDFromLink(subCommand[2])
+	when := getTextAfterFields(c.Args.Command, 3)
+
+	bmarks, err := bookmarks.NewBookmarksWithUser(c.API, c.Args.UserId)
+	if err != nil {
+		return c.responsef(c.Args, "Unable to get bookmarks")
+	}
+	bmark, err := bmarks.GetBookmark(bmarkID)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	location, err := bookmarks.GetUserLocation(c.API, c.Args.UserId)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	var remindAt int64
+	switch when {
+	case "":
+		if !bmark.HasReminder() {
+			return c.responsef(c.Args, "Bookmark `%s` has no reminder. You can try %v", bmarkID, getHelp(remindCommandText))
+		}
+		return c.responsef(c.Args, "You will be reminded of bookmark `%s` on %s", bmarkID, bookmarks.FormatRemindAt(bmark.RemindAt, location))
+	case "off":
+	default:
+		t, err := bookmarks.ParseRemindAt(when, time.Now().In(location))
+		if err != nil {
+			return c.responsef(c.Args, err.Error())
+		}
+		remindAt = utils.GetMillis(t)
+	}
+
+	if err = bmarks.SetReminder(bmarkID, remindAt); err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	labelNames, err := bmarks.GetBmarkLabelNames(bmark)
+	if err != nil {
+		return c.responsef(c.Args, "Unable to get labels for bookmark, %s", err)
+	}
+	bmarkText, err := bmarks.GetBmarkTextOneLine(bmark, labelNames)
+	if err != nil {
+		return c.responsef(c.Args, "Unable to get bookmarks list bookmark")
+	}
+
+	if remindAt == 0 {
+		return c.responsef(c.Args, "Removed the reminder of bookmark: %s", bmarkText)
+	}
+	return c.responsef(c.Args, "The Bookmarks bot will remind you on %s of bookmark: %s", bookmarks.FormatRemindAt(remindAt, location), bmarkText)
+}
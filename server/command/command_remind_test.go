@@ -0,0 +1,138 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/utils"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteCommandRemind(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.Nil(t, err)
+
+	tests := map[string]struct {
+		command           string
+		remindAt          int64
+		expectedMsgPrefix string
+		expectedContains  []string
+		expectedRemindAt  int64
+		stored            bool
+	}{
+		"User doesn't provide an ID": {
+			command:           "/bookmarks remind",
+			expectedMsgPrefix: "Missing sub-command",
+			expectedContains:  []string{"bookmarks remind"},
+		},
+		"Bookmark doesn't exist": {
+			command:           fmt.Sprintf("/bookmarks remind %v in 2h", PostIDDoesNotExist),
+			expectedMsgPrefix: fmt.Sprintf("Bookmark `%v` does not exist", PostIDDoesNotExist),
+		},
+		"Bookmark has no reminder": {
+			command:           fmt.Sprintf("/bookmarks remind %v", p2ID),
+			expectedMsgPrefix: fmt.Sprintf("Bookmark `%v` has no reminder", p2ID),
+		},
+		"Reminder is shown in the timezone of the user": {
+			command:           fmt.Sprintf("/bookmarks remind %v", p2ID),
+			remindAt:          utils.GetMillis(time.Date(2027, time.January, 5, 14, 0, 0, 0, berlin)),
+			expectedMsgPrefix: fmt.Sprintf("You will be reminded of bookmark `%v` on Tue Jan 5 2027 at 2:00 PM CET", p2ID),
+		},
+		"Invalid time": {
+			command:           fmt.Sprintf("/bookmarks remind %v someday", p2ID),
+			expectedMsgPrefix: "`someday` is not a reminder time",
+		},
+		"Reminder set in the timezone of the user": {
+			command:           fmt.Sprintf("/bookmarks remind %v 2027-01-05 2pm", p2ID),
+			expectedMsgPrefix: "The Bookmarks bot will remind you on Tue Jan 5 2027 at 2:00 PM CET of bookmark: [:link:](https://myhost.com/_redirect/pl/ID2) `label1` `label2` **_Title2",
+			expectedRemindAt:  utils.GetMillis(time.Date(2027, time.January, 5, 14, 0, 0, 0, berlin)),
+			stored:            true,
+		},
+		"Reminder removed": {
+			command:           fmt.Sprintf("/bookmarks remind %v off", p2ID),
+			remindAt:          utils.GetMillis(time.Date(2027, time.January, 5, 14, 0, 0, 0, berlin)),
+			expectedMsgPrefix: "Removed the reminder of bookmark: [:link:](https://myhost.com/_redirect/pl/ID2)",
+			stored:            true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+			mockGetPostsByIds(mockPluginAPI)
+			mockChannelMember(mockPluginAPI)
+			mockPluginAPI.EXPECT().GetPost(p2ID).Return(&model.Post{Message: "this is the post.Message"}, nil).AnyTimes()
+			mockPluginAPI.EXPECT().GetUser(UserID).Return(&model.User{
+				Id: UserID,
+				Timezone: model.StringMap{
+					"useAutomaticTimezone": "false",
+					"manualTimezone":       "Europe/Berlin",
+				},
+			}, nil).AnyTimes()
+
+			config := &model.Config{
+				ServiceSettings: model.ServiceSettings{
+					SiteURL: model.NewString("https://myhost.com"),
+				},
+			}
+			mockPluginAPI.EXPECT().GetConfig().Return(config).AnyTimes()
+
+			bmarks := getExecuteCommandTestBookmarks()
+			bmarks.ByID[p2ID].RemindAt = tt.remindAt
+			jsonLabels, err := json.Marshal(getExecuteCommandTestLabels())
+			assert.Nil(t, err)
+			mockPluginAPI.EXPECT().KVGet(bookmarks.GetLabelsKey(UserID)).Return(jsonLabels, nil).AnyTimes()
+			mockBookmarksKV(t, mockPluginAPI, bmarks)
+
+			// bookmarks loaded from an older schema are stored again as well
+			var stored, storedReminders []byte
+			if tt.stored {
+				mockPluginAPI.EXPECT().KVCompareAndSet(bookmarks.GetBookmarkKey(UserID, p2ID), gomock.Any(), gomock.Any()).DoAndReturn(
+					func(key string, oldValue, newValue []byte) (bool, error) {
+						stored = newValue
+						return true, nil
+					})
+				remindersKey := keyPrefix(bookmarks.StoreRemindersKey + "_")
+				mockPluginAPI.EXPECT().KVGet(remindersKey).Return(nil, nil).AnyTimes()
+				mockPluginAPI.EXPECT().KVSetWithOptions(remindersKey, gomock.Any(), gomock.Any()).DoAndReturn(
+					func(key string, value []byte, options model.PluginKVSetOptions) (bool, error) {
+						storedReminders = value
+						return true, nil
+					}).AnyTimes()
+			}
+			mockPluginAPI.EXPECT().KVCompareAndSet(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).AnyTimes()
+
+			testCommand := Command{
+				Args: &model.CommandArgs{
+					UserId:  UserID,
+					Command: tt.command},
+				API: mockPluginAPI,
+			}
+
+			actual := strings.TrimSpace(testCommand.Handle())
+			assert.True(t, strings.HasPrefix(actual, tt.expectedMsgPrefix), "Expected returned message to start with: \n%s\nActual:\n%s", tt.expectedMsgPrefix, actual)
+			for _, s := range tt.expectedContains {
+				assert.Contains(t, actual, s)
+			}
+
+			if tt.stored {
+				assert.Equal(t, tt.expectedRemindAt, getStoredBookmark(t, stored).RemindAt)
+
+				// a removed reminder leaves no index behind
+				if tt.expectedRemindAt == 0 {
+					assert.Nil(t, storedReminders)
+				} else {
+					assert.Contains(t, string(storedReminders), fmt.Sprintf(`"remind_at":%d`, tt.expectedRemindAt))
+				}
+			}
+		})
+	}
+}
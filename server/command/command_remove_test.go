@@ -55,14 +55,14 @@ func TestExecuteCommandRemove(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 		mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+		mockGetPostsByIds(mockPluginAPI)
+		mockChannelMember(mockPluginAPI)
 
 		mockPluginAPI.EXPECT().GetPost(p1ID).Return(&model.Post{Message: "this is the post.Message"}, nil).AnyTimes()
 		mockPluginAPI.EXPECT().GetPost(p2ID).Return(&model.Post{Message: "this is the post.Message"}, nil).AnyTimes()
 		mockPluginAPI.EXPECT().GetPost(p3ID).Return(&model.Post{Message: "this is the post.Message"}, nil).AnyTimes()
 
 		bmarks := getExecuteCommandTestBookmarks()
-		jsonBmarks, err := json.Marshal(bmarks)
-		assert.Nil(t, err)
 
 		labels := getExecuteCommandTestLabels()
 		jsonLabels, err := json.Marshal(labels)
@@ -76,9 +76,11 @@ func TestExecuteCommandRemove(t *testing.T) {
 		mockPluginAPI.EXPECT().GetConfig().Return(config).AnyTimes()
 
 		mockPluginAPI.EXPECT().KVSet(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+		mockPluginAPI.EXPECT().KVCompareAndSet(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).AnyTimes()
+		mockPluginAPI.EXPECT().KVSetWithOptions(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).AnyTimes()
 
 		mockPluginAPI.EXPECT().KVGet(bookmarks.GetLabelsKey(UserID)).Return(jsonLabels, nil).AnyTimes()
-		mockPluginAPI.EXPECT().KVGet(bookmarks.GetBookmarksKey(UserID)).Return(jsonBmarks, nil).AnyTimes()
+		mockBookmarksKV(t, mockPluginAPI, bmarks)
 
 		t.Run(name, func(t *testing.T) {
 			assert.Nil(t, err)
@@ -0,0 +1,57 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
+	"github.com/spf13/pflag"
+)
+
+func getSearchFlagSet() *pflag.FlagSet {
+	flagSet := pflag.NewFlagSet("search bookmarks", pflag.ContinueOnError)
+	flagSet.Int(flagLimit, bookmarks.DefaultPageSize, "number of search results per page")
+	flagSet.Int(flagPage, 1, "page of search results to view")
+
+	return flagSet
+}
+
+// executeCommandSearch shows all bookmarks matching a query in an ephemeral post
+func (c *Command) executeCommandSearch() string {
+	subCommand := strings.Fields(c.Args.Command)
+	if len(subCommand) < 3 {
+		return c.responsef(c.Args, "Missing search query. You can try %v", getHelp(searchCommandText))
+	}
+
+	searchFlagSet := getSearchFlagSet()
+	if err := searchFlagSet.Parse(subCommand[2:]); err != nil {
+		return c.responsef(c.Args, "Unable to parse options, %s", err)
+	}
+
+	options := bookmarks.NewListOptions()
+	var err error
+	options.Limit, err = searchFlagSet.GetInt(flagLimit)
+	if err != nil {
+		return c.responsef(c.Args, "Unable to parse options, %s", err)
+	}
+	options.Page, err = searchFlagSet.GetInt(flagPage)
+	if err != nil {
+		return c.responsef(c.Args, "Unable to parse options, %s", err)
+	}
+
+	query := strings.Join(searchFlagSet.Args(), " ")
+	if query == "" {
+		return c.responsef(c.Args, "Missing search query. You can try %v", getHelp(searchCommandText))
+	}
+
+	bmarks, err := bookmarks.NewBookmarksWithUser(c.API, c.Args.UserId)
+	if err != nil {
+		return c.responsef(c.Args, "Unable to retrieve bookmarks for user %s", c.Args.UserId)
+	}
+
+	text, err := bmarks.GetBmarksSearchEphemeralText(query, options)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	return c.responsef(c.Args, text)
+}
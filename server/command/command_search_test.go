@@ -0,0 +1,153 @@
+package command
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/utils"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteCommandSearch(t *testing.T) {
+	p1IDmodel := &model.Post{
+		Message:  "the deploy runbook lives in the wiki",
+		CreateAt: model.GetMillis(),
+	}
+	p2IDmodel := &model.Post{
+		Message:  "lunch order for friday",
+		CreateAt: model.GetMillis() + 5,
+	}
+	p3IDmodel := &model.Post{
+		Message:  "rollback steps if the deploy fails",
+		CreateAt: model.GetMillis() + 2,
+	}
+	p4IDmodel := &model.Post{
+		Message:  "Deploy Runbook v2 draft, deploy with care",
+		CreateAt: model.GetMillis() + 3,
+	}
+
+	tests := map[string]struct {
+		command             string
+		bmarks              *bookmarks.Bookmarks
+		expectedMsgPrefix   string
+		expectedContains    []string
+		expectedNotContains []string
+	}{
+		"User does not provide a query": {
+			command:           "/bookmarks search",
+			expectedMsgPrefix: "Missing search query",
+			expectedContains:  []string{"bookmarks search"},
+		},
+		"User has no bookmarks": {
+			command:           "/bookmarks search deploy",
+			bmarks:            &bookmarks.Bookmarks{},
+			expectedMsgPrefix: "You do not have any saved bookmarks",
+		},
+		"No bookmarks match": {
+			command:           "/bookmarks search kubernetes",
+			expectedMsgPrefix: "No bookmarks found matching: `kubernetes`",
+		},
+		"Match bookmark title": {
+			command:             "/bookmarks search updated once",
+			expectedMsgPrefix:   strings.TrimSpace(utils.GetLegendText()),
+			expectedContains:    []string{"#### Search Results", "ID3"},
+			expectedNotContains: []string{"ID1", "ID2", "ID4"},
+		},
+		"Match post message of bookmark without a title": {
+			command:             "/bookmarks search runbook v2",
+			expectedMsgPrefix:   strings.TrimSpace(utils.GetLegendText()),
+			expectedContains:    []string{"[:link:](https://myhost.com/_redirect/pl/ID4) **`TFP`** Deploy Runbook v2 draft, deploy with care"},
+			expectedNotContains: []string{"ID1", "ID2", "ID3"},
+		},
+		"Match quoted phrase": {
+			command:             `/bookmarks search "deploy runbook"`,
+			expectedMsgPrefix:   strings.TrimSpace(utils.GetLegendText()),
+			expectedContains:    []string{"ID1", "ID4"},
+			expectedNotContains: []string{"ID2", "ID3"},
+		},
+		"Results ranked by relevance": {
+			command:           "/bookmarks search deploy",
+			expectedMsgPrefix: strings.TrimSpace(utils.GetLegendText()),
+			expectedContains: []string{strings.Join([]string{
+				"[:link:](https://myhost.com/_redirect/pl/ID4) **`TFP`** Deploy Runbook v2 draft, deploy with care",
+				"[:link:](https://myhost.com/_redirect/pl/ID1) `label1` `label2` **_Title1 - New Bookmark - times are zero_**",
+				"[:link:](https://myhost.com/_redirect/pl/ID3) `label3` **_Title3 - bookmarks already updated once_**",
+			}, "\n")},
+			expectedNotContains: []string{"ID2"},
+		},
+		"Page of results": {
+			command:           "/bookmarks search deploy --limit 1 --page 2",
+			expectedMsgPrefix: strings.TrimSpace(utils.GetLegendText()),
+			expectedContains: []string{
+				"#### Search Results\n[:link:](https://myhost.com/_redirect/pl/ID1)",
+				"_Showing bookmarks 2-2 of 3._ _View the next page with_ `--page 3`",
+			},
+			expectedNotContains: []string{"ID2", "ID3", "ID4"},
+		},
+		"Page after the last page": {
+			command:           "/bookmarks search deploy --limit 2 --page 3",
+			expectedMsgPrefix: "Page 3 is empty. The last page of search results is 2",
+		},
+		"Invalid limit": {
+			command:           "/bookmarks search deploy --limit 0",
+			expectedMsgPrefix: "limit must be at least 1",
+		},
+	}
+	for name, tt := range tests {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+		mockGetPostsByIds(mockPluginAPI)
+		mockChannelMember(mockPluginAPI)
+
+		config := &model.Config{
+			ServiceSettings: model.ServiceSettings{
+				SiteURL: model.NewString("https://myhost.com"),
+			},
+		}
+		mockPluginAPI.EXPECT().GetConfig().Return(config).AnyTimes()
+		mockPluginAPI.EXPECT().GetPost(p1ID).Return(p1IDmodel, nil).AnyTimes()
+		mockPluginAPI.EXPECT().GetPost(p2ID).Return(p2IDmodel, nil).AnyTimes()
+		mockPluginAPI.EXPECT().GetPost(p3ID).Return(p3IDmodel, nil).AnyTimes()
+		mockPluginAPI.EXPECT().GetPost(p4ID).Return(p4IDmodel, nil).AnyTimes()
+
+		bmarks := tt.bmarks
+		if tt.bmarks == nil {
+			bmarks = getExecuteCommandViewBookmarks()
+		}
+
+		labels := getExecuteCommandViewLabels()
+		jsonLabels, err := json.Marshal(labels)
+		assert.Nil(t, err)
+
+		mockPluginAPI.EXPECT().KVGet(bookmarks.GetLabelsKey(UserID)).Return(jsonLabels, nil).AnyTimes()
+		mockBookmarksKV(t, mockPluginAPI, bmarks)
+
+		t.Run(name, func(t *testing.T) {
+			assert.Nil(t, err)
+			testCommand := Command{
+				Args: &model.CommandArgs{
+					UserId:  UserID,
+					Command: tt.command},
+				API: mockPluginAPI,
+			}
+
+			message := testCommand.Handle()
+			actual := strings.TrimSpace(message)
+			assert.True(t, strings.HasPrefix(actual, tt.expectedMsgPrefix), "Expected returned message to start with: \n%s\nActual:\n%s", tt.expectedMsgPrefix, actual)
+
+			for i := range tt.expectedNotContains {
+				assert.NotContains(t, actual, tt.expectedNotContains[i])
+			}
+			for i := range tt.expectedContains {
+				assert.Contains(t, actual, tt.expectedContains[i])
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/utils"
+)
+
+// executeCommandArchive archives a bookmark, hiding it from the view command
+func (c *Command) executeCommandArchive() string {
+	return c.executeCommandStatus(bookmarks.StatusArchived, archiveCommandText, "Archived bookmark", "Bookmark is already archived")
+}
+
+// executeCommandDone marks a bookmark read. An archived bookmark is restored
+// as read
+func (c *Command) executeCommandDone() string {
+	return c.executeCommandStatus(bookmarks.StatusRead, doneCommandText, "Marked bookmark read", "Bookmark is already read")
+}
+
+// executeCommandStatus sets the status of the bookmark given in the command
+// and responds with the bookmark
+func (c *Command) executeCommandStatus(status, commandText, changedText, unchangedText string) string {
+	subCommand := strings.Fields(c.Args.Command)
+
+	if len(subCommand) != 3 {
+		return c.responsef(c.Args, "Please specify a bookmark. You can try %v", getHelp(commandText))
+	}
+	bmarkID := utils.GetPostIDFromLink(subCommand[2])
+
+	bmarks, err := bookmarks.NewBookmarksWithUser(c.API, c.Args.UserId)
+	if err != nil {
+		return c.responsef(c.Args, "Unable to get bookmarks")
+	}
+
+	changed, err := bmarks.SetStatus(bmarkID, status)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+
+	bmark, err := bmarks.GetBookmark(bmarkID)
+	if err != nil {
+		return c.responsef(c.Args, err.Error())
+	}
+	labelNames, err := bmarks.GetBmarkLabelNames(bmark)
+	if err != nil {
+		return c.responsef(c.Args, "Unable to get labels for bookmark, %s", err)
+	}
+	bmarkText, err := bmarks.GetBmarkTextOneLine(bmark, labelNames)
+	if err != nil {
+		return c.responsef(c.Args, "Unable to get bookmarks list bookmark")
+	}
+
+	if !changed {
+		return c.responsef(c.Args, "%s: %s", unchangedText, bmarkText)
+	}
+	return c.responsef(c.Args, "%s: %s", changedText, bmarkText)
+}
@@ -0,0 +1,118 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteCommandStatus(t *testing.T) {
+	tests := map[string]struct {
+		command           string
+		status            string
+		expectedMsgPrefix string
+		expectedContains  []string
+		expectedStatus    string
+	}{
+		"User doesn't provide an ID to archive": {
+			command:           "/bookmarks archive",
+			expectedMsgPrefix: "Please specify a bookmark",
+			expectedContains:  []string{"bookmarks archive <post_id>"},
+		},
+		"User doesn't provide an ID to mark read": {
+			command:           "/bookmarks done",
+			expectedMsgPrefix: "Please specify a bookmark",
+			expectedContains:  []string{"bookmarks done <post_id>"},
+		},
+		"Bookmark to archive doesn't exist": {
+			command:           fmt.Sprintf("/bookmarks archive %v", PostIDDoesNotExist),
+			expectedMsgPrefix: fmt.Sprintf("Bookmark `%v` does not exist", PostIDDoesNotExist),
+		},
+		"Bookmark archived": {
+			command:           fmt.Sprintf("/bookmarks archive %v", p2ID),
+			expectedMsgPrefix: "Archived bookmark: [:link:](https://myhost.com/_redirect/pl/ID2) **`ARCHIVED`** `label1` `label2` **_Title2",
+			expectedStatus:    bookmarks.StatusArchived,
+		},
+		"Bookmark already archived": {
+			command:           fmt.Sprintf("/bookmarks archive %v", p2ID),
+			status:            bookmarks.StatusArchived,
+			expectedMsgPrefix: "Bookmark is already archived: [:link:](https://myhost.com/_redirect/pl/ID2)",
+		},
+		"Bookmark marked read": {
+			command:           fmt.Sprintf("/bookmarks done %v", p2ID),
+			status:            bookmarks.StatusUnread,
+			expectedMsgPrefix: "Marked bookmark read: [:link:](https://myhost.com/_redirect/pl/ID2) `label1` `label2` **_Title2",
+			expectedStatus:    bookmarks.StatusRead,
+		},
+		"Archived bookmark restored as read": {
+			command:           fmt.Sprintf("/bookmarks done %v", p2ID),
+			status:            bookmarks.StatusArchived,
+			expectedMsgPrefix: "Marked bookmark read: [:link:](https://myhost.com/_redirect/pl/ID2) `label1`",
+			expectedStatus:    bookmarks.StatusRead,
+		},
+		"Bookmark already read": {
+			command:           fmt.Sprintf("/bookmarks done %v", p2ID),
+			status:            bookmarks.StatusRead,
+			expectedMsgPrefix: "Bookmark is already read: [:link:](https://myhost.com/_redirect/pl/ID2)",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+			mockGetPostsByIds(mockPluginAPI)
+			mockChannelMember(mockPluginAPI)
+			mockPluginAPI.EXPECT().GetPost(p2ID).Return(&model.Post{Message: "this is the post.Message"}, nil).AnyTimes()
+
+			config := &model.Config{
+				ServiceSettings: model.ServiceSettings{
+					SiteURL: model.NewString("https://myhost.com"),
+				},
+			}
+			mockPluginAPI.EXPECT().GetConfig().Return(config).AnyTimes()
+
+			bmarks := getExecuteCommandTestBookmarks()
+			bmarks.ByID[p2ID].Status = tt.status
+			jsonLabels, err := json.Marshal(getExecuteCommandTestLabels())
+			assert.Nil(t, err)
+			mockPluginAPI.EXPECT().KVGet(bookmarks.GetLabelsKey(UserID)).Return(jsonLabels, nil).AnyTimes()
+			mockBookmarksKV(t, mockPluginAPI, bmarks)
+
+			// bookmarks loaded from an older schema are stored again as well
+			var stored []byte
+			if tt.expectedStatus != "" {
+				mockPluginAPI.EXPECT().KVCompareAndSet(bookmarks.GetBookmarkKey(UserID, p2ID), gomock.Any(), gomock.Any()).DoAndReturn(
+					func(key string, oldValue, newValue []byte) (bool, error) {
+						stored = newValue
+						return true, nil
+					})
+			}
+			mockPluginAPI.EXPECT().KVCompareAndSet(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).AnyTimes()
+
+			testCommand := Command{
+				Args: &model.CommandArgs{
+					UserId:  UserID,
+					Command: tt.command},
+				API: mockPluginAPI,
+			}
+
+			actual := strings.TrimSpace(testCommand.Handle())
+			assert.True(t, strings.HasPrefix(actual, tt.expectedMsgPrefix), "Expected returned message to start with: \n%s\nActual:\n%s", tt.expectedMsgPrefix, actual)
+			for _, s := range tt.expectedContains {
+				assert.Contains(t, actual, s)
+			}
+
+			if tt.expectedStatus != "" {
+				assert.Equal(t, tt.expectedStatus, getStoredBookmark(t, stored).Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
+)
+
+// executeCommandSync turns the sync of the bookmarks of the user with their
+// flagged posts on or off
+func (c *Command) executeCommandSync() string {
+	subCommand := strings.Fields(c.Args.Command)
+	subCommand = subCommand[2:]
+
+	if len(subCommand) == 0 {
+		settings, err := bookmarks.NewSettingsWithUser(c.API, c.Args.UserId)
+		if err != nil {
+			return c.responsef(c.Args, err.Error())
+		}
+		if settings.SyncFlagged {
+			return c.responsef(c.Args, "Bookmarks are synced with your saved posts")
+		}
+		return c.responsef(c.Args, "Bookmarks are not synced with your saved posts. You can try %v", getHelp(syncCommandText))
+	}
+	if len(subCommand) != 1 {
+		return c.responsef(c.Args, "Please specify `on` or `off` %v", getHelp(syncCommandText))
+	}
+
+	switch subCommand[0] {
+	case "on":
+		bmarks, err := bookmarks.NewBookmarksWithUser(c.API, c.Args.UserId)
+		if err != nil {
+			return c.responsef(c.Args, "Unable to retrieve bookmarks for user %s", c.Args.UserId)
+		}
+		result, err := bmarks.EnableFlaggedSync()
+		if err != nil {
+			return c.responsef(c.Args, err.Error())
+		}
+		return c.responsef(c.Args, "Bookmarks are synced with your saved posts. %d bookmarked posts were saved and %d saved posts were bookmarked", result.Flagged, result.Added)
+	case "off":
+		if err := bookmarks.DisableFlaggedSync(c.API, c.Args.UserId); err != nil {
+			return c.responsef(c.Args, err.Error())
+		}
+		return c.responsef(c.Args, "Bookmarks are no longer synced with your saved posts. Your bookmarks and saved posts were kept")
+	}
+	return c.responsef(c.Args, "Please specify `on` or `off` %v", getHelp(syncCommandText))
+}
@@ -0,0 +1,90 @@
+package command
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi/mock_pluginapi"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteCommandSync(t *testing.T) {
+	tests := map[string]struct {
+		command           string
+		syncFlagged       bool
+		expectedMsgPrefix string
+		expectedSettings  *bookmarks.Settings
+	}{
+		"User does not sync": {
+			command:           "/bookmarks sync",
+			expectedMsgPrefix: "Bookmarks are not synced with your saved posts",
+		},
+		"User syncs": {
+			command:           "/bookmarks sync",
+			syncFlagged:       true,
+			expectedMsgPrefix: "Bookmarks are synced with your saved posts",
+		},
+		"User provides an unknown argument": {
+			command:           "/bookmarks sync maybe",
+			expectedMsgPrefix: "Please specify `on` or `off`",
+		},
+		"User turns the sync on": {
+			command:           "/bookmarks sync on",
+			expectedMsgPrefix: "Bookmarks are synced with your saved posts. 3 bookmarked posts were saved and 0 saved posts were bookmarked",
+			expectedSettings:  &bookmarks.Settings{SyncFlagged: true},
+		},
+		"User turns the sync off": {
+			command:           "/bookmarks sync off",
+			syncFlagged:       true,
+			expectedMsgPrefix: "Bookmarks are no longer synced with your saved posts",
+			expectedSettings:  &bookmarks.Settings{},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+
+			jsonSettings, err := json.Marshal(&bookmarks.Settings{SyncFlagged: tt.syncFlagged})
+			assert.Nil(t, err)
+			mockPluginAPI.EXPECT().KVGet(bookmarks.GetSettingsKey(UserID)).Return(jsonSettings, nil).AnyTimes()
+			mockBookmarksKV(t, mockPluginAPI, getExecuteCommandViewBookmarks())
+
+			// the user saved the post of the first bookmark
+			mockPluginAPI.EXPECT().GetPreferencesForUser(UserID).Return([]model.Preference{
+				{UserId: UserID, Category: model.PREFERENCE_CATEGORY_FLAGGED_POST, Name: p1ID, Value: "true"},
+			}, nil).AnyTimes()
+			mockPluginAPI.EXPECT().UpdatePreferencesForUser(UserID, gomock.Any()).DoAndReturn(func(userID string, preferences []model.Preference) error {
+				assert.Len(t, preferences, 3)
+				return nil
+			}).AnyTimes()
+			mockPluginAPI.EXPECT().KVGet(bookmarks.GetLabelsKey(UserID)).Return(nil, nil).AnyTimes()
+			mockPluginAPI.EXPECT().GetPostsByIds(gomock.Any()).Return(map[string]*model.Post{}, nil).AnyTimes()
+			mockPluginAPI.EXPECT().KVGet(bookmarks.GetFlaggedPostsKey(UserID)).Return(nil, nil).AnyTimes()
+			mockPluginAPI.EXPECT().KVCompareAndSet(bookmarks.GetFlaggedPostsKey(UserID), nil, gomock.Any()).Return(true, nil).AnyTimes()
+			mockPluginAPI.EXPECT().KVDelete(bookmarks.GetFlaggedPostsKey(UserID)).Return(nil).AnyTimes()
+			if tt.expectedSettings != nil {
+				expected, err := json.Marshal(tt.expectedSettings)
+				assert.Nil(t, err)
+				mockPluginAPI.EXPECT().KVCompareAndSet(bookmarks.GetSettingsKey(UserID), jsonSettings, expected).Return(true, nil)
+			}
+
+			testCommand := Command{
+				Args: &model.CommandArgs{
+					UserId:  UserID,
+					Command: tt.command},
+				API: mockPluginAPI,
+			}
+
+			message := testCommand.Handle()
+			actual := strings.TrimSpace(message)
+			assert.True(t, strings.HasPrefix(actual, tt.expectedMsgPrefix), "Expected returned message to start with: \n%s\nActual:\n%s", tt.expectedMsgPrefix, actual)
+		})
+	}
+}
@@ -137,3 +137,107 @@ func makeAPIMock() *plugintest.API {
 
 	return api
 }
+
+func TestSplitArguments(t *testing.T) {
+	tests := map[string]struct {
+		command  string
+		expected []string
+	}{
+		"empty":              {command: "", expected: nil},
+		"fields":             {command: " /bookmarks  view --since 7d ", expected: []string{"/bookmarks", "view", "--since", "7d"}},
+		"quoted value":       {command: `view --query "label:a OR label:b"`, expected: []string{"view", "--query", "label:a OR label:b"}},
+		"quotes inside word": {command: `--query="label:a OR label:b" x`, expected: []string{"--query=label:a OR label:b", "x"}},
+		"empty quotes":       {command: `view ""`, expected: []string{"view", ""}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, splitArguments(tt.command))
+		})
+	}
+}
+
+func TestCreateViewCommand(t *testing.T) {
+	assert.Nil(t, createBookmarksCommand().AutocompleteData.IsValid())
+
+	view := createViewCommand()
+	var collection *model.AutocompleteArg
+	for _, arg := range view.Arguments {
+		if arg.Name == flagCollection {
+			collection = arg
+		}
+	}
+	if assert.NotNil(t, collection) {
+		assert.Equal(t, model.AutocompleteArgTypeDynamicList, collection.Type)
+		assert.Equal(t, prefixWithAPI(routeAutocompleteCollections), collection.Data.(*model.AutocompleteDynamicListArg).FetchURL)
+	}
+}
+
+// mockBookmarksKV mocks the KV values of bookmarks stored individually with an
+// index of their IDs. A nil bmarks mocks a user without bookmarks
+func mockBookmarksKV(t *testing.T, api *mock_pluginapi.MockAPI, bmarks *bookmarks.Bookmarks) {
+	var jsonIndex []byte
+	if bmarks != nil {
+		ids := []string{}
+		for id, bmark := range bmarks.ByID {
+			jsonBmark, err := json.Marshal(bmark)
+			assert.Nil(t, err)
+			api.EXPECT().KVGet(bookmarks.GetBookmarkKey(UserID, id)).Return(jsonBmark, nil).AnyTimes()
+			ids = append(ids, id)
+		}
+
+		var err error
+		jsonIndex, err = json.Marshal(ids)
+		assert.Nil(t, err)
+	}
+
+	api.EXPECT().KVGet(bookmarks.GetBookmarksIndexKey(UserID)).Return(jsonIndex, nil).AnyTimes()
+	api.EXPECT().KVGet(bookmarks.GetBookmarksKey(UserID)).Return(nil, nil).AnyTimes()
+	api.EXPECT().KVGet(bookmarks.GetSettingsKey(UserID)).Return(nil, nil).AnyTimes()
+
+	// no other user bookmarked the posts
+	postBookmarksKey := keyPrefix(bookmarks.StorePostBookmarksKey + "_")
+	api.EXPECT().KVGet(postBookmarksKey).Return(nil, nil).AnyTimes()
+	api.EXPECT().KVSetWithOptions(postBookmarksKey, gomock.Any(), gomock.Any()).Return(true, nil).AnyTimes()
+}
+
+// getStoredBookmark returns the bookmark of a stored bookmark document
+func getStoredBookmark(t *testing.T, stored []byte) *bookmarks.Bookmark {
+	var document struct {
+		Data *bookmarks.Bookmark `json:"data"`
+	}
+	assert.Nil(t, json.Unmarshal(stored, &document))
+	return document.Data
+}
+
+// keyPrefix matches KV keys starting with the prefix
+type keyPrefix string
+
+func (p keyPrefix) Matches(x interface{}) bool {
+	key, ok := x.(string)
+	return ok && strings.HasPrefix(key, string(p))
+}
+
+func (p keyPrefix) String() string {
+	return "has prefix " + string(p)
+}
+
+// mockChannelMember makes the user a member of every channel
+func mockChannelMember(api *mock_pluginapi.MockAPI) {
+	api.EXPECT().GetChannelMember(gomock.Any(), UserID).Return(&model.ChannelMember{}, nil).AnyTimes()
+}
+
+// mockGetPostsByIds answers GetPostsByIds with the GetPost expectations of the
+// test
+func mockGetPostsByIds(api *mock_pluginapi.MockAPI) {
+	api.EXPECT().GetPostsByIds(gomock.Any()).DoAndReturn(func(postIDs []string) (map[string]*model.Post, error) {
+		posts := make(map[string]*model.Post)
+		for _, postID := range postIDs {
+			post, err := api.GetPost(postID)
+			if err != nil {
+				return nil, err
+			}
+			posts[postID] = post
+		}
+		return posts, nil
+	}).AnyTimes()
+}
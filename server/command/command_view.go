@@ -1,7 +1,11 @@
 package command
 
 import (
+	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
 	"github.com/jfrerich/mattermost-plugin-bookmarks/server/utils"
@@ -10,24 +14,73 @@ import (
 )
 
 const (
-	flagFilterLabels = "filter-labels"
+	flagFilterLabels    = "filter-labels"
+	flagSince           = "since"
+	flagUntil           = "until"
+	flagChannel         = "channel"
+	flagTeam            = "team"
+	flagFrom            = "from"
+	flagQuery           = "query"
+	flagCollection      = "collection"
+	flagIncludeArchived = "include-archived"
+	flagUnread          = "unread"
+	flagSort            = "sort"
+	flagReverse         = "reverse"
+	flagLimit           = "limit"
+	flagPage            = "page"
+
+	dateLayout = "2006-01-02"
 )
 
 func getViewBookmarkFlagSet() *pflag.FlagSet {
 	flagSet := pflag.NewFlagSet("filter bookmarks by label", pflag.ContinueOnError)
 	flagSet.StringSlice(flagFilterLabels, nil, "filter by label")
+	flagSet.String(flagSince, "", "filter by bookmarks saved on or after a date (YYYY-MM-DD) in your timezone or a duration ago (12h, 7d, 2w)")
+	flagSet.String(flagUntil, "", "filter by bookmarks saved on or before a date (YYYY-MM-DD) in your timezone or a duration ago (12h, 7d, 2w)")
+	flagSet.String(flagChannel, "", "filter by the channel of the bookmarked post")
+	flagSet.String(flagTeam, "", "filter by the team of the bookmarked post")
+	flagSet.String(flagFrom, "", "filter by the author of the bookmarked post")
+	flagSet.String(flagQuery, "", "filter by a boolean label query")
+	flagSet.String(flagCollection, "", "filter by the filters of a saved collection")
+	flagSet.Bool(flagIncludeArchived, false, "include archived bookmarks")
+	flagSet.Bool(flagUnread, false, "filter by bookmarks you have not read yet")
+	flagSet.String(flagSort, bookmarks.SortByCreated, "sort by created, modified, bookmarked, title, or channel")
+	flagSet.Bool(flagReverse, false, "reverse the sort order")
+	flagSet.Int(flagLimit, bookmarks.DefaultPageSize, "number of bookmarks per page")
+	flagSet.Int(flagPage, 1, "page of bookmarks to view")
 
 	return flagSet
 }
 
 type viewBookmarkOptions struct {
-	labels []string
+	labels  []string
+	since   string
+	until   string
+	channel string
+	team    string
+	from    string
+	query   string
+
+	collection string
+
+	includeArchived bool
+	unread          bool
+
+	sortBy  string
+	reverse bool
+	limit   int
+	page    int
 }
 
 func parseViewBookmarkArgs(args []string) (viewBookmarkOptions, error) {
+	return parseViewBookmarkFlags(getViewBookmarkFlagSet(), args)
+}
+
+// parseViewBookmarkFlags parses args with a flag set that has at least the
+// flags of the view command
+func parseViewBookmarkFlags(viewBookmarkFlagSet *pflag.FlagSet, args []string) (viewBookmarkOptions, error) {
 	var options viewBookmarkOptions
 
-	viewBookmarkFlagSet := getViewBookmarkFlagSet()
 	err := viewBookmarkFlagSet.Parse(args)
 	if err != nil {
 		return options, err
@@ -38,12 +91,200 @@ func parseViewBookmarkArgs(args []string) (viewBookmarkOptions, error) {
 		return options, err
 	}
 
+	options.since, err = viewBookmarkFlagSet.GetString(flagSince)
+	if err != nil {
+		return options, err
+	}
+
+	options.until, err = viewBookmarkFlagSet.GetString(flagUntil)
+	if err != nil {
+		return options, err
+	}
+
+	options.channel, err = viewBookmarkFlagSet.GetString(flagChannel)
+	if err != nil {
+		return options, err
+	}
+
+	options.team, err = viewBookmarkFlagSet.GetString(flagTeam)
+	if err != nil {
+		return options, err
+	}
+
+	options.from, err = viewBookmarkFlagSet.GetString(flagFrom)
+	if err != nil {
+		return options, err
+	}
+
+	options.query, err = viewBookmarkFlagSet.GetString(flagQuery)
+	if err != nil {
+		return options, err
+	}
+
+	options.collection, err = viewBookmarkFlagSet.GetString(flagCollection)
+	if err != nil {
+		return options, err
+	}
+
+	options.includeArchived, err = viewBookmarkFlagSet.GetBool(flagIncludeArchived)
+	if err != nil {
+		return options, err
+	}
+
+	options.unread, err = viewBookmarkFlagSet.GetBool(flagUnread)
+	if err != nil {
+		return options, err
+	}
+
+	options.sortBy, err = viewBookmarkFlagSet.GetString(flagSort)
+	if err != nil {
+		return options, err
+	}
+
+	options.reverse, err = viewBookmarkFlagSet.GetBool(flagReverse)
+	if err != nil {
+		return options, err
+	}
+
+	options.limit, err = viewBookmarkFlagSet.GetInt(flagLimit)
+	if err != nil {
+		return options, err
+	}
+
+	options.page, err = viewBookmarkFlagSet.GetInt(flagPage)
+	if err != nil {
+		return options, err
+	}
+
 	return options, nil
 }
 
+// hasFilters returns true if the options select some of the bookmarks
+func (o viewBookmarkOptions) hasFilters() bool {
+	return len(o.labels) != 0 || o.since != "" || o.until != "" || o.channel != "" ||
+		o.team != "" || o.from != "" || o.query != "" || o.collection != "" || o.unread
+}
+
+// getListOptions returns the order and page of the bookmarks listing
+func getListOptions(options viewBookmarkOptions) *bookmarks.ListOptions {
+	return &bookmarks.ListOptions{
+		SortBy:  options.sortBy,
+		Reverse: options.reverse,
+		Limit:   options.limit,
+		Page:    options.page,
+	}
+}
+
+// getFilters resolves the view options into bookmark filters
+func (c *Command) getFilters(options viewBookmarkOptions) (*bookmarks.Filters, error) {
+	filters := &bookmarks.Filters{
+		LabelNames:      options.labels,
+		IncludeArchived: options.includeArchived,
+		Unread:          options.unread,
+	}
+
+	// dates are in the timezone of the user, like reminders and digests
+	now := time.Now()
+	if options.since != "" || options.until != "" {
+		location, err := bookmarks.GetUserLocation(c.API, c.Args.UserId)
+		if err != nil {
+			return nil, err
+		}
+		now = now.In(location)
+	}
+	if options.since != "" {
+		since, err := parseTimeFlag(options.since, now, false)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid --%s value", flagSince)
+		}
+		filters.Since = since
+	}
+
+	if options.until != "" {
+		until, err := parseTimeFlag(options.until, now, true)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid --%s value", flagUntil)
+		}
+		filters.Until = until
+	}
+
+	// channel names are looked up in the requested team, or the current team
+	teamID := c.Args.TeamId
+	if options.team != "" {
+		team, err := c.API.GetTeamByName(options.team)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Team `%s` does not exist", options.team))
+		}
+		filters.TeamID = team.Id
+		teamID = team.Id
+	}
+
+	if options.channel != "" {
+		name := strings.TrimPrefix(options.channel, "~")
+		channel, err := c.API.GetChannelByName(teamID, name)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Channel `~%s` does not exist", name))
+		}
+		filters.ChannelID = channel.Id
+	}
+
+	if options.from != "" {
+		username := strings.TrimPrefix(options.from, "@")
+		user, err := c.API.GetUserByUsername(username)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("User `@%s` does not exist", username))
+		}
+		filters.AuthorID = user.Id
+	}
+
+	if options.query != "" {
+		query, err := bookmarks.ParseQuery(options.query)
+		if err != nil {
+			return nil, errors.Wrap(err, "Unable to parse query")
+		}
+		filters.Query = query
+	}
+
+	return filters, nil
+}
+
+var durationAgo = regexp.MustCompile(`^(\d+)([hdw])$`)
+
+// parseTimeFlag returns the time in milliseconds for a date (YYYY-MM-DD) or a
+// duration ago (12h, 7d, 2w). Dates are in the location of now and resolve to
+// the end of the day when endOfDay is set so the whole day is included in a
+// range
+func parseTimeFlag(value string, now time.Time, endOfDay bool) (int64, error) {
+	if match := durationAgo.FindStringSubmatch(value); match != nil {
+		n, err := strconv.Atoi(match[1])
+		if err != nil {
+			return 0, err
+		}
+
+		unit := time.Hour
+		switch match[2] {
+		case "d":
+			unit = 24 * time.Hour
+		case "w":
+			unit = 7 * 24 * time.Hour
+		}
+		return utils.GetMillis(now.Add(-time.Duration(n) * unit)), nil
+	}
+
+	date, err := time.ParseInLocation(dateLayout, value, now.Location())
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("`%s` is not a date (YYYY-MM-DD) or a duration (12h, 7d, 2w)", value))
+	}
+	if endOfDay {
+		// days are not 24 hours long when daylight saving time changes
+		date = date.AddDate(0, 0, 1).Add(-time.Millisecond)
+	}
+	return utils.GetMillis(date), nil
+}
+
 // executeCommandView shows all bookmarks in an ephemeral post
 func (c *Command) executeCommandView() string {
-	subCommand := strings.Fields(c.Args.Command)
+	subCommand := splitArguments(c.Args.Command)
 
 	bmarks, err := bookmarks.NewBookmarksWithUser(c.API, c.Args.UserId)
 	if err != nil {
@@ -61,8 +302,11 @@ func (c *Command) executeCommandView() string {
 	if len(subCommand) == 3 && !strings.HasPrefix(subCommand[2], "--") {
 		postID := subCommand[2]
 		postID = utils.GetPostIDFromLink(postID)
-		text, _ := c.commandViewPostID(postID, bmarks)
-		return c.responsef(c.Args, text)
+		text, err := c.commandViewPostID(postID, bmarks)
+		if err != nil {
+			return c.responsef(c.Args, "%s", err.Error())
+		}
+		return c.responsef(c.Args, "%s", text)
 	}
 
 	options, err := parseViewBookmarkArgs(subCommand)
@@ -70,18 +314,56 @@ func (c *Command) executeCommandView() string {
 		return c.responsef(c.Args, "Unable to parse options, %s", err)
 	}
 
-	var bmarkFilters bookmarks.Filters
-	bmarkFilters.LabelNames = options.labels
+	options, err = c.addCollectionArgs(options, subCommand, parseViewBookmarkArgs)
+	if err != nil {
+		return c.responsef(c.Args, "%s", err.Error())
+	}
+
+	bmarkFilters, err := c.getFilters(options)
+	if err != nil {
+		return c.responsef(c.Args, "%s", err.Error())
+	}
+
+	listOptions := getListOptions(options)
+	if err = listOptions.IsValid(); err != nil {
+		return c.responsef(c.Args, "%s", err.Error())
+	}
 
-	text, err := bmarks.GetBmarksEphemeralText(c.Args.UserId, &bmarkFilters)
+	text, err := bmarks.GetBmarksEphemeralText(c.Args.UserId, bmarkFilters, listOptions)
 	if err != nil {
-		return c.responsef(c.Args, text)
+		return c.responsef(c.Args, "%s", err.Error())
 	}
 
-	return c.responsef(c.Args, text)
+	return c.responsef(c.Args, "%s", text)
 }
 
-// executeCommandView shows all bookmarks in an ephemeral post
+// addCollectionArgs returns the options with the saved filters of the
+// requested collection. The saved filters are applied before the filters
+// given in the command, which take precedence
+func (c *Command) addCollectionArgs(options viewBookmarkOptions, args []string, parse func([]string) (viewBookmarkOptions, error)) (viewBookmarkOptions, error) {
+	if options.collection == "" {
+		return options, nil
+	}
+
+	collections, err := bookmarks.NewCollectionsWithUser(c.API, c.Args.UserId)
+	if err != nil {
+		return options, err
+	}
+	collection, err := collections.GetCollection(options.collection)
+	if err != nil {
+		return options, err
+	}
+
+	args = append(append([]string{}, collection.Args...), args...)
+	options, err = parse(args)
+	if err != nil {
+		return options, errors.New(fmt.Sprintf("Unable to parse options, %s", err))
+	}
+	return options, nil
+}
+
+// commandViewPostID returns the detailed text of a bookmark and marks it
+// read. Archived bookmarks stay archived
 func (c *Command) commandViewPostID(postID string, bmarks *bookmarks.Bookmarks) (string, error) {
 	postID = utils.GetPostIDFromLink(postID)
 
@@ -102,5 +384,11 @@ func (c *Command) commandViewPostID(postID string, bmarks *bookmarks.Bookmarks)
 	if err != nil {
 		return "", errors.Wrap(err, "Unable to get bookmark text")
 	}
+
+	if bmark.GetStatus() == bookmarks.StatusUnread {
+		if _, err = bmarks.SetStatus(postID, bookmarks.StatusRead); err != nil {
+			return "", errors.Wrap(err, "Unable to mark bookmark read")
+		}
+	}
 	return text, nil
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"strings"
 	"testing"
+	"time"
 
 	gomock "github.com/golang/mock/gomock"
 	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
@@ -15,30 +16,52 @@ import (
 )
 
 func TestExecuteCommandView(t *testing.T) {
+	const (
+		channelID1 = "channelID1"
+		channelID2 = "channelID2"
+		channelID3 = "channelID3"
+		teamID2    = "teamID2"
+		authorID1  = "authorID1"
+		authorID2  = "authorID2"
+	)
+
 	p1IDmodel := &model.Post{
-		Message:  "this is the post.Message",
-		CreateAt: model.GetMillis(),
+		Message:   "this is the post.Message",
+		CreateAt:  model.GetMillis(),
+		ChannelId: channelID1,
+		UserId:    authorID1,
 	}
 	p2IDmodel := &model.Post{
-		Message:  "this is the post.Message",
-		CreateAt: model.GetMillis() + 5,
+		Message:   "this is the post.Message",
+		CreateAt:  model.GetMillis() + 5,
+		ChannelId: channelID1,
+		UserId:    authorID2,
 	}
 	p3IDmodel := &model.Post{
-		Message:  "this is the post.Message",
-		CreateAt: model.GetMillis() + 2,
+		Message:   "this is the post.Message",
+		CreateAt:  model.GetMillis() + 2,
+		ChannelId: channelID2,
+		UserId:    authorID1,
 	}
 	p4IDmodel := &model.Post{
-		Message:  "this is the post.Message",
-		CreateAt: model.GetMillis() + 3,
+		Message:   "this is the post.Message",
+		CreateAt:  model.GetMillis() + 3,
+		ChannelId: channelID3,
+		UserId:    authorID2,
 	}
 
+	b1Line := "[:link:](https://myhost.com/_redirect/pl/ID1) `label1` `label2` **_Title1 - New Bookmark - times are zero_**"
+	b2Line := "[:link:](https://myhost.com/_redirect/pl/ID2) `label1` `label2` `label3` **_Title2 - bookmarks initialized. Times created and same_**"
+	b3Line := "[:link:](https://myhost.com/_redirect/pl/ID3) `label3` **_Title3 - bookmarks already updated once_**"
+	b4Line := "[:link:](https://myhost.com/_redirect/pl/ID4) **`TFP`** this is the post.Message"
+
 	defaultSortString := []string{
 		strings.TrimSpace(utils.GetLegendText()),
 		"#### Bookmarks",
-		"[:link:](https://myhost.com/_redirect/pl/ID1) `label1` `label2` **_Title1 - New Bookmark - times are zero_**",
-		"[:link:](https://myhost.com/_redirect/pl/ID3) `label3` **_Title3 - bookmarks already updated once_**",
-		"[:link:](https://myhost.com/_redirect/pl/ID4) **`TFP`** this is the post.Message",
-		"[:link:](https://myhost.com/_redirect/pl/ID2) `label1` `label2` `label3` **_Title2 - bookmarks initialized. Times created and same_**",
+		b1Line,
+		b3Line,
+		b4Line,
+		b2Line,
 	}
 
 	tests := map[string]struct {
@@ -47,6 +70,9 @@ func TestExecuteCommandView(t *testing.T) {
 		expectedMsgPrefix   string
 		expectedContains    []string
 		expectedNotContains []string
+		archivedIDs         []string
+		unreadIDs           []string
+		expectedStatus      string // status of ID2 after the command
 	}{
 		// User has no bookmarks
 		"User has no bookmarks": {
@@ -59,6 +85,7 @@ func TestExecuteCommandView(t *testing.T) {
 		// View individual bookmark
 		"User requests to view bookmark by ID that has a title defined": {
 			command:           "/bookmarks view ID2",
+			unreadIDs:         []string{p2ID},
 			expectedMsgPrefix: "",
 			expectedContains: []string{
 				"#### Bookmark Title [:link:](https://myhost.com/_redirect/pl/ID2)",
@@ -67,6 +94,17 @@ func TestExecuteCommandView(t *testing.T) {
 				"##### Post Message",
 				"this is the post.Message",
 			},
+			expectedStatus: bookmarks.StatusRead,
+		},
+		"User requests to view a bookmark by ID that does not exist": {
+			command:           "/bookmarks view " + PostIDDoesNotExist,
+			expectedMsgPrefix: "Bookmark `" + PostIDDoesNotExist + "` does not exist",
+		},
+		"User requests to view an archived bookmark by ID": {
+			command:           "/bookmarks view ID2",
+			archivedIDs:       []string{p2ID},
+			expectedMsgPrefix: "**`ARCHIVED`** `label1` `label2`",
+			expectedStatus:    bookmarks.StatusArchived,
 		},
 
 		// View all bookmarks
@@ -88,6 +126,66 @@ func TestExecuteCommandView(t *testing.T) {
 			expectedContains:  nil,
 		},
 
+		"Sorted by title": {
+			command:           "/bookmarks view --sort title",
+			expectedMsgPrefix: strings.TrimSpace(utils.GetLegendText()),
+			expectedContains:  []string{strings.Join([]string{b4Line, b1Line, b2Line, b3Line}, "\n")},
+		},
+		"Sorted by title reversed": {
+			command:           "/bookmarks view --sort title --reverse",
+			expectedMsgPrefix: strings.TrimSpace(utils.GetLegendText()),
+			expectedContains:  []string{strings.Join([]string{b3Line, b2Line, b1Line, b4Line}, "\n")},
+		},
+		"Sorted by bookmarked time": {
+			command:           "/bookmarks view --sort bookmarked",
+			expectedMsgPrefix: strings.TrimSpace(utils.GetLegendText()),
+			expectedContains:  []string{strings.Join([]string{b1Line, b3Line, b4Line, b2Line}, "\n")},
+		},
+		"Sorted by channel": {
+			command:           "/bookmarks view --sort channel",
+			expectedMsgPrefix: strings.TrimSpace(utils.GetLegendText()),
+			expectedContains:  []string{strings.Join([]string{b3Line, b1Line, b2Line, b4Line}, "\n")},
+		},
+		"Sorted by unknown key": {
+			command:           "/bookmarks view --sort color",
+			expectedMsgPrefix: "Unknown sort `color`. Sort by one of: created, modified, bookmarked, title, channel",
+		},
+
+		// View pages
+		"First page": {
+			command:             "/bookmarks view --limit 2",
+			expectedMsgPrefix:   strings.TrimSpace(utils.GetLegendText()),
+			expectedContains:    []string{b1Line + "\n" + b3Line + "\n", "_Showing bookmarks 1-2 of 4._ _View the next page with_ `--page 2`"},
+			expectedNotContains: []string{"ID2", "ID4"},
+		},
+		"Last page": {
+			command:             "/bookmarks view --limit 2 --page 2",
+			expectedMsgPrefix:   strings.TrimSpace(utils.GetLegendText()),
+			expectedContains:    []string{b4Line + "\n" + b2Line + "\n", "_Showing bookmarks 3-4 of 4._"},
+			expectedNotContains: []string{"ID1", "ID3", "next page"},
+		},
+		"Page after the last page": {
+			command:           "/bookmarks view --limit 2 --page 3",
+			expectedMsgPrefix: "Page 3 is empty. The last page of bookmarks is 2",
+		},
+		"All bookmarks fit on one page": {
+			command:             "/bookmarks view --limit 4",
+			expectedMsgPrefix:   strings.TrimSpace(utils.GetLegendText()),
+			expectedNotContains: []string{"Showing bookmarks"},
+		},
+		"Invalid limit": {
+			command:           "/bookmarks view --limit 0",
+			expectedMsgPrefix: "limit must be at least 1",
+		},
+		"Limit too large": {
+			command:           "/bookmarks view --limit 100000",
+			expectedMsgPrefix: "limit must be at most 50",
+		},
+		"Invalid page": {
+			command:           "/bookmarks view --page 0",
+			expectedMsgPrefix: "page must be at least 1",
+		},
+
 		// filter bookmarks
 		"User filter by label  filter one label  label1": {
 			command:           "/bookmarks view --filter-labels label1",
@@ -112,11 +210,117 @@ func TestExecuteCommandView(t *testing.T) {
 			expectedContains:    []string{"Bookmarks", "ID1", "ID2", "ID3"},
 			expectedNotContains: []string{"ID4"},
 		},
+
+		// filter bookmarks by time, channel, team and author
+		"User filter by since date": {
+			command:             "/bookmarks view --since 2020-01-01",
+			expectedMsgPrefix:   strings.TrimSpace(utils.GetLegendText()),
+			expectedContains:    []string{"ID2", "ID3", "ID4"},
+			expectedNotContains: []string{"ID1"},
+		},
+		"User filter by since duration": {
+			command:             "/bookmarks view --since 1d",
+			expectedMsgPrefix:   strings.TrimSpace(utils.GetLegendText()),
+			expectedContains:    []string{"ID2", "ID3", "ID4"},
+			expectedNotContains: []string{"ID1"},
+		},
+		"User filter by until date": {
+			command:             "/bookmarks view --until 2020-01-01",
+			expectedMsgPrefix:   strings.TrimSpace(utils.GetLegendText()),
+			expectedContains:    []string{"ID1"},
+			expectedNotContains: []string{"ID2", "ID3", "ID4"},
+		},
+		"User filter by invalid date": {
+			command:           "/bookmarks view --since yesterday",
+			expectedMsgPrefix: "invalid --since value: `yesterday` is not a date (YYYY-MM-DD) or a duration (12h, 7d, 2w)",
+		},
+		"User filter by channel": {
+			command:             "/bookmarks view --channel ~incidents",
+			expectedMsgPrefix:   strings.TrimSpace(utils.GetLegendText()),
+			expectedContains:    []string{"ID1", "ID2"},
+			expectedNotContains: []string{"ID3", "ID4"},
+		},
+		"User filter by channel that does not exist": {
+			command:           "/bookmarks view --channel ~unknown",
+			expectedMsgPrefix: "Channel `~unknown` does not exist",
+		},
+		"User filter by team": {
+			command:             "/bookmarks view --team team2",
+			expectedMsgPrefix:   strings.TrimSpace(utils.GetLegendText()),
+			expectedContains:    []string{"ID4"},
+			expectedNotContains: []string{"ID1", "ID2", "ID3"},
+		},
+		"User filter by author": {
+			command:             "/bookmarks view --from @author1",
+			expectedMsgPrefix:   strings.TrimSpace(utils.GetLegendText()),
+			expectedContains:    []string{"ID1", "ID3"},
+			expectedNotContains: []string{"ID2", "ID4"},
+		},
+		"User filter by author and label": {
+			command:             "/bookmarks view --from @author1 --filter-labels label3",
+			expectedMsgPrefix:   strings.TrimSpace(utils.GetLegendText()),
+			expectedContains:    []string{"ID3"},
+			expectedNotContains: []string{"ID1", "ID2", "ID4"},
+		},
+		"User filter by label query  all labels required": {
+			command:             `/bookmarks view --query "label:label1 AND label:label3"`,
+			expectedMsgPrefix:   strings.TrimSpace(utils.GetLegendText()),
+			expectedContains:    []string{"ID2"},
+			expectedNotContains: []string{"ID1", "ID3", "ID4"},
+		},
+		"User filter by label query  excluded label": {
+			command:             `/bookmarks view --query "(label:label1 OR label:label3) AND NOT label:label2"`,
+			expectedMsgPrefix:   strings.TrimSpace(utils.GetLegendText()),
+			expectedContains:    []string{"ID3"},
+			expectedNotContains: []string{"ID1", "ID2", "ID4"},
+		},
+		"User filter by label query  no labels": {
+			command:             `/bookmarks view --query "NOT (label:label1 OR label:label2 OR label:label3)"`,
+			expectedMsgPrefix:   strings.TrimSpace(utils.GetLegendText()),
+			expectedContains:    []string{"ID4"},
+			expectedNotContains: []string{"ID1", "ID2", "ID3"},
+		},
+		"User filter by invalid label query": {
+			command:           `/bookmarks view --query "label:label1 AND"`,
+			expectedMsgPrefix: "Unable to parse query: query ends unexpectedly",
+		},
+		"Archived bookmarks are hidden": {
+			command:             "/bookmarks view",
+			archivedIDs:         []string{p2ID, p3ID},
+			expectedMsgPrefix:   strings.TrimSpace(utils.GetLegendText()),
+			expectedContains:    []string{b1Line, b4Line},
+			expectedNotContains: []string{"ID2", "ID3"},
+			expectedStatus:      bookmarks.StatusArchived,
+		},
+		"User includes archived bookmarks": {
+			command:           "/bookmarks view --include-archived",
+			archivedIDs:       []string{p2ID},
+			expectedMsgPrefix: strings.TrimSpace(utils.GetLegendText()),
+			expectedContains: []string{
+				b1Line,
+				"[:link:](https://myhost.com/_redirect/pl/ID2) **`ARCHIVED`** `label1` `label2` `label3`",
+			},
+			expectedStatus: bookmarks.StatusArchived,
+		},
+		"User views unread bookmarks": {
+			command:             "/bookmarks view --unread",
+			unreadIDs:           []string{p2ID, p4ID},
+			archivedIDs:         []string{p4ID},
+			expectedMsgPrefix:   strings.TrimSpace(utils.GetLegendText()),
+			expectedContains:    []string{"[:link:](https://myhost.com/_redirect/pl/ID2) **`UNREAD`** `label1` `label2` `label3`"},
+			expectedNotContains: []string{"ID1", "ID3", "ID4"},
+		},
+		"User filter matches no bookmarks": {
+			command:           "/bookmarks view --from @author1 --channel incidents --filter-labels label3",
+			expectedMsgPrefix: "You do not have any saved bookmarks",
+		},
 	}
 	for name, tt := range tests {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 		mockPluginAPI := mock_pluginapi.NewMockAPI(ctrl)
+		mockGetPostsByIds(mockPluginAPI)
+		mockChannelMember(mockPluginAPI)
 
 		config := &model.Config{
 			ServiceSettings: model.ServiceSettings{
@@ -129,27 +333,49 @@ func TestExecuteCommandView(t *testing.T) {
 		mockPluginAPI.EXPECT().GetPost(p2ID).Return(p2IDmodel, nil).AnyTimes()
 		mockPluginAPI.EXPECT().GetPost(p3ID).Return(p3IDmodel, nil).AnyTimes()
 		mockPluginAPI.EXPECT().GetPost(p4ID).Return(p4IDmodel, nil).AnyTimes()
+		mockPluginAPI.EXPECT().GetChannel(channelID1).Return(&model.Channel{Id: channelID1, TeamId: teamID1, DisplayName: "Incidents"}, nil).AnyTimes()
+		mockPluginAPI.EXPECT().GetChannel(channelID2).Return(&model.Channel{Id: channelID2, TeamId: teamID1, DisplayName: "Alpha"}, nil).AnyTimes()
+		mockPluginAPI.EXPECT().GetChannel(channelID3).Return(&model.Channel{Id: channelID3, TeamId: teamID2, DisplayName: "Zulu"}, nil).AnyTimes()
+		mockPluginAPI.EXPECT().GetChannelByName(teamID1, "incidents").Return(&model.Channel{Id: channelID1, TeamId: teamID1}, nil).AnyTimes()
+		mockPluginAPI.EXPECT().GetChannelByName(teamID1, "unknown").Return(nil, &model.AppError{Message: "An Error Occurred"}).AnyTimes()
+		mockPluginAPI.EXPECT().GetTeamByName("team2").Return(&model.Team{Id: teamID2}, nil).AnyTimes()
+		mockPluginAPI.EXPECT().GetUser(UserID).Return(&model.User{Id: UserID}, nil).AnyTimes()
+		mockPluginAPI.EXPECT().GetUserByUsername("author1").Return(&model.User{Id: authorID1}, nil).AnyTimes()
+		mockPluginAPI.EXPECT().GetUserByUsername("author2").Return(&model.User{Id: authorID2}, nil).AnyTimes()
 
 		bmarks := tt.bmarks
 		if tt.bmarks == nil {
 			bmarks = getExecuteCommandViewBookmarks()
 		}
-
-		jsonBmarks, err := json.Marshal(bmarks)
-		assert.Nil(t, err)
+		for _, id := range tt.unreadIDs {
+			bmarks.ByID[id].Status = bookmarks.StatusUnread
+		}
+		for _, id := range tt.archivedIDs {
+			bmarks.ByID[id].Status = bookmarks.StatusArchived
+		}
 
 		labels := getExecuteCommandViewLabels()
 		jsonLabels, err := json.Marshal(labels)
 		assert.Nil(t, err)
 
 		mockPluginAPI.EXPECT().KVGet(bookmarks.GetLabelsKey(UserID)).Return(jsonLabels, nil).AnyTimes()
-		mockPluginAPI.EXPECT().KVGet(bookmarks.GetBookmarksKey(UserID)).Return(jsonBmarks, nil).AnyTimes()
+		mockBookmarksKV(t, mockPluginAPI, bmarks)
+
+		// viewing a bookmark stores its status
+		var stored []byte
+		mockPluginAPI.EXPECT().KVCompareAndSet(bookmarks.GetBookmarkKey(UserID, p2ID), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(key string, oldValue, newValue []byte) (bool, error) {
+				stored = newValue
+				return true, nil
+			}).AnyTimes()
+		mockPluginAPI.EXPECT().KVCompareAndSet(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil).AnyTimes()
 
 		t.Run(name, func(t *testing.T) {
 			assert.Nil(t, err)
 			testCommand := Command{
 				Args: &model.CommandArgs{
 					UserId:  UserID,
+					TeamId:  teamID1,
 					Command: tt.command},
 				API: mockPluginAPI,
 			}
@@ -171,6 +397,59 @@ func TestExecuteCommandView(t *testing.T) {
 					assert.Contains(t, actual, tt.expectedContains[i])
 				}
 			}
+
+			if tt.expectedStatus != "" {
+				status := bmarks.ByID[p2ID].GetStatus()
+				if stored != nil {
+					status = getStoredBookmark(t, stored).GetStatus()
+				}
+				assert.Equal(t, tt.expectedStatus, status)
+			}
+		})
+	}
+}
+
+func TestParseTimeFlag(t *testing.T) {
+	now := time.Date(2020, 6, 15, 12, 0, 0, 0, time.UTC)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.Nil(t, err)
+
+	tests := map[string]struct {
+		value    string
+		location *time.Location // location of now, UTC if nil
+		endOfDay bool
+		expected time.Time
+		wantErr  bool
+	}{
+		"date":             {value: "2020-06-01", expected: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)},
+		"date end of day":  {value: "2020-06-01", endOfDay: true, expected: time.Date(2020, 6, 1, 23, 59, 59, int(999*time.Millisecond), time.UTC)},
+		"hours ago":        {value: "12h", expected: time.Date(2020, 6, 15, 0, 0, 0, 0, time.UTC)},
+		"days ago":         {value: "7d", expected: time.Date(2020, 6, 8, 12, 0, 0, 0, time.UTC)},
+		"weeks ago":        {value: "2w", expected: time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)},
+		"unknown unit":     {value: "2y", wantErr: true},
+		"not a date":       {value: "last week", wantErr: true},
+		"invalid calendar": {value: "2020-13-01", wantErr: true},
+		"date in the timezone of the user": {
+			value:    "2020-06-01",
+			location: berlin,
+			endOfDay: true,
+			expected: time.Date(2020, 6, 1, 23, 59, 59, int(999*time.Millisecond), berlin),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			now := now
+			if tt.location != nil {
+				now = now.In(tt.location)
+			}
+			millis, err := parseTimeFlag(tt.value, now, tt.endOfDay)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, utils.GetMillis(tt.expected), millis)
 		})
 	}
 }
@@ -18,6 +18,9 @@ import (
 // If you add non-reference types to your configuration struct, be sure to rewrite Clone as a deep
 // copy appropriate for your types.
 type configuration struct {
+	// SchemaUpgradeDryRun logs the stored data the schema upgrade would
+	// change without changing it
+	SchemaUpgradeDryRun bool
 }
 
 // Clone shallow copies the configuration. Your implementation may require a deep copy if
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi"
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// sendDueDigests sends the digests that are due in direct messages from the
+// Bookmarks bot
+func (p *Plugin) sendDueDigests() {
+	sent, err := bookmarks.SendDueDigests(pluginapi.New(p.API), model.GetMillis(), p.PostBotDM)
+	if err != nil {
+		p.API.LogError("Failed to send digests", "err", err.Error())
+	}
+	if sent > 0 {
+		p.API.LogDebug("Sent digests", "digests", sent)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
@@ -30,9 +32,10 @@ func writeAPIError(w http.ResponseWriter, err *APIErrorResponse) {
 }
 
 const (
-	routeAPIPrefix             = "/api/v1"
-	routeAutocompleteLabels    = "/autocomplete/labels"
-	routeAutocompleteBookmarks = "/autocomplete/bookmarks"
+	routeAPIPrefix               = "/api/v1"
+	routeAutocompleteLabels      = "/autocomplete/labels"
+	routeAutocompleteBookmarks   = "/autocomplete/bookmarks"
+	routeAutocompleteCollections = "/autocomplete/collections"
 )
 
 func (p *Plugin) initialiseAPI() {
@@ -41,11 +44,15 @@ func (p *Plugin) initialiseAPI() {
 
 	apiRouter.HandleFunc(routeAutocompleteLabels, p.extractUserMiddleWare(p.handleAutoCompleteLabels, true)).Methods("GET")
 	apiRouter.HandleFunc(routeAutocompleteBookmarks, p.extractUserMiddleWare(p.handleAutoCompleteBookmarks, true)).Methods("GET")
+	apiRouter.HandleFunc(routeAutocompleteCollections, p.extractUserMiddleWare(p.handleAutoCompleteCollections, true)).Methods("GET")
 	apiRouter.HandleFunc("/view", p.extractUserMiddleWare(p.handleViewBookmarks, true)).Methods("POST")
 	apiRouter.HandleFunc("/add", p.extractUserMiddleWare(p.handleAddBookmark, true)).Methods("POST")
 	apiRouter.HandleFunc("/get", p.extractUserMiddleWare(p.handleGetBookmark, true)).Methods("GET")
 	apiRouter.HandleFunc("/labels/get", p.extractUserMiddleWare(p.handleLabelsGet, true)).Methods("GET")
 	apiRouter.HandleFunc("/labels/add", p.extractUserMiddleWare(p.handleLabelsAdd, true)).Methods("POST")
+	apiRouter.HandleFunc("/flagged/sync", p.extractUserMiddleWare(p.handleFlaggedSync, true)).Methods("POST")
+	apiRouter.HandleFunc(routeReminderSnooze, p.extractUserMiddleWare(p.handleReminderSnooze, true)).Methods("POST")
+	apiRouter.HandleFunc(routeReminderDone, p.extractUserMiddleWare(p.handleReminderDone, true)).Methods("POST")
 }
 
 func (p *Plugin) ServeHTTP(c *plugin.Context, w http.ResponseWriter, r *http.Request) {
@@ -95,6 +102,23 @@ func (p *Plugin) handleAddBookmark(w http.ResponseWriter, r *http.Request, userI
 		return respondErr(w, http.StatusInternalServerError, err)
 	}
 
+	// check access before any new label is stored
+	if _, err = bmarks.GetBookmark(bmark.PostID); err != nil {
+		var post *model.Post
+		post, err = pluginapi.GetPost(bmark.PostID)
+		if err != nil {
+			return respondErr(w, http.StatusBadRequest, err)
+		}
+		var readable bool
+		readable, err = bookmarks.CanReadChannel(pluginapi, userID, post.ChannelId)
+		if err != nil {
+			return respondErr(w, http.StatusInternalServerError, err)
+		}
+		if !readable {
+			return respondErr(w, http.StatusForbidden, bookmarks.ErrPostNotAccessible)
+		}
+	}
+
 	l, err := bookmarks.NewLabelsWithUser(pluginapi, userID)
 	if err != nil {
 		return respondErr(w, http.StatusInternalServerError, err)
@@ -103,19 +127,24 @@ func (p *Plugin) handleAddBookmark(w http.ResponseWriter, r *http.Request, userI
 
 	var newIDs []string
 	for _, id := range ids {
-		label := l.ByID[id]
-		// if doesn't exist, this is a name and needs to be added to the labels
-		// store.  also save the id to the bookmark, not the name
-		if label == nil {
+		if _, ok := l.ByID[id]; ok {
+			newIDs = append(newIDs, id)
+			continue
+		}
+
+		// if doesn't exist, this is a name or an alias. Names of new labels
+		// need to be added to the labels store.  also save the id to the
+		// bookmark, not the name
+		labelID, idErr := l.GetIDFromName(id)
+		if idErr != nil {
 			var labelNew *bookmarks.Label
 			labelNew, err = l.AddLabel(id)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return respondErr(w, http.StatusBadRequest, err)
 			}
-			newIDs = append(newIDs, labelNew.ID)
-			continue
+			labelID = labelNew.ID
 		}
-		newIDs = append(newIDs, id)
+		newIDs = append(newIDs, labelID)
 	}
 
 	// update bmark with UUID values, not the names
@@ -178,7 +207,12 @@ func (p *Plugin) handleViewBookmarks(w http.ResponseWriter, r *http.Request, use
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 
-	text, err := bmarks.GetBmarksEphemeralText(userID, nil)
+	options, err := getListOptions(r.URL.Query())
+	if err != nil {
+		return respondErr(w, http.StatusBadRequest, err)
+	}
+
+	text, err := bmarks.GetBmarksEphemeralText(userID, nil, options)
 	if err != nil {
 		return respondErr(w, http.StatusInternalServerError, err)
 	}
@@ -193,6 +227,43 @@ func (p *Plugin) handleViewBookmarks(w http.ResponseWriter, r *http.Request, use
 	return http.StatusOK, nil
 }
 
+// getListOptions returns the order and page of a bookmarks listing from the
+// sort, reverse, limit, and page query parameters
+func getListOptions(query url.Values) (*bookmarks.ListOptions, error) {
+	options := bookmarks.NewListOptions()
+
+	if sortBy := query.Get("sort"); sortBy != "" {
+		options.SortBy = sortBy
+	}
+
+	var err error
+	if reverse := query.Get("reverse"); reverse != "" {
+		options.Reverse, err = strconv.ParseBool(reverse)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid reverse parameter")
+		}
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		options.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid limit parameter")
+		}
+	}
+
+	if page := query.Get("page"); page != "" {
+		options.Page, err = strconv.Atoi(page)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid page parameter")
+		}
+	}
+
+	if err = options.IsValid(); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
 // handleGetBookmark returns a bookmark
 func (p *Plugin) handleGetBookmark(w http.ResponseWriter, r *http.Request, userID string) (int, error) {
 	query := r.URL.Query()
@@ -265,6 +336,23 @@ func (p *Plugin) handleAutoCompleteBookmarks(w http.ResponseWriter, r *http.Requ
 	return respondJSON(w, out)
 }
 
+// handleAutoCompleteCollections returns all autocomplete collection names
+func (p *Plugin) handleAutoCompleteCollections(w http.ResponseWriter, r *http.Request, userID string) (int, error) {
+	pluginapi := pluginapi.New(p.API)
+	collections, err := bookmarks.NewCollectionsWithUser(pluginapi, userID)
+	if err != nil {
+		return respondErr(w, http.StatusInternalServerError, err)
+	}
+
+	out := []model.AutocompleteListItem{}
+	for name := range collections.ByName {
+		out = append(out, model.AutocompleteListItem{
+			Item: name,
+		})
+	}
+	return respondJSON(w, out)
+}
+
 func respondErr(w http.ResponseWriter, code int, err error) (int, error) {
 	http.Error(w, err.Error(), code)
 	return code, err
@@ -341,3 +429,24 @@ func (p *Plugin) handleErrorWithCode(w http.ResponseWriter, code int, errTitle s
 	})
 	_, _ = w.Write(b)
 }
+
+// handleFlaggedSync applies the posts flagged and unflagged in Mattermost to
+// the bookmarks of a user who syncs them. The webapp calls it when the flagged
+// posts of the user change
+func (p *Plugin) handleFlaggedSync(w http.ResponseWriter, r *http.Request, userID string) (int, error) {
+	result, err := bookmarks.SyncFlaggedPostsWithUser(pluginapi.New(p.API), userID)
+	if err != nil {
+		return respondErr(w, http.StatusInternalServerError, err)
+	}
+
+	resp, err := json.Marshal(result)
+	if err != nil {
+		return respondErr(w, http.StatusInternalServerError, err)
+	}
+
+	_, err = w.Write(resp)
+	if err != nil {
+		return respondErr(w, http.StatusInternalServerError, err)
+	}
+	return http.StatusOK, nil
+}
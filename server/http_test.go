@@ -3,6 +3,7 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -98,6 +99,14 @@ func TestHandleAddBookmark(t *testing.T) {
 		PostID:   "ID3",
 		LabelIDs: []string{"newLabel"},
 	}
+	b4 := bookmarks.Bookmark{
+		PostID:   "PrivatePostID",
+		LabelIDs: []string{"newLabel"},
+	}
+	b5 := bookmarks.Bookmark{
+		PostID:   "ID3",
+		LabelIDs: []string{"projects//apollo"},
+	}
 
 	type bmarkWithChannel struct {
 		Bookmark  *bookmarks.Bookmark `json:"bookmark"`
@@ -110,6 +119,7 @@ func TestHandleAddBookmark(t *testing.T) {
 		userID              string
 		bookmark            *bookmarks.Bookmark
 		bookmarks           *bookmarks.Bookmarks
+		noChannelAccess     bool
 		expectedCode        int
 		expectedMsgPrefix   string
 		expectedContains    []string
@@ -149,6 +159,19 @@ func TestHandleAddBookmark(t *testing.T) {
 			expectedContains: []string{
 				fmt.Sprintf("[:link:](https://myhost.com/_redirect/pl/%v) `newLabel` **_PostID-Title_**", b3.PostID)},
 		},
+		"bookmark contains a label name that is not valid": {
+			userID:       UserID,
+			bookmark:     &b5,
+			bookmarks:    bmarks,
+			expectedCode: http.StatusBadRequest,
+		},
+		"post in a channel the user cannot read": {
+			userID:          UserID,
+			bookmark:        &b4,
+			bookmarks:       bmarks,
+			noChannelAccess: true,
+			expectedCode:    http.StatusForbidden,
+		},
 	}
 
 	for name, tt := range tests {
@@ -165,15 +188,22 @@ func TestHandleAddBookmark(t *testing.T) {
 			}
 			jsonBmark, err := json.Marshal(bWithChannel)
 			assert.Nil(t, err)
-			jsonBmarks, err := json.Marshal(tt.bookmarks)
-			assert.Nil(t, err)
-
 			siteURL := "https://myhost.com"
 
 			api.On("KVSet", mock.Anything, mock.Anything).Return(nil)
-			api.On("KVGet", bookmarks.GetBookmarksKey(UserID)).Return(jsonBmarks, nil)
+			api.On("KVCompareAndSet", mock.Anything, mock.Anything, mock.Anything).Return(true, nil)
+			mockBookmarksKV(t, api, tt.bookmarks)
 			api.On("KVGet", bookmarks.GetLabelsKey(UserID)).Return(nil, nil)
+			api.On("KVGet", bookmarks.GetSettingsKey(UserID)).Return(nil, nil)
 			api.On("GetPost", tt.bookmark.PostID).Return(&model.Post{Message: "this is the post.Message"}, nil)
+			if tt.noChannelAccess {
+				api.On("GetChannelMember", mock.Anything, UserID).Return(nil, &model.AppError{StatusCode: http.StatusNotFound})
+				api.On("GetChannel", mock.Anything).Return(&model.Channel{Type: model.CHANNEL_PRIVATE}, nil)
+				api.On("HasPermissionToChannel", UserID, mock.Anything, model.PERMISSION_READ_CHANNEL).Return(false)
+			}
+			api.On("GetChannelMember", mock.Anything, UserID).Return(&model.ChannelMember{}, nil)
+			api.On("GetUser", mock.Anything).Return(&model.User{Username: "author"}, nil)
+			api.On("GetChannel", mock.Anything).Return(&model.Channel{DisplayName: "Town Square"}, nil)
 
 			api.On("GetConfig", mock.Anything).Return(&model.Config{ServiceSettings: model.ServiceSettings{SiteURL: &siteURL}})
 			// mockPluginAPI.EXPECT().KVSet(bookmarks.GetBookmarksKey(UserID), gomock.Any)
@@ -215,10 +245,44 @@ func TestHandleAddBookmark(t *testing.T) {
 			result := w.Result()
 			assert.NotNil(t, result)
 			assert.Equal(t, tt.expectedCode, result.StatusCode)
+
+			// labels are only created for bookmarks that are added
+			if tt.noChannelAccess {
+				api.AssertNotCalled(t, "KVCompareAndSet", bookmarks.GetLabelsKey(UserID), mock.Anything, mock.Anything)
+			}
 		})
 	}
 }
 
+// mockBookmarksKV mocks the KV values of bookmarks stored individually with an
+// index of their IDs. A nil bmarks mocks a user without bookmarks
+func mockBookmarksKV(t *testing.T, api *plugintest.API, bmarks *bookmarks.Bookmarks) {
+	var jsonIndex []byte
+	if bmarks != nil {
+		ids := []string{}
+		for id, bmark := range bmarks.ByID {
+			jsonBmark, err := json.Marshal(bmark)
+			assert.Nil(t, err)
+			api.On("KVGet", bookmarks.GetBookmarkKey(UserID, id)).Return(jsonBmark, nil)
+			ids = append(ids, id)
+		}
+
+		var err error
+		jsonIndex, err = json.Marshal(ids)
+		assert.Nil(t, err)
+	}
+
+	api.On("KVGet", bookmarks.GetBookmarksIndexKey(UserID)).Return(jsonIndex, nil)
+	api.On("KVGet", bookmarks.GetBookmarksKey(UserID)).Return(nil, nil)
+
+	// no other user bookmarked the posts
+	isPostBookmarksKey := mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, bookmarks.StorePostBookmarksKey+"_")
+	})
+	api.On("KVGet", isPostBookmarksKey).Return(nil, nil)
+	api.On("KVSetWithOptions", isPostBookmarksKey, mock.Anything, mock.Anything).Return(true, nil)
+}
+
 //nolint
 func makePlugin(api *plugintest.API) *Plugin {
 	p := &Plugin{}
@@ -251,10 +315,7 @@ func TestHandleGetBookmark(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			jsonBmark, err := json.Marshal(bookmark)
 			assert.Nil(t, err)
-			jsonBmarks, err := json.Marshal(bmarks)
-			assert.Nil(t, err)
-
-			api.On("KVGet", bookmarks.GetBookmarksKey(UserID)).Return(jsonBmarks, nil)
+			mockBookmarksKV(t, api, bmarks)
 
 			r := httptest.NewRequest(http.MethodGet, "/api/v1/get?postID=ID1", strings.NewReader(string(jsonBmark)))
 			r.Header.Add("Mattermost-User-Id", tt.userID)
@@ -275,6 +336,7 @@ func TestHandleViewBookmarks(t *testing.T) {
 
 	tests := map[string]struct {
 		userID       string
+		query        string
 		bookmark     *bookmarks.Bookmark
 		bookmarks    *bookmarks.Bookmarks
 		expectedCode int
@@ -290,6 +352,27 @@ func TestHandleViewBookmarks(t *testing.T) {
 			bookmarks:    bmarks,
 			expectedCode: http.StatusOK,
 		},
+		"sorted page": {
+			userID:       UserID,
+			query:        "?sort=title&reverse=true&limit=2&page=2",
+			bookmark:     bmarks.ByID["ID1"],
+			bookmarks:    bmarks,
+			expectedCode: http.StatusOK,
+		},
+		"unknown sort": {
+			userID:       UserID,
+			query:        "?sort=color",
+			bookmark:     bmarks.ByID["ID1"],
+			bookmarks:    bmarks,
+			expectedCode: http.StatusBadRequest,
+		},
+		"invalid limit": {
+			userID:       UserID,
+			query:        "?limit=all",
+			bookmark:     bmarks.ByID["ID1"],
+			bookmarks:    bmarks,
+			expectedCode: http.StatusBadRequest,
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -301,13 +384,14 @@ func TestHandleViewBookmarks(t *testing.T) {
 
 			siteURL := "https://myhost.com"
 
-			api.On("KVGet", bookmarks.GetBookmarksKey(UserID)).Return(jsonBmarks, nil)
+			mockBookmarksKV(t, api, tt.bookmarks)
 			api.On("KVGet", bookmarks.GetLabelsKey(UserID)).Return(nil, nil)
 
 			api.On("GetConfig", mock.Anything).Return(&model.Config{ServiceSettings: model.ServiceSettings{SiteURL: &siteURL}})
 			api.On("GetPost", mock.Anything).Return(&model.Post{Message: "this is the post.Message"}, nil)
+			api.On("GetChannelMember", mock.Anything, UserID).Return(&model.ChannelMember{}, nil)
 
-			r := httptest.NewRequest(http.MethodPost, "/api/v1/view", strings.NewReader(string(jsonBmarks)))
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/view"+tt.query, strings.NewReader(string(jsonBmarks)))
 			r.Header.Add("Mattermost-User-Id", tt.userID)
 
 			if tt.expectedCode == http.StatusOK {
@@ -409,6 +493,7 @@ func TestHandleLabelsAdd(t *testing.T) {
 
 			siteURL := "https://myhost.com"
 			api.On("KVSet", mock.Anything, mock.Anything).Return(nil)
+			api.On("KVCompareAndSet", mock.Anything, mock.Anything, mock.Anything).Return(true, nil)
 			api.On("KVGet", bookmarks.GetLabelsKey(UserID)).Return(nil, nil)
 			api.On("GetConfig", mock.Anything).Return(&model.Config{ServiceSettings: model.ServiceSettings{SiteURL: &siteURL}})
 
@@ -426,6 +511,101 @@ func TestHandleLabelsAdd(t *testing.T) {
 	}
 }
 
+func TestHandleFlaggedSync(t *testing.T) {
+	tests := map[string]struct {
+		userID         string
+		syncFlagged    bool
+		expectedCode   int
+		expectedResult *bookmarks.FlagSyncResult
+	}{
+		"Unauthed User": {
+			expectedCode: http.StatusUnauthorized,
+		},
+		"User does not sync flagged posts": {
+			userID:         UserID,
+			expectedCode:   http.StatusOK,
+			expectedResult: &bookmarks.FlagSyncResult{},
+		},
+		"User flagged and unflagged posts": {
+			userID:         UserID,
+			syncFlagged:    true,
+			expectedCode:   http.StatusOK,
+			expectedResult: &bookmarks.FlagSyncResult{Added: 1, Removed: 1},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			api := makeAPIMock()
+			p := makePlugin(api)
+			mockBookmarksKV(t, api, getHTTPTestBookmarks())
+
+			jsonSettings, err := json.Marshal(&bookmarks.Settings{SyncFlagged: tt.syncFlagged})
+			assert.Nil(t, err)
+			api.On("KVGet", bookmarks.GetSettingsKey(UserID)).Return(jsonSettings, nil)
+			api.On("KVGet", bookmarks.GetLabelsKey(UserID)).Return(nil, nil)
+
+			// ID1 was unflagged and ID5 was flagged since the last sync
+			api.On("KVGet", bookmarks.GetFlaggedPostsKey(UserID)).Return([]byte(`["ID1","ID2"]`), nil)
+			api.On("GetPreferencesForUser", UserID).Return([]model.Preference{
+				{UserId: UserID, Category: model.PREFERENCE_CATEGORY_FLAGGED_POST, Name: "ID2", Value: "true"},
+				{UserId: UserID, Category: model.PREFERENCE_CATEGORY_FLAGGED_POST, Name: "ID5", Value: "true"},
+			}, nil)
+			api.On("GetPost", "ID5").Return(&model.Post{Id: "ID5", Message: "flagged", UserId: "authorID", ChannelId: "channelID"}, nil)
+			api.On("GetChannelMember", "channelID", UserID).Return(&model.ChannelMember{}, nil)
+			api.On("GetUser", "authorID").Return(&model.User{Username: "author"}, nil)
+			api.On("GetChannel", "channelID").Return(&model.Channel{DisplayName: "Town Square"}, nil)
+			api.On("KVCompareAndSet", bookmarks.GetFlaggedPostsKey(UserID), []byte(`["ID1","ID2"]`), []byte(`["ID2","ID5"]`)).Return(true, nil)
+			api.On("KVCompareAndSet", mock.Anything, mock.Anything, mock.Anything).Return(true, nil)
+			api.On("KVSetWithOptions", bookmarks.GetBookmarkKey(UserID, "ID1"), mock.Anything, mock.Anything).Return(true, nil)
+			api.On("KVSet", bookmarks.GetBookmarkKey(UserID, "ID5"), mock.Anything).Return(nil)
+
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/flagged/sync", nil)
+			r.Header.Add("Mattermost-User-Id", tt.userID)
+
+			p.initialiseAPI()
+			w := httptest.NewRecorder()
+			p.ServeHTTP(&plugin.Context{}, w, r)
+
+			result := w.Result()
+			assert.Equal(t, tt.expectedCode, result.StatusCode)
+			if tt.expectedResult != nil {
+				var actual bookmarks.FlagSyncResult
+				assert.Nil(t, json.NewDecoder(result.Body).Decode(&actual))
+				assert.Equal(t, tt.expectedResult, &actual)
+			}
+		})
+	}
+}
+
+func TestExecuteCommand_syncsFlaggedPosts(t *testing.T) {
+	api := makeAPIMock()
+	p := makePlugin(api)
+	mockBookmarksKV(t, api, getHTTPTestBookmarks())
+
+	api.On("KVGet", bookmarks.GetSettingsKey(UserID)).Return([]byte(`{"sync_flagged":true}`), nil)
+	api.On("KVGet", bookmarks.GetLabelsKey(UserID)).Return(nil, nil)
+
+	// ID5 was flagged in an app without the webapp plugin since the last sync
+	api.On("KVGet", bookmarks.GetFlaggedPostsKey(UserID)).Return([]byte(`["ID1"]`), nil)
+	api.On("GetPreferencesForUser", UserID).Return([]model.Preference{
+		{UserId: UserID, Category: model.PREFERENCE_CATEGORY_FLAGGED_POST, Name: "ID1", Value: "true"},
+		{UserId: UserID, Category: model.PREFERENCE_CATEGORY_FLAGGED_POST, Name: "ID5", Value: "true"},
+	}, nil)
+	api.On("GetPost", "ID5").Return(&model.Post{Id: "ID5", Message: "flagged", UserId: "authorID", ChannelId: "channelID"}, nil)
+	api.On("GetChannelMember", "channelID", UserID).Return(&model.ChannelMember{}, nil)
+	api.On("GetUser", "authorID").Return(&model.User{Username: "author"}, nil)
+	api.On("GetChannel", "channelID").Return(&model.Channel{DisplayName: "Town Square"}, nil)
+	api.On("KVCompareAndSet", bookmarks.GetFlaggedPostsKey(UserID), []byte(`["ID1"]`), []byte(`["ID1","ID5"]`)).Return(true, nil)
+	api.On("KVCompareAndSet", mock.Anything, mock.Anything, mock.Anything).Return(true, nil)
+	api.On("KVSet", bookmarks.GetBookmarkKey(UserID, "ID5"), mock.Anything).Return(nil)
+	api.On("SendEphemeralPost", UserID, mock.Anything).Return(nil)
+
+	_, appErr := p.ExecuteCommand(&plugin.Context{}, &model.CommandArgs{UserId: UserID, Command: "/bookmarks help"})
+	assert.Nil(t, appErr)
+	api.AssertCalled(t, "KVSet", bookmarks.GetBookmarkKey(UserID, "ID5"), mock.Anything)
+	api.AssertCalled(t, "KVCompareAndSet", bookmarks.GetFlaggedPostsKey(UserID), []byte(`["ID1"]`), []byte(`["ID1","ID5"]`))
+}
+
 func makeAPIMock() *plugintest.API {
 	api := &plugintest.API{}
 
@@ -433,5 +613,43 @@ func makeAPIMock() *plugintest.API {
 	api.On("LogWarn", mock.Anything, mock.Anything, mock.Anything).Maybe()
 	api.On("LogError", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
 
+	// the server does not return several posts in one request, so each post
+	// is requested with GetPost
+	api.On("PluginHTTP", mock.Anything).Return(&http.Response{
+		StatusCode: http.StatusNotFound,
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+	}).Maybe()
+
 	return api
 }
+
+func TestHandleViewBookmarks_archived(t *testing.T) {
+	bmarks := getHTTPTestBookmarks()
+	bmarks.ByID[p2ID].Status = bookmarks.StatusArchived
+
+	api := makeAPIMock()
+	p := makePlugin(api)
+
+	siteURL := "https://myhost.com"
+	mockBookmarksKV(t, api, bmarks)
+	api.On("KVGet", bookmarks.GetLabelsKey(UserID)).Return(nil, nil)
+	api.On("GetConfig", mock.Anything).Return(&model.Config{ServiceSettings: model.ServiceSettings{SiteURL: &siteURL}})
+	api.On("GetPost", mock.Anything).Return(&model.Post{Message: "this is the post.Message"}, nil)
+	api.On("GetChannelMember", mock.Anything, UserID).Return(&model.ChannelMember{}, nil)
+
+	var message string
+	api.On("SendEphemeralPost", UserID, mock.Anything).Return(&model.Post{}).Run(func(args mock.Arguments) {
+		message = args.Get(1).(*model.Post).Message
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/view", strings.NewReader(`{"channelId":"channelID"}`))
+	r.Header.Add("Mattermost-User-Id", UserID)
+
+	p.initialiseAPI()
+	w := httptest.NewRecorder()
+	p.ServeHTTP(&plugin.Context{}, w, r)
+
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	assert.Contains(t, message, "/pl/"+p1ID+")")
+	assert.NotContains(t, message, "/pl/"+p2ID+")")
+}
@@ -4,6 +4,7 @@ import (
 	"sync"
 
 	"github.com/gorilla/mux"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
 	"github.com/jfrerich/mattermost-plugin-bookmarks/server/command"
 	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi"
 	"github.com/mattermost/mattermost-server/v5/model"
@@ -27,6 +28,10 @@ type Plugin struct {
 	BotUserID string
 
 	router *mux.Router
+
+	// stopScheduler stops sending the due reminders and digests when the
+	// plugin deactivates
+	stopScheduler chan struct{}
 }
 
 // OnActivate runs when the plugin activates and ensures the plugin is properly
@@ -49,11 +54,107 @@ func (p *Plugin) OnActivate() error {
 	}
 	p.BotUserID = botID
 
+	// bookmarks that are not migrated here are migrated when the user next
+	// loads them, so a failure does not prevent activation
+	migrated, err := bookmarks.MigrateBookmarks(pluginapi.New(p.API))
+	if err != nil {
+		p.API.LogError("Failed to migrate bookmarks to per-bookmark keys", "err", err.Error())
+	}
+	if len(migrated) > 0 {
+		p.API.LogInfo("Migrated bookmarks to per-bookmark keys", "users", len(migrated))
+	}
+
+	go p.upgradeSchema()
+	go p.buildPostBookmarks()
+	go p.reportDuplicateLabels()
+
+	p.stopScheduler = make(chan struct{})
+	go p.runScheduler(p.stopScheduler)
+
 	// return p.API.RegisterCommand(createBookmarksCommand())
 	command.Register(p.API.RegisterCommand)
 	return nil
 }
 
+// OnDeactivate stops sending reminders and digests
+func (p *Plugin) OnDeactivate() error {
+	if p.stopScheduler != nil {
+		close(p.stopScheduler)
+		p.stopScheduler = nil
+	}
+	return nil
+}
+
+// upgradeSchema upgrades stored bookmarks and labels to the current schema
+// version. Data is also upgraded as it is read, so activation does not wait
+// for the upgrade
+func (p *Plugin) upgradeSchema() {
+	dryRun := p.getConfiguration().SchemaUpgradeDryRun
+	p.API.LogInfo("Starting schema upgrade", "schema_version", bookmarks.SchemaVersion, "dry_run", dryRun)
+
+	result, err := bookmarks.UpgradeSchema(pluginapi.New(p.API), dryRun, p.API.LogInfo)
+	if err != nil {
+		p.API.LogError("Failed to upgrade schema", "err", err.Error())
+		return
+	}
+	p.API.LogInfo("Finished schema upgrade", "users", result.Users, "documents", result.Documents, "dry_run", dryRun)
+}
+
+// buildPostBookmarks records the users who bookmarked each post for bookmarks
+// stored before they were recorded, so the post hooks find them
+func (p *Plugin) buildPostBookmarks() {
+	users, err := bookmarks.BuildPostBookmarks(pluginapi.New(p.API))
+	if err != nil {
+		p.API.LogError("Failed to record the users who bookmarked each post", "err", err.Error())
+		return
+	}
+	if users > 0 {
+		p.API.LogInfo("Recorded the users who bookmarked each post", "users", users)
+	}
+}
+
+// reportDuplicateLabels tells users whose labels differ only in case how to
+// merge them. Such labels were stored before label names were normalized
+func (p *Plugin) reportDuplicateLabels() {
+	duplicates, err := bookmarks.FindDuplicateLabels(pluginapi.New(p.API))
+	if err != nil {
+		p.API.LogError("Failed to find duplicate labels", "err", err.Error())
+		return
+	}
+
+	for userID, groups := range duplicates {
+		channel, appErr := p.API.GetDirectChannel(userID, p.BotUserID)
+		if appErr != nil {
+			p.API.LogError("Failed to get the direct channel of a user with duplicate labels", "user_id", userID, "err", appErr.Error())
+			continue
+		}
+
+		post := &model.Post{
+			UserId:    p.BotUserID,
+			ChannelId: channel.Id,
+			Message: "#### Duplicate labels\n" +
+				"Label names are no longer case sensitive. These labels differ only in case:\n" +
+				bookmarks.GetDuplicatesText(groups),
+		}
+		if _, appErr = p.API.CreatePost(post); appErr != nil {
+			p.API.LogError("Failed to tell a user about duplicate labels", "user_id", userID, "err", appErr.Error())
+		}
+	}
+}
+
+// MessageHasBeenUpdated marks the bookmarks of an edited post. The server has
+// no hook for deleted posts. Bookmarks of a deleted post are displayed as
+// deleted when the post is no longer found
+func (p *Plugin) MessageHasBeenUpdated(c *plugin.Context, newPost, oldPost *model.Post) {
+	if newPost.Message == oldPost.Message {
+		return
+	}
+
+	if err := bookmarks.MarkPostEdited(pluginapi.New(p.API), newPost); err != nil {
+		p.API.LogError("Failed to mark bookmarks of an edited post", "post_id", newPost.Id, "err", err.Error())
+	}
+}
+
 // GetSiteURL returns the SiteURL from the config settings
 func (p *Plugin) GetSiteURL() string {
 	ptr := p.API.GetConfig().ServiceSettings.SiteURL
@@ -66,11 +167,20 @@ func (p *Plugin) GetSiteURL() string {
 // ExecuteCommand executes a command that has been previously registered via the RegisterCommand API.
 func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
 	pluginapi := pluginapi.New(p.API)
+
+	// posts flagged since the last command are bookmarked before the command
+	// runs, also when the user flagged them in an app that does not run the
+	// webapp plugin
+	if _, err := bookmarks.SyncFlaggedPostsWithUser(pluginapi, args.UserId); err != nil {
+		p.API.LogError("Failed to sync flagged posts", "user_id", args.UserId, "err", err.Error())
+	}
+
 	command := command.Command{
 		Context:   c,
 		Args:      args,
 		ChannelID: args.ChannelId,
 		API:       pluginapi,
+		BotUserID: p.GetBotID(),
 	}
 
 	out := command.Handle()
@@ -1,19 +1,51 @@
 package pluginapi
 
 import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
 	"github.com/mattermost/mattermost-server/v5/model"
 	"github.com/mattermost/mattermost-server/v5/plugin"
+	"github.com/pkg/errors"
 )
 
 type api struct {
 	papi plugin.API
+
+	// noBatchPosts is set once the server did not serve a request for
+	// several posts
+	noBatchPosts int32
 }
 
 type API interface {
 	GetPost(postID string) (*model.Post, error)
+	GetPostsByIds(postIDs []string) (map[string]*model.Post, error)
+	GetChannel(channelID string) (*model.Channel, error)
+	GetChannelByName(teamID, name string) (*model.Channel, error)
+	GetTeamByName(name string) (*model.Team, error)
+	GetUserByUsername(username string) (*model.User, error)
+	GetUser(userID string) (*model.User, error)
+	GetFileInfo(fileID string) (*model.FileInfo, error)
+	GetFile(fileID string) ([]byte, error)
+	GetPreferencesForUser(userID string) ([]model.Preference, error)
+	UpdatePreferencesForUser(userID string, preferences []model.Preference) error
+	DeletePreferencesForUser(userID string, preferences []model.Preference) error
+	GetChannelMember(channelID, userID string) (*model.ChannelMember, error)
+	HasPermissionToChannel(userID, channelID string, permission *model.Permission) bool
 	GetConfig() *model.Config
+	GetDirectChannel(userID1, userID2 string) (*model.Channel, error)
+	UploadFile(data []byte, channelID string, filename string) (*model.FileInfo, error)
+	CreatePost(post *model.Post) (*model.Post, error)
 	KVSet(key string, value []byte) error
+	KVCompareAndSet(key string, oldValue, newValue []byte) (bool, error)
+	KVSetWithOptions(key string, value []byte, options model.PluginKVSetOptions) (bool, error)
 	KVGet(key string) ([]byte, error)
+	KVDelete(key string) error
+	KVList(page, perPage int) ([]string, error)
+	LogWarn(msg string, keyValuePairs ...interface{})
 }
 
 func New(a plugin.API) API {
@@ -30,6 +62,251 @@ func (a *api) GetPost(postID string) (*model.Post, error) {
 	return p, nil
 }
 
+// IsNotFound returns true if err is the error returned by the server for a
+// resource that does not exist
+func IsNotFound(err error) bool {
+	appErr, ok := err.(*model.AppError)
+	return ok && appErr.StatusCode == http.StatusNotFound
+}
+
+// getPostsConcurrency is the number of posts requested at once when the
+// server cannot return them in a single request
+const getPostsConcurrency = 16
+
+// getPostsByIdsPath is the REST API route returning several posts in one
+// request
+const getPostsByIdsPath = "/api/v4/posts/ids"
+
+// GetPostsByIds returns the posts with the given IDs by post ID, requesting
+// them from the server in a single request. Servers that do not serve the
+// request to a plugin get each post requested concurrently instead. Posts that
+// do not exist or were deleted are left out
+func (a *api) GetPostsByIds(postIDs []string) (map[string]*model.Post, error) {
+	var ids []string
+	requested := make(map[string]bool, len(postIDs))
+	for _, postID := range postIDs {
+		if requested[postID] {
+			continue
+		}
+		requested[postID] = true
+		ids = append(ids, postID)
+	}
+	if len(ids) == 0 {
+		return map[string]*model.Post{}, nil
+	}
+
+	if atomic.LoadInt32(&a.noBatchPosts) == 0 {
+		posts, ok, err := a.getPostsBatch(ids)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return posts, nil
+		}
+		// the server is not asked again until the plugin is restarted
+		atomic.StoreInt32(&a.noBatchPosts, 1)
+	}
+
+	return a.getPostsConcurrently(ids)
+}
+
+// getPostsBatch requests posts from the server in a single request through
+// PluginHTTP. The returned bool is false if the server does not serve the
+// request
+func (a *api) getPostsBatch(postIDs []string) (map[string]*model.Post, bool, error) {
+	body, err := json.Marshal(postIDs)
+	if err != nil {
+		return nil, false, err
+	}
+	req, err := http.NewRequest(http.MethodPost, getPostsByIdsPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := a.papi.PluginHTTP(req)
+	if resp == nil {
+		return nil, false, nil
+	}
+	if resp.Body != nil {
+		defer resp.Body.Close()
+	}
+	if resp.StatusCode != http.StatusOK || resp.Body == nil {
+		return nil, false, nil
+	}
+
+	var list []*model.Post
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, false, errors.Wrap(err, "Unable to decode the requested posts")
+	}
+
+	posts := make(map[string]*model.Post, len(list))
+	for _, p := range list {
+		if p.DeleteAt != 0 {
+			continue
+		}
+		posts[p.Id] = p
+	}
+	return posts, true, nil
+}
+
+// getPostsConcurrently requests each post on its own, getPostsConcurrency
+// posts at once
+func (a *api) getPostsConcurrently(postIDs []string) (map[string]*model.Post, error) {
+	posts := make(map[string]*model.Post, len(postIDs))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var firstErr error
+	sem := make(chan struct{}, getPostsConcurrency)
+
+	for _, postID := range postIDs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(postID string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			p, appErr := a.papi.GetPost(postID)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if appErr != nil {
+				if appErr.StatusCode == http.StatusNotFound {
+					return
+				}
+				if firstErr == nil {
+					firstErr = appErr
+				}
+				return
+			}
+			posts[postID] = p
+		}(postID)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return posts, nil
+}
+
+func (a *api) GetChannel(channelID string) (*model.Channel, error) {
+	c, appErr := a.papi.GetChannel(channelID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return c, nil
+}
+
+func (a *api) GetChannelByName(teamID, name string) (*model.Channel, error) {
+	c, appErr := a.papi.GetChannelByName(teamID, name, false)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return c, nil
+}
+
+func (a *api) GetTeamByName(name string) (*model.Team, error) {
+	t, appErr := a.papi.GetTeamByName(name)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return t, nil
+}
+
+func (a *api) GetUserByUsername(username string) (*model.User, error) {
+	u, appErr := a.papi.GetUserByUsername(username)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return u, nil
+}
+
+func (a *api) GetUser(userID string) (*model.User, error) {
+	u, appErr := a.papi.GetUser(userID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return u, nil
+}
+
+func (a *api) GetFileInfo(fileID string) (*model.FileInfo, error) {
+	f, appErr := a.papi.GetFileInfo(fileID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return f, nil
+}
+
+func (a *api) GetFile(fileID string) ([]byte, error) {
+	data, appErr := a.papi.GetFile(fileID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return data, nil
+}
+
+func (a *api) GetPreferencesForUser(userID string) ([]model.Preference, error) {
+	preferences, appErr := a.papi.GetPreferencesForUser(userID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return preferences, nil
+}
+
+func (a *api) UpdatePreferencesForUser(userID string, preferences []model.Preference) error {
+	appErr := a.papi.UpdatePreferencesForUser(userID, preferences)
+	if appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+func (a *api) DeletePreferencesForUser(userID string, preferences []model.Preference) error {
+	appErr := a.papi.DeletePreferencesForUser(userID, preferences)
+	if appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+func (a *api) GetChannelMember(channelID, userID string) (*model.ChannelMember, error) {
+	m, appErr := a.papi.GetChannelMember(channelID, userID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return m, nil
+}
+
+func (a *api) HasPermissionToChannel(userID, channelID string, permission *model.Permission) bool {
+	return a.papi.HasPermissionToChannel(userID, channelID, permission)
+}
+
+func (a *api) GetDirectChannel(userID1, userID2 string) (*model.Channel, error) {
+	c, appErr := a.papi.GetDirectChannel(userID1, userID2)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return c, nil
+}
+
+func (a *api) UploadFile(data []byte, channelID string, filename string) (*model.FileInfo, error) {
+	f, appErr := a.papi.UploadFile(data, channelID, filename)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return f, nil
+}
+
+func (a *api) CreatePost(post *model.Post) (*model.Post, error) {
+	p, appErr := a.papi.CreatePost(post)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return p, nil
+}
+
 func (a *api) KVSet(key string, value []byte) error {
 	appErr := a.papi.KVSet(key, value)
 	if appErr != nil {
@@ -38,6 +315,22 @@ func (a *api) KVSet(key string, value []byte) error {
 	return nil
 }
 
+func (a *api) KVCompareAndSet(key string, oldValue, newValue []byte) (bool, error) {
+	ok, appErr := a.papi.KVCompareAndSet(key, oldValue, newValue)
+	if appErr != nil {
+		return false, appErr
+	}
+	return ok, nil
+}
+
+func (a *api) KVSetWithOptions(key string, value []byte, options model.PluginKVSetOptions) (bool, error) {
+	ok, appErr := a.papi.KVSetWithOptions(key, value, options)
+	if appErr != nil {
+		return false, appErr
+	}
+	return ok, nil
+}
+
 func (a *api) KVGet(key string) ([]byte, error) {
 	value, appErr := a.papi.KVGet(key)
 	if appErr != nil {
@@ -46,6 +339,26 @@ func (a *api) KVGet(key string) ([]byte, error) {
 	return value, nil
 }
 
+func (a *api) KVDelete(key string) error {
+	appErr := a.papi.KVDelete(key)
+	if appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+func (a *api) KVList(page, perPage int) ([]string, error) {
+	keys, appErr := a.papi.KVList(page, perPage)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return keys, nil
+}
+
 func (a *api) GetConfig() *model.Config {
 	return a.papi.GetConfig()
 }
+
+func (a *api) LogWarn(msg string, keyValuePairs ...interface{}) {
+	a.papi.LogWarn(msg, keyValuePairs...)
+}
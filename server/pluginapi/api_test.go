@@ -0,0 +1,107 @@
+package pluginapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetPostsByIds(t *testing.T) {
+	tests := map[string]struct {
+		postIDs       []string
+		batchPosts    []*model.Post // posts returned by the server in one request, nil if it does not serve it
+		expectedPosts map[string]*model.Post
+		numCalls      int
+		wantErrMsg    string
+	}{
+		"posts requested in one request": {
+			postIDs:    []string{"ID1", "ID2", "ID1", "deleted"},
+			batchPosts: []*model.Post{{Id: "ID1"}, {Id: "ID2"}, {Id: "deleted", DeleteAt: 1}},
+			expectedPosts: map[string]*model.Post{
+				"ID1": {Id: "ID1"},
+				"ID2": {Id: "ID2"},
+			},
+		},
+		"no posts": {
+			postIDs:       nil,
+			expectedPosts: map[string]*model.Post{},
+		},
+		"duplicate IDs are fetched once": {
+			postIDs: []string{"ID1", "ID2", "ID1"},
+			expectedPosts: map[string]*model.Post{
+				"ID1": {Id: "ID1"},
+				"ID2": {Id: "ID2"},
+			},
+			numCalls: 2,
+		},
+		"a deleted post is left out": {
+			postIDs: []string{"ID1", "deleted"},
+			expectedPosts: map[string]*model.Post{
+				"ID1": {Id: "ID1"},
+			},
+			numCalls: 2,
+		},
+		"a post cannot be fetched": {
+			postIDs:    []string{"ID1", "missing"},
+			wantErrMsg: "post not found",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			papi := &plugintest.API{}
+			papi.On("GetPost", "ID1").Return(&model.Post{Id: "ID1"}, nil)
+			papi.On("GetPost", "ID2").Return(&model.Post{Id: "ID2"}, nil)
+			papi.On("GetPost", "deleted").Return(nil, &model.AppError{Message: "post deleted", StatusCode: http.StatusNotFound})
+			papi.On("GetPost", "missing").Return(nil, &model.AppError{Message: "post not found"})
+			papi.On("PluginHTTP", mock.Anything).Return(func(req *http.Request) *http.Response {
+				if tt.batchPosts == nil {
+					return &http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(strings.NewReader(""))}
+				}
+
+				var ids []string
+				assert.Nil(t, json.NewDecoder(req.Body).Decode(&ids))
+				assert.Equal(t, "/api/v4/posts/ids", req.URL.Path)
+				assert.Len(t, ids, len(tt.batchPosts))
+
+				body, err := json.Marshal(tt.batchPosts)
+				assert.Nil(t, err)
+				return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(body))}
+			})
+
+			posts, err := New(papi).GetPostsByIds(tt.postIDs)
+			if tt.wantErrMsg != "" {
+				assert.Contains(t, err.Error(), tt.wantErrMsg)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expectedPosts, posts)
+			papi.AssertNumberOfCalls(t, "GetPost", tt.numCalls)
+		})
+	}
+}
+
+func TestGetPostsByIds_batchNotServed(t *testing.T) {
+	papi := &plugintest.API{}
+	papi.On("GetPost", "ID1").Return(&model.Post{Id: "ID1"}, nil)
+	papi.On("PluginHTTP", mock.Anything).Return(&http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(strings.NewReader(""))})
+
+	a := New(papi)
+	for i := 0; i < 2; i++ {
+		posts, err := a.GetPostsByIds([]string{"ID1"})
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]*model.Post{"ID1": {Id: "ID1"}}, posts)
+	}
+
+	// the server is only asked once for several posts
+	papi.AssertNumberOfCalls(t, "PluginHTTP", 1)
+	papi.AssertNumberOfCalls(t, "GetPost", 2)
+}
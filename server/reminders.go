@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/utils"
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	routeReminderSnooze = "/reminders/snooze"
+	routeReminderDone   = "/reminders/done"
+)
+
+// sendDueReminders sends the reminders that are due
+func (p *Plugin) sendDueReminders() {
+	sent, err := bookmarks.SendDueReminders(pluginapi.New(p.API), model.GetMillis(), p.sendReminder)
+	if err != nil {
+		p.API.LogError("Failed to send reminders", "err", err.Error())
+	}
+	if sent > 0 {
+		p.API.LogDebug("Sent reminders", "reminders", sent)
+	}
+}
+
+// sendReminder sends the user a direct message from the Bookmarks bot with a
+// link to the bookmarked post and its title, and buttons to snooze the
+// reminder or mark it done
+func (p *Plugin) sendReminder(userID string, bmarks *bookmarks.Bookmarks, bmark *bookmarks.Bookmark) error {
+	text, err := getReminderText(bmarks, bmark)
+	if err != nil {
+		return err
+	}
+
+	channel, appErr := p.API.GetDirectChannel(userID, p.BotUserID)
+	if appErr != nil {
+		return appErr
+	}
+
+	post := &model.Post{
+		UserId:    p.BotUserID,
+		ChannelId: channel.Id,
+	}
+	model.ParseSlackAttachment(post, []*model.SlackAttachment{getReminderAttachment(bmark.PostID, text, "")})
+	if _, appErr = p.API.CreatePost(post); appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+// getReminderText returns the link and title of a bookmark shown in a
+// reminder
+func getReminderText(bmarks *bookmarks.Bookmarks, bmark *bookmarks.Bookmark) (string, error) {
+	labelNames, err := bmarks.GetBmarkLabelNames(bmark)
+	if err != nil {
+		return "", err
+	}
+	return bmarks.GetBmarkTextOneLine(bmark, labelNames)
+}
+
+// getReminderAttachment returns the attachment of a reminder. A reminder that
+// was snoozed or marked done shows its status in place of the buttons
+func getReminderAttachment(postID, text, status string) *model.SlackAttachment {
+	attachment := &model.SlackAttachment{
+		Title: "Reminder",
+		Text:  text,
+	}
+	if status != "" {
+		attachment.Footer = status
+		return attachment
+	}
+
+	attachment.Actions = []*model.PostAction{
+		getReminderAction("Snooze 1 hour", routeReminderSnooze, postID, "in 1h"),
+		getReminderAction("Snooze until tomorrow", routeReminderSnooze, postID, "tomorrow"),
+		getReminderAction("Done", routeReminderDone, postID, ""),
+	}
+	return attachment
+}
+
+// getReminderAction returns a button of a reminder calling a route of the
+// plugin. Snooze buttons also carry the time the reminder is snoozed until
+func getReminderAction(name, route, postID, snooze string) *model.PostAction {
+	context := map[string]interface{}{"post_id": postID}
+	if snooze != "" {
+		context["snooze"] = snooze
+	}
+
+	return &model.PostAction{
+		Name: name,
+		Type: model.POST_ACTION_TYPE_BUTTON,
+		Integration: &model.PostActionIntegration{
+			URL:     fmt.Sprintf("/plugins/%s%s%s", manifest.Id, routeAPIPrefix, route),
+			Context: context,
+		},
+	}
+}
+
+// handleReminderSnooze sets the reminder of a bookmark again from one of the
+// snooze buttons of a reminder
+func (p *Plugin) handleReminderSnooze(w http.ResponseWriter, r *http.Request, userID string) (int, error) {
+	return p.handleReminderAction(w, r, userID, func(api pluginapi.API, bmarks *bookmarks.Bookmarks, request *model.PostActionIntegrationRequest) (string, error) {
+		snooze, _ := request.Context["snooze"].(string)
+		location, err := bookmarks.GetUserLocation(api, userID)
+		if err != nil {
+			return "", err
+		}
+
+		remindAt, err := bookmarks.ParseRemindAt(snooze, time.Now().In(location))
+		if err != nil {
+			return "", err
+		}
+		if err = bmarks.SetReminder(request.Context["post_id"].(string), utils.GetMillis(remindAt)); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Snoozed until %s", bookmarks.FormatRemindAt(utils.GetMillis(remindAt), location)), nil
+	})
+}
+
+// handleReminderDone removes the reminder of a bookmark from the done button
+// of a reminder
+func (p *Plugin) handleReminderDone(w http.ResponseWriter, r *http.Request, userID string) (int, error) {
+	return p.handleReminderAction(w, r, userID, func(api pluginapi.API, bmarks *bookmarks.Bookmarks, request *model.PostActionIntegrationRequest) (string, error) {
+		if err := bmarks.SetReminder(request.Context["post_id"].(string), 0); err != nil {
+			return "", err
+		}
+		return "Done", nil
+	})
+}
+
+// handleReminderAction applies a button of a reminder to the bookmark and
+// replaces the buttons with the status the action returns. Errors are shown
+// to the user in an ephemeral post
+func (p *Plugin) handleReminderAction(w http.ResponseWriter, r *http.Request, userID string, action func(api pluginapi.API, bmarks *bookmarks.Bookmarks, request *model.PostActionIntegrationRequest) (string, error)) (int, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return respondErr(w, http.StatusBadRequest, err)
+	}
+
+	var request *model.PostActionIntegrationRequest
+	if err = json.Unmarshal(body, &request); err != nil {
+		return respondErr(w, http.StatusBadRequest, err)
+	}
+	postID, ok := request.Context["post_id"].(string)
+	if !ok || postID == "" {
+		return respondJSON(w, &model.PostActionIntegrationResponse{EphemeralText: "The reminder has no bookmark"})
+	}
+
+	pluginapi := pluginapi.New(p.API)
+	bmarks, err := bookmarks.NewBookmarksWithUser(pluginapi, userID)
+	if err != nil {
+		return respondJSON(w, &model.PostActionIntegrationResponse{EphemeralText: err.Error()})
+	}
+
+	status, err := action(pluginapi, bmarks, request)
+	if err != nil {
+		return respondJSON(w, &model.PostActionIntegrationResponse{EphemeralText: err.Error()})
+	}
+
+	bmark, err := bmarks.GetBookmark(postID)
+	if err != nil {
+		return respondJSON(w, &model.PostActionIntegrationResponse{EphemeralText: err.Error()})
+	}
+	text, err := getReminderText(bmarks, bmark)
+	if err != nil {
+		return respondJSON(w, &model.PostActionIntegrationResponse{EphemeralText: err.Error()})
+	}
+
+	update := &model.Post{}
+	model.ParseSlackAttachment(update, []*model.SlackAttachment{getReminderAttachment(postID, text, status)})
+	return respondJSON(w, &model.PostActionIntegrationResponse{Update: update})
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/bookmarks"
+	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin"
+)
+
+func TestSendReminder(t *testing.T) {
+	api := makeAPIMock()
+	p := makePlugin(api)
+	p.BotUserID = "botID"
+	mockBookmarksKV(t, api, getHTTPTestBookmarks())
+	bmarks, err := bookmarks.NewBookmarksWithUser(pluginapi.New(api), UserID)
+	assert.Nil(t, err)
+
+	api.On("KVGet", bookmarks.GetLabelsKey(UserID)).Return(nil, nil)
+	api.On("GetPost", p2ID).Return(&model.Post{Id: p2ID, Message: "review the PR", ChannelId: "channelID"}, nil)
+	api.On("GetChannelMember", "channelID", UserID).Return(&model.ChannelMember{}, nil)
+	api.On("GetConfig").Return(&model.Config{ServiceSettings: model.ServiceSettings{SiteURL: model.NewString("https://myhost.com")}})
+	api.On("GetDirectChannel", UserID, "botID").Return(&model.Channel{Id: "dmChannelID"}, nil)
+
+	var created *model.Post
+	api.On("CreatePost", mock.Anything).Run(func(args mock.Arguments) {
+		created = args.Get(0).(*model.Post)
+	}).Return(&model.Post{}, nil)
+
+	bmark := bmarks.ByID[p2ID]
+	bmark.LabelIDs = nil
+	assert.Nil(t, p.sendReminder(UserID, bmarks, bmark))
+
+	assert.Equal(t, "botID", created.UserId)
+	assert.Equal(t, "dmChannelID", created.ChannelId)
+	attachments := created.Attachments()
+	assert.Len(t, attachments, 1)
+	assert.Contains(t, attachments[0].Text, "[:link:](https://myhost.com/_redirect/pl/ID2)")
+	assert.Contains(t, attachments[0].Text, b2Title)
+
+	var names []string
+	for _, action := range attachments[0].Actions {
+		names = append(names, action.Name)
+		assert.Equal(t, p2ID, action.Integration.Context["post_id"])
+	}
+	assert.Equal(t, []string{"Snooze 1 hour", "Snooze until tomorrow", "Done"}, names)
+	assert.Equal(t, "/plugins/com.mattermost.bookmarks/api/v1/reminders/snooze", attachments[0].Actions[0].Integration.URL)
+	assert.Equal(t, "in 1h", attachments[0].Actions[0].Integration.Context["snooze"])
+}
+
+func TestHandleReminderActions(t *testing.T) {
+	tests := map[string]struct {
+		userID            string
+		route             string
+		context           map[string]interface{}
+		expectedCode      int
+		expectedStatus    string
+		expectedEphemeral string
+		expectedReminder  bool
+	}{
+		"Unauthed User": {
+			route:        routeReminderDone,
+			context:      map[string]interface{}{"post_id": p2ID},
+			expectedCode: http.StatusUnauthorized,
+		},
+		"User snoozes a reminder": {
+			userID:           UserID,
+			route:            routeReminderSnooze,
+			context:          map[string]interface{}{"post_id": p2ID, "snooze": "in 1h"},
+			expectedCode:     http.StatusOK,
+			expectedStatus:   "Snoozed until ",
+			expectedReminder: true,
+		},
+		"User marks a reminder done": {
+			userID:         UserID,
+			route:          routeReminderDone,
+			context:        map[string]interface{}{"post_id": p2ID},
+			expectedCode:   http.StatusOK,
+			expectedStatus: "Done",
+		},
+		"Bookmark was removed": {
+			userID:            UserID,
+			route:             routeReminderDone,
+			context:           map[string]interface{}{"post_id": "IDRemoved"},
+			expectedCode:      http.StatusOK,
+			expectedEphemeral: "Bookmark `IDRemoved` does not exist",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			api := makeAPIMock()
+			p := makePlugin(api)
+			mockBookmarksKV(t, api, getHTTPTestBookmarks())
+
+			api.On("KVGet", bookmarks.GetLabelsKey(UserID)).Return(nil, nil)
+			api.On("GetUser", UserID).Return(&model.User{Id: UserID}, nil)
+			api.On("GetPost", p2ID).Return(&model.Post{Id: p2ID, Message: "review the PR", ChannelId: "channelID"}, nil)
+			api.On("GetChannelMember", "channelID", UserID).Return(&model.ChannelMember{}, nil)
+			api.On("GetConfig").Return(&model.Config{ServiceSettings: model.ServiceSettings{SiteURL: model.NewString("https://myhost.com")}})
+			api.On("KVCompareAndSet", mock.Anything, mock.Anything, mock.Anything).Return(true, nil)
+			isRemindersKey := mock.MatchedBy(func(key string) bool {
+				return strings.HasPrefix(key, bookmarks.StoreRemindersKey+"_")
+			})
+			api.On("KVGet", isRemindersKey).Return(nil, nil)
+
+			var storedReminders []byte
+			api.On("KVSetWithOptions", isRemindersKey, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				storedReminders = args.Get(1).([]byte)
+			}).Return(true, nil)
+
+			body, err := json.Marshal(&model.PostActionIntegrationRequest{
+				UserId:  tt.userID,
+				PostId:  "reminderPostID",
+				Context: tt.context,
+			})
+			assert.Nil(t, err)
+			r := httptest.NewRequest(http.MethodPost, routeAPIPrefix+tt.route, bytes.NewReader(body))
+			r.Header.Add("Mattermost-User-Id", tt.userID)
+
+			p.initialiseAPI()
+			w := httptest.NewRecorder()
+			p.ServeHTTP(&plugin.Context{}, w, r)
+
+			result := w.Result()
+			assert.Equal(t, tt.expectedCode, result.StatusCode)
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+
+			var response model.PostActionIntegrationResponse
+			assert.Nil(t, json.NewDecoder(result.Body).Decode(&response))
+			assert.Equal(t, tt.expectedEphemeral, response.EphemeralText)
+			if tt.expectedEphemeral != "" {
+				assert.Nil(t, response.Update)
+				return
+			}
+
+			// the buttons are replaced with the status
+			attachments := response.Update.Attachments()
+			assert.Len(t, attachments, 1)
+			assert.Empty(t, attachments[0].Actions)
+			assert.Contains(t, attachments[0].Footer, tt.expectedStatus)
+			assert.Contains(t, attachments[0].Text, b2Title)
+
+			if tt.expectedReminder {
+				assert.Contains(t, string(storedReminders), `"post_id":"ID2"`)
+			} else {
+				assert.Nil(t, storedReminders)
+			}
+		})
+	}
+}
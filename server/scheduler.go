@@ -0,0 +1,25 @@
+package main
+
+import (
+	"time"
+)
+
+// schedulerInterval is how often the due reminders and digests are sent
+const schedulerInterval = time.Minute
+
+// runScheduler sends the due reminders and digests every schedulerInterval
+// until stop is closed
+func (p *Plugin) runScheduler(stop <-chan struct{}) {
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.sendDueReminders()
+			p.sendDueDigests()
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/base32"
 	"regexp"
+	"time"
 
 	"github.com/jfrerich/mattermost-plugin-bookmarks/server/pluginapi"
 	"github.com/mattermost/mattermost-server/v5/plugin"
@@ -25,10 +26,32 @@ type API struct {
 
 const TitleFromPostLabel = "**`TFP`**"
 
+// PostDeletedLabel marks a bookmark whose post was deleted
+const PostDeletedLabel = "**`DELETED`**"
+
+// PostHiddenLabel marks a bookmark whose post is in a channel the user can no
+// longer read
+const PostHiddenLabel = "**`HIDDEN`**"
+
+// PostEditedLabel marks a bookmark whose post was edited after it was
+// bookmarked
+const PostEditedLabel = "**`EDITED`**"
+
+// ArchivedLabel marks a bookmark the user archived
+const ArchivedLabel = "**`ARCHIVED`**"
+
+// UnreadLabel marks a bookmark the user has not read yet
+const UnreadLabel = "**`UNREAD`**"
+
 func GetLegendText() string {
 	text := "#### Legend\n"
 	text += ":link: - Jump to the bookmarked post \n\n"
 	text += TitleFromPostLabel + " (**T**ext**F**rom**P**ost) - Autogenerated label representing bookmarks without a user provided title.  Display text is generated from the bookmarked post message\n"
+	text += PostDeletedLabel + " - The bookmarked post was deleted\n"
+	text += PostEditedLabel + " - The bookmarked post was edited after it was bookmarked\n"
+	text += PostHiddenLabel + " - You can no longer read the channel of the bookmarked post\n"
+	text += UnreadLabel + " - You have not read the bookmark yet\n"
+	text += ArchivedLabel + " - You archived the bookmark\n"
 	text += "`label` - **_Italicized & Bolded text signifies the bookmark has a saved title_**\n\n"
 	text += "***\n"
 	return text
@@ -52,3 +75,8 @@ func NewID() string {
 	b.Truncate(26) // removes the '==' padding
 	return b.String()
 }
+
+// GetMillis returns the time in milliseconds since the epoch
+func GetMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
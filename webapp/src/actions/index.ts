@@ -93,6 +93,36 @@ export function addLabelByName(labelName: string) {
     };
 }
 
+export function syncFlaggedPosts() {
+    return async () => {
+        let data;
+        try {
+            data = await (new Client()).syncFlaggedPosts();
+        } catch (error) {
+            return {error};
+        }
+
+        return {data};
+    };
+}
+
+// handleFlaggedPostsChanged syncs the bookmarks when the user flags or
+// unflags a post. The server only syncs users who turned the sync on
+export function handleFlaggedPostsChanged() {
+    return (msg: {data: {preferences?: string}}) => {
+        let preferences: Array<{category: string}> = [];
+        try {
+            preferences = JSON.parse(msg.data.preferences || '[]');
+        } catch (error) {
+            return;
+        }
+
+        if (preferences.some((preference) => preference.category === 'flagged_post')) {
+            syncFlaggedPosts()();
+        }
+    };
+}
+
 export const openAddBookmarkModal = (postID: string) => {
     return {
         type: ActionTypes.OPEN_ADD_BOOKMARK_MODAL,
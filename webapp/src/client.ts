@@ -30,6 +30,10 @@ export default class Client {
         return this.doGet(`${this.url}/labels/get`);
     }
 
+    syncFlaggedPosts = async () => {
+        return this.doPost(`${this.url}/flagged/sync`, {});
+    }
+
     doGet = async (url: string, headers = {}) => {
         headers['X-Timezone-Offset'] = new Date().getTimezoneOffset();
 
@@ -27,6 +27,7 @@ export type State = {
     bookmark: Bookmark;
     allLabels: Labels;
     title: string;
+    note: string;
     bmarkLabelIds: string;
     selectLabelValues: SelectValue[];
 };
@@ -37,6 +38,7 @@ export default class AddBookmarkForm extends PureComponent<Props, State> {
         bookmark: null,
         allLabels: null,
         title: '',
+        note: '',
         bmarkLabelIds: '',
         selectLabelValues: [],
     };
@@ -66,6 +68,7 @@ export default class AddBookmarkForm extends PureComponent<Props, State> {
         this.setState({
             bookmark: bmarkResult.data,
             title: bmarkResult.data.title,
+            note: bmarkResult.data.note,
             bmarkLabelIds,
             submitting: false,
         });
@@ -99,6 +102,7 @@ export default class AddBookmarkForm extends PureComponent<Props, State> {
         const bookmark = {
             postid: this.props.post.id,
             title: this.state.title,
+            note: this.state.note,
             label_ids: labelIds,
             create_at: timestamp,
             update_at: timestamp,
@@ -119,6 +123,12 @@ export default class AddBookmarkForm extends PureComponent<Props, State> {
         });
     }
 
+    handleNoteChange = (e) => {
+        this.setState({
+            note: e.target.value,
+        });
+    }
+
     handleLabelChange = (e) => {
         this.setState({
             selectLabelValues: e,
@@ -183,6 +193,19 @@ export default class AddBookmarkForm extends PureComponent<Props, State> {
             </div>
         );
 
+        const noteComponent = (
+            <div className='form-group'>
+                <label className='control-label'>{'Note'}</label>
+                <textarea
+                    className='form-control'
+                    rows={4}
+                    placeholder={'Why did you save this post? Markdown is supported'}
+                    onChange={this.handleNoteChange}
+                    value={this.state.note ? this.state.note : ''}
+                />
+            </div>
+        );
+
         return (
             <form
                 role='form'
@@ -191,6 +214,7 @@ export default class AddBookmarkForm extends PureComponent<Props, State> {
                 <Modal.Body ref='modalBody' >
                     {titleComponent}
                     {labelCreateComponent}
+                    {noteComponent}
                     {postMessageComponent}
                 </Modal.Body>
                 <Modal.Footer >
@@ -8,7 +8,7 @@ import AddBookmarkPostMenuAction from 'components/post_menu_actions/add_bookmark
 
 import pluginId from 'plugin_id';
 
-import {postEphemeralBookmarks} from './actions';
+import {handleFlaggedPostsChanged, postEphemeralBookmarks} from './actions';
 
 import reducer from './reducer';
 
@@ -24,6 +24,11 @@ export default class Plugin {
             (channel) => postEphemeralBookmarks(channel.id)(store.dispatch, store.getState),
             'Bookmarks',
             'View Bookmarks');
+
+        // posts flagged while the webapp is open are synced right away. The
+        // server syncs the others when a bookmarks command runs
+        registry.registerWebSocketEventHandler('preferences_changed', handleFlaggedPostsChanged());
+        registry.registerWebSocketEventHandler('preferences_deleted', handleFlaggedPostsChanged());
     }
 }
 window.registerPlugin(pluginId, new Plugin());
@@ -21,7 +21,15 @@ const manifest = JSON.parse(`
     "settings_schema": {
         "header": "",
         "footer": "",
-        "settings": []
+        "settings": [
+            {
+                "key": "SchemaUpgradeDryRun",
+                "display_name": "Dry Run Data Upgrades:",
+                "type": "bool",
+                "help_text": "When true, the upgrade of stored bookmarks and labels that runs when the plugin is enabled only logs the data it would change. Data is still upgraded when a user changes their bookmarks or labels.",
+                "default": false
+            }
+        ]
     }
 }
 `);
@@ -4,12 +4,13 @@ export type Bookmark = {
     create_at: number;
     update_at: number;
     label_ids: string[];
+    note: string;
 };
 
 export type Label = {
     name: string;
     color: string;
-    description: number;
+    description: string;
 };
 
 export type Labels = {